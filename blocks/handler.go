@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+
+	"github.com/osteele/liquid"
+
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/revision"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+// block is a reusable Liquid snippet stored in the partition of its website.
+// Templates and other blocks include it by name, therefore the name is its identifier and can not be changed.
+type block struct {
+	crud.Meta
+	WebsiteID string `json:"website_id" dynamodbav:"pk"`
+	Name      string `json:"name" dynamodbav:"name" validate:"required,max=100,pattern=^[a-z0-9][a-z0-9_-]*$"`
+	Body      string `json:"body" dynamodbav:"body" validate:"required,max=65536"`
+}
+
+func (b *block) Identity() (string, string) {
+	return b.WebsiteID, b.Name
+}
+
+func (b *block) SetIdentity(websiteID, name string) {
+	b.WebsiteID = websiteID
+	b.Name = name
+}
+
+// blockKey returns the table key of a block.
+func blockKey(websiteID, name string) dynamo.Key {
+	return dynamo.K2(websiteID, blockType+name)
+}
+
+// isPatchable tells whether clients may change a block field via PATCH, the name is part of the key.
+func isPatchable(field string) bool {
+	return field == bodyField
+}
+
+type cursors interface {
+	Encode(dynamo.Query, dynamo.Key) (string, error)
+	Decode(dynamo.Query, string) (dynamo.Key, error)
+}
+
+type revisions interface {
+	Record(context.Context, string, string, int64, string, interface{}) error
+	List(context.Context, string, string, int32, dynamo.Key) ([]revision.Revision, dynamo.Page, error)
+	Get(context.Context, string, string, string) (revision.Revision, error)
+	Purge(context.Context, string, string) error
+}
+
+// Handler is a collection of handlers.
+// Every saved version of a block is recorded as a revision, which the block can be rolled back to.
+// Templates including a deleted block fail to render until a block with the same name is created again.
+type Handler = crud.Handler[block, *block]
+
+func NewHandler(repo crud.Repository[block], websites crud.Websites, cursors cursors, revisions revisions, engine *liquid.Engine) *Handler {
+	resource := crud.Resource[block]{
+		Name:          "block",
+		Type:          blockType,
+		IDParam:       nameParam,
+		Key:           blockKey,
+		GenerateID:    false,
+		Validate:      validateBody(engine),
+		Patchable:     isPatchable,
+		ValidatePatch: validatePatchedBody(engine),
+		Prepare:       nil,
+		Guard:         nil,
+		Deleting:      nil,
+		Present:       nil,
+		Deleted:       nil,
+	}
+
+	return crud.NewHandler(resource, repo, websites, cursors, revisions)
+}
+
+// validateBody returns a hook parsing the body of a block to reject syntax errors.
+func validateBody(engine *liquid.Engine) func(block) error {
+	return func(entity block) error {
+		_, err := tmpl.Parse(engine, bodyField, entity.Body)
+
+		return err
+	}
+}
+
+// validatePatchedBody returns a hook parsing the body a patch assigns to a block to reject syntax errors.
+func validatePatchedBody(engine *liquid.Engine) func(context.Context, string, []patch.Operation) error {
+	return func(_ context.Context, _ string, ops []patch.Operation) error {
+		for _, op := range ops {
+			if body, ok := op.Value.(string); ok && op.Path.Field() == bodyField && op.Op != patch.OpTest {
+				_, err := tmpl.Parse(engine, bodyField, body)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/blocks/mocks"
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/cursor"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	pkgmocks "github.com/abtercms/abtercms2/pkg/mocks"
+	"github.com/abtercms/abtercms2/pkg/revision"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+func TestHandler_RetrieveCollection(t *testing.T) {
+	t.Run("fail limit out of range causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/blocks",
+			HTTPMethod:            http.MethodGet,
+			PathParameters:        map[string]string{"website": "abc"},
+			QueryStringParameters: map[string]string{"limit": "101"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedQuery := dynamo.Query{
+			Index:             "",
+			Partition:         "abc",
+			SortKey:           dynamo.SortKeyBeginsWith("BLOCK#"),
+			Filters:           nil,
+			Limit:             crud.DefaultLimit,
+			ExclusiveStartKey: nil,
+			Descending:        false,
+		}
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, expectedQuery).
+			Once().
+			Return([]block{}, dynamo.Page{}, nil)
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+}
+
+func TestHandler_CreateEntity(t *testing.T) {
+	t.Run("fail syntax error causes 422 unprocessable entity pointing to the line", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"footer","body":"<footer>\n{% if website.name %}\n{{ website.name }}\n</footer>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"body"`)
+		assert.Contains(t, res.Body, "line 2")
+	})
+
+	t.Run("fail invalid name causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"Site Footer","body":"<footer></footer>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"name"`)
+	})
+
+	t.Run("fail missing website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"footer","body":"<footer>{{ website.name }}</footer>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, _, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{}, dynamo.ErrNotFound)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"footer","body":"<footer>{{ website.name }}</footer>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusCreated
+
+		// system under test
+		sut, repoMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		blockMatcher := mock.MatchedBy(func(input block) bool {
+			return input.WebsiteID == "abc" && input.SK == "BLOCK#footer" && input.CreatedBy == audit.Anonymous
+		})
+		repoMock.On("Create", ctx, blockMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RetrieveEntity(t *testing.T) {
+	t.Run("fail missing entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, blockKey("abc", "footer")).
+			Once().
+			Return(block{}, dynamo.ErrNotFound)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, blockKey("abc", "footer")).
+			Once().
+			Return(storedBlock("footer"), nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, `"3"`, res.Headers["ETag"])
+	})
+}
+
+func TestHandler_UpdateEntity(t *testing.T) {
+	t.Run("fail renaming causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"name":"header","body":"<header></header>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail syntax error causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"name":"footer","body":"{% for %}{% endfor %}"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"name":"footer","body":"<footer>{% block \"copyright\" %}</footer>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, blockKey("abc", "footer")).
+			Once().
+			Return(storedBlock("footer"), nil)
+		blockMatcher := mock.MatchedBy(func(input block) bool {
+			return input.Body == `<footer>{% block "copyright" %}</footer>` && input.CreatedBy == "alice"
+		})
+		repoMock.On("Update", ctx, blockMatcher, int64(3)).
+			Once().
+			Return(int64(4), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_PatchEntity(t *testing.T) {
+	t.Run("fail patching name causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+			Headers:        map[string]string{"If-Match": `"3"`, "Content-Type": "application/merge-patch+json"},
+			Body:           `{"name":"header"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail syntax error causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+			Headers:        map[string]string{"If-Match": `"3"`, "Content-Type": "application/merge-patch+json"},
+			Body:           `{"body":"{{ website.name | }}"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+			Headers:        map[string]string{"If-Match": `"3"`, "Content-Type": "application/merge-patch+json"},
+			Body:           `{"body":"<footer>{{ website.name | upcase }}</footer>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Patch", ctx, blockKey("abc", "footer"), int64(3), mock.Anything).
+			Once().
+			Return(storedBlock("footer"), int64(4), nil)
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_DeleteEntity(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "name": "footer"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNoContent
+
+		// system under test
+		sut, repoMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, blockKey("abc", "footer")).
+			Once().
+			Return(storedBlock("footer"), nil)
+		repoMock.On("Delete", ctx, blockKey("abc", "footer")).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RestoreRevision(t *testing.T) {
+	t.Run("fail missing entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer/revisions/ghi/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "name": "footer", "rev": "ghi"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, blockKey("abc", "footer")).
+			Once().
+			Return(block{}, dynamo.ErrNotFound)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		revisionsMock.AssertExpectations(t)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/blocks/footer/revisions/ghi/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "name": "footer", "rev": "ghi"},
+		}
+		revisionStub := revision.Revision{ID: "ghi", Version: 1, Data: json.RawMessage(`{"website_id":"abc","name":"footer","body":"<footer>old</footer>","version":1}`)}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		blockMatcher := mock.MatchedBy(func(input block) bool {
+			return input.Body == "<footer>old</footer>" && input.SK == "BLOCK#footer" && input.CreatedBy == "alice"
+		})
+		repoMock.On("Get", ctx, blockKey("abc", "footer")).
+			Once().
+			Return(storedBlock("footer"), nil)
+		revisionsMock.On("Get", ctx, "abc", "BLOCK#footer", "ghi").
+			Once().
+			Return(revisionStub, nil)
+		repoMock.On("Update", ctx, blockMatcher, int64(3)).
+			Once().
+			Return(int64(4), nil)
+		revisionsMock.On("Record", ctx, "abc", "BLOCK#footer", int64(4), "anonymous", blockMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		revisionsMock.AssertExpectations(t)
+	})
+}
+
+func createTestHandler() (*Handler, *pkgmocks.Repository[block], *pkgmocks.Websites) {
+	repoMock := &pkgmocks.Repository[block]{}
+	websitesMock := &pkgmocks.Websites{}
+	revisionsMock := &mocks.Revisions{}
+	revisionsMock.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Maybe().
+		Return(nil)
+	revisionsMock.On("Purge", mock.Anything, mock.Anything, mock.Anything).
+		Maybe().
+		Return(nil)
+
+	sut := NewHandler(repoMock, websitesMock, cursor.NewCodec([]byte("secret")), revisionsMock, tmpl.NewEngine())
+
+	return sut, repoMock, websitesMock
+}
+
+func createTestRevisionsHandler() (*Handler, *pkgmocks.Repository[block], *mocks.Revisions) {
+	repoMock := &pkgmocks.Repository[block]{}
+	websitesMock := &pkgmocks.Websites{}
+	websitesMock.On("Get", mock.Anything, mock.Anything).
+		Maybe().
+		Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+	revisionsMock := &mocks.Revisions{}
+
+	sut := NewHandler(repoMock, websitesMock, cursor.NewCodec([]byte("secret")), revisionsMock, tmpl.NewEngine())
+
+	return sut, repoMock, revisionsMock
+}
+
+// storedBlock returns a stored block of website abc created by alice.
+func storedBlock(name string) block {
+	createdAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	return block{
+		Meta: crud.Meta{
+			Fields:  audit.Fields{CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "alice", UpdatedBy: "alice"},
+			Version: 3,
+		},
+		WebsiteID: "abc",
+		Name:      name,
+		Body:      "<footer>{{ website.name }}</footer>",
+	}
+}
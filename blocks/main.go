@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+const (
+	// collection is the path segment of blocks in the path of their website.
+	collection = "blocks"
+
+	// nameParam is the path parameter holding the name of a block.
+	nameParam = "name"
+
+	// blockType prefixes the sort key of blocks, which are stored in the partition of their website and identified by their name.
+	blockType = "BLOCK#"
+
+	// bodyField is the field holding the Liquid source of a block.
+	bodyField = "body"
+)
+
+func main() {
+	env := crud.Bootstrap(context.TODO())
+
+	h := NewHandler(dynamo.NewGuardedRepo[block](env.Table), dynamo.NewTypedRepo[crud.Website](env.Table), env.Cursors, env.Revisions, tmpl.NewEngine())
+	lambda.Start(NewRouter(h).Handler)
+}
+
+type handler interface {
+	RetrieveCollection(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	CreateEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	UpdateEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	PatchEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	DeleteEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveRevisions(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveRevision(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RestoreRevision(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}
+
+func NewRouter(h handler) *lmdrouter.Router {
+	return crud.NewRouter(collection, nameParam, crud.Routes{
+		RetrieveCollection: h.RetrieveCollection,
+		CreateEntity:       h.CreateEntity,
+		RetrieveEntity:     h.RetrieveEntity,
+		UpdateEntity:       h.UpdateEntity,
+		PatchEntity:        h.PatchEntity,
+		DeleteEntity:       h.DeleteEntity,
+		RetrieveRevisions:  h.RetrieveRevisions,
+		RetrieveRevision:   h.RetrieveRevision,
+		RestoreRevision:    h.RestoreRevision,
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+// fileMode is the mode of the files in archives, exported websites are plain readable files.
+const fileMode = 0o644
+
+// archive collects the files of an exported website.
+type archive interface {
+	Add(name string, content []byte) error
+	Close() error
+}
+
+// newArchive creates an archive of the given format writing to w, all files are stamped with modTime.
+func newArchive(format string, w io.Writer, modTime time.Time) archive {
+	if format == formatTarGz {
+		gz := gzip.NewWriter(w)
+
+		return &tarArchive{gz: gz, tw: tar.NewWriter(gz), modTime: modTime}
+	}
+
+	return &zipArchive{zw: zip.NewWriter(w), modTime: modTime}
+}
+
+// zipArchive writes a zip file.
+type zipArchive struct {
+	zw      *zip.Writer
+	modTime time.Time
+}
+
+func (a *zipArchive) Add(name string, content []byte) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.modTime}
+	header.SetMode(fileMode)
+
+	f, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errWritingArchive, name)
+	}
+
+	_, err = f.Write(content)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errWritingArchive, name)
+	}
+
+	return nil
+}
+
+func (a *zipArchive) Close() error {
+	err := a.zw.Close()
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errClosingArchive)
+	}
+
+	return nil
+}
+
+// tarArchive writes a gzip compressed tar file.
+type tarArchive struct {
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	modTime time.Time
+}
+
+func (a *tarArchive) Add(name string, content []byte) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(content)),
+		Mode:     fileMode,
+		ModTime:  a.modTime,
+	}
+
+	err := a.tw.WriteHeader(header)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errWritingArchive, name)
+	}
+
+	_, err = a.tw.Write(content)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errWritingArchive, name)
+	}
+
+	return nil
+}
+
+func (a *tarArchive) Close() error {
+	err := a.tw.Close()
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errClosingArchive)
+	}
+
+	err = a.gz.Close()
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errClosingArchive)
+	}
+
+	return nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/osteele/liquid"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/site"
+	"github.com/abtercms/abtercms2/pkg/storage"
+)
+
+type exportParams struct {
+	WebsiteID string `lambda:"path.website"` // a path parameter declared as :website
+	Format    string `lambda:"query.format"` // a query parameter named "format"
+}
+
+// website holds the fields of a website needed to tell whether it is served.
+type website struct {
+	ID        string     `dynamodbav:"pk"`
+	Status    string     `dynamodbav:"status"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at,omitempty"`
+}
+
+// media holds the fields of a media needed to copy its content into an archive.
+type media struct {
+	ID       string `dynamodbav:"media_id"`
+	Filename string `dynamodbav:"filename"`
+	Status   string `dynamodbav:"status"`
+}
+
+// websiteKey returns the table key of a website.
+func websiteKey(websiteID string) dynamo.Key {
+	return dynamo.K2(websiteID, websiteType)
+}
+
+// publishedWebsiteKey returns the table key of the published snapshot of a website.
+func publishedWebsiteKey(websiteID string) dynamo.Key {
+	return dynamo.K2(websiteID, publishedWebsiteType)
+}
+
+// mediaKey returns the table key of a media.
+func mediaKey(websiteID, mediaID string) dynamo.Key {
+	return dynamo.K2(websiteID, mediaType+mediaID)
+}
+
+// blobKey returns the key the content of a media is stored with.
+func blobKey(websiteID, mediaID string) string {
+	return websiteID + "/" + mediaID
+}
+
+// publishedPagesQuery returns the query listing the published snapshots of the pages of a website.
+func publishedPagesQuery(websiteID string) dynamo.Query {
+	return dynamo.Query{
+		Index:             "",
+		Partition:         websiteID,
+		SortKey:           dynamo.SortKeyBeginsWith(publishedPageType),
+		Filters:           nil,
+		Limit:             batchSize,
+		ExclusiveStartKey: nil,
+		Descending:        false,
+	}
+}
+
+// mediaReferences returns the pattern matching references to the content of media of a website.
+// Pages refer to media by the path of their content in the API, optionally absolute and signed, e.g.
+// https://api.example.com/websites/abc/media/def/content?expires=1&signature=ghi, the id of the media is captured.
+func mediaReferences(websiteID string) *regexp.Regexp {
+	return regexp.MustCompile(`(?:https?://[^/"'\s<>]+)?/websites/` + regexp.QuoteMeta(websiteID) +
+		`/media/([0-9A-Za-z]+)/content(?:\?[^"'\s<>)]*)?`)
+}
+
+// pageFile returns the name of the file a page is exported to, e.g. "/" to "index.html" and "/blog" to "blog/index.html".
+func pageFile(pagePath string) string {
+	dir := strings.Trim(path.Clean("/"+pagePath), "/")
+	if dir == "" {
+		return indexFile
+	}
+
+	return dir + "/" + indexFile
+}
+
+// mediaFile returns the name of the file the content of a media is exported to.
+// Filenames are chosen by clients, therefore only their base name is kept so that they stay inside the media directory.
+func mediaFile(entity media) string {
+	name := path.Base("/" + entity.Filename)
+	if name == "/" || name == "." || name == ".." {
+		name = entity.ID
+	}
+
+	return mediaDir + "/" + entity.ID + "/" + name
+}
+
+type repo interface {
+	dynamo.Store
+}
+
+// Handler is a collection of handlers.
+type Handler struct {
+	repo      repo
+	websites  *dynamo.TypedRepo[website]
+	snapshots *dynamo.TypedRepo[site.Website]
+	media     *dynamo.TypedRepo[media]
+	blobs     storage.BlobStore
+	renderer  *site.Renderer
+}
+
+func NewHandler(repo repo, blobs storage.BlobStore, engine *liquid.Engine) *Handler {
+	return &Handler{
+		repo:      repo,
+		websites:  dynamo.NewTypedRepo[website](repo),
+		snapshots: dynamo.NewTypedRepo[site.Website](repo),
+		media:     dynamo.NewTypedRepo[media](repo),
+		blobs:     blobs,
+		renderer:  site.NewRenderer(repo, engine),
+	}
+}
+
+// ExportWebsite is a handler to export an active website as static files packed into a zip or a tar.gz archive.
+// Every published page is rendered like it is served and stored as index.html in the directory matching its path.
+// Ready media referenced by pages are copied into the media directory and the references are rewritten to the copies.
+// Archives are returned in the response, therefore they are limited to maxArchiveSize.
+func (h *Handler) ExportWebsite(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params exportParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	format, err := exportFormat(req, params)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	owner, err := h.owner(ctx, params.WebsiteID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	pages, err := h.renderPages(ctx, owner)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	var buf bytes.Buffer
+
+	err = h.write(ctx, newArchive(format, &buf, time.Now()), owner.ID, pages)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	if buf.Len() > maxArchiveSize {
+		return lhttp.HandleError(lhttp.NewProblem(http.StatusRequestEntityTooLarge, errArchiveTooLarge, buf.Len(), maxArchiveSize), nil)
+	}
+
+	contentType := contentTypeZip
+	if format == formatTarGz {
+		contentType = contentTypeTarGz
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			headerContentType:        contentType,
+			headerContentDisposition: fmt.Sprintf("attachment; filename=%q", owner.ID+"."+format),
+		},
+		Body:            base64.StdEncoding.EncodeToString(buf.Bytes()),
+		IsBase64Encoded: true,
+	}, nil
+}
+
+// exportFormat returns the archive format requested by the client, falling back to zip if none was requested.
+func exportFormat(req events.APIGatewayProxyRequest, params exportParams) (string, error) {
+	for name := range req.QueryStringParameters {
+		if name != formatParam {
+			return "", lhttp.NewProblem(http.StatusBadRequest, errUnsupportedParam, name)
+		}
+	}
+
+	switch params.Format {
+	case "", formatZip:
+		return formatZip, nil
+	case formatTarGz:
+		return formatTarGz, nil
+	}
+
+	return "", lhttp.NewProblem(http.StatusBadRequest, errUnsupportedFormat, params.Format, formatZip, formatTarGz)
+}
+
+// renderPages renders every published page of a website, keyed by the name of the file it is exported to.
+func (h *Handler) renderPages(ctx context.Context, owner site.Website) (map[string]string, error) {
+	var (
+		query = publishedPagesQuery(owner.ID)
+		pages = map[string]string{}
+	)
+
+	for {
+		var collection []site.Page
+
+		result, err := h.repo.Query(ctx, query, &collection)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entity := range collection {
+			html, err := h.renderer.Render(ctx, owner, entity)
+			if err != nil {
+				return nil, err
+			}
+
+			pages[pageFile(entity.Path)] = html
+		}
+
+		if !result.HasMore {
+			return pages, nil
+		}
+
+		query.ExclusiveStartKey = result.Next
+	}
+}
+
+// write adds the rendered pages and the media they refer to to an archive, and closes it.
+// References to media which are missing or not uploaded yet are left as they are.
+func (h *Handler) write(ctx context.Context, out archive, websiteID string, pages map[string]string) error {
+	references := mediaReferences(websiteID)
+
+	mediaIDs := map[string]bool{}
+	for _, html := range pages {
+		for _, match := range references.FindAllStringSubmatch(html, -1) {
+			mediaIDs[match[1]] = true
+		}
+	}
+
+	links := map[string]string{}
+
+	for _, mediaID := range sortedKeys(mediaIDs) {
+		entity, ready, err := h.readyMedia(ctx, websiteID, mediaID)
+		if err != nil {
+			return err
+		}
+
+		if !ready {
+			continue
+		}
+
+		content, err := h.blobs.Get(ctx, blobKey(websiteID, mediaID))
+		if err != nil {
+			return err
+		}
+
+		name := mediaFile(entity)
+
+		err = out.Add(name, content)
+		if err != nil {
+			return err
+		}
+
+		links[mediaID] = (&url.URL{Path: "/" + name}).EscapedPath()
+	}
+
+	for _, name := range sortedKeys(pages) {
+		html := references.ReplaceAllStringFunc(pages[name], func(reference string) string {
+			if link, ok := links[references.FindStringSubmatch(reference)[1]]; ok {
+				return link
+			}
+
+			return reference
+		})
+
+		err := out.Add(name, []byte(html))
+		if err != nil {
+			return err
+		}
+	}
+
+	return out.Close()
+}
+
+// sortedKeys returns the keys of a map in order, so that archives of the same website list their files alike.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// owner reads the published snapshot of an active website, other websites result in a 404 not found.
+func (h *Handler) owner(ctx context.Context, websiteID string) (site.Website, error) {
+	live, err := h.websites.Get(ctx, websiteKey(websiteID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return site.Website{}, lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotFound, websiteID)
+	}
+
+	if err != nil {
+		return site.Website{}, err
+	}
+
+	if live.DeletedAt != nil || live.Status != statusActive {
+		return site.Website{}, lhttp.NewProblem(http.StatusNotFound, errWebsiteNotFound, websiteID)
+	}
+
+	owner, err := h.snapshots.Get(ctx, publishedWebsiteKey(websiteID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return owner, lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotFound, websiteID)
+	}
+
+	return owner, err
+}
+
+// readyMedia reads a media of a website and reports whether it is ready, missing media are never ready.
+func (h *Handler) readyMedia(ctx context.Context, websiteID, mediaID string) (media, bool, error) {
+	entity, err := h.media.Get(ctx, mediaKey(websiteID, mediaID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return entity, false, nil
+	}
+
+	if err != nil {
+		return entity, false, err
+	}
+
+	return entity, entity.Status == statusReady, nil
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/export/mocks"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	pkgmocks "github.com/abtercms/abtercms2/pkg/mocks"
+	"github.com/abtercms/abtercms2/pkg/site"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+func TestHandler_ExportWebsite(t *testing.T) {
+	t.Run("fail unsupported format causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/export",
+			HTTPMethod:            http.MethodGet,
+			PathParameters:        map[string]string{"website": "abc"},
+			QueryStringParameters: map[string]string{"format": "rar"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// execute
+		res, err := sut.ExportWebsite(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail inactive website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/export",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("inactive")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.ExportWebsite(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail unpublished website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/export",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.ExportWebsite(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success zip with media", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/export",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+		pagesStub := []site.Page{
+			{ID: "def", ParentID: "", Path: "/", Title: "Home", Body: "![Logo](/websites/abc/media/ghi/content)", TemplateID: ""},
+			{ID: "jkl", ParentID: "def", Path: "/blog/hello", Title: "Hello", Body: "![Gone](/websites/abc/media/mno/content)", TemplateID: ""},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedFiles := map[string]string{
+			"media/ghi/logo.png":    "png",
+			"index.html":            "<p><img src=\"/media/ghi/logo.png\" alt=\"Logo\" /></p>\n",
+			"blog/hello/index.html": "<p><img src=\"/websites/abc/media/mno/content\" alt=\"Gone\" /></p>\n",
+		}
+
+		// system under test
+		sut, repoMock, blobsMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, publishedPagesQuery("abc"), publishedPagesModifier(pagesStub)).
+			Once().
+			Return(dynamo.Page{Next: nil, HasMore: false}, nil)
+		repoMock.On("Find", ctx, mediaKey("abc", "mno"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("Find", ctx, mediaKey("abc", "ghi"), storedMediaModifier("ghi", "logo.png")).
+			Once().
+			Return(true, nil)
+		blobsMock.On("Get", ctx, "abc/ghi").
+			Once().
+			Return([]byte("png"), nil)
+
+		// execute
+		res, err := sut.ExportWebsite(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, "application/zip", res.Headers["Content-Type"])
+		assert.Equal(t, `attachment; filename="abc.zip"`, res.Headers["Content-Disposition"])
+		assert.True(t, res.IsBase64Encoded)
+		assert.Equal(t, expectedFiles, readZip(t, res.Body))
+		repoMock.AssertExpectations(t)
+		blobsMock.AssertExpectations(t)
+	})
+
+	t.Run("success tar.gz", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/export",
+			HTTPMethod:            http.MethodGet,
+			PathParameters:        map[string]string{"website": "abc"},
+			QueryStringParameters: map[string]string{"format": "tar.gz"},
+		}
+		pagesStub := []site.Page{
+			{ID: "def", ParentID: "", Path: "/blog", Title: "Posts", Body: "# Hello", TemplateID: ""},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedFiles := map[string]string{
+			"blog/index.html": "<h1>Hello</h1>\n",
+		}
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, publishedPagesQuery("abc"), publishedPagesModifier(pagesStub)).
+			Once().
+			Return(dynamo.Page{Next: nil, HasMore: false}, nil)
+
+		// execute
+		res, err := sut.ExportWebsite(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, "application/gzip", res.Headers["Content-Type"])
+		assert.Equal(t, `attachment; filename="abc.tar.gz"`, res.Headers["Content-Disposition"])
+		assert.Equal(t, expectedFiles, readTarGz(t, res.Body))
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestPageFile(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"/":            "index.html",
+		"/blog":        "blog/index.html",
+		"/blog/hello/": "blog/hello/index.html",
+	}
+
+	for pagePath, expected := range tests {
+		assert.Equal(t, expected, pageFile(pagePath))
+	}
+}
+
+func TestMediaFile(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"logo.png":         "media/abc/logo.png",
+		"../../index.html": "media/abc/index.html",
+		"..":               "media/abc/abc",
+		"":                 "media/abc/abc",
+	}
+
+	for filename, expected := range tests {
+		assert.Equal(t, expected, mediaFile(media{ID: "abc", Filename: filename, Status: statusReady}))
+	}
+}
+
+func createTestHandler() (*Handler, *mocks.Repo, *pkgmocks.BlobStore) {
+	repoMock := &mocks.Repo{}
+	blobsMock := &pkgmocks.BlobStore{}
+
+	sut := NewHandler(repoMock, blobsMock, tmpl.NewEngine())
+
+	return sut, repoMock, blobsMock
+}
+
+// storedWebsiteModifier fills the website read from the repository with website abc.
+func storedWebsiteModifier(status string) interface{} {
+	return mock.MatchedBy(func(input *website) bool {
+		*input = website{ID: "abc", Status: status, DeletedAt: nil}
+
+		return true
+	})
+}
+
+// publishedWebsiteModifier fills the snapshot read from the repository with website abc published as Foo.
+func publishedWebsiteModifier() interface{} {
+	return mock.MatchedBy(func(input *site.Website) bool {
+		*input = site.Website{ID: "abc", Name: "Foo"}
+
+		return true
+	})
+}
+
+// publishedPagesModifier fills the collection read from the repository with the given snapshots.
+func publishedPagesModifier(pages []site.Page) interface{} {
+	return mock.MatchedBy(func(input *[]site.Page) bool {
+		*input = pages
+
+		return true
+	})
+}
+
+// storedMediaModifier fills the media read from the repository with a ready media.
+func storedMediaModifier(id, filename string) interface{} {
+	return mock.MatchedBy(func(input *media) bool {
+		*input = media{ID: id, Filename: filename, Status: statusReady}
+
+		return true
+	})
+}
+
+// readZip returns the files of a base64 encoded zip archive keyed by name.
+func readZip(t *testing.T, body string) map[string]string {
+	t.Helper()
+
+	content, err := base64.StdEncoding.DecodeString(body)
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+
+		files[f.Name] = string(data)
+	}
+
+	return files
+}
+
+// readTarGz returns the files of a base64 encoded tar.gz archive keyed by name.
+func readTarGz(t *testing.T, body string) map[string]string {
+	t.Helper()
+
+	content, err := base64.StdEncoding.DecodeString(body)
+	require.NoError(t, err)
+
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	require.NoError(t, err)
+
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+
+		files[header.Name] = string(data)
+	}
+
+	return files
+}
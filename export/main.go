@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/storage"
+	"github.com/abtercms/abtercms2/pkg/table"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+const (
+	// websiteType is the sort key of websites, pages and media are stored in the partition of their website.
+	websiteType = "WEBSITE"
+
+	// publishedWebsiteType is the sort key of the published snapshots of websites.
+	publishedWebsiteType = "PUBLISHED#WEBSITE"
+
+	// publishedPageType prefixes the sort key of the published snapshots of pages.
+	publishedPageType = "PUBLISHED#PAGE#"
+
+	// mediaType prefixes the sort key of media.
+	mediaType = "MEDIA#"
+
+	statusActive = "active"
+	statusReady  = "ready"
+
+	formatParam = "format"
+	formatZip   = "zip"
+	formatTarGz = "tar.gz"
+
+	// indexFile is the file a page is exported to, in the directory matching the path of the page.
+	indexFile = "index.html"
+
+	// mediaDir is the directory media referenced by pages are exported to.
+	mediaDir = "media"
+
+	// batchSize is the number of published pages read from the table at once.
+	batchSize int32 = 100
+
+	// maxArchiveSize is the size of the largest archive returned, Lambda limits responses to 6 MB and base64 adds a third.
+	maxArchiveSize = 4 << 20
+
+	EnvAwsRegion                = "AWS_REGION"
+	EnvTableName                = "TABLE_NAME"
+	EnvAwsSamLocal              = "AWS_SAM_LOCAL"
+	EnvAwsDynamoDBLocalEndpoint = "AWS_DYNAMODB_LOCAL_ENDPOINT"
+	EnvDatabaseURL              = "DATABASE_URL"
+	EnvRepository               = "REPOSITORY"
+	EnvMediaBucket              = "MEDIA_BUCKET"
+	EnvMediaLocalDir            = "MEDIA_LOCAL_DIR"
+
+	trueString = "true"
+
+	headerContentType        = "Content-Type"
+	headerContentDisposition = "Content-Disposition"
+	contentTypeZip           = "application/zip"
+	contentTypeTarGz         = "application/gzip"
+
+	errUnmarshallParams  = "failed to unmarshal the request, query: %v"
+	errUnsupportedParam  = "query parameter is not supported: %s"
+	errUnsupportedFormat = "format %s is not supported, it must be %s or %s"
+	errWebsiteNotFound   = "website not found in storage: %s"
+	errWritingArchive    = "failed to write %s to the archive"
+	errClosingArchive    = "failed to close the archive"
+	errArchiveTooLarge   = "archive of %d bytes exceeds the limit of %d bytes"
+)
+
+func main() {
+	var (
+		awsRegion        = os.Getenv(EnvAwsRegion)
+		tableName        = os.Getenv(EnvTableName)
+		mediaBucket      = os.Getenv(EnvMediaBucket)
+		mediaLocalDir    = ""
+		databaseURL      = os.Getenv(EnvDatabaseURL)
+		dynamoDBEndpoint = ""
+		repository       = ""
+	)
+
+	if os.Getenv(EnvAwsSamLocal) == trueString {
+		dynamoDBEndpoint = os.Getenv(EnvAwsDynamoDBLocalEndpoint)
+		repository = os.Getenv(EnvRepository)
+		mediaLocalDir = os.Getenv(EnvMediaLocalDir)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO(), func(o *config.LoadOptions) error {
+		o.Region = awsRegion
+
+		return nil
+	})
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvAwsRegion, awsRegion).
+			Str(EnvTableName, tableName).
+			Msg("cannot establish connection with dynamodb")
+	}
+
+	// SAM local has no S3, therefore files are kept on the local filesystem instead
+	var blobs storage.BlobStore = storage.NewS3Store(sdkConfig, mediaBucket, "")
+	if mediaLocalDir != "" {
+		blobs = storage.NewFSStore(mediaLocalDir)
+	}
+
+	repo, err := table.Open(context.TODO(), sdkConfig, tableName, dynamoDBEndpoint, databaseURL, repository)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvTableName, tableName).
+			Msg("cannot open the table")
+	}
+
+	lambda.Start(NewRouter(NewHandler(repo, blobs, tmpl.NewEngine())).Handler)
+}
+
+type handler interface {
+	ExportWebsite(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}
+
+func NewRouter(h handler) *lmdrouter.Router {
+	router := lmdrouter.NewRouter("/websites", lhttp.LoggerMiddleware)
+	router.Route(http.MethodGet, "/:website/export", h.ExportWebsite)
+
+	return router
+}
@@ -0,0 +1,59 @@
+//go:generate mockery-latest --all --exported --case underscore
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abtercms/abtercms2/export/mocks"
+)
+
+func TestRouter(t *testing.T) {
+	// hack needed because zerolog gets a global log builder
+	{
+		l := log.Logger
+
+		log.Logger = zerolog.Nop()
+		defer func() {
+			log.Logger = l
+		}()
+	}
+
+	t.Run("export website", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/export",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("ExportWebsite", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/storage"
+)
+
+type entityParams struct {
+	WebsiteID string `lambda:"path.website"` // a path parameter declared as :website
+	ID        string `lambda:"path.id"`      // a path parameter declared as :id
+}
+
+// media is a file of a website, its metadata is stored in the partition of the website and its content in a BlobStore.
+// Media are created pending, the content is uploaded afterwards via a signed URL, which makes them ready.
+type media struct {
+	crud.Meta
+	WebsiteID   string `json:"website_id" dynamodbav:"pk"`
+	ID          string `json:"id" dynamodbav:"media_id"`
+	Filename    string `json:"filename" dynamodbav:"filename" validate:"required,max=255"`
+	ContentType string `json:"content_type" dynamodbav:"content_type" validate:"required,max=100,pattern=^[a-z]+/[a-zA-Z0-9.+-]+$"`
+	Size        int64  `json:"size" dynamodbav:"size"`
+	Status      string `json:"status" dynamodbav:"status"`
+
+	// signed URLs are issued on every response, therefore they are never stored
+	UploadURL    string     `json:"upload_url,omitempty" dynamodbav:"-"`
+	DownloadURL  string     `json:"download_url,omitempty" dynamodbav:"-"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty" dynamodbav:"-"`
+}
+
+func (m *media) Identity() (string, string) {
+	return m.WebsiteID, m.ID
+}
+
+func (m *media) SetIdentity(websiteID, id string) {
+	m.WebsiteID = websiteID
+	m.ID = id
+}
+
+// mediaKey returns the table key of a media.
+func mediaKey(websiteID, mediaID string) dynamo.Key {
+	return dynamo.K2(websiteID, mediaType+mediaID)
+}
+
+// blobKey returns the key the content of a media is stored with.
+func blobKey(websiteID, mediaID string) string {
+	return websiteID + "/" + mediaID
+}
+
+// contentPath returns the path of the content of a media, which is the path signed URLs are issued for.
+func contentPath(websiteID, mediaID string) string {
+	return "/websites/" + websiteID + "/media/" + mediaID + "/content"
+}
+
+// baseURL returns the URL of the API a request was sent to, which signed URLs are prefixed with.
+// The configured base URL takes precedence, otherwise API Gateway passes the domain as the host, which the stage follows.
+// Requests without a host, e.g. invoked directly, result in no base URL, therefore in signed URLs relative to the API.
+func (h *Handler) baseURL(req events.APIGatewayProxyRequest) string {
+	if h.base != "" {
+		return h.base
+	}
+
+	host, ok := lhttp.Header(req.Headers, headerHost)
+	if !ok || host == "" {
+		return ""
+	}
+
+	scheme, ok := lhttp.Header(req.Headers, headerForwardedProto)
+	if !ok || scheme == "" {
+		scheme = defaultScheme
+	}
+
+	return scheme + "://" + host + "/" + req.RequestContext.Stage
+}
+
+// setKeys sets the storage keys of a media, media are listed via the table itself.
+func (m *media) setKeys() {
+	m.Keys = dynamo.Keys{
+		SK:     mediaType + m.ID,
+		GSI1PK: "",
+		GSI1SK: "",
+	}
+}
+
+type cursors interface {
+	Encode(dynamo.Query, dynamo.Key) (string, error)
+	Decode(dynamo.Query, string) (dynamo.Key, error)
+}
+
+type signer interface {
+	Sign(string, string, time.Time) (string, time.Time)
+	Verify(string, string, map[string]string, time.Time) error
+}
+
+// Handler is a collection of handlers.
+// Contents are never served by the API directly, they are uploaded and downloaded via URLs signed by the handler.
+// Media can not be changed by clients, therefore only their collection, creation, retrieval and deletion are routed.
+type Handler struct {
+	*crud.Handler[media, *media]
+	repo   crud.Repository[media]
+	blobs  storage.BlobStore
+	signer signer
+	base   string
+}
+
+// NewHandler creates a new Handler instance, base is the URL signed URLs are prefixed with, empty derives it from requests.
+func NewHandler(repo crud.Repository[media], websites crud.Websites, cursors cursors, blobs storage.BlobStore, signer signer, base string) *Handler {
+	h := &Handler{
+		Handler: nil,
+		repo:    repo,
+		blobs:   blobs,
+		signer:  signer,
+		base:    strings.TrimSuffix(base, "/"),
+	}
+
+	resource := crud.Resource[media]{
+		Name:          "media",
+		Type:          mediaType,
+		IDParam:       idParam,
+		Key:           mediaKey,
+		GenerateID:    true,
+		Validate:      nil,
+		Patchable:     nil,
+		ValidatePatch: nil,
+		Prepare:       prepare,
+		Guard:         nil,
+		Deleting:      nil,
+		Present: func(req events.APIGatewayProxyRequest, entity media) media {
+			return h.sign(req, entity, time.Now())
+		},
+		Deleted: h.deleteContent,
+	}
+
+	h.Handler = crud.NewHandler(resource, repo, websites, cursors, nil)
+
+	return h
+}
+
+// prepare makes a media about to be created pending, its content is uploaded afterwards.
+func prepare(entity *media) {
+	entity.Size = 0
+	entity.Status = statusPending
+}
+
+// sign sets the URL clients can use next, pending media are to be uploaded and ready ones to be downloaded.
+// URLs are absolute ones pointing to the API the request was sent to, while only their path is signed.
+func (h *Handler) sign(req events.APIGatewayProxyRequest, entity media, now time.Time) media {
+	var (
+		path      = contentPath(entity.WebsiteID, entity.ID)
+		signed    string
+		expiresAt time.Time
+	)
+
+	if entity.Status == statusReady {
+		signed, expiresAt = h.signer.Sign(http.MethodGet, path, now)
+		entity.DownloadURL = h.baseURL(req) + signed
+	} else {
+		signed, expiresAt = h.signer.Sign(http.MethodPut, path, now)
+		entity.UploadURL = h.baseURL(req) + signed
+	}
+
+	entity.URLExpiresAt = &expiresAt
+
+	return entity
+}
+
+// get reads a media, missing media result in a 404 not found.
+func (h *Handler) get(ctx context.Context, websiteID, mediaID string) (media, error) {
+	entity, err := h.repo.Get(ctx, mediaKey(websiteID, mediaID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return entity, lhttp.WrapProblem(err, http.StatusNotFound, errMediaNotFound, mediaID)
+	}
+
+	return entity, err
+}
+
+// deleteContent deletes the content of a deleted media.
+// The metadata is deleted first, therefore a failure leaves an orphaned content behind rather than a broken media.
+func (h *Handler) deleteContent(ctx context.Context, websiteID, mediaID string) error {
+	return h.blobs.Delete(ctx, blobKey(websiteID, mediaID))
+}
+
+// UploadContent is a handler to store the content of a media, requests must be signed by an upload URL.
+// Uploading again replaces the content, the content type of the media is kept regardless of the one of the request.
+func (h *Handler) UploadContent(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params entityParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	now := time.Now()
+
+	err = h.signer.Verify(http.MethodPut, contentPath(params.WebsiteID, params.ID), req.QueryStringParameters, now)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	content, err := decodeContent(req)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	stored, err := h.get(ctx, params.WebsiteID, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.blobs.Put(ctx, blobKey(stored.WebsiteID, stored.ID), stored.ContentType, content)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	entity := stored
+	entity.Size = int64(len(content))
+	entity.Status = statusReady
+	entity.Fields = stored.Fields.Update(audit.Actor(req), now)
+	entity.setKeys()
+
+	entity.Version, err = h.repo.Update(ctx, entity, stored.Version)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(entity.Version), h.sign(req, entity, now))
+}
+
+// decodeContent returns the body of an upload, API Gateway passes binary bodies base64 encoded.
+func decodeContent(req events.APIGatewayProxyRequest) ([]byte, error) {
+	content := []byte(req.Body)
+
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return nil, lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallContent)
+		}
+
+		content = decoded
+	}
+
+	if len(content) > maxContentSize {
+		return nil, lhttp.NewProblem(http.StatusRequestEntityTooLarge, errContentTooLarge, len(content), maxContentSize)
+	}
+
+	return content, nil
+}
+
+// DownloadContent is a handler to retrieve the content of a media, requests must be signed by a download URL.
+func (h *Handler) DownloadContent(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params entityParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	err = h.signer.Verify(http.MethodGet, contentPath(params.WebsiteID, params.ID), req.QueryStringParameters, time.Now())
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	entity, err := h.get(ctx, params.WebsiteID, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	if entity.Status != statusReady {
+		return lhttp.HandleError(lhttp.NewProblem(http.StatusNotFound, errMediaNotUploaded, params.ID), nil)
+	}
+
+	content, err := h.blobs.Get(ctx, blobKey(entity.WebsiteID, entity.ID))
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			headerContentType:        entity.ContentType,
+			headerContentDisposition: mime.FormatMediaType("inline", map[string]string{"filename": entity.Filename}),
+		},
+		Body:            base64.StdEncoding.EncodeToString(content),
+		IsBase64Encoded: true,
+	}, nil
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/cursor"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/mocks"
+	"github.com/abtercms/abtercms2/pkg/presign"
+)
+
+func TestHandler_CreateEntity(t *testing.T) {
+	t.Run("fail invalid content type causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/media",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"filename":"logo.png","content_type":"png"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"content_type"`)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/media",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"filename":"logo.png","content_type":"image/png","status":"ready","size":42}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusCreated
+
+		// system under test
+		sut, repoMock, _, websitesMock := createTestHandler()
+
+		// mocks
+		mediaMatcher := mock.MatchedBy(func(input media) bool {
+			return input.WebsiteID == "abc" && input.SK == "MEDIA#"+input.ID && input.Status == statusPending && input.Size == 0
+		})
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Create", ctx, mediaMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"upload_url":"/websites/abc/media/`)
+		assert.NotContains(t, res.Body, `"download_url"`)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RetrieveEntity(t *testing.T) {
+	t.Run("success /w download url of ready media", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/media/def",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"Host": "api.example.com"},
+			RequestContext: events.APIGatewayProxyRequestContext{Stage: "Prod"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, _, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, mediaKey("abc", "def")).
+			Once().
+			Return(storedMedia(statusReady), nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"download_url":"https://api.example.com/Prod/websites/abc/media/def/content?`)
+		assert.NotContains(t, res.Body, `"upload_url"`)
+	})
+
+	t.Run("success /w download url of configured base url", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/media/def",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"Host": "127.0.0.1:3000", "X-Forwarded-Proto": "http"},
+			RequestContext: events.APIGatewayProxyRequestContext{Stage: "Prod"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		repoMock := &mocks.Repository[media]{}
+		sut := NewHandler(repoMock, &mocks.Websites{}, cursor.NewCodec([]byte("secret")), &mocks.BlobStore{}, createTestSigner(), "https://cms.example.com/api/")
+
+		// mocks
+		repoMock.On("Get", ctx, mediaKey("abc", "def")).
+			Once().
+			Return(storedMedia(statusReady), nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"download_url":"https://cms.example.com/api/websites/abc/media/def/content?`)
+	})
+}
+
+func TestHandler_DeleteEntity(t *testing.T) {
+	t.Run("fail missing media causes 404 not found w/o deleting content", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/media/def",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, blobsMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, mediaKey("abc", "def")).
+			Once().
+			Return(media{}, dynamo.ErrNotFound)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		blobsMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/media/def",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNoContent
+
+		// system under test
+		sut, repoMock, blobsMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, mediaKey("abc", "def")).
+			Once().
+			Return(storedMedia(statusReady), nil)
+		repoMock.On("Delete", ctx, mediaKey("abc", "def")).
+			Once().
+			Return(nil)
+		blobsMock.On("Delete", ctx, "abc/def").
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		blobsMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_UploadContent(t *testing.T) {
+	t.Run("fail unsigned request causes 403 forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/media/def/content",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Body:           "foo",
+		}
+
+		// expectations
+		expectedStatus := http.StatusForbidden
+
+		// system under test
+		sut, repoMock, blobsMock, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UploadContent(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		blobsMock.AssertExpectations(t)
+	})
+
+	t.Run("fail download url causes 403 forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/media/def/content",
+			HTTPMethod:            http.MethodPut,
+			PathParameters:        map[string]string{"website": "abc", "id": "def"},
+			QueryStringParameters: createTestSignature(http.MethodGet, "/websites/abc/media/def/content"),
+			Body:                  "foo",
+		}
+
+		// expectations
+		expectedStatus := http.StatusForbidden
+
+		// system under test
+		sut, _, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UploadContent(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail too large content causes 413 request entity too large", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/media/def/content",
+			HTTPMethod:            http.MethodPut,
+			PathParameters:        map[string]string{"website": "abc", "id": "def"},
+			QueryStringParameters: createTestSignature(http.MethodPut, "/websites/abc/media/def/content"),
+			Body:                  strings.Repeat("a", maxContentSize+1),
+		}
+
+		// expectations
+		expectedStatus := http.StatusRequestEntityTooLarge
+
+		// system under test
+		sut, _, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UploadContent(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success /w base64 encoded body", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/media/def/content",
+			HTTPMethod:            http.MethodPut,
+			PathParameters:        map[string]string{"website": "abc", "id": "def"},
+			QueryStringParameters: createTestSignature(http.MethodPut, "/websites/abc/media/def/content"),
+			Body:                  base64.StdEncoding.EncodeToString([]byte("\x89PNG")),
+			IsBase64Encoded:       true,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, blobsMock, _ := createTestHandler()
+
+		// mocks
+		mediaMatcher := mock.MatchedBy(func(input media) bool {
+			return input.Status == statusReady && input.Size == 4 && input.CreatedBy == "alice" && input.SK == "MEDIA#def"
+		})
+		repoMock.On("Get", ctx, mediaKey("abc", "def")).
+			Once().
+			Return(storedMedia(statusPending), nil)
+		blobsMock.On("Put", ctx, "abc/def", "image/png", []byte("\x89PNG")).
+			Once().
+			Return(nil)
+		repoMock.On("Update", ctx, mediaMatcher, int64(1)).
+			Once().
+			Return(int64(2), nil)
+
+		// execute
+		res, err := sut.UploadContent(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"download_url"`)
+		repoMock.AssertExpectations(t)
+		blobsMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_DownloadContent(t *testing.T) {
+	t.Run("fail pending media causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/media/def/content",
+			HTTPMethod:            http.MethodGet,
+			PathParameters:        map[string]string{"website": "abc", "id": "def"},
+			QueryStringParameters: createTestSignature(http.MethodGet, "/websites/abc/media/def/content"),
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, blobsMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, mediaKey("abc", "def")).
+			Once().
+			Return(storedMedia(statusPending), nil)
+
+		// execute
+		res, err := sut.DownloadContent(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		blobsMock.AssertExpectations(t)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/media/def/content",
+			HTTPMethod:            http.MethodGet,
+			PathParameters:        map[string]string{"website": "abc", "id": "def"},
+			QueryStringParameters: createTestSignature(http.MethodGet, "/websites/abc/media/def/content"),
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedBody := base64.StdEncoding.EncodeToString([]byte("\x89PNG"))
+
+		// system under test
+		sut, repoMock, blobsMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, mediaKey("abc", "def")).
+			Once().
+			Return(storedMedia(statusReady), nil)
+		blobsMock.On("Get", ctx, "abc/def").
+			Once().
+			Return([]byte("\x89PNG"), nil)
+
+		// execute
+		res, err := sut.DownloadContent(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.True(t, res.IsBase64Encoded)
+		assert.Equal(t, expectedBody, res.Body)
+		assert.Equal(t, "image/png", res.Headers[headerContentType])
+		assert.Equal(t, `inline; filename=logo.png`, res.Headers[headerContentDisposition])
+	})
+}
+
+func createTestHandler() (*Handler, *mocks.Repository[media], *mocks.BlobStore, *mocks.Websites) {
+	repoMock := &mocks.Repository[media]{}
+	blobsMock := &mocks.BlobStore{}
+	websitesMock := &mocks.Websites{}
+
+	sut := NewHandler(repoMock, websitesMock, cursor.NewCodec([]byte("secret")), blobsMock, createTestSigner(), "")
+
+	return sut, repoMock, blobsMock, websitesMock
+}
+
+func createTestSigner() *presign.Signer {
+	return presign.NewSigner([]byte("secret"), time.Minute)
+}
+
+// createTestSignature returns the query parameters of a URL signed by createTestSigner.
+func createTestSignature(method, path string) map[string]string {
+	signed, _ := createTestSigner().Sign(method, path, time.Now())
+
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		panic(err)
+	}
+
+	return map[string]string{
+		presign.ExpiresParam:   parsed.Query().Get(presign.ExpiresParam),
+		presign.SignatureParam: parsed.Query().Get(presign.SignatureParam),
+	}
+}
+
+// storedMedia returns media def of website abc created by alice.
+func storedMedia(status string) media {
+	createdAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	return media{
+		Meta: crud.Meta{
+			Fields:  audit.Fields{CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "alice", UpdatedBy: "alice"},
+			Version: 1,
+		},
+		WebsiteID:   "abc",
+		ID:          "def",
+		Filename:    "logo.png",
+		ContentType: "image/png",
+		Status:      status,
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rs/zerolog/log"
+
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/presign"
+	"github.com/abtercms/abtercms2/pkg/storage"
+)
+
+const (
+	// collection is the path segment of media in the path of their website.
+	collection = "media"
+
+	// idParam is the path parameter holding the id of a media.
+	idParam = "id"
+
+	// mediaType prefixes the sort key of media, which are stored in the partition of their website.
+	mediaType = "MEDIA#"
+
+	statusPending = "pending"
+	statusReady   = "ready"
+
+	// maxContentSize is the size of the largest file accepted, Lambda limits requests to 6 MB and base64 adds a third.
+	maxContentSize = 4 << 20
+
+	// urlTTL is the time signed upload and download URLs are valid for.
+	urlTTL = 15 * time.Minute
+
+	EnvMediaSecret   = "MEDIA_SECRET"
+	EnvMediaBucket   = "MEDIA_BUCKET"
+	EnvMediaLocalDir = "MEDIA_LOCAL_DIR"
+	EnvMediaBaseURL  = "MEDIA_BASE_URL"
+
+	headerContentType        = "Content-Type"
+	headerContentDisposition = "Content-Disposition"
+	headerForwardedProto     = "X-Forwarded-Proto"
+	headerHost               = "Host"
+
+	// defaultScheme is the scheme of signed URLs if the request does not tell the one it was sent with.
+	defaultScheme = "https"
+
+	errUnmarshallParams  = "failed to unmarshal the request, query: %v"
+	errUnmarshallContent = "failed to decode the uploaded content"
+	errContentTooLarge   = "content of %d bytes exceeds the limit of %d bytes"
+	errMediaNotFound     = "media not found in storage: %s"
+	errMediaNotUploaded  = "media has not been uploaded yet: %s"
+)
+
+func main() {
+	var (
+		env           = crud.Bootstrap(context.TODO())
+		mediaSecret   = os.Getenv(EnvMediaSecret)
+		mediaBucket   = os.Getenv(EnvMediaBucket)
+		mediaLocalDir = ""
+		mediaBaseURL  = os.Getenv(EnvMediaBaseURL)
+	)
+
+	if env.Local {
+		mediaLocalDir = os.Getenv(EnvMediaLocalDir)
+	}
+
+	if mediaSecret == "" {
+		log.Fatal().
+			Str(crud.EnvTableName, os.Getenv(crud.EnvTableName)).
+			Msg("media secret is required to sign upload and download urls")
+	}
+
+	// SAM local has no S3, therefore files are kept on the local filesystem instead
+	var blobs storage.BlobStore = storage.NewS3Store(env.SDKConfig, mediaBucket, "")
+	if mediaLocalDir != "" {
+		blobs = storage.NewFSStore(mediaLocalDir)
+	}
+
+	signer := presign.NewSigner([]byte(mediaSecret), urlTTL)
+
+	h := NewHandler(dynamo.NewGuardedRepo[media](env.Table), dynamo.NewTypedRepo[crud.Website](env.Table), env.Cursors, blobs, signer, mediaBaseURL)
+	lambda.Start(NewRouter(h).Handler)
+}
+
+type handler interface {
+	RetrieveCollection(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	CreateEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	DeleteEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	UploadContent(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	DownloadContent(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}
+
+func NewRouter(h handler) *lmdrouter.Router {
+	router := crud.NewRouter(collection, idParam, crud.Routes{
+		RetrieveCollection: h.RetrieveCollection,
+		CreateEntity:       h.CreateEntity,
+		RetrieveEntity:     h.RetrieveEntity,
+		UpdateEntity:       nil,
+		PatchEntity:        nil,
+		DeleteEntity:       h.DeleteEntity,
+		RetrieveRevisions:  nil,
+		RetrieveRevision:   nil,
+		RestoreRevision:    nil,
+	})
+	router.Route(http.MethodPut, "/:website/media/:id/content", h.UploadContent)
+	router.Route(http.MethodGet, "/:website/media/:id/content", h.DownloadContent)
+
+	return router
+}
@@ -0,0 +1,729 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/revision"
+)
+
+type listParams struct {
+	WebsiteID string `lambda:"path.website"` // a path parameter declared as :website
+	ID        string `lambda:"path.id"`      // a path parameter declared as :id
+	Cursor    string `lambda:"query.cursor"` // a query parameter named "cursor"
+	Limit     int32  `lambda:"query.limit"`  // a query parameter named "limit"
+}
+
+type listResponse struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	HasMore    bool        `json:"has_more"`
+}
+
+// isListParam tells whether a query parameter is supported when retrieving a collection.
+func isListParam(name string) bool {
+	return name == "cursor" || name == limitParam
+}
+
+// pageLimit returns the page size requested by the client, falling back to defaultLimit if none was requested.
+func pageLimit(req events.APIGatewayProxyRequest, params listParams) (int32, error) {
+	for name := range req.QueryStringParameters {
+		if !isListParam(name) {
+			return 0, lhttp.NewProblem(http.StatusBadRequest, errUnsupportedParam, name)
+		}
+	}
+
+	if _, ok := req.QueryStringParameters[limitParam]; !ok {
+		return defaultLimit, nil
+	}
+
+	if params.Limit < minLimit || params.Limit > maxLimit {
+		return 0, lhttp.NewProblem(http.StatusBadRequest, errInvalidLimit, params.Limit, minLimit, maxLimit)
+	}
+
+	return params.Limit, nil
+}
+
+type entityParams struct {
+	WebsiteID string `lambda:"path.website"` // a path parameter declared as :website
+	ID        string `lambda:"path.id"`      // a path parameter declared as :id
+}
+
+// page is stored in the partition of its website, its path is unique within the website.
+// The parent of a page is the page at the parent path, top level pages such as "/blog" and the home page "/" have none.
+type page struct {
+	crud.Meta
+	WebsiteID string `json:"website_id" dynamodbav:"pk"`
+	ID        string `json:"id" dynamodbav:"page_id"`
+	ParentID  string `json:"parent_id,omitempty" dynamodbav:"parent_id,omitempty"`
+	Path      string `json:"path" dynamodbav:"path" validate:"required,max=1024,pattern=^/([a-z0-9][a-z0-9._-]*(/[a-z0-9][a-z0-9._-]*)*)?$"`
+	Title     string `json:"title" dynamodbav:"title" validate:"required,max=200"`
+	Body      string `json:"body" dynamodbav:"body"`
+
+	// TemplateID is the template used as the layout of the page when it is rendered.
+	TemplateID string `json:"template_id,omitempty" dynamodbav:"template_id,omitempty"`
+
+	// PublishAt and UnpublishAt schedule the page to be published and unpublished by the scheduler.
+	PublishAt   *time.Time `json:"publish_at,omitempty" dynamodbav:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty" dynamodbav:"unpublish_at,omitempty"`
+}
+
+func (p *page) Identity() (string, string) {
+	return p.WebsiteID, p.ID
+}
+
+func (p *page) SetIdentity(websiteID, id string) {
+	p.WebsiteID = websiteID
+	p.ID = id
+}
+
+// schedule is due when a page is to be published or unpublished, the scheduler lists due items via the index.
+// Its keys contain the due time, therefore rescheduling replaces the item instead of updating it.
+type schedule struct {
+	dynamo.Keys
+	WebsiteID string    `dynamodbav:"pk"`
+	PageID    string    `dynamodbav:"page_id"`
+	Action    string    `dynamodbav:"action"`
+	DueAt     time.Time `dynamodbav:"due_at"`
+}
+
+// snapshot is the published copy of a page, which is rendered publicly while editors keep changing the page itself.
+// It is replaced as a whole whenever the page is published again, its version is the one of the page published.
+type snapshot struct {
+	WebsiteID   string    `json:"website_id" dynamodbav:"pk"`
+	SK          string    `json:"-" dynamodbav:"sk"`
+	ID          string    `json:"id" dynamodbav:"page_id"`
+	ParentID    string    `json:"parent_id,omitempty" dynamodbav:"parent_id,omitempty"`
+	Path        string    `json:"path" dynamodbav:"path"`
+	Title       string    `json:"title" dynamodbav:"title"`
+	Body        string    `json:"body" dynamodbav:"body"`
+	TemplateID  string    `json:"template_id,omitempty" dynamodbav:"template_id,omitempty"`
+	Version     int64     `json:"version" dynamodbav:"version"`
+	PublishedAt time.Time `json:"published_at" dynamodbav:"published_at"`
+	PublishedBy string    `json:"published_by" dynamodbav:"published_by"`
+}
+
+// template holds the fields of a template needed to tell whether it may be used as a layout.
+type template struct {
+	ID string `dynamodbav:"template_id"`
+}
+
+// pathGuard reserves the path of a page within its website.
+type pathGuard struct {
+	WebsiteID string `dynamodbav:"pk"`
+	SK        string `dynamodbav:"sk"`
+	PageID    string `dynamodbav:"page_id"`
+}
+
+// pageKey returns the table key of a page.
+func pageKey(websiteID, pageID string) dynamo.Key {
+	return dynamo.K2(websiteID, pageType+pageID)
+}
+
+// templateKey returns the table key of a template.
+func templateKey(websiteID, templateID string) dynamo.Key {
+	return dynamo.K2(websiteID, templateType+templateID)
+}
+
+// pathGuardKey returns the table key of the guard reserving a path.
+func pathGuardKey(websiteID, pagePath string) dynamo.Key {
+	return dynamo.K2(websiteID, pathType+pagePath)
+}
+
+// snapshotKey returns the table key of the published snapshot of a page.
+func snapshotKey(websiteID, pageID string) dynamo.Key {
+	return dynamo.K2(websiteID, publishedPageType+pageID)
+}
+
+// publishedPathKey returns the table key of the guard reserving a published path.
+func publishedPathKey(websiteID, pagePath string) dynamo.Key {
+	return dynamo.K2(websiteID, publishedPathType+pagePath)
+}
+
+// childrenPartition returns the index partition listing the children of a page, or the top level pages if pageID is empty.
+func childrenPartition(websiteID, pageID string) string {
+	return childrenType + websiteID + "#" + pageID
+}
+
+// parentPath returns the path of the parent of a page, or an empty string for top level pages.
+func parentPath(pagePath string) string {
+	parent := path.Dir(pagePath)
+	if parent == rootPath || pagePath == rootPath {
+		return ""
+	}
+
+	return parent
+}
+
+// setKeys sets the storage keys of a page, children are listed in the partition of their parent ordered by path.
+func (p *page) setKeys() {
+	p.Keys = dynamo.Keys{
+		SK:     pageType + p.ID,
+		GSI1PK: childrenPartition(p.WebsiteID, p.ParentID),
+		GSI1SK: p.Path,
+	}
+}
+
+// pathGuard returns the guard reserving the path of a page.
+func (p *page) pathGuard() pathGuard {
+	return pathGuard{
+		WebsiteID: p.WebsiteID,
+		SK:        pathType + p.Path,
+		PageID:    p.ID,
+	}
+}
+
+// snapshot returns the published copy of a page.
+func (p *page) snapshot(actor string, now time.Time) snapshot {
+	return snapshot{
+		WebsiteID:   p.WebsiteID,
+		SK:          publishedPageType + p.ID,
+		ID:          p.ID,
+		ParentID:    p.ParentID,
+		Path:        p.Path,
+		Title:       p.Title,
+		Body:        p.Body,
+		TemplateID:  p.TemplateID,
+		Version:     p.Version,
+		PublishedAt: now.UTC(),
+		PublishedBy: actor,
+	}
+}
+
+// publishedPathGuard returns the guard reserving the path of a published snapshot.
+func (s *snapshot) publishedPathGuard() pathGuard {
+	return pathGuard{
+		WebsiteID: s.WebsiteID,
+		SK:        publishedPathType + s.Path,
+		PageID:    s.ID,
+	}
+}
+
+// validateSchedule checks that a page scheduled to be published and unpublished is unpublished after it is published.
+// Scheduled times are compared the way they are stored, see dueTime.
+func validateSchedule(entity page) error {
+	publishAt, unpublishAt := dueTime(entity.PublishAt), dueTime(entity.UnpublishAt)
+
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return lhttp.NewInvalidParamsProblem([]lhttp.InvalidParam{{Name: "unpublish_at", Reason: reasonUnpublishBeforePublish}})
+	}
+
+	return nil
+}
+
+// dueTime returns a scheduled time the way it is stored, the precision of due times is a second.
+func dueTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	due := t.UTC().Truncate(time.Second)
+
+	return &due
+}
+
+// schedules returns the items due when a page is scheduled to be published or unpublished.
+func (p *page) schedules() []schedule {
+	var result []schedule
+
+	if p.PublishAt != nil {
+		result = append(result, p.schedule(actionPublish, *p.PublishAt))
+	}
+
+	if p.UnpublishAt != nil {
+		result = append(result, p.schedule(actionUnpublish, *p.UnpublishAt))
+	}
+
+	return result
+}
+
+// schedule returns the item due when a page is to be published or unpublished.
+// Due items are listed in chronological order, followed by their website, page and action to tell them apart.
+func (p *page) schedule(action string, dueAt time.Time) schedule {
+	due := dueAt.Format(dueFormat)
+
+	return schedule{
+		Keys: dynamo.Keys{
+			SK:     scheduleType + p.ID + "#" + action + "#" + due,
+			GSI1PK: dueType,
+			GSI1SK: due + "#" + p.WebsiteID + "#" + p.ID + "#" + action,
+		},
+		WebsiteID: p.WebsiteID,
+		PageID:    p.ID,
+		Action:    action,
+		DueAt:     dueAt,
+	}
+}
+
+// scheduleKeys returns the table keys of the items due for a page.
+func (p *page) scheduleKeys() []dynamo.Key {
+	var keys []dynamo.Key
+
+	for _, item := range p.schedules() {
+		keys = append(keys, dynamo.K2(item.WebsiteID, item.SK))
+	}
+
+	return keys
+}
+
+// scheduleGuards returns the guards to add and remove when the schedule of a page changes from stored to p.
+// Unchanged items are kept, so that items already processed by the scheduler are not scheduled again.
+func (p *page) scheduleGuards(stored page) dynamo.Guards {
+	guards := dynamo.Guards{Add: nil, Remove: nil, Keep: nil}
+
+	current := map[string]bool{}
+	for _, item := range stored.schedules() {
+		current[item.SK] = true
+	}
+
+	next := map[string]bool{}
+	for _, item := range p.schedules() {
+		next[item.SK] = true
+
+		if !current[item.SK] {
+			guards.Add = append(guards.Add, item)
+		}
+	}
+
+	for _, item := range stored.schedules() {
+		if !next[item.SK] {
+			guards.Remove = append(guards.Remove, dynamo.K2(item.WebsiteID, item.SK))
+		}
+	}
+
+	return guards
+}
+
+// isPatchable tells whether clients may change a page field via PATCH.
+// Paths and schedules are excluded as changing them requires guards and scheduled items to be replaced, PUT does that.
+func isPatchable(field string) bool {
+	switch field {
+	case "title", "body", templateField:
+		return true
+	default:
+		return false
+	}
+}
+
+type repo interface {
+	dynamo.Store
+	CreateGuarded(context.Context, interface{}, dynamo.Guards) error
+	UpdateGuarded(context.Context, interface{}, int64, dynamo.Guards) (int64, error)
+	PutGuarded(context.Context, interface{}, dynamo.Guards) error
+	DeleteGuarded(context.Context, dynamo.Key, []dynamo.Key) error
+}
+
+type cursors interface {
+	Encode(dynamo.Query, dynamo.Key) (string, error)
+	Decode(dynamo.Query, string) (dynamo.Key, error)
+}
+
+type revisions interface {
+	Record(context.Context, string, string, int64, string, interface{}) error
+	List(context.Context, string, string, int32, dynamo.Key) ([]revision.Revision, dynamo.Page, error)
+	Get(context.Context, string, string, string) (revision.Revision, error)
+	Purge(context.Context, string, string) error
+}
+
+// Handler is a collection of handlers.
+// Pages are served by a crud.Handler whose hooks reserve their paths, keep their parents and schedule them,
+// the handlers listing the children of pages and publishing them are added on top.
+// Every saved version of a page is recorded as a revision, which the page can be rolled back to.
+type Handler struct {
+	*crud.Handler[page, *page]
+	repo      repo
+	pages     *dynamo.TypedRepo[page]
+	snapshots *dynamo.TypedRepo[snapshot]
+	guards    *dynamo.TypedRepo[pathGuard]
+	templates *dynamo.TypedRepo[template]
+	cursors   cursors
+}
+
+func NewHandler(repo repo, cursors cursors, revisions revisions) *Handler {
+	h := &Handler{
+		Handler:   nil,
+		repo:      repo,
+		pages:     dynamo.NewTypedRepo[page](repo),
+		snapshots: dynamo.NewTypedRepo[snapshot](repo),
+		guards:    dynamo.NewTypedRepo[pathGuard](repo),
+		templates: dynamo.NewTypedRepo[template](repo),
+		cursors:   cursors,
+	}
+
+	resource := crud.Resource[page]{
+		Name:          "page",
+		Type:          pageType,
+		IDParam:       idParam,
+		Key:           pageKey,
+		GenerateID:    true,
+		Validate:      validateSchedule,
+		Patchable:     isPatchable,
+		ValidatePatch: h.validatePatch,
+		Prepare:       nil,
+		Guard:         h.guard,
+		Deleting:      h.deleting,
+		Present:       nil,
+		Deleted:       nil,
+	}
+
+	h.Handler = crud.NewHandler[page, *page](resource, dynamo.NewGuardedRepo[page](repo), dynamo.NewTypedRepo[crud.Website](repo), cursors, revisions)
+
+	return h
+}
+
+// RetrieveChildren is a handler to retrieve the children of a page ordered by path.
+func (h *Handler) RetrieveChildren(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var (
+		params     listParams
+		collection = []page{}
+	)
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	_, err = h.get(ctx, params.WebsiteID, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	query := dynamo.Query{
+		Index:             dynamo.GSI1,
+		Partition:         childrenPartition(params.WebsiteID, params.ID),
+		SortKey:           nil,
+		Filters:           nil,
+		Limit:             0,
+		ExclusiveStartKey: nil,
+		Descending:        false,
+	}
+
+	return h.list(ctx, req, params, query, &collection)
+}
+
+// list runs a collection query for a page of results chosen by the cursor and limit of a request.
+func (h *Handler) list(ctx context.Context, req events.APIGatewayProxyRequest, params listParams, query dynamo.Query, collection *[]page) (events.APIGatewayProxyResponse, error) {
+	var err error
+
+	query.Limit, err = pageLimit(req, params)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	query.ExclusiveStartKey, err = h.cursors.Decode(query, params.Cursor)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	result, err := h.repo.Query(ctx, query, collection)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	nextCursor, err := h.cursors.Encode(query, result.Next)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, listResponse{Items: *collection, NextCursor: nextCursor, HasMore: result.HasMore})
+}
+
+// PublishEntity is a handler to publish the current state of a page, replacing its previously published snapshot.
+// The snapshot is served at the path the page has when it is published,
+// which results in a 409 conflict if another page is still published at that path.
+func (h *Handler) PublishEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params entityParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	stored, err := h.get(ctx, params.WebsiteID, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	published, isPublished, err := h.published(ctx, params.WebsiteID, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	entity := stored.snapshot(audit.Actor(req), time.Now())
+
+	// the published path only needs to be reserved again if the page was moved since it was last published
+	guards := dynamo.Guards{Add: nil, Remove: nil, Keep: nil}
+	if !isPublished || published.Path != entity.Path {
+		guards.Add = []interface{}{entity.publishedPathGuard()}
+	}
+
+	if isPublished && published.Path != entity.Path {
+		guards.Remove = []dynamo.Key{publishedPathKey(published.WebsiteID, published.Path)}
+	}
+
+	err = h.repo.PutGuarded(ctx, entity, guards)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, entity)
+}
+
+// UnpublishEntity is a handler to take down the published snapshot of a page, the page itself is kept.
+func (h *Handler) UnpublishEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params entityParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	published, err := h.snapshots.Get(ctx, snapshotKey(params.WebsiteID, params.ID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusNotFound, errPageNotPublished, params.ID), nil)
+	}
+
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.repo.DeleteGuarded(ctx, snapshotKey(params.WebsiteID, params.ID), []dynamo.Key{publishedPathKey(params.WebsiteID, published.Path)})
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusNoContent, nil, nil)
+}
+
+// get reads a page, missing pages result in a 404 not found.
+func (h *Handler) get(ctx context.Context, websiteID, pageID string) (page, error) {
+	entity, err := h.pages.Get(ctx, pageKey(websiteID, pageID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return entity, lhttp.WrapProblem(err, http.StatusNotFound, errPageNotFound, pageID)
+	}
+
+	return entity, err
+}
+
+// published reads the published snapshot of a page and reports whether the page is published at all.
+func (h *Handler) published(ctx context.Context, websiteID, pageID string) (snapshot, bool, error) {
+	published, err := h.snapshots.Get(ctx, snapshotKey(websiteID, pageID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return published, false, nil
+	}
+
+	return published, err == nil, err
+}
+
+// guard completes a page about to be created, or to replace stored on updates, and returns the guards saved along with
+// it. Scheduled times are truncated to the second in UTC, see dueTime.
+// Changing the path moves the page under the page at the new parent path, pages with children can not be moved.
+// Changing publish_at or unpublish_at reschedules the page, the scheduler publishes and unpublishes it when due.
+func (h *Handler) guard(ctx context.Context, entity, stored *page) (dynamo.Guards, error) {
+	entity.PublishAt = dueTime(entity.PublishAt)
+	entity.UnpublishAt = dueTime(entity.UnpublishAt)
+
+	if stored == nil {
+		return h.reserve(ctx, entity)
+	}
+
+	err := h.checkTemplate(ctx, entity.WebsiteID, entity.TemplateID)
+	if err != nil {
+		return dynamo.Guards{Add: nil, Remove: nil, Keep: nil}, err
+	}
+
+	// the parent is read-only, therefore it is taken from the stored entity unless the page is moved
+	entity.ParentID = stored.ParentID
+	entity.setKeys()
+
+	guards := entity.scheduleGuards(*stored)
+
+	if entity.Path != stored.Path {
+		err = h.move(ctx, entity, *stored, &guards)
+	}
+
+	return guards, err
+}
+
+// reserve returns the guards of a page about to be created, its path is reserved and its parent is looked up by path.
+func (h *Handler) reserve(ctx context.Context, entity *page) (dynamo.Guards, error) {
+	guards := entity.scheduleGuards(page{})
+
+	parent, err := h.parent(ctx, entity.WebsiteID, entity.Path)
+	if err != nil {
+		return guards, err
+	}
+
+	if parent != nil {
+		entity.ParentID = parent.PageID
+		guards.Keep = append(guards.Keep, *parent)
+	}
+
+	err = h.checkTemplate(ctx, entity.WebsiteID, entity.TemplateID)
+	if err != nil {
+		return guards, err
+	}
+
+	entity.setKeys()
+
+	guards.Add = append(guards.Add, entity.pathGuard())
+
+	return guards, nil
+}
+
+// deleting checks that a page has no children and returns the guards deleted along with it, which are its path and
+// schedule. Published pages are taken down as well.
+func (h *Handler) deleting(ctx context.Context, stored page) ([]dynamo.Key, error) {
+	err := h.checkNoChildren(ctx, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	published, isPublished, err := h.published(ctx, stored.WebsiteID, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	guards := append([]dynamo.Key{pathGuardKey(stored.WebsiteID, stored.Path)}, stored.scheduleKeys()...)
+	if isPublished {
+		guards = append(guards, snapshotKey(stored.WebsiteID, stored.ID), publishedPathKey(stored.WebsiteID, published.Path))
+	}
+
+	return guards, nil
+}
+
+// validatePatch checks that the layouts a patch assigns to a page exist.
+func (h *Handler) validatePatch(ctx context.Context, websiteID string, ops []patch.Operation) error {
+	for _, op := range ops {
+		if templateID, ok := op.Value.(string); ok && op.Path.Field() == templateField && op.Op != patch.OpTest {
+			err := h.checkTemplate(ctx, websiteID, templateID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// move moves a page to its new path, under the page at the new parent path.
+// Pages with children can not be moved, pages can not be moved below their own path either as they would become their
+// own ancestor.
+func (h *Handler) move(ctx context.Context, entity *page, stored page, guards *dynamo.Guards) error {
+	if strings.HasPrefix(entity.Path, stored.Path+"/") {
+		return belowItself(stored.Path)
+	}
+
+	err := h.checkNoChildren(ctx, stored)
+	if err != nil {
+		return err
+	}
+
+	parent, err := h.parent(ctx, entity.WebsiteID, entity.Path)
+	if err != nil {
+		return err
+	}
+
+	entity.ParentID = ""
+
+	if parent != nil {
+		if parent.PageID == stored.ID {
+			return belowItself(stored.Path)
+		}
+
+		entity.ParentID = parent.PageID
+		guards.Keep = append(guards.Keep, *parent)
+	}
+
+	entity.setKeys()
+
+	guards.Add = append(guards.Add, entity.pathGuard())
+	guards.Remove = append(guards.Remove, pathGuardKey(stored.WebsiteID, stored.Path))
+
+	return nil
+}
+
+// belowItself returns the problem of a page moved below its own path.
+func belowItself(storedPath string) error {
+	return lhttp.NewInvalidParamsProblem([]lhttp.InvalidParam{
+		{Name: "path", Reason: fmt.Sprintf(reasonBelowItself, storedPath)},
+	})
+}
+
+// parent looks up the guard reserving the parent path of a page, the parent must exist unless the page is at the top
+// level, which results in nil. The guard is kept by the transaction saving the page, therefore a parent moved or
+// deleted in the meantime results in a 409 conflict instead of an orphan.
+func (h *Handler) parent(ctx context.Context, websiteID, pagePath string) (*pathGuard, error) {
+	parent := parentPath(pagePath)
+	if parent == "" {
+		return nil, nil
+	}
+
+	guard, err := h.guards.Get(ctx, pathGuardKey(websiteID, parent))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return nil, lhttp.NewInvalidParamsProblem([]lhttp.InvalidParam{
+			{Name: "path", Reason: fmt.Sprintf(errParentNotFound, parent)},
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &pathGuard{WebsiteID: websiteID, SK: pathType + parent, PageID: guard.PageID}, nil
+}
+
+// checkTemplate fails with a 422 problem if a page refers to a layout which does not exist, pages need no layout.
+func (h *Handler) checkTemplate(ctx context.Context, websiteID, templateID string) error {
+	if templateID == "" {
+		return nil
+	}
+
+	_, err := h.templates.Get(ctx, templateKey(websiteID, templateID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return lhttp.NewInvalidParamsProblem([]lhttp.InvalidParam{
+			{Name: templateField, Reason: fmt.Sprintf(errTemplateNotFound, templateID)},
+		})
+	}
+
+	return err
+}
+
+// checkNoChildren fails with a 409 conflict if a page has children.
+func (h *Handler) checkNoChildren(ctx context.Context, entity page) error {
+	var children []page
+
+	query := dynamo.Query{
+		Index:             dynamo.GSI1,
+		Partition:         childrenPartition(entity.WebsiteID, entity.ID),
+		SortKey:           nil,
+		Filters:           nil,
+		Limit:             1,
+		ExclusiveStartKey: nil,
+		Descending:        false,
+	}
+
+	_, err := h.repo.Query(ctx, query, &children)
+	if err != nil {
+		return err
+	}
+
+	if len(children) > 0 {
+		return lhttp.NewProblem(http.StatusConflict, errPageHasChildren, entity.Path)
+	}
+
+	return nil
+}
@@ -0,0 +1,1532 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pages/mocks"
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/cursor"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/revision"
+)
+
+func TestHandler_RetrieveCollection(t *testing.T) {
+	t.Run("fail unsupported query parameters cause 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		for _, paramStub := range []map[string]string{{"limit": "0"}, {"limit": "101"}, {"sort": "path"}} {
+			// stubs
+			ctx := context.Background()
+			requestStub := events.APIGatewayProxyRequest{
+				Path:                  "/websites/abc/pages",
+				HTTPMethod:            http.MethodGet,
+				PathParameters:        map[string]string{"website": "abc"},
+				QueryStringParameters: paramStub,
+			}
+
+			// expectations
+			expectedStatus := http.StatusBadRequest
+
+			// system under test
+			sut, _ := createTestHandler()
+
+			// execute
+			res, err := sut.RetrieveCollection(ctx, requestStub)
+
+			// asserts
+			assert.Error(t, err)
+			assert.Equal(t, expectedStatus, res.StatusCode, "params = %v", paramStub)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/pages",
+			HTTPMethod:            http.MethodGet,
+			PathParameters:        map[string]string{"website": "abc"},
+			QueryStringParameters: map[string]string{"limit": "2"},
+		}
+		nextStub := dynamo.K2("abc", "PAGE#def")
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedQuery := dynamo.Query{
+			Index:             "",
+			Partition:         "abc",
+			SortKey:           dynamo.SortKeyBeginsWith("PAGE#"),
+			Filters:           nil,
+			Limit:             2,
+			ExclusiveStartKey: nil,
+			Descending:        false,
+		}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, expectedQuery, mock.Anything).
+			Once().
+			Return(dynamo.Page{Next: nextStub, HasMore: true}, nil)
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+
+		var body listResponse
+		require.NoError(t, json.Unmarshal([]byte(res.Body), &body))
+		assert.True(t, body.HasMore)
+		assert.NotEmpty(t, body.NextCursor)
+	})
+}
+
+func TestHandler_RetrieveChildren(t *testing.T) {
+	t.Run("fail missing parent causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/children",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.RetrieveChildren(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/children",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedQuery := dynamo.Query{
+			Index:             dynamo.GSI1,
+			Partition:         "CHILDREN#abc#def",
+			SortKey:           nil,
+			Filters:           nil,
+			Limit:             defaultLimit,
+			ExclusiveStartKey: nil,
+			Descending:        false,
+		}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, expectedQuery, mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+
+		// execute
+		res, err := sut.RetrieveChildren(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_CreateEntity(t *testing.T) {
+	t.Run("fail entity with existing id causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"id":"def","path":"/blog","title":"Blog"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail invalid paths cause 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		for _, pathStub := range []string{"", "blog", "/blog/", "/Blog", "/blog//2022", "/-blog"} {
+			// stubs
+			ctx := context.Background()
+			requestStub := events.APIGatewayProxyRequest{
+				Path:           "/websites/abc/pages",
+				HTTPMethod:     http.MethodPost,
+				PathParameters: map[string]string{"website": "abc"},
+				Body:           `{"path":"` + pathStub + `","title":"Blog"}`,
+			}
+
+			// expectations
+			expectedStatus := http.StatusUnprocessableEntity
+
+			// system under test
+			sut, _ := createTestHandler()
+
+			// execute
+			res, err := sut.CreateEntity(ctx, requestStub)
+
+			// asserts
+			assert.Error(t, err)
+			assert.Equal(t, expectedStatus, res.StatusCode, "path = %s", pathStub)
+		}
+	})
+
+	t.Run("fail unpublishing before publishing causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"path":"/blog","title":"Blog","publish_at":"2022-07-01T10:00:00Z","unpublish_at":"2022-07-01T10:00:00Z"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, "unpublish_at")
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail missing website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"path":"/blog","title":"Blog"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail missing parent causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"path":"/blog/2022/hello","title":"Hello"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pathGuardKey("abc", "/blog/2022"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"path"`)
+	})
+
+	t.Run("fail missing template causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"path":"/blog","title":"Blog","template_id":"xyz"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, templateKey("abc", "xyz"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"template_id"`)
+	})
+
+	t.Run("fail taken path causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"path":"/blog","title":"Blog"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusConflict
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("CreateGuarded", ctx, mock.AnythingOfType("main.page"), mock.AnythingOfType("dynamo.Guards")).
+			Once().
+			Return(lhttp.NewProblem(http.StatusConflict, "unique value already taken"))
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"website_id":"mallory","parent_id":"mallory","path":"/blog/2022","title":"2022","body":"Posts of 2022"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusCreated
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pathGuardKey("abc", "/blog"), pathGuardModifier("def")).
+			Once().
+			Return(true, nil)
+		pageMatcher := mock.MatchedBy(func(input page) bool {
+			return input.WebsiteID == "abc" &&
+				input.ID != "" &&
+				input.ParentID == "def" &&
+				input.SK == "PAGE#"+input.ID &&
+				input.GSI1PK == "CHILDREN#abc#def" &&
+				input.GSI1SK == "/blog/2022"
+		})
+		guardsMatcher := mock.MatchedBy(func(input dynamo.Guards) bool {
+			guard, ok := input.Add[0].(pathGuard)
+
+			return len(input.Add) == 1 && ok && guard.SK == "PATH#/blog/2022" && guard.PageID != "" &&
+				assert.ObjectsAreEqual([]interface{}{pathGuard{WebsiteID: "abc", SK: "PATH#/blog", PageID: "def"}}, input.Keep)
+		})
+		repoMock.On("CreateGuarded", ctx, pageMatcher, guardsMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RetrieveEntity(t *testing.T) {
+	t.Run("fail missing entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, `"3"`, res.Headers["ETag"])
+		assert.NotContains(t, res.Body, `"sk"`)
+	})
+}
+
+func TestHandler_UpdateEntity(t *testing.T) {
+	t.Run("fail missing If-Match header causes 428 precondition required", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Body:           `{"id":"def","path":"/blog","title":"Blog"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusPreconditionRequired
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail moving page with children causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"id":"def","path":"/news","title":"News"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusConflict
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, mock.AnythingOfType("dynamo.Query"), childrenModifier("ghi")).
+			Once().
+			Return(dynamo.Page{}, nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail moving page below its own path causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"id":"def","path":"/blog/hello/world","title":"Hello"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog/hello", "ghi")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, "invalid_params")
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail parent path reserved for the page itself causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"id":"def","path":"/news/hello","title":"Hello"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog/hello", "ghi")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, mock.AnythingOfType("dynamo.Query"), mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Find", ctx, pathGuardKey("abc", "/news"), pathGuardModifier("def")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w same path", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"id":"def","path":"/blog","title":"Blog","body":"All posts"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		pageMatcher := mock.MatchedBy(func(input page) bool {
+			return input.Body == "All posts" && input.CreatedBy == "alice" && input.GSI1PK == "CHILDREN#abc#"
+		})
+		repoMock.On("Update", ctx, pageMatcher, int64(3)).
+			Once().
+			Return(int64(4), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success /w rescheduled publishing", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"id":"def","path":"/blog","title":"Blog","publish_at":"2022-07-02T12:00:00.5+02:00","unpublish_at":"2022-08-01T00:00:00Z"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedGuards := dynamo.Guards{
+			Add: []interface{}{
+				schedule{
+					Keys: dynamo.Keys{
+						SK:     "SCHEDULE#def#publish#2022-07-02T10:00:00Z",
+						GSI1PK: "DUE",
+						GSI1SK: "2022-07-02T10:00:00Z#abc#def#publish",
+					},
+					WebsiteID: "abc",
+					PageID:    "def",
+					Action:    actionPublish,
+					DueAt:     time.Date(2022, 7, 2, 10, 0, 0, 0, time.UTC),
+				},
+			},
+			Remove: []dynamo.Key{dynamo.K2("abc", "SCHEDULE#def#publish#2022-07-01T10:00:00Z")},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		storedModifier := mock.MatchedBy(func(input *page) bool {
+			publishAt := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+			unpublishAt := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+
+			*input = page{WebsiteID: "abc", ID: "def", Path: "/blog", Title: "Blog", Meta: crud.Meta{Version: 3}, PublishAt: &publishAt, UnpublishAt: &unpublishAt}
+
+			return true
+		})
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedModifier).
+			Once().
+			Return(true, nil)
+		repoMock.On("UpdateGuarded", ctx, mock.AnythingOfType("main.page"), int64(3), expectedGuards).
+			Once().
+			Return(int64(4), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"publish_at":"2022-07-02T10:00:00Z"`)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w new path", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"id":"def","path":"/news/hello","title":"Hello"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog/hello", "ghi")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, mock.AnythingOfType("dynamo.Query"), mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Find", ctx, pathGuardKey("abc", "/news"), pathGuardModifier("jkl")).
+			Once().
+			Return(true, nil)
+		pageMatcher := mock.MatchedBy(func(input page) bool {
+			return input.ParentID == "jkl" && input.GSI1PK == "CHILDREN#abc#jkl" && input.GSI1SK == "/news/hello"
+		})
+		guardsMatcher := mock.MatchedBy(func(input dynamo.Guards) bool {
+			return len(input.Add) == 1 &&
+				assert.ObjectsAreEqual(pathGuard{WebsiteID: "abc", SK: "PATH#/news/hello", PageID: "def"}, input.Add[0]) &&
+				assert.ObjectsAreEqual([]dynamo.Key{pathGuardKey("abc", "/blog/hello")}, input.Remove) &&
+				assert.ObjectsAreEqual([]interface{}{pathGuard{WebsiteID: "abc", SK: "PATH#/news", PageID: "jkl"}}, input.Keep)
+		})
+		repoMock.On("UpdateGuarded", ctx, pageMatcher, int64(3), guardsMatcher).
+			Once().
+			Return(int64(4), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_PatchEntity(t *testing.T) {
+	t.Run("fail patching path causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`, "Content-Type": "application/merge-patch+json"},
+			Body:           `{"path":"/news"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`, "Content-Type": "application/merge-patch+json"},
+			Body:           `{"title":"News"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		opsMatcher := mock.MatchedBy(func(ops []patch.Operation) bool {
+			return len(ops) == 3 && ops[0].Path.Field() == "title" && ops[1].Path.Field() == "updated_at"
+		})
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Patch", ctx, pageKey("abc", "def"), int64(3), opsMatcher, mock.AnythingOfType("*main.page")).
+			Once().
+			Return(int64(4), nil)
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+}
+
+func TestHandler_DeleteEntity(t *testing.T) {
+	t.Run("fail deleting page with children causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusConflict
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, mock.AnythingOfType("dynamo.Query"), childrenModifier("ghi")).
+			Once().
+			Return(dynamo.Page{}, nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNoContent
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, mock.AnythingOfType("dynamo.Query"), mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("DeleteGuarded", ctx, pageKey("abc", "def"), []dynamo.Key{pathGuardKey("abc", "/blog")}).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w published page", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNoContent
+		expectedGuards := []dynamo.Key{pathGuardKey("abc", "/blog"), snapshotKey("abc", "def"), publishedPathKey("abc", "/news")}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Query", ctx, mock.AnythingOfType("dynamo.Query"), mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), snapshotModifier("def", "/news")).
+			Once().
+			Return(true, nil)
+		repoMock.On("DeleteGuarded", ctx, pageKey("abc", "def"), expectedGuards).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_PublishEntity(t *testing.T) {
+	t.Run("fail missing entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/publish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.PublishEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail path published by another page causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/publish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusConflict
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("PutGuarded", ctx, mock.AnythingOfType("main.snapshot"), mock.AnythingOfType("dynamo.Guards")).
+			Once().
+			Return(lhttp.NewProblem(http.StatusConflict, "unique value already taken"))
+
+		// execute
+		res, err := sut.PublishEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success first publish", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/publish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedGuards := dynamo.Guards{
+			Add:    []interface{}{pathGuard{WebsiteID: "abc", SK: publishedPathType + "/blog", PageID: "def"}},
+			Remove: nil,
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("PutGuarded", ctx, mock.AnythingOfType("main.snapshot"), expectedGuards).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.PublishEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"version":3`)
+		assert.Contains(t, res.Body, `"published_by":"anonymous"`)
+		assert.NotContains(t, res.Body, publishedPageType)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w same path keeps published path", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/publish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedGuards := dynamo.Guards{Add: nil, Remove: nil, Keep: nil}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), snapshotModifier("def", "/blog")).
+			Once().
+			Return(true, nil)
+		repoMock.On("PutGuarded", ctx, mock.AnythingOfType("main.snapshot"), expectedGuards).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.PublishEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w moved page replaces published path", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/publish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedGuards := dynamo.Guards{
+			Add:    []interface{}{pathGuard{WebsiteID: "abc", SK: publishedPathType + "/blog", PageID: "def"}},
+			Remove: []dynamo.Key{publishedPathKey("abc", "/news")},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), snapshotModifier("def", "/news")).
+			Once().
+			Return(true, nil)
+		repoMock.On("PutGuarded", ctx, mock.AnythingOfType("main.snapshot"), expectedGuards).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.PublishEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_UnpublishEntity(t *testing.T) {
+	t.Run("fail unpublished entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/unpublish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.UnpublishEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/unpublish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNoContent
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), snapshotModifier("def", "/news")).
+			Once().
+			Return(true, nil)
+		repoMock.On("DeleteGuarded", ctx, snapshotKey("abc", "def"), []dynamo.Key{publishedPathKey("abc", "/news")}).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.UnpublishEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RetrieveRevisions(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/revisions",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		revisionsStub := []revision.Revision{{ID: "ghi", Version: 2, Data: json.RawMessage(`{"title":"Blog"}`)}}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, _, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		revisionsMock.On("List", ctx, "abc", "PAGE#def", defaultLimit, dynamo.Key(nil)).
+			Once().
+			Return(revisionsStub, dynamo.Page{}, nil)
+
+		// execute
+		res, err := sut.RetrieveRevisions(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"data":{"title":"Blog"}`)
+		revisionsMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RetrieveRevision(t *testing.T) {
+	t.Run("fail missing revision causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/revisions/ghi",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def", "rev": "ghi"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, _, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		revisionsMock.On("Get", ctx, "abc", "PAGE#def", "ghi").
+			Once().
+			Return(revision.Revision{}, lhttp.NewProblem(http.StatusNotFound, "revision not found in storage: ghi"))
+
+		// execute
+		res, err := sut.RetrieveRevision(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+}
+
+func TestHandler_RestoreRevision(t *testing.T) {
+	t.Run("fail missing entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/revisions/ghi/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def", "rev": "ghi"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		revisionsMock.AssertExpectations(t)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/revisions/ghi/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def", "rev": "ghi"},
+		}
+		revisionStub := revision.Revision{
+			ID:      "ghi",
+			Version: 1,
+			Data:    json.RawMessage(`{"website_id":"abc","id":"def","path":"/blog","title":"Old blog","body":"Old posts","version":1}`),
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedETag := `"4"`
+
+		// system under test
+		sut, repoMock, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		pageMatcher := mock.MatchedBy(func(input page) bool {
+			return input.Title == "Old blog" && input.Body == "Old posts" && input.CreatedBy == "alice" && input.SK == "PAGE#def"
+		})
+		repoMock.On("Find", ctx, crud.WebsiteKey("abc"), storedWebsiteModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+		revisionsMock.On("Get", ctx, "abc", "PAGE#def", "ghi").
+			Once().
+			Return(revisionStub, nil)
+		repoMock.On("Update", ctx, pageMatcher, int64(3)).
+			Once().
+			Return(int64(4), nil)
+		revisionsMock.On("Record", ctx, "abc", "PAGE#def", int64(4), "anonymous", pageMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, expectedETag, res.Headers["ETag"])
+		repoMock.AssertExpectations(t)
+		revisionsMock.AssertExpectations(t)
+	})
+}
+
+func TestParentPath(t *testing.T) {
+	t.Parallel()
+
+	for pathStub, expected := range map[string]string{
+		"/":                 "",
+		"/blog":             "",
+		"/blog/2022":        "/blog",
+		"/blog/2022/hello":  "/blog/2022",
+		"/blog/2022/hel.lo": "/blog/2022",
+	} {
+		assert.Equal(t, expected, parentPath(pathStub), pathStub)
+	}
+}
+
+// createTestHandler creates a handler recording revisions successfully, see createTestRevisionsHandler to expect them.
+func createTestHandler() (*Handler, *mocks.Repo) {
+	repoMock := &mocks.Repo{}
+	revisionsMock := &mocks.Revisions{}
+	revisionsMock.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Maybe().
+		Return(nil)
+	revisionsMock.On("Purge", mock.Anything, mock.Anything, mock.Anything).
+		Maybe().
+		Return(nil)
+
+	sut := NewHandler(repoMock, cursor.NewCodec([]byte("secret")), revisionsMock)
+
+	return sut, repoMock
+}
+
+func createTestRevisionsHandler() (*Handler, *mocks.Repo, *mocks.Revisions) {
+	repoMock := &mocks.Repo{}
+	revisionsMock := &mocks.Revisions{}
+
+	sut := NewHandler(repoMock, cursor.NewCodec([]byte("secret")), revisionsMock)
+
+	return sut, repoMock, revisionsMock
+}
+
+// storedWebsiteModifier fills the website read from the repository with an active website.
+func storedWebsiteModifier(id string) interface{} {
+	return mock.MatchedBy(func(input *crud.Website) bool {
+		*input = crud.Website{ID: id, DeletedAt: nil}
+
+		return true
+	})
+}
+
+// storedPageModifier fills the page read from the repository with a stored page of website abc created by alice.
+func storedPageModifier(id, pagePath, parentID string) interface{} {
+	return mock.MatchedBy(func(input *page) bool {
+		createdAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+		*input = page{
+			WebsiteID: "abc",
+			ID:        id,
+			ParentID:  parentID,
+			Path:      pagePath,
+			Title:     "Blog",
+			Meta: crud.Meta{
+				Fields:  audit.Fields{CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "alice", UpdatedBy: "alice"},
+				Version: 3,
+			},
+		}
+
+		return true
+	})
+}
+
+// pathGuardModifier fills the path guard read from the repository with a guard held by the given page.
+func pathGuardModifier(pageID string) interface{} {
+	return mock.MatchedBy(func(input *pathGuard) bool {
+		input.PageID = pageID
+
+		return true
+	})
+}
+
+// snapshotModifier fills the snapshot read from the repository with a page of website abc published at the given path.
+func snapshotModifier(id, pagePath string) interface{} {
+	return mock.MatchedBy(func(input *snapshot) bool {
+		*input = snapshot{
+			WebsiteID:   "abc",
+			SK:          publishedPageType + id,
+			ID:          id,
+			ParentID:    "",
+			Path:        pagePath,
+			Title:       "Blog",
+			Body:        "",
+			TemplateID:  "",
+			Version:     2,
+			PublishedAt: time.Date(2022, 6, 2, 10, 0, 0, 0, time.UTC),
+			PublishedBy: "alice",
+		}
+
+		return true
+	})
+}
+
+// childrenModifier fills the children read from the repository with a single page.
+func childrenModifier(id string) interface{} {
+	return mock.MatchedBy(func(input *[]page) bool {
+		*input = []page{{WebsiteID: "abc", ID: id}}
+
+		return true
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/abtercms/abtercms2/pkg/crud"
+)
+
+const (
+	defaultLimit int32 = 25
+	minLimit     int32 = 1
+	maxLimit     int32 = 100
+	limitParam         = "limit"
+
+	// collection is the path segment of pages in the path of their website.
+	collection = "pages"
+
+	// idParam is the path parameter holding the id of a page.
+	idParam = "id"
+
+	// pageType prefixes the sort key of pages.
+	pageType = "PAGE#"
+
+	// templateType prefixes the sort key of templates.
+	templateType = "TEMPLATE#"
+
+	// templateField is the field of a page referring to its layout.
+	templateField = "template_id"
+
+	// pathType prefixes the sort key of the guards reserving the paths of pages.
+	pathType = "PATH#"
+
+	// publishedPageType prefixes the sort key of the published snapshots of pages.
+	publishedPageType = "PUBLISHED#PAGE#"
+
+	// publishedPathType prefixes the sort key of the guards reserving the paths of published snapshots.
+	publishedPathType = "PUBLISHED#PATH#"
+
+	// scheduleType prefixes the sort key of the items due when a page is to be published or unpublished.
+	scheduleType = "SCHEDULE#"
+
+	// dueType is the partition of the index listing scheduled items by the time they are due.
+	dueType = "DUE"
+
+	// dueFormat is the format of due times in sort keys, UTC to the second so that they sort chronologically.
+	dueFormat = "2006-01-02T15:04:05Z"
+
+	actionPublish   = "publish"
+	actionUnpublish = "unpublish"
+
+	// childrenType prefixes the partition of the index listing the children of a page.
+	childrenType = "CHILDREN#"
+
+	// rootPath is the path of the home page of a website.
+	rootPath = "/"
+
+	errUnmarshallParams = "failed to unmarshal the request, query: %v"
+	errInvalidLimit     = "limit %d is out of range, it must be between %d and %d"
+	errUnsupportedParam = "query parameter is not supported: %s"
+	errPageNotFound     = "page not found in storage: %s"
+	errPageHasChildren  = "page has children, move or delete them first: %s"
+	errParentNotFound   = "parent page does not exist: %s"
+	errTemplateNotFound = "template does not exist: %s"
+	errPageNotPublished = "page is not published: %s"
+
+	reasonUnpublishBeforePublish = "must be after publish_at"
+	reasonBelowItself            = "must not be below the current path of the page: %s"
+)
+
+func main() {
+	env := crud.Bootstrap(context.TODO())
+
+	lambda.Start(NewRouter(NewHandler(env.Table, env.Cursors, env.Revisions)).Handler)
+}
+
+type handler interface {
+	RetrieveCollection(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	CreateEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	UpdateEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	PatchEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	DeleteEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveChildren(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	PublishEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	UnpublishEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveRevisions(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveRevision(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RestoreRevision(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}
+
+func NewRouter(h handler) *lmdrouter.Router {
+	router := crud.NewRouter(collection, idParam, crud.Routes{
+		RetrieveCollection: h.RetrieveCollection,
+		CreateEntity:       h.CreateEntity,
+		RetrieveEntity:     h.RetrieveEntity,
+		UpdateEntity:       h.UpdateEntity,
+		PatchEntity:        h.PatchEntity,
+		DeleteEntity:       h.DeleteEntity,
+		RetrieveRevisions:  h.RetrieveRevisions,
+		RetrieveRevision:   h.RetrieveRevision,
+		RestoreRevision:    h.RestoreRevision,
+	})
+	router.Route(http.MethodGet, "/:website/pages/:id/children", h.RetrieveChildren)
+	router.Route(http.MethodPost, "/:website/pages/:id/publish", h.PublishEntity)
+	router.Route(http.MethodPost, "/:website/pages/:id/unpublish", h.UnpublishEntity)
+
+	return router
+}
@@ -0,0 +1,411 @@
+//go:generate mockery-latest --all --exported --case underscore
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abtercms/abtercms2/pages/mocks"
+)
+
+func TestRouter(t *testing.T) {
+	// hack needed because zerolog gets a global log builder
+	{
+		l := log.Logger
+
+		log.Logger = zerolog.Nop()
+		defer func() {
+			log.Logger = l
+		}()
+	}
+
+	t.Run("retrieve collection", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("RetrieveCollection", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("create entity", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("CreateEntity", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("retrieve entity", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("RetrieveEntity", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("update entity", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("UpdateEntity", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("patch entity", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("PatchEntity", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("delete entity", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("DeleteEntity", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("retrieve children", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/children",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("RetrieveChildren", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("publish entity", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/publish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("PublishEntity", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("unpublish entity", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/unpublish",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("UnpublishEntity", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("retrieve revisions", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/revisions",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("RetrieveRevisions", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("retrieve revision", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/revisions/ghi",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def", "rev": "ghi"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("RetrieveRevision", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("restore revision", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/pages/def/revisions/ghi/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def", "rev": "ghi"},
+		}
+		expectedStatus := http.StatusAccepted
+		responseStub := events.APIGatewayProxyResponse{
+			StatusCode: expectedStatus,
+		}
+
+		// mocks
+		handlerMock := &mocks.Handler{}
+		handlerMock.On("RestoreRevision", ctx, requestStub).
+			Once().
+			Return(responseStub, nil)
+
+		// system under test
+		sut := NewRouter(handlerMock)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+}
@@ -0,0 +1,249 @@
+// Package attr for comparing and copying DynamoDB attribute values the way DynamoDB does
+package attr
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Equal compares attribute values like the = comparator of DynamoDB, numbers are equal if their values are.
+func Equal(a, b types.AttributeValue) bool {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		y, ok := b.(*types.AttributeValueMemberS)
+
+		return ok && x.Value == y.Value
+	case *types.AttributeValueMemberN:
+		y, ok := b.(*types.AttributeValueMemberN)
+
+		return ok && compareNumbers(x.Value, y.Value) == 0
+	case *types.AttributeValueMemberB:
+		y, ok := b.(*types.AttributeValueMemberB)
+
+		return ok && bytes.Equal(x.Value, y.Value)
+	case *types.AttributeValueMemberBOOL:
+		y, ok := b.(*types.AttributeValueMemberBOOL)
+
+		return ok && x.Value == y.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := b.(*types.AttributeValueMemberNULL)
+
+		return ok
+	case *types.AttributeValueMemberL:
+		y, ok := b.(*types.AttributeValueMemberL)
+
+		return ok && equalLists(x.Value, y.Value)
+	case *types.AttributeValueMemberM:
+		y, ok := b.(*types.AttributeValueMemberM)
+
+		return ok && equalDocuments(x.Value, y.Value)
+	case *types.AttributeValueMemberSS:
+		y, ok := b.(*types.AttributeValueMemberSS)
+
+		return ok && equalSets(x.Value, y.Value)
+	case *types.AttributeValueMemberNS:
+		y, ok := b.(*types.AttributeValueMemberNS)
+
+		return ok && len(x.Value) == len(y.Value) && containsAll(y.Value, x.Value, containsNumber)
+	case *types.AttributeValueMemberBS:
+		y, ok := b.(*types.AttributeValueMemberBS)
+
+		return ok && len(x.Value) == len(y.Value) && containsAll(y.Value, x.Value, containsBinary)
+	default:
+		return false
+	}
+}
+
+func equalLists(a, b []types.AttributeValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalDocuments(a, b map[string]types.AttributeValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for name, value := range a {
+		if !Equal(value, b[name]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	members := make(map[string]bool, len(a))
+	for _, member := range a {
+		members[member] = true
+	}
+
+	for _, member := range b {
+		if !members[member] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Compare orders strings, numbers and binaries like the <, <=, > and >= comparators of DynamoDB.
+// The second return value is false if the values can not be compared, e.g. because their types differ.
+func Compare(a, b types.AttributeValue) (int, bool) {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		y, ok := b.(*types.AttributeValueMemberS)
+		if !ok {
+			return 0, false
+		}
+
+		return strings.Compare(x.Value, y.Value), true
+	case *types.AttributeValueMemberN:
+		y, ok := b.(*types.AttributeValueMemberN)
+		if !ok {
+			return 0, false
+		}
+
+		return compareNumbers(x.Value, y.Value), true
+	case *types.AttributeValueMemberB:
+		y, ok := b.(*types.AttributeValueMemberB)
+		if !ok {
+			return 0, false
+		}
+
+		return bytes.Compare(x.Value, y.Value), true
+	default:
+		return 0, false
+	}
+}
+
+// compareNumbers compares numbers in the string representation DynamoDB uses for them.
+func compareNumbers(a, b string) int {
+	x, _, errX := big.ParseFloat(a, 10, 128, big.ToNearestEven)
+	y, _, errY := big.ParseFloat(b, 10, 128, big.ToNearestEven)
+
+	if errX != nil || errY != nil {
+		return strings.Compare(a, b)
+	}
+
+	return x.Cmp(y)
+}
+
+// Contains tells whether a string contains a substring or a set or list contains an element,
+// like the contains function of DynamoDB.
+func Contains(haystack, needle types.AttributeValue) bool {
+	switch h := haystack.(type) {
+	case *types.AttributeValueMemberS:
+		n, ok := needle.(*types.AttributeValueMemberS)
+
+		return ok && strings.Contains(h.Value, n.Value)
+	case *types.AttributeValueMemberSS:
+		n, ok := needle.(*types.AttributeValueMemberS)
+
+		return ok && containsString(h.Value, n.Value)
+	case *types.AttributeValueMemberNS:
+		n, ok := needle.(*types.AttributeValueMemberN)
+
+		return ok && containsNumber(h.Value, n.Value)
+	case *types.AttributeValueMemberBS:
+		n, ok := needle.(*types.AttributeValueMemberB)
+
+		return ok && containsBinary(h.Value, n.Value)
+	case *types.AttributeValueMemberL:
+		for _, element := range h.Value {
+			if Equal(element, needle) {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return false
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsNumber(values []string, value string) bool {
+	for _, v := range values {
+		if compareNumbers(v, value) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsBinary(values [][]byte, value []byte) bool {
+	for _, v := range values {
+		if bytes.Equal(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsAll tells whether a set contains every member of another set.
+func containsAll[T any](set, members []T, contains func([]T, T) bool) bool {
+	for _, member := range members {
+		if !contains(set, member) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CloneItem copies an item deep enough for the copy to be changed without affecting the original.
+func CloneItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	clone := make(map[string]types.AttributeValue, len(item))
+
+	for name, value := range item {
+		clone[name] = Clone(value)
+	}
+
+	return clone
+}
+
+// Clone copies documents and lists, other attribute values are never changed in place.
+func Clone(value types.AttributeValue) types.AttributeValue {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: CloneItem(v.Value)}
+	case *types.AttributeValueMemberL:
+		list := make([]types.AttributeValue, len(v.Value))
+		for i, element := range v.Value {
+			list[i] = Clone(element)
+		}
+
+		return &types.AttributeValueMemberL{Value: list}
+	default:
+		return value
+	}
+}
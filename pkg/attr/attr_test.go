@@ -0,0 +1,137 @@
+package attr_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+)
+
+func s(value string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: value}
+}
+
+func n(value string) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: value}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b types.AttributeValue
+		want bool
+	}{
+		{name: "equal strings", a: s("foo"), b: s("foo"), want: true},
+		{name: "numbers of equal value", a: n("1.50"), b: n("1.5"), want: true},
+		{name: "string and number", a: s("1"), b: n("1"), want: false},
+		{
+			name: "sets in another order",
+			a:    &types.AttributeValueMemberNS{Value: []string{"1", "2.0"}},
+			b:    &types.AttributeValueMemberNS{Value: []string{"2", "1"}},
+			want: true,
+		},
+		{
+			name: "lists in another order",
+			a:    &types.AttributeValueMemberL{Value: []types.AttributeValue{s("a"), s("b")}},
+			b:    &types.AttributeValueMemberL{Value: []types.AttributeValue{s("b"), s("a")}},
+			want: false,
+		},
+		{
+			name: "documents",
+			a:    &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"a": n("1")}},
+			b:    &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"a": n("1.0")}},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// execute
+			got := attr.Equal(tt.a, tt.b)
+
+			// asserts
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   types.AttributeValue
+		want   int
+		wantOK bool
+	}{
+		{name: "strings", a: s("a"), b: s("b"), want: -1, wantOK: true},
+		{name: "numbers by value", a: n("10"), b: n("9"), want: 1, wantOK: true},
+		{name: "binaries", a: &types.AttributeValueMemberB{Value: []byte{2}}, b: &types.AttributeValueMemberB{Value: []byte{1}}, want: 1, wantOK: true},
+		{name: "different types", a: s("1"), b: n("1"), want: 0, wantOK: false},
+		{name: "booleans", a: &types.AttributeValueMemberBOOL{}, b: &types.AttributeValueMemberBOOL{}, want: 0, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// execute
+			got, ok := attr.Compare(tt.a, tt.b)
+
+			// asserts
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		name             string
+		haystack, needle types.AttributeValue
+		want             bool
+	}{
+		{name: "substring", haystack: s("foobar"), needle: s("oba"), want: true},
+		{name: "string set", haystack: &types.AttributeValueMemberSS{Value: []string{"a", "b"}}, needle: s("b"), want: true},
+		{name: "number set by value", haystack: &types.AttributeValueMemberNS{Value: []string{"1.0"}}, needle: n("1"), want: true},
+		{name: "list", haystack: &types.AttributeValueMemberL{Value: []types.AttributeValue{n("1")}}, needle: s("1"), want: false},
+		{name: "number", haystack: n("12"), needle: n("1"), want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// execute
+			got := attr.Contains(tt.haystack, tt.needle)
+
+			// asserts
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCloneItem(t *testing.T) {
+	// stubs
+	item := map[string]types.AttributeValue{
+		"meta": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"title": s("foo")}},
+		"tags": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("a")}},
+	}
+
+	// execute
+	clone := attr.CloneItem(item)
+	clone["meta"].(*types.AttributeValueMemberM).Value["title"] = s("bar")
+	clone["tags"].(*types.AttributeValueMemberL).Value[0] = s("b")
+
+	// asserts
+	assert.Equal(t, s("foo"), item["meta"].(*types.AttributeValueMemberM).Value["title"])
+	assert.Equal(t, s("a"), item["tags"].(*types.AttributeValueMemberL).Value[0])
+}
@@ -0,0 +1,89 @@
+// Package audit for tracking when and by whom entities were created and changed
+package audit
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/abtercms/abtercms2/pkg/id"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	// Anonymous is the actor recorded for requests without an identity.
+	Anonymous = "anonymous"
+
+	errInvalidID = "failed to derive creation time from id: %s"
+)
+
+// Fields are the audit fields of an entity.
+// They are managed by the server, clients can read them but never change them.
+type Fields struct {
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+	CreatedBy string    `json:"created_by" dynamodbav:"created_by"`
+	UpdatedBy string    `json:"updated_by" dynamodbav:"updated_by"`
+}
+
+// Create returns the audit fields of an entity created by actor.
+// The creation time is derived from the ULID of the entity, so that both always agree.
+func Create(entityID, actor string) (Fields, error) {
+	createdAt, err := id.Time(entityID)
+	if err != nil {
+		return Fields{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errInvalidID, entityID)
+	}
+
+	return Fields{
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		CreatedBy: actor,
+		UpdatedBy: actor,
+	}, nil
+}
+
+// New returns the audit fields of an entity created by actor at the given time.
+// It is meant for entities identified by a name instead of a ULID, others should use Create.
+func New(actor string, now time.Time) Fields {
+	now = now.UTC()
+
+	return Fields{
+		CreatedAt: now,
+		UpdatedAt: now,
+		CreatedBy: actor,
+		UpdatedBy: actor,
+	}
+}
+
+// Update returns the audit fields of an entity changed by actor at the given time, keeping the creation fields.
+func (f Fields) Update(actor string, now time.Time) Fields {
+	f.UpdatedAt = now.UTC()
+	f.UpdatedBy = actor
+
+	return f
+}
+
+// Actor returns the identity of the caller of a request.
+// Authorizer claims take precedence over the IAM or Cognito identity of the caller.
+func Actor(req events.APIGatewayProxyRequest) string {
+	if claims, ok := req.RequestContext.Authorizer["claims"].(map[string]interface{}); ok {
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			return sub
+		}
+	}
+
+	if principalID, ok := req.RequestContext.Authorizer["principalId"].(string); ok && principalID != "" {
+		return principalID
+	}
+
+	identity := req.RequestContext.Identity
+
+	for _, actor := range []string{identity.CognitoIdentityID, identity.UserArn, identity.User} {
+		if actor != "" {
+			return actor
+		}
+	}
+
+	return Anonymous
+}
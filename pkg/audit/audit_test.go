@@ -0,0 +1,130 @@
+package audit_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail invalid id causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		_, err := audit.Create("foo", "bar")
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		createdAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+		idStub := ulid.MustNew(ulid.Timestamp(createdAt), ulid.DefaultEntropy()).String()
+
+		// execute
+		got, err := audit.Create(idStub, "bar")
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, audit.Fields{CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "bar", UpdatedBy: "bar"}, got)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	// stubs
+	createdAt := time.Date(2022, 6, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	// expectations
+	expectedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	// execute
+	got := audit.New("bar", createdAt)
+
+	// asserts
+	assert.Equal(t, audit.Fields{CreatedAt: expectedAt, UpdatedAt: expectedAt, CreatedBy: "bar", UpdatedBy: "bar"}, got)
+}
+
+func TestFields_Update(t *testing.T) {
+	t.Parallel()
+
+	// stubs
+	createdAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2022, 6, 2, 10, 0, 0, 0, time.UTC)
+	sut := audit.Fields{CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "foo", UpdatedBy: "foo"}
+
+	// execute
+	got := sut.Update("bar", updatedAt)
+
+	// asserts
+	assert.Equal(t, audit.Fields{CreatedAt: createdAt, UpdatedAt: updatedAt, CreatedBy: "foo", UpdatedBy: "bar"}, got)
+}
+
+func TestActor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		context events.APIGatewayProxyRequestContext
+		want    string
+	}{
+		{
+			name: "cognito user pool claims",
+			context: events.APIGatewayProxyRequestContext{
+				Authorizer: map[string]interface{}{
+					"claims":      map[string]interface{}{"sub": "foo"},
+					"principalId": "bar",
+				},
+			},
+			want: "foo",
+		},
+		{
+			name: "lambda authorizer principal",
+			context: events.APIGatewayProxyRequestContext{
+				Authorizer: map[string]interface{}{"principalId": "bar"},
+			},
+			want: "bar",
+		},
+		{
+			name: "iam identity",
+			context: events.APIGatewayProxyRequestContext{
+				Identity: events.APIGatewayRequestIdentity{UserArn: "arn:aws:iam::123456789012:user/baz", User: "baz"},
+			},
+			want: "arn:aws:iam::123456789012:user/baz",
+		},
+		{
+			name:    "anonymous",
+			context: events.APIGatewayProxyRequestContext{},
+			want:    audit.Anonymous,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// execute
+			got := audit.Actor(events.APIGatewayProxyRequest{RequestContext: tt.context})
+
+			// asserts
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
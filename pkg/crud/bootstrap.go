@@ -0,0 +1,95 @@
+package crud
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/abtercms/abtercms2/pkg/cursor"
+	"github.com/abtercms/abtercms2/pkg/revision"
+	"github.com/abtercms/abtercms2/pkg/table"
+)
+
+const (
+	EnvAwsRegion                = "AWS_REGION"
+	EnvTableName                = "TABLE_NAME"
+	EnvAwsSamLocal              = "AWS_SAM_LOCAL"
+	EnvAwsDynamoDBLocalEndpoint = "AWS_DYNAMODB_LOCAL_ENDPOINT"
+	EnvDatabaseURL              = "DATABASE_URL"
+	EnvRepository               = "REPOSITORY"
+	EnvCursorSecret             = "CURSOR_SECRET"
+
+	trueString = "true"
+)
+
+// Env is what the function serving a resource is built from, see Bootstrap.
+type Env struct {
+	// Local tells whether the function is run by SAM CLI, which emulates neither DynamoDB nor S3.
+	Local     bool
+	SDKConfig aws.Config
+	Table     table.Store
+	Cursors   *cursor.Codec
+	Revisions *revision.Store
+}
+
+// Bootstrap reads the environment shared by the functions serving resources, then opens the table along with the codec
+// of pagination cursors and the store of revisions. Functions cannot serve any request without them, therefore a
+// missing cursor secret or a table which cannot be opened exits the function.
+func Bootstrap(ctx context.Context) Env {
+	var (
+		awsRegion        = os.Getenv(EnvAwsRegion)
+		tableName        = os.Getenv(EnvTableName)
+		cursorSecret     = os.Getenv(EnvCursorSecret)
+		databaseURL      = os.Getenv(EnvDatabaseURL)
+		local            = os.Getenv(EnvAwsSamLocal) == trueString
+		dynamoDBEndpoint = ""
+		repository       = ""
+	)
+
+	if local {
+		dynamoDBEndpoint = os.Getenv(EnvAwsDynamoDBLocalEndpoint)
+		repository = os.Getenv(EnvRepository)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+
+	if cursorSecret == "" {
+		log.Fatal().
+			Str(EnvTableName, tableName).
+			Msg("cursor secret is required to sign pagination cursors")
+	}
+
+	sdkConfig, err := config.LoadDefaultConfig(ctx, func(o *config.LoadOptions) error {
+		o.Region = awsRegion
+
+		return nil
+	})
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvAwsRegion, awsRegion).
+			Str(EnvTableName, tableName).
+			Msg("cannot establish connection with dynamodb")
+	}
+
+	repo, err := table.Open(ctx, sdkConfig, tableName, dynamoDBEndpoint, databaseURL, repository)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvTableName, tableName).
+			Msg("cannot open the table")
+	}
+
+	return Env{
+		Local:     local,
+		SDKConfig: sdkConfig,
+		Table:     repo,
+		Cursors:   cursor.NewCodec([]byte(cursorSecret)),
+		Revisions: revision.NewStore(repo, revision.DefaultLimit),
+	}
+}
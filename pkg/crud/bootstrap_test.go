@@ -0,0 +1,55 @@
+package crud_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/memory"
+	"github.com/abtercms/abtercms2/pkg/table"
+)
+
+func TestBootstrap(t *testing.T) {
+	t.Run("success memory repository run locally", func(t *testing.T) {
+		// stubs
+		ctx := context.Background()
+
+		t.Setenv(crud.EnvAwsRegion, "eu-central-1")
+		t.Setenv(crud.EnvTableName, "websites")
+		t.Setenv(crud.EnvAwsSamLocal, "true")
+		t.Setenv(crud.EnvRepository, table.RepositoryMemory)
+		t.Setenv(crud.EnvCursorSecret, "secret")
+
+		// execute
+		got := crud.Bootstrap(ctx)
+
+		// asserts
+		assert.True(t, got.Local)
+		assert.IsType(t, &memory.Store{}, got.Table)
+		require.NotNil(t, got.Cursors)
+		assert.NotNil(t, got.Revisions)
+	})
+
+	t.Run("success repository ignored when not run locally", func(t *testing.T) {
+		// stubs
+		ctx := context.Background()
+
+		t.Setenv(crud.EnvAwsRegion, "eu-central-1")
+		t.Setenv(crud.EnvTableName, "websites")
+		t.Setenv(crud.EnvAwsSamLocal, "")
+		t.Setenv(crud.EnvRepository, table.RepositoryMemory)
+		t.Setenv(crud.EnvDatabaseURL, "")
+		t.Setenv(crud.EnvCursorSecret, "secret")
+
+		// execute
+		got := crud.Bootstrap(ctx)
+
+		// asserts
+		assert.False(t, got.Local)
+		assert.IsType(t, &dynamo.Repo{}, got.Table)
+	})
+}
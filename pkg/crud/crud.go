@@ -0,0 +1,167 @@
+// Package crud for resources stored in the partition of their website, which are declared instead of hand written
+//
+// Pages, templates, blocks and media are served by it. Websites are not: they are the partitions resources are stored
+// in rather than resources of a website, they are trashed and restored instead of deleted and their hostnames are
+// guarded across websites, which leaves nothing of Handler to share.
+package crud
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/revision"
+)
+
+const (
+	// DefaultLimit is the page size of collections if clients request none.
+	DefaultLimit int32 = 25
+	minLimit     int32 = 1
+	maxLimit     int32 = 100
+	cursorParam        = "cursor"
+	limitParam         = "limit"
+
+	// websiteParam is the path parameter holding the website owning a resource.
+	websiteParam = "website"
+
+	// revisionParam is the path parameter holding the id of a revision.
+	revisionParam = "rev"
+
+	// websiteType is the sort key of websites, resources are stored in the partition of their website.
+	websiteType = "WEBSITE"
+
+	errUnmarshallBody             = "failed to unmarshal the request, body: %s"
+	errUnmarshallParams           = "failed to unmarshal the request, query: %v"
+	errInvalidIDDetail            = "value in path: \"%s\", in payload: \"%s\", err: %s"
+	errInvalidID                  = "received %ss are invalid."
+	errPrimaryKeyNotAllowedDetail = "primary key: \"%s\", err: %w"
+	errFieldNotPatchable          = "field can not be patched: %s"
+	errInvalidLimit               = "limit %d is out of range, it must be between %d and %d"
+	errUnsupportedParam           = "query parameter is not supported: %s"
+	errWebsiteNotFound            = "website not found in storage: %s"
+	errEntityNotFound             = "%s not found in storage: %s"
+)
+
+var errPrimaryKeyNotAllowed = lhttp.NewProblem(http.StatusBadRequest, "primary key is not allowed when creating entity.")
+
+// Meta holds the storage keys, the audit fields and the version every resource managed by a Handler has.
+// Resources embed it, therefore its fields are promoted to the resource in Go, JSON and DynamoDB alike.
+type Meta struct {
+	dynamo.Keys
+	audit.Fields
+	Version int64 `json:"version" dynamodbav:"version"`
+}
+
+// Base returns the metadata of a resource, resources embedding Meta implement it via their pointers.
+func (m *Meta) Base() *Meta {
+	return m
+}
+
+// Entity is the constraint of the pointers to resources managed by a Handler.
+type Entity[T any] interface {
+	*T
+	Base() *Meta
+	// Identity returns the website owning the entity and the id of the entity within the website.
+	Identity() (websiteID, id string)
+	// SetIdentity sets the website owning the entity and the id of the entity.
+	SetIdentity(websiteID, id string)
+}
+
+// Resource declares a resource served by a Handler.
+// Hooks are optional, a resource without Patchable can not be patched at all.
+// Resources with unique values or relations to other records, such as pages, save guards along with their entities
+// via Guard and Deleting.
+type Resource[T any] struct {
+	// Name is the name of the resource in messages, e.g. "template".
+	Name string
+	// Type prefixes the sort key of the resource, e.g. "TEMPLATE#", it is followed by the id of the entity.
+	Type string
+	// IDParam is the path parameter holding the id of an entity, e.g. "id".
+	IDParam string
+	// Key returns the table key of an entity of a website.
+	Key func(websiteID, id string) dynamo.Key
+	// GenerateID tells whether ids are generated on creation, otherwise clients choose them in the payload.
+	GenerateID bool
+	// Validate checks an entity beyond its validation rules before it is saved.
+	Validate func(entity T) error
+	// Patchable tells whether clients may change a field via PATCH.
+	Patchable func(field string) bool
+	// ValidatePatch checks the operations of a patch of an entity of a website beyond the validation rules of the fields
+	// changed.
+	ValidatePatch func(ctx context.Context, websiteID string, ops []patch.Operation) error
+	// Prepare sets the fields managed by the server on an entity about to be created.
+	Prepare func(entity *T)
+	// Guard completes an entity about to be created, or to replace stored on updates, and returns the guards saved
+	// along with it. Stored is nil on creation.
+	Guard func(ctx context.Context, entity *T, stored *T) (dynamo.Guards, error)
+	// Deleting checks whether a stored entity may be deleted and returns the keys of the guards deleted along with it.
+	Deleting func(ctx context.Context, stored T) ([]dynamo.Key, error)
+	// Present completes an entity before it is returned to clients, the request is given to point them back to the API.
+	Present func(req events.APIGatewayProxyRequest, entity T) T
+	// Deleted cleans up after an entity was deleted.
+	Deleted func(ctx context.Context, websiteID, id string) error
+}
+
+// Website holds the fields of a website needed to tell whether resources may be added to it.
+type Website struct {
+	ID        string     `dynamodbav:"pk"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at,omitempty"`
+}
+
+// WebsiteKey returns the table key of a website.
+func WebsiteKey(websiteID string) dynamo.Key {
+	return dynamo.K2(websiteID, websiteType)
+}
+
+type listParams struct {
+	WebsiteID string `lambda:"path.website"` // a path parameter declared as :website
+	Cursor    string `lambda:"query.cursor"` // a query parameter named "cursor"
+	Limit     int32  `lambda:"query.limit"`  // a query parameter named "limit"
+}
+
+type listResponse struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	HasMore    bool        `json:"has_more"`
+}
+
+// Repository is the storage of the entities of a resource, usually a dynamo.GuardedRepo.
+// Get reports missing entities via an error wrapping dynamo.ErrNotFound.
+// The guarded methods are only used for resources whose hooks return guards.
+type Repository[T any] interface {
+	Get(ctx context.Context, key dynamo.Key) (T, error)
+	Query(ctx context.Context, query dynamo.Query) ([]T, dynamo.Page, error)
+	Create(ctx context.Context, item T) error
+	Update(ctx context.Context, item T, version int64) (int64, error)
+	Patch(ctx context.Context, key dynamo.Key, version int64, ops []patch.Operation) (T, int64, error)
+	Delete(ctx context.Context, key dynamo.Key) error
+	CreateGuarded(ctx context.Context, item T, guards dynamo.Guards) error
+	UpdateGuarded(ctx context.Context, item T, version int64, guards dynamo.Guards) (int64, error)
+	DeleteGuarded(ctx context.Context, key dynamo.Key, guards []dynamo.Key) error
+}
+
+// Websites reads the websites owning resources.
+// Get reports missing websites via an error wrapping dynamo.ErrNotFound.
+type Websites interface {
+	Get(ctx context.Context, key dynamo.Key) (Website, error)
+}
+
+// Cursors encodes and decodes the cursors of paginated collections, cursors are bound to the query they continue.
+type Cursors interface {
+	Encode(query dynamo.Query, key dynamo.Key) (string, error)
+	Decode(query dynamo.Query, cursor string) (dynamo.Key, error)
+}
+
+// Revisions records the history of resources.
+type Revisions interface {
+	Record(ctx context.Context, partition, sortKey string, version int64, actor string, entity interface{}) error
+	List(ctx context.Context, partition, sortKey string, limit int32, exclusiveStartKey dynamo.Key) ([]revision.Revision, dynamo.Page, error)
+	Get(ctx context.Context, partition, sortKey, revisionID string) (revision.Revision, error)
+	Purge(ctx context.Context, partition, sortKey string) error
+}
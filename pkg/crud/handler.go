@@ -0,0 +1,635 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/id"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/revision"
+	"github.com/abtercms/abtercms2/pkg/validate"
+)
+
+const (
+	headerContentType = "Content-Type"
+
+	errRevisionsNotKept = "revisions are not kept for %ss"
+)
+
+// isListParam tells whether a query parameter is supported when retrieving a collection.
+func isListParam(name string) bool {
+	return name == cursorParam || name == limitParam
+}
+
+// pageLimit returns the page size requested by the client, falling back to DefaultLimit if none was requested.
+func pageLimit(req events.APIGatewayProxyRequest, params listParams) (int32, error) {
+	for name := range req.QueryStringParameters {
+		if !isListParam(name) {
+			return 0, lhttp.NewProblem(http.StatusBadRequest, errUnsupportedParam, name)
+		}
+	}
+
+	if _, ok := req.QueryStringParameters[limitParam]; !ok {
+		return DefaultLimit, nil
+	}
+
+	if params.Limit < minLimit || params.Limit > maxLimit {
+		return 0, lhttp.NewProblem(http.StatusBadRequest, errInvalidLimit, params.Limit, minLimit, maxLimit)
+	}
+
+	return params.Limit, nil
+}
+
+// Handler is a collection of handlers of a resource.
+// Every saved version of an entity is recorded as a revision, which the entity can be rolled back to,
+// unless the handler was created without revisions.
+type Handler[T any, P Entity[T]] struct {
+	resource  Resource[T]
+	repo      Repository[T]
+	websites  Websites
+	cursors   Cursors
+	revisions Revisions
+}
+
+// NewHandler creates a new Handler instance of a resource, revisions may be nil to keep no history.
+func NewHandler[T any, P Entity[T]](resource Resource[T], repo Repository[T], websites Websites, cursors Cursors, revisions Revisions) *Handler[T, P] {
+	return &Handler[T, P]{
+		resource:  resource,
+		repo:      repo,
+		websites:  websites,
+		cursors:   cursors,
+		revisions: revisions,
+	}
+}
+
+// pathIDs returns the website and the id of the entity in the path of a request.
+func (h *Handler[T, P]) pathIDs(req events.APIGatewayProxyRequest) (string, string) {
+	return req.PathParameters[websiteParam], req.PathParameters[h.resource.IDParam]
+}
+
+// invalidID returns the problem of an id missing from the path or differing from the one in the payload.
+func (h *Handler[T, P]) invalidID(pathID, payloadID string) error {
+	problem := lhttp.NewProblem(http.StatusBadRequest, errInvalidID, h.resource.IDParam)
+
+	return lhttp.WrapProblem(problem, http.StatusBadRequest, errInvalidIDDetail, pathID, payloadID, problem.Error())
+}
+
+// present completes an entity before it is returned to the client of a request.
+func (h *Handler[T, P]) present(req events.APIGatewayProxyRequest, entity T) T {
+	if h.resource.Present == nil {
+		return entity
+	}
+
+	return h.resource.Present(req, entity)
+}
+
+// validate checks an entity against its validation rules, then against the validation hook of the resource.
+func (h *Handler[T, P]) validate(entity T) error {
+	err := validate.Struct(entity)
+	if err != nil || h.resource.Validate == nil {
+		return err
+	}
+
+	return h.resource.Validate(entity)
+}
+
+// get reads an entity, missing entities result in a 404 not found.
+func (h *Handler[T, P]) get(ctx context.Context, websiteID, entityID string) (T, error) {
+	entity, err := h.repo.Get(ctx, h.resource.Key(websiteID, entityID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return entity, lhttp.WrapProblem(err, http.StatusNotFound, errEntityNotFound, h.resource.Name, entityID)
+	}
+
+	return entity, err
+}
+
+// checkWebsite makes sure a website exists and has not been deleted, otherwise it results in a 404 not found.
+func (h *Handler[T, P]) checkWebsite(ctx context.Context, websiteID string) error {
+	owner, err := h.websites.Get(ctx, WebsiteKey(websiteID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotFound, websiteID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if owner.DeletedAt != nil {
+		return lhttp.NewProblem(http.StatusNotFound, errWebsiteNotFound, websiteID)
+	}
+
+	return nil
+}
+
+// setKeys sets the storage keys of an entity, resources are listed via the table itself.
+func (h *Handler[T, P]) setKeys(entity P) {
+	_, entityID := entity.Identity()
+
+	entity.Base().Keys = dynamo.Keys{
+		SK:     h.resource.Type + entityID,
+		GSI1PK: "",
+		GSI1SK: "",
+	}
+}
+
+// guard runs the guard hook of the resource on an entity about to be saved, resources without it save no guards.
+func (h *Handler[T, P]) guard(ctx context.Context, entity, stored *T) (dynamo.Guards, error) {
+	if h.resource.Guard == nil {
+		return dynamo.Guards{Add: nil, Remove: nil, Keep: nil}, nil
+	}
+
+	return h.resource.Guard(ctx, entity, stored)
+}
+
+// isUnguarded tells whether an entity is saved without guards, which spares the transaction.
+func isUnguarded(guards dynamo.Guards) bool {
+	return len(guards.Add) == 0 && len(guards.Remove) == 0 && len(guards.Keep) == 0
+}
+
+// create stores a new entity along with the guards returned by the guard hook of the resource.
+func (h *Handler[T, P]) create(ctx context.Context, entity *T) error {
+	guards, err := h.guard(ctx, entity, nil)
+	if err != nil {
+		return err
+	}
+
+	if isUnguarded(guards) {
+		return h.repo.Create(ctx, *entity)
+	}
+
+	return h.repo.CreateGuarded(ctx, *entity, guards)
+}
+
+// delete deletes an existing entity, missing entities result in a 404 not found like when retrieving them.
+// Resources with a deleting hook have their stored entity checked and their guards deleted along with it.
+func (h *Handler[T, P]) delete(ctx context.Context, websiteID, entityID string) error {
+	key := h.resource.Key(websiteID, entityID)
+
+	stored, err := h.get(ctx, websiteID, entityID)
+	if err != nil {
+		return err
+	}
+
+	if h.resource.Deleting == nil {
+		return h.repo.Delete(ctx, key)
+	}
+
+	guards, err := h.resource.Deleting(ctx, stored)
+	if err != nil {
+		return err
+	}
+
+	if len(guards) == 0 {
+		return h.repo.Delete(ctx, key)
+	}
+
+	return h.repo.DeleteGuarded(ctx, key, guards)
+}
+
+// record records the revision of an entity saved, it does nothing if the handler keeps no history.
+func (h *Handler[T, P]) record(ctx context.Context, websiteID, entityID, actor string, entity T) error {
+	if h.revisions == nil {
+		return nil
+	}
+
+	return h.revisions.Record(ctx, websiteID, h.resource.Type+entityID, P(&entity).Base().Version, actor, entity)
+}
+
+// RetrieveCollection is a handler to retrieve every entity of a website in the order of their ids.
+func (h *Handler[T, P]) RetrieveCollection(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params listParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	pageSize, err := pageLimit(req, params)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	query := dynamo.Query{
+		Index:             "",
+		Partition:         params.WebsiteID,
+		SortKey:           dynamo.SortKeyBeginsWith(h.resource.Type),
+		Filters:           nil,
+		Limit:             pageSize,
+		ExclusiveStartKey: nil,
+		Descending:        false,
+	}
+
+	query.ExclusiveStartKey, err = h.cursors.Decode(query, params.Cursor)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	collection, page, err := h.repo.Query(ctx, query)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	for i := range collection {
+		collection[i] = h.present(req, collection[i])
+	}
+
+	nextCursor, err := h.cursors.Encode(query, page.Next)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, listResponse{Items: collection, NextCursor: nextCursor, HasMore: page.HasMore})
+}
+
+// CreateEntity is a handler to create a new entity in a website which has not been deleted.
+// Ids chosen by clients which are already taken in the website result in a 409 conflict.
+func (h *Handler[T, P]) CreateEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var (
+		entity T
+		fields audit.Fields
+	)
+
+	err := lmdrouter.UnmarshalRequest(req, true, &entity)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallBody, req.Body), nil)
+	}
+
+	websiteID, _ := h.pathIDs(req)
+	_, entityID := P(&entity).Identity()
+
+	if h.resource.GenerateID && entityID != "" {
+		return lhttp.HandleError(fmt.Errorf(errPrimaryKeyNotAllowedDetail, entityID, errPrimaryKeyNotAllowed), nil)
+	}
+
+	err = h.validate(entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.checkWebsite(ctx, websiteID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	actor := audit.Actor(req)
+
+	if h.resource.GenerateID {
+		entityID = id.NewGenerator().NewString()
+
+		fields, err = audit.Create(entityID, actor)
+		if err != nil {
+			return lhttp.HandleError(err, nil)
+		}
+	} else {
+		fields = audit.New(actor, time.Now())
+	}
+
+	P(&entity).SetIdentity(websiteID, entityID)
+	P(&entity).Base().Fields = fields
+	P(&entity).Base().Version = dynamo.InitialVersion
+	h.setKeys(&entity)
+
+	if h.resource.Prepare != nil {
+		h.resource.Prepare(&entity)
+	}
+
+	err = h.create(ctx, &entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.record(ctx, websiteID, entityID, actor, entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusCreated, lhttp.ETagHeaders(P(&entity).Base().Version), h.present(req, entity))
+}
+
+// RetrieveEntity is a handler to retrieve an entity.
+func (h *Handler[T, P]) RetrieveEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	websiteID, entityID := h.pathIDs(req)
+	if entityID == "" {
+		return lhttp.HandleError(h.invalidID(entityID, ""), nil)
+	}
+
+	entity, err := h.get(ctx, websiteID, entityID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(P(&entity).Base().Version), h.present(req, entity))
+}
+
+// UpdateEntity is a handler to update an existing entity of a website which has not been deleted, the id in the payload
+// must match the one in the path.
+func (h *Handler[T, P]) UpdateEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var entity T
+
+	err := lmdrouter.UnmarshalRequest(req, true, &entity)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallBody, req.Body), nil)
+	}
+
+	websiteID, entityID := h.pathIDs(req)
+	_, payloadID := P(&entity).Identity()
+
+	if entityID == "" || entityID != payloadID {
+		return lhttp.HandleError(h.invalidID(entityID, payloadID), nil)
+	}
+
+	version, err := lhttp.IfMatch(req.Headers)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.validate(entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.checkWebsite(ctx, websiteID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	// audit fields are read-only, therefore they are taken from the stored entity instead of the request
+	stored, err := h.get(ctx, websiteID, entityID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	if version == lhttp.AnyVersion {
+		version = P(&stored).Base().Version
+	}
+
+	entity, err = h.update(ctx, audit.Actor(req), entity, stored, version)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(P(&entity).Base().Version), h.present(req, entity))
+}
+
+// update replaces a stored entity by a validated entity, along with the guards returned by the guard hook of the
+// resource, and records the revision saved.
+func (h *Handler[T, P]) update(ctx context.Context, actor string, entity, stored T, version int64) (T, error) {
+	websiteID, entityID := P(&stored).Identity()
+
+	P(&entity).SetIdentity(websiteID, entityID)
+	P(&entity).Base().Fields = P(&stored).Base().Fields.Update(actor, time.Now())
+	h.setKeys(&entity)
+
+	guards, err := h.guard(ctx, &entity, &stored)
+	if err != nil {
+		return entity, err
+	}
+
+	if isUnguarded(guards) {
+		P(&entity).Base().Version, err = h.repo.Update(ctx, entity, version)
+	} else {
+		P(&entity).Base().Version, err = h.repo.UpdateGuarded(ctx, entity, version, guards)
+	}
+
+	if err != nil {
+		return entity, err
+	}
+
+	return entity, h.record(ctx, websiteID, entityID, actor, entity)
+}
+
+// PatchEntity is a handler to partially update an existing entity of a website which has not been deleted using JSON
+// Merge Patch or JSON Patch.
+func (h *Handler[T, P]) PatchEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var entity T
+
+	websiteID, entityID := h.pathIDs(req)
+	if entityID == "" {
+		return lhttp.HandleError(h.invalidID(entityID, ""), nil)
+	}
+
+	version, err := lhttp.IfMatch(req.Headers)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	contentType, _ := lhttp.Header(req.Headers, headerContentType)
+
+	ops, err := patch.Parse(contentType, []byte(req.Body))
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	for _, op := range ops {
+		if h.resource.Patchable == nil || !h.resource.Patchable(op.Path.Field()) {
+			return lhttp.HandleError(lhttp.NewProblem(http.StatusUnprocessableEntity, errFieldNotPatchable, op.Path.String()), nil)
+		}
+	}
+
+	err = validate.Patch(entity, ops)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	if h.resource.ValidatePatch != nil {
+		err = h.resource.ValidatePatch(ctx, websiteID, ops)
+		if err != nil {
+			return lhttp.HandleError(err, nil)
+		}
+	}
+
+	err = h.checkWebsite(ctx, websiteID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	version, err = h.checkStored(ctx, websiteID, entityID, version, ops)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	actor := audit.Actor(req)
+
+	ops = append(ops,
+		patch.Operation{Op: patch.OpSet, Path: patch.Path{"updated_at"}, Value: time.Now().UTC()},
+		patch.Operation{Op: patch.OpSet, Path: patch.Path{"updated_by"}, Value: actor},
+	)
+
+	entity, version, err = h.repo.Patch(ctx, h.resource.Key(websiteID, entityID), version, ops)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	P(&entity).Base().Version = version
+
+	err = h.record(ctx, websiteID, entityID, actor, entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(P(&entity).Base().Version), h.present(req, entity))
+}
+
+// checkStored checks a patch against the stored entity and returns the version it is conditioned on. The values
+// removed by a JSON Patch must exist, and If-Match: * conditions the patch on the version stored, patches needing
+// neither spare reading the entity. The version condition makes sure the entity checked is the one patched.
+func (h *Handler[T, P]) checkStored(ctx context.Context, websiteID, entityID string, version int64, ops []patch.Operation) (int64, error) {
+	if version != lhttp.AnyVersion && !patch.HasRemovals(ops) {
+		return version, nil
+	}
+
+	stored, err := h.get(ctx, websiteID, entityID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = patch.CheckRemovals(stored, ops)
+	if err != nil {
+		return 0, err
+	}
+
+	if version == lhttp.AnyVersion {
+		version = P(&stored).Base().Version
+	}
+
+	return version, nil
+}
+
+// DeleteEntity is a handler to delete an existing entity of a website which has not been deleted along with its revisions.
+func (h *Handler[T, P]) DeleteEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	websiteID, entityID := h.pathIDs(req)
+
+	err := h.checkWebsite(ctx, websiteID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.delete(ctx, websiteID, entityID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	if h.revisions != nil {
+		err = h.revisions.Purge(ctx, websiteID, h.resource.Type+entityID)
+		if err != nil {
+			return lhttp.HandleError(err, nil)
+		}
+	}
+
+	if h.resource.Deleted != nil {
+		err = h.resource.Deleted(ctx, websiteID, entityID)
+		if err != nil {
+			return lhttp.HandleError(err, nil)
+		}
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusNoContent, nil, nil)
+}
+
+// RetrieveRevisions is a handler to retrieve the revisions of an entity, latest first.
+func (h *Handler[T, P]) RetrieveRevisions(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params listParams
+
+	if h.revisions == nil {
+		return lhttp.HandleError(lhttp.NewProblem(http.StatusNotFound, errRevisionsNotKept, h.resource.Name), nil)
+	}
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	limit, err := pageLimit(req, params)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	websiteID, entityID := h.pathIDs(req)
+	scope := revision.ListQuery(websiteID, h.resource.Type+entityID)
+
+	exclusiveStartKey, err := h.cursors.Decode(scope, params.Cursor)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	collection, page, err := h.revisions.List(ctx, websiteID, h.resource.Type+entityID, limit, exclusiveStartKey)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	nextCursor, err := h.cursors.Encode(scope, page.Next)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, listResponse{Items: collection, NextCursor: nextCursor, HasMore: page.HasMore})
+}
+
+// RetrieveRevision is a handler to retrieve a revision of an entity.
+func (h *Handler[T, P]) RetrieveRevision(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if h.revisions == nil {
+		return lhttp.HandleError(lhttp.NewProblem(http.StatusNotFound, errRevisionsNotKept, h.resource.Name), nil)
+	}
+
+	websiteID, entityID := h.pathIDs(req)
+
+	entity, err := h.revisions.Get(ctx, websiteID, h.resource.Type+entityID, req.PathParameters[revisionParam])
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, entity)
+}
+
+// RestoreRevision is a handler to roll an entity of a website which has not been deleted back to one of its revisions,
+// which is saved as the latest version.
+func (h *Handler[T, P]) RestoreRevision(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var entity T
+
+	if h.revisions == nil {
+		return lhttp.HandleError(lhttp.NewProblem(http.StatusNotFound, errRevisionsNotKept, h.resource.Name), nil)
+	}
+
+	websiteID, entityID := h.pathIDs(req)
+
+	err := h.checkWebsite(ctx, websiteID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	stored, err := h.get(ctx, websiteID, entityID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	saved, err := h.revisions.Get(ctx, websiteID, h.resource.Type+entityID, req.PathParameters[revisionParam])
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = saved.Decode(&entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.validate(entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	entity, err = h.update(ctx, audit.Actor(req), entity, stored, P(&stored).Base().Version)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(P(&entity).Base().Version), h.present(req, entity))
+}
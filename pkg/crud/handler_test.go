@@ -0,0 +1,788 @@
+package crud_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/cursor"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/mocks"
+)
+
+const (
+	noteType = "NOTE#"
+
+	// lockedText is the text of notes which can not be deleted.
+	lockedText = "locked"
+)
+
+// note is a resource identified by a name chosen by clients.
+type note struct {
+	crud.Meta
+	WebsiteID string `json:"website_id" dynamodbav:"pk"`
+	Name      string `json:"name" dynamodbav:"name" validate:"required,max=10"`
+	Text      string `json:"text" dynamodbav:"text"`
+	Seen      bool   `json:"seen" dynamodbav:"-"`
+}
+
+func (n *note) Identity() (string, string) {
+	return n.WebsiteID, n.Name
+}
+
+func (n *note) SetIdentity(websiteID, name string) {
+	n.WebsiteID = websiteID
+	n.Name = name
+}
+
+func noteKey(websiteID, name string) dynamo.Key {
+	return dynamo.K2(websiteID, noteType+name)
+}
+
+// textGuard reserves the text of a note within its website.
+type textGuard struct {
+	WebsiteID string `dynamodbav:"pk"`
+	SK        string `dynamodbav:"sk"`
+}
+
+func textGuardKey(websiteID, text string) dynamo.Key {
+	return dynamo.K2(websiteID, "TEXT#"+text)
+}
+
+func TestHandler_RetrieveCollection(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success presents every entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+		query := dynamo.Query{
+			Index:             "",
+			Partition:         "abc",
+			SortKey:           dynamo.SortKeyBeginsWith(noteType),
+			Filters:           nil,
+			Limit:             crud.DefaultLimit,
+			ExclusiveStartKey: nil,
+			Descending:        false,
+		}
+
+		// system under test
+		sut, repoMock, _, _ := createTestHandler(nil)
+
+		// mocks
+		repoMock.On("Query", ctx, query).
+			Once().
+			Return([]note{{WebsiteID: "abc", Name: "foo"}, {WebsiteID: "abc", Name: "bar"}}, dynamo.Page{Next: nil, HasMore: false}, nil)
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"foo","text":"","seen":true`)
+		assert.Contains(t, res.Body, `"name":"bar","text":"","seen":true`)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_CreateEntity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail deleted website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"foo","text":"bar"}`,
+		}
+
+		// system under test
+		sut, repoMock, websitesMock, _ := createTestHandler(nil)
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{}, lhttp.WrapProblem(dynamo.ErrNotFound, http.StatusNotFound, "foo"))
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success with name chosen by client", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"foo","text":"bar"}`,
+		}
+
+		// system under test
+		revisionsMock := &mocks.Revisions{}
+		sut, repoMock, websitesMock, _ := createTestHandler(revisionsMock)
+
+		// mocks
+		noteMatcher := mock.MatchedBy(func(input note) bool {
+			return input.WebsiteID == "abc" && input.SK == "NOTE#foo" && input.Version == dynamo.InitialVersion && input.CreatedBy == "anonymous"
+		})
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Create", ctx, noteMatcher).
+			Once().
+			Return(nil)
+		revisionsMock.On("Record", ctx, "abc", "NOTE#foo", dynamo.InitialVersion, "anonymous", noteMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Contains(t, res.Body, `"seen":true`)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+		revisionsMock.AssertExpectations(t)
+	})
+
+	t.Run("success saves guards returned by guard hook", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"foo","text":"bar"}`,
+		}
+		guardsStub := dynamo.Guards{Add: []interface{}{textGuard{WebsiteID: "abc", SK: "TEXT#bar"}}, Remove: nil, Keep: nil}
+
+		// system under test
+		sut, repoMock, websitesMock := createTestGuardedHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("CreateGuarded", ctx, mock.AnythingOfType("crud_test.note"), guardsStub).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_UpdateEntity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail deleted website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		deletedAt := time.Now()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+			Headers:        map[string]string{"If-Match": `"2"`},
+			Body:           `{"name":"foo","text":"bar"}`,
+		}
+
+		// system under test
+		sut, repoMock, websitesMock, _ := createTestHandler(nil)
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: &deletedAt}, nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success replaces guards of changed text", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+			Headers:        map[string]string{"If-Match": `"2"`},
+			Body:           `{"name":"foo","text":"baz"}`,
+		}
+		storedStub := note{WebsiteID: "abc", Name: "foo", Text: "bar"}
+		guardsStub := dynamo.Guards{
+			Add:    []interface{}{textGuard{WebsiteID: "abc", SK: "TEXT#baz"}},
+			Remove: []dynamo.Key{dynamo.K2("abc", "TEXT#bar")},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, repoMock, websitesMock := createTestGuardedHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(storedStub, nil)
+		repoMock.On("UpdateGuarded", ctx, mock.AnythingOfType("crud_test.note"), int64(2), guardsStub).
+			Once().
+			Return(int64(3), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `"3"`, res.Headers["ETag"])
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success w/o guards updates entity alone", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+			Headers:        map[string]string{"If-Match": `"2"`},
+			Body:           `{"name":"foo","text":"bar"}`,
+		}
+		storedStub := note{WebsiteID: "abc", Name: "foo", Text: "bar"}
+
+		// system under test
+		sut, repoMock, websitesMock := createTestGuardedHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(storedStub, nil)
+		repoMock.On("Update", ctx, mock.AnythingOfType("crud_test.note"), int64(2)).
+			Once().
+			Return(int64(3), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w any version updates the version stored", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+			Headers:        map[string]string{"If-Match": "*"},
+			Body:           `{"name":"foo","text":"bar"}`,
+		}
+		storedStub := note{Meta: crud.Meta{Version: 4}, WebsiteID: "abc", Name: "foo", Text: "bar"}
+
+		// system under test
+		sut, repoMock, websitesMock := createTestGuardedHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(storedStub, nil)
+		repoMock.On("Update", ctx, mock.AnythingOfType("crud_test.note"), int64(4)).
+			Once().
+			Return(int64(5), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `"5"`, res.Headers["ETag"])
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_PatchEntity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail deleted website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		deletedAt := time.Now()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+			Headers:        map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `"1"`},
+			Body:           `{"text":"bar"}`,
+		}
+
+		// system under test
+		sut, repoMock, websitesMock, _ := createTestHandler(nil)
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: &deletedAt}, nil)
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("fail removing missing value causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+			Headers:        map[string]string{"Content-Type": "application/json-patch+json", "If-Match": `"1"`},
+			Body:           `[{"op":"remove","path":"/text/bar"}]`,
+		}
+
+		// system under test
+		sut, repoMock, websitesMock, _ := createTestHandler(nil)
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(note{WebsiteID: "abc", Name: "foo", Text: "bar"}, nil)
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Contains(t, res.Body, `"invalid_params":[{"name":"/text/bar"`)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("fail field not patchable causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+			Headers:        map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `"1"`},
+			Body:           `{"name":"bar"}`,
+		}
+
+		// system under test
+		sut, repoMock, _, _ := createTestHandler(nil)
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_DeleteEntity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail deleted website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		deletedAt := time.Now()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+		}
+
+		// system under test
+		sut, repoMock, websitesMock, deleted := createTestHandler(nil)
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: &deletedAt}, nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		assert.Empty(t, *deleted)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success calls deleted hook", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+		}
+
+		// system under test
+		sut, repoMock, websitesMock, deleted := createTestHandler(nil)
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(note{WebsiteID: "abc", Name: "foo", Text: "bar"}, nil)
+		repoMock.On("Delete", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Equal(t, []string{"abc/foo"}, *deleted)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("fail missing entity causes 404 not found w/o calling deleted hook", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+		}
+
+		// system under test
+		sut, repoMock, websitesMock, deleted := createTestHandler(nil)
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(note{}, dynamo.ErrNotFound)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		assert.Empty(t, *deleted)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("fail deleting hook refusing causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+		}
+		storedStub := note{WebsiteID: "abc", Name: "foo", Text: lockedText}
+
+		// system under test
+		sut, repoMock, websitesMock := createTestGuardedHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(storedStub, nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success deletes guards returned by deleting hook", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "name": "foo"},
+		}
+		storedStub := note{WebsiteID: "abc", Name: "foo", Text: "bar"}
+
+		// system under test
+		sut, repoMock, websitesMock := createTestGuardedHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, noteKey("abc", "foo")).
+			Once().
+			Return(storedStub, nil)
+		repoMock.On("DeleteGuarded", ctx, noteKey("abc", "foo"), []dynamo.Key{dynamo.K2("abc", "TEXT#bar")}).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RestoreRevision(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail deleted website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		deletedAt := time.Now()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo/revisions/def/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "name": "foo", "rev": "def"},
+		}
+
+		// system under test
+		revisionsMock := &mocks.Revisions{}
+		sut, repoMock, websitesMock, _ := createTestHandler(revisionsMock)
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: &deletedAt}, nil)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+		revisionsMock.AssertExpectations(t)
+	})
+
+	t.Run("fail handler without revisions causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/notes/foo/revisions/def/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "name": "foo", "rev": "def"},
+		}
+
+		// system under test
+		sut, repoMock, _, _ := createTestHandler(nil)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+// createTestHandler creates a handler of notes along with its mocks, revisions are only kept if revisionsMock is not nil.
+// The keys of deleted notes are collected by the deleted hook.
+func createTestHandler(revisionsMock *mocks.Revisions) (*crud.Handler[note, *note], *mocks.Repository[note], *mocks.Websites, *[]string) {
+	var (
+		repoMock     = &mocks.Repository[note]{}
+		websitesMock = &mocks.Websites{}
+		deleted      = &[]string{}
+		revisions    crud.Revisions
+	)
+
+	if revisionsMock != nil {
+		revisions = revisionsMock
+	}
+
+	resource := crud.Resource[note]{
+		Name:       "note",
+		Type:       noteType,
+		IDParam:    "name",
+		Key:        noteKey,
+		GenerateID: false,
+		Validate:   nil,
+		Patchable: func(field string) bool {
+			return field == "text"
+		},
+		ValidatePatch: nil,
+		Prepare:       nil,
+		Guard:         nil,
+		Deleting:      nil,
+		Present: func(_ events.APIGatewayProxyRequest, entity note) note {
+			entity.Seen = true
+
+			return entity
+		},
+		Deleted: func(ctx context.Context, websiteID, name string) error {
+			*deleted = append(*deleted, websiteID+"/"+name)
+
+			return nil
+		},
+	}
+
+	sut := crud.NewHandler[note](resource, repoMock, websitesMock, cursor.NewCodec([]byte("secret")), revisions)
+
+	return sut, repoMock, websitesMock, deleted
+}
+
+// createTestGuardedHandler creates a handler of notes whose texts are unique within their website, along with its mocks.
+// Notes with the locked text can not be deleted.
+func createTestGuardedHandler() (*crud.Handler[note, *note], *mocks.Repository[note], *mocks.Websites) {
+	var (
+		repoMock     = &mocks.Repository[note]{}
+		websitesMock = &mocks.Websites{}
+	)
+
+	resource := crud.Resource[note]{
+		Name:          "note",
+		Type:          noteType,
+		IDParam:       "name",
+		Key:           noteKey,
+		GenerateID:    false,
+		Validate:      nil,
+		Patchable:     nil,
+		ValidatePatch: nil,
+		Prepare:       nil,
+		Guard: func(ctx context.Context, entity, stored *note) (dynamo.Guards, error) {
+			guards := dynamo.Guards{Add: nil, Remove: nil, Keep: nil}
+
+			if stored == nil || stored.Text != entity.Text {
+				guards.Add = []interface{}{textGuard{WebsiteID: entity.WebsiteID, SK: "TEXT#" + entity.Text}}
+			}
+
+			if stored != nil && stored.Text != entity.Text {
+				guards.Remove = []dynamo.Key{textGuardKey(stored.WebsiteID, stored.Text)}
+			}
+
+			return guards, nil
+		},
+		Deleting: func(ctx context.Context, stored note) ([]dynamo.Key, error) {
+			if stored.Text == lockedText {
+				return nil, lhttp.NewProblem(http.StatusConflict, "note is locked: %s", stored.Name)
+			}
+
+			return []dynamo.Key{textGuardKey(stored.WebsiteID, stored.Text)}, nil
+		},
+		Present: nil,
+		Deleted: nil,
+	}
+
+	sut := crud.NewHandler[note](resource, repoMock, websitesMock, cursor.NewCodec([]byte("secret")), nil)
+
+	return sut, repoMock, websitesMock
+}
@@ -0,0 +1,56 @@
+package crud
+
+import (
+	"net/http"
+
+	"github.com/aquasecurity/lmdrouter"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+// basePath prefixes the paths of resources, followed by the website owning them.
+const basePath = "/websites"
+
+// Routes are the handlers of a resource, handlers which are nil are not routed.
+type Routes struct {
+	RetrieveCollection lmdrouter.Handler
+	CreateEntity       lmdrouter.Handler
+	RetrieveEntity     lmdrouter.Handler
+	UpdateEntity       lmdrouter.Handler
+	PatchEntity        lmdrouter.Handler
+	DeleteEntity       lmdrouter.Handler
+	RetrieveRevisions  lmdrouter.Handler
+	RetrieveRevision   lmdrouter.Handler
+	RestoreRevision    lmdrouter.Handler
+}
+
+// NewRouter creates a router serving a collection of a resource in the path of its website,
+// e.g. /websites/:website/templates for the collection and /websites/:website/templates/:id for an entity.
+func NewRouter(collection, idParam string, routes Routes) *lmdrouter.Router {
+	var (
+		router         = lmdrouter.NewRouter(basePath, lhttp.LoggerMiddleware)
+		collectionPath = "/:" + websiteParam + "/" + collection
+		entityPath     = collectionPath + "/:" + idParam
+		revisionsPath  = entityPath + "/revisions"
+		revisionPath   = revisionsPath + "/:" + revisionParam
+	)
+
+	route(router, http.MethodGet, collectionPath, routes.RetrieveCollection)
+	route(router, http.MethodPost, collectionPath, routes.CreateEntity)
+	route(router, http.MethodGet, entityPath, routes.RetrieveEntity)
+	route(router, http.MethodPut, entityPath, routes.UpdateEntity)
+	route(router, http.MethodPatch, entityPath, routes.PatchEntity)
+	route(router, http.MethodDelete, entityPath, routes.DeleteEntity)
+	route(router, http.MethodGet, revisionsPath, routes.RetrieveRevisions)
+	route(router, http.MethodGet, revisionPath, routes.RetrieveRevision)
+	route(router, http.MethodPost, revisionPath+"/restore", routes.RestoreRevision)
+
+	return router
+}
+
+// route adds a handler to a router unless it is nil.
+func route(router *lmdrouter.Router, method, path string, h lmdrouter.Handler) {
+	if h != nil {
+		router.Route(method, path, h)
+	}
+}
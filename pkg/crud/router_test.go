@@ -0,0 +1,95 @@
+package crud_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abtercms/abtercms2/pkg/crud"
+)
+
+func TestNewRouter(t *testing.T) {
+	// hack needed because zerolog gets a global log builder
+	{
+		l := log.Logger
+
+		log.Logger = zerolog.Nop()
+		defer func() {
+			log.Logger = l
+		}()
+	}
+
+	respond := func(status int) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{StatusCode: status}, nil
+		}
+	}
+
+	t.Run("routes entity in the path of its website", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/abc/notes/foo/revisions/def/restore",
+			HTTPMethod: http.MethodPost,
+		}
+		routes := crud.Routes{
+			RetrieveCollection: nil,
+			CreateEntity:       nil,
+			RetrieveEntity:     nil,
+			UpdateEntity:       nil,
+			PatchEntity:        nil,
+			DeleteEntity:       nil,
+			RetrieveRevisions:  nil,
+			RetrieveRevision:   nil,
+			RestoreRevision:    respond(http.StatusAccepted),
+		}
+
+		// system under test
+		sut := crud.NewRouter("notes", "name", routes)
+
+		// execute
+		res, err := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	})
+
+	t.Run("skips nil handlers", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/abc/notes/foo",
+			HTTPMethod: http.MethodPut,
+		}
+		routes := crud.Routes{
+			RetrieveCollection: respond(http.StatusOK),
+			CreateEntity:       respond(http.StatusCreated),
+			RetrieveEntity:     respond(http.StatusOK),
+			UpdateEntity:       nil,
+			PatchEntity:        nil,
+			DeleteEntity:       respond(http.StatusNoContent),
+			RetrieveRevisions:  nil,
+			RetrieveRevision:   nil,
+			RestoreRevision:    nil,
+		}
+
+		// system under test
+		sut := crud.NewRouter("notes", "name", routes)
+
+		// execute
+		res, _ := sut.Handler(ctx, requestStub)
+
+		// asserts
+		assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	})
+}
@@ -0,0 +1,185 @@
+// Package cursor for opaque, tamper-proof pagination cursors
+package cursor
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	errEncodingCursor = "failed to encode cursor"
+	errInvalidCursor  = "invalid cursor"
+	errUnsupportedKey = "unsupported key attribute: %s"
+)
+
+var (
+	errSignatureMismatch = errors.New("cursor signature mismatch")
+	errTooShort          = errors.New("cursor too short")
+	errQueryMismatch     = errors.New("cursor belongs to another query")
+)
+
+// attribute is the serialized form of a key attribute, keys only ever contain strings, numbers or binaries.
+type attribute struct {
+	S *string `json:"s,omitempty"`
+	N *string `json:"n,omitempty"`
+	B []byte  `json:"b,omitempty"`
+}
+
+// payload is the signed content of a cursor.
+type payload struct {
+	Query []byte               `json:"q"`
+	Key   map[string]attribute `json:"k"`
+}
+
+// scope holds the parts of a query a cursor is bound to, the page size may change from one page to the next.
+type scope struct {
+	Index      string                   `json:"i"`
+	Partition  string                   `json:"p"`
+	SortKey    *dynamo.SortKeyCondition `json:"s"`
+	Filters    []dynamo.Filter          `json:"f"`
+	Descending bool                     `json:"d"`
+}
+
+// Codec converts keys to cursors and back.
+// Cursors are signed but not encrypted, therefore clients can read the keys they carry but cannot forge them.
+// Cursors are bound to the query they continue, they are rejected by queries of other partitions, filters or orders.
+type Codec struct {
+	secret []byte
+}
+
+// NewCodec creates a new Codec instance.
+func NewCodec(secret []byte) *Codec {
+	return &Codec{
+		secret: secret,
+	}
+}
+
+// Encode converts the key a query continues after to a cursor, an empty key results in an empty cursor.
+func (c *Codec) Encode(query dynamo.Query, key dynamo.Key) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	attributes := make(map[string]attribute, len(key))
+
+	for name, value := range key {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			attributes[name] = attribute{S: &v.Value, N: nil, B: nil}
+		case *types.AttributeValueMemberN:
+			attributes[name] = attribute{S: nil, N: &v.Value, B: nil}
+		case *types.AttributeValueMemberB:
+			attributes[name] = attribute{S: nil, N: nil, B: v.Value}
+		default:
+			return "", lhttp.NewProblem(http.StatusInternalServerError, errUnsupportedKey, name)
+		}
+	}
+
+	fingerprint, err := fingerprintOf(query)
+	if err != nil {
+		return "", lhttp.WrapProblem(err, http.StatusInternalServerError, errEncodingCursor)
+	}
+
+	content, err := json.Marshal(payload{Query: fingerprint, Key: attributes})
+	if err != nil {
+		return "", lhttp.WrapProblem(err, http.StatusInternalServerError, errEncodingCursor)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(append(c.sign(content), content...)), nil
+}
+
+// Decode converts a cursor back to the key the query continues after, an empty cursor results in an empty key.
+// Cursors produced by another query result in a 400 bad request.
+func (c *Codec) Decode(query dynamo.Query, cursor string) (dynamo.Key, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusBadRequest, errInvalidCursor)
+	}
+
+	if len(raw) <= sha256.Size {
+		return nil, lhttp.WrapProblem(errTooShort, http.StatusBadRequest, errInvalidCursor)
+	}
+
+	signature, content := raw[:sha256.Size], raw[sha256.Size:]
+	if !hmac.Equal(signature, c.sign(content)) {
+		return nil, lhttp.WrapProblem(errSignatureMismatch, http.StatusBadRequest, errInvalidCursor)
+	}
+
+	var p payload
+
+	err = json.Unmarshal(content, &p)
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusBadRequest, errInvalidCursor)
+	}
+
+	fingerprint, err := fingerprintOf(query)
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusInternalServerError, errInvalidCursor)
+	}
+
+	if !bytes.Equal(p.Query, fingerprint) {
+		return nil, lhttp.WrapProblem(errQueryMismatch, http.StatusBadRequest, errInvalidCursor)
+	}
+
+	return keyOf(p.Key)
+}
+
+// keyOf converts the serialized attributes of a cursor back to a key.
+func keyOf(attributes map[string]attribute) (dynamo.Key, error) {
+	key := make(dynamo.Key, len(attributes))
+
+	for name, a := range attributes {
+		switch {
+		case a.S != nil:
+			key[name] = &types.AttributeValueMemberS{Value: *a.S}
+		case a.N != nil:
+			key[name] = &types.AttributeValueMemberN{Value: *a.N}
+		case a.B != nil:
+			key[name] = &types.AttributeValueMemberB{Value: a.B}
+		default:
+			return nil, lhttp.WrapProblem(fmt.Errorf(errUnsupportedKey, name), http.StatusBadRequest, errInvalidCursor) // nolint: goerr113
+		}
+	}
+
+	return key, nil
+}
+
+// fingerprintOf hashes the parts of a query a cursor is bound to, which keeps cursors short whatever the filters.
+func fingerprintOf(query dynamo.Query) ([]byte, error) {
+	content, err := json.Marshal(scope{
+		Index:      query.Index,
+		Partition:  query.Partition,
+		SortKey:    query.SortKey,
+		Filters:    query.Filters,
+		Descending: query.Descending,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+
+	return sum[:], nil
+}
+
+func (c *Codec) sign(content []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(content)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,224 @@
+package cursor_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/cursor"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+// queryStub is the query cursors are produced and consumed by, unless a test says otherwise.
+var queryStub = dynamo.Query{
+	Index:             dynamo.GSI1,
+	Partition:         "WEBSITE",
+	SortKey:           nil,
+	Filters:           []dynamo.Filter{{Op: dynamo.FilterEqual, Name: "status", Value: "active"}},
+	Limit:             25,
+	ExclusiveStartKey: nil,
+	Descending:        false,
+}
+
+func TestCodec_Encode(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty key results in empty cursor", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		// execute
+		got, err := sut.Encode(queryStub, nil)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("fail unsupported attribute causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.Key{"pk": &types.AttributeValueMemberBOOL{Value: true}}
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		// execute
+		_, err := sut.Encode(queryStub, keyStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("cursor is opaque", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K2("foo", "bar")
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		// execute
+		got, err := sut.Encode(queryStub, keyStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.NotContains(t, got, "foo")
+		assert.NotContains(t, got, "=")
+	})
+}
+
+func TestCodec_Decode(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.IndexKey(dynamo.K2("foo", "bar"), dynamo.GSI1, "baz", "qux")
+		keyStub["version"] = &types.AttributeValueMemberN{Value: "3"}
+		keyStub["bin"] = &types.AttributeValueMemberB{Value: []byte{1, 2, 3}}
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		// execute
+		encoded, err := sut.Encode(queryStub, keyStub)
+		require.NoError(t, err)
+
+		got, err := sut.Decode(queryStub, encoded)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, keyStub, got)
+	})
+
+	t.Run("empty cursor results in empty key", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		// execute
+		got, err := sut.Decode(queryStub, "")
+
+		// asserts
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("fail cursor signed with another secret causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		encoded, err := cursor.NewCodec([]byte("other")).Encode(queryStub, dynamo.K2("foo", "bar"))
+		require.NoError(t, err)
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		// execute
+		_, err = sut.Decode(queryStub, encoded)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success page size may change from one page to the next", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		query := queryStub
+		query.Limit = 50
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		encoded, err := sut.Encode(queryStub, dynamo.K2("foo", "bar"))
+		require.NoError(t, err)
+
+		// execute
+		got, err := sut.Decode(query, encoded)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.K2("foo", "bar"), got)
+	})
+
+	t.Run("fail cursor of another query causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		otherPartition, otherFilters, otherOrder, otherSortKey := queryStub, queryStub, queryStub, queryStub
+		otherPartition.Partition = "TRASH#WEBSITE"
+		otherFilters.Filters = []dynamo.Filter{{Op: dynamo.FilterEqual, Name: "status", Value: "inactive"}}
+		otherOrder.Descending = true
+		otherSortKey.SortKey = dynamo.SortKeyBeginsWith("2022")
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		encoded, err := sut.Encode(queryStub, dynamo.K2("foo", "bar"))
+		require.NoError(t, err)
+
+		for _, query := range []dynamo.Query{otherPartition, otherFilters, otherOrder, otherSortKey} {
+			// execute
+			_, err := sut.Decode(query, encoded)
+
+			// asserts
+			require.Error(t, err)
+			assert.Equal(t, http.StatusBadRequest, lhttp.ToProblem(err).Status)
+		}
+	})
+
+	t.Run("fail tampered cursor causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		// stubs
+		encoded, err := sut.Encode(queryStub, dynamo.K2("foo", "bar"))
+		require.NoError(t, err)
+
+		raw, err := base64.RawURLEncoding.DecodeString(encoded)
+		require.NoError(t, err)
+
+		tampered := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), "foo", "fop", 1)))
+
+		// execute
+		_, err = sut.Decode(queryStub, tampered)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail malformed cursor causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := cursor.NewCodec([]byte("secret"))
+
+		for _, c := range []string{"!!!", "Zm9v"} {
+			// execute
+			_, err := sut.Decode(queryStub, c)
+
+			// asserts
+			require.Error(t, err)
+			assert.Equal(t, http.StatusBadRequest, lhttp.ToProblem(err).Status)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package dynamo_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/dynamotest"
+	"github.com/abtercms/abtercms2/pkg/storetest"
+)
+
+const (
+	// envTestEndpoint and envTestTable point the conformance tests to a table, e.g. in DynamoDB Local.
+	// The table needs the keys of the single table design, including GSI1.
+	envTestEndpoint = "DYNAMODB_TEST_ENDPOINT"
+	envTestTable    = "DYNAMODB_TEST_TABLE"
+)
+
+func TestRepo_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, dynamo.NewRepo(aws.Config{}, "websites", "").SetDB(dynamotest.NewDB()))
+}
+
+func TestRepo_ConformanceDynamoDB(t *testing.T) {
+	t.Parallel()
+
+	endpoint, tableName := os.Getenv(envTestEndpoint), os.Getenv(envTestTable)
+	if endpoint == "" || tableName == "" {
+		t.Skipf("%s and %s are required to run the conformance tests against DynamoDB", envTestEndpoint, envTestTable)
+	}
+
+	sdkConfig, err := config.LoadDefaultConfig(context.Background())
+	require.NoError(t, err)
+
+	storetest.Run(t, dynamo.NewRepo(sdkConfig, tableName, endpoint))
+}
@@ -0,0 +1,380 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	errGuardTaken     = "unique value already taken"
+	errGuardReleased  = "required record changed in the meantime"
+	errMarshallGuards = "failed to marshal guard"
+	errPuttingItem    = "failed to put item"
+
+	reasonConditionalCheckFailed = "ConditionalCheckFailed"
+)
+
+// Guards are items reserving unique values, such as the path of a page, for the record owning them.
+// They are written in the same transaction as their owner, therefore a value can never be taken twice.
+// Add holds the guards to create, Remove the keys of the guards to delete.
+// Keep holds guards which must be stored as they are for the transaction to be written, such as the guard of the path
+// of the parent of a page, a guard changed or deleted in the meantime results in a 409 conflict.
+type Guards struct {
+	Add    []interface{}
+	Remove []Key
+	Keep   []interface{}
+}
+
+// failure returns the problem of a transaction canceled by the guard item at the given index.
+// Guard items follow the record guarded, which is the first item of the transaction.
+func (g Guards) failure(index int, err error) error {
+	if index > len(g.Add)+len(g.Remove) {
+		return lhttp.WrapProblem(err, http.StatusConflict, errGuardReleased)
+	}
+
+	return lhttp.WrapProblem(err, http.StatusConflict, errGuardTaken)
+}
+
+// CreateGuarded creates a new record along with the guards reserving its unique values.
+// A taken unique value results in a 409 conflict, like an existing record.
+func (r *Repo) CreateGuarded(ctx context.Context, item interface{}, guards Guards) error {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusBadRequest, errCreatingItem)
+	}
+
+	itemMarshalled[versionKey] = versionValue(InitialVersion)
+
+	put, err := r.putIfNotExists(itemMarshalled)
+	if err != nil {
+		return err
+	}
+
+	items, err := r.guardItems(guards)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{put}, items...),
+	})
+	if index, ok := failedCondition(err); ok {
+		if index == 0 {
+			return lhttp.WrapProblem(err, http.StatusConflict, errItemExists)
+		}
+
+		return guards.failure(index, err)
+	}
+
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errCreatingItem)
+	}
+
+	return nil
+}
+
+// PutGuarded writes a record whether it exists or not, along with adding and removing guards of its unique values.
+// It suits records without versions, such as snapshots replaced as a whole.
+func (r *Repo) PutGuarded(ctx context.Context, item interface{}, guards Guards) error {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	put := types.TransactWriteItem{
+		Put: &types.Put{
+			Item:      itemMarshalled,
+			TableName: aws.String(r.tableName),
+		},
+	}
+
+	items, err := r.guardItems(guards)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{put}, items...),
+	})
+	if index, ok := failedCondition(err); ok {
+		return guards.failure(index, err)
+	}
+
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errPuttingItem)
+	}
+
+	return nil
+}
+
+// UpdateGuarded updates an existing record like Update, while adding and removing guards of its unique values.
+func (r *Repo) UpdateGuarded(ctx context.Context, item interface{}, version int64, guards Guards) (int64, error) {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	itemMarshalled[versionKey] = versionValue(version + 1)
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.And(
+			expression.AttributeExists(expression.Name(privateKey)),
+			expression.AttributeNotExists(expression.Name(deletedAtKey)),
+			expression.Name(versionKey).Equal(expression.Value(version)),
+		)).
+		Build()
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
+	}
+
+	put := types.TransactWriteItem{
+		Put: &types.Put{
+			Item:                      itemMarshalled,
+			TableName:                 aws.String(r.tableName),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+	}
+
+	items, err := r.guardItems(guards)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = r.db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{put}, items...),
+	})
+	if index, ok := failedCondition(err); ok {
+		if index == 0 {
+			return 0, r.conditionFailure(ctx, keyOf(itemMarshalled), version, err)
+		}
+
+		return 0, guards.failure(index, err)
+	}
+
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errUpdatingItem)
+	}
+
+	return version + 1, nil
+}
+
+// DeleteGuarded deletes an existing record along with the guards of its unique values.
+func (r *Repo) DeleteGuarded(ctx context.Context, key Key, guards []Key) error {
+	items := []types.TransactWriteItem{
+		{Delete: &types.Delete{Key: key, TableName: aws.String(r.tableName)}},
+	}
+
+	for _, guard := range guards {
+		items = append(items, types.TransactWriteItem{
+			Delete: &types.Delete{Key: guard, TableName: aws.String(r.tableName)},
+		})
+	}
+
+	_, err := r.db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errDeletingItem)
+	}
+
+	return nil
+}
+
+// TrashGuarded trashes an existing record like Trash, while deleting the guards of its unique values.
+// Trashed records therefore release their unique values, which can be taken by other records until they are restored.
+func (r *Repo) TrashGuarded(ctx context.Context, key Key, index Keys, deletedAt, expiresAt time.Time, guards []Key) error {
+	expr, err := trashExpression(index, deletedAt, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	update := types.TransactWriteItem{
+		Update: &types.Update{
+			Key:                       key,
+			TableName:                 aws.String(r.tableName),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+	}
+
+	items, err := r.guardItems(Guards{Add: nil, Remove: guards, Keep: nil})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{update}, items...),
+	})
+	if _, ok := failedCondition(err); ok {
+		return lhttp.WrapProblem(err, http.StatusNotFound, errItemNotFound)
+	}
+
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errTrashingItem)
+	}
+
+	return nil
+}
+
+// RestoreGuarded restores a trashed record like Restore, while taking back the guards of its unique values.
+// Unique values taken by other records in the meantime result in a 409 conflict, the record is kept in the trash then.
+func (r *Repo) RestoreGuarded(ctx context.Context, key Key, index Keys, guards []interface{}, result interface{}) error {
+	expr, err := restoreExpression(index)
+	if err != nil {
+		return err
+	}
+
+	update := types.TransactWriteItem{
+		Update: &types.Update{
+			Key:                       key,
+			TableName:                 aws.String(r.tableName),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+	}
+
+	items, err := r.guardItems(Guards{Add: guards, Remove: nil, Keep: nil})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{update}, items...),
+	})
+	if failed, ok := failedCondition(err); ok {
+		if failed == 0 {
+			return lhttp.WrapProblem(err, http.StatusNotFound, errItemNotTrashed)
+		}
+
+		return lhttp.WrapProblem(err, http.StatusConflict, errGuardTaken)
+	}
+
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errRestoringItem)
+	}
+
+	// transactions do not return the items they write
+	return r.Get(ctx, key, result)
+}
+
+func (r *Repo) guardItems(guards Guards) ([]types.TransactWriteItem, error) {
+	items := make([]types.TransactWriteItem, 0, len(guards.Add)+len(guards.Remove)+len(guards.Keep))
+
+	for _, guard := range guards.Add {
+		guardMarshalled, err := attributevalue.MarshalMap(guard)
+		if err != nil {
+			return nil, lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallGuards)
+		}
+
+		put, err := r.putIfNotExists(guardMarshalled)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, put)
+	}
+
+	for _, key := range guards.Remove {
+		items = append(items, types.TransactWriteItem{
+			Delete: &types.Delete{Key: key, TableName: aws.String(r.tableName)},
+		})
+	}
+
+	for _, guard := range guards.Keep {
+		check, err := r.checkUnchanged(guard)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, check)
+	}
+
+	return items, nil
+}
+
+// checkUnchanged checks that a guard is stored with every attribute it has.
+func (r *Repo) checkUnchanged(guard interface{}) (types.TransactWriteItem, error) {
+	guardMarshalled, err := attributevalue.MarshalMap(guard)
+	if err != nil {
+		return types.TransactWriteItem{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallGuards)
+	}
+
+	names := make([]string, 0, len(guardMarshalled))
+	for name := range guardMarshalled {
+		names = append(names, name)
+	}
+
+	// names are sorted to build the same expression for the same guard every time
+	sort.Strings(names)
+
+	condition := expression.AttributeExists(expression.Name(privateKey))
+	for _, name := range names {
+		condition = condition.And(expression.Name(name).Equal(expression.Value(guardMarshalled[name])))
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return types.TransactWriteItem{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
+	}
+
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			Key:                       keyOf(guardMarshalled),
+			TableName:                 aws.String(r.tableName),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+	}, nil
+}
+
+func (r *Repo) putIfNotExists(item Key) (types.TransactWriteItem, error) {
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name(privateKey))).
+		Build()
+	if err != nil {
+		return types.TransactWriteItem{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
+	}
+
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			Item:                     item,
+			TableName:                aws.String(r.tableName),
+			ConditionExpression:      expr.Condition(),
+			ExpressionAttributeNames: expr.Names(),
+		},
+	}, nil
+}
+
+// failedCondition returns the index of the first item of a canceled transaction which failed its condition.
+func failedCondition(err error) (int, bool) {
+	var tce *types.TransactionCanceledException
+	if !errors.As(err, &tce) {
+		return 0, false
+	}
+
+	for i, reason := range tce.CancellationReasons {
+		if aws.ToString(reason.Code) == reasonConditionalCheckFailed {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,544 @@
+package dynamo_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/dynamotest"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+type guardedItem struct {
+	PK   string `dynamodbav:"pk"`
+	SK   string `dynamodbav:"sk"`
+	Path string `dynamodbav:"path"`
+}
+
+type guardItem struct {
+	PK string `dynamodbav:"pk"`
+	SK string `dynamodbav:"sk"`
+}
+
+type ownedGuardItem struct {
+	PK    string `dynamodbav:"pk"`
+	SK    string `dynamodbav:"sk"`
+	Owner string `dynamodbav:"owner"`
+}
+
+// canceledAt returns a canceled transaction whose item at index failed its condition.
+func canceledAt(index, count int) error {
+	reasons := make([]types.CancellationReason, count)
+	for i := range reasons {
+		reasons[i].Code = aws.String("None")
+	}
+
+	reasons[index].Code = aws.String("ConditionalCheckFailed")
+
+	return &types.TransactionCanceledException{CancellationReasons: reasons}
+}
+
+func TestRepo_CreateGuarded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail existing item causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/baz"}
+		guardsStub := dynamo.Guards{Add: []interface{}{guardItem{PK: "foo", SK: "PATH#/baz"}}, Remove: nil, Keep: nil}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(0, 2))
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub, guardsStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+		assert.Contains(t, err.Error(), "item already exists")
+	})
+
+	t.Run("fail taken unique value causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/baz"}
+		guardsStub := dynamo.Guards{Add: []interface{}{guardItem{PK: "foo", SK: "PATH#/baz"}}, Remove: nil, Keep: nil}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(1, 2))
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub, guardsStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+		assert.Contains(t, err.Error(), "unique value already taken")
+	})
+
+	t.Run("fail kept guard changed in the meantime causes 409 conflict and creates nothing", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/baz/qux"}
+		guardsStub := dynamo.Guards{Add: nil, Remove: nil, Keep: []interface{}{ownedGuardItem{PK: "foo", SK: "PATH#/baz", Owner: "baz"}}}
+
+		// system under test
+		sut := dynamo.NewRepo(aws.Config{}, "websites", "").SetDB(dynamotest.NewDB())
+		require.NoError(t, sut.Create(ctx, ownedGuardItem{PK: "foo", SK: "PATH#/baz", Owner: "quux"}))
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub, guardsStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+		assert.Contains(t, err.Error(), "required record changed in the meantime")
+
+		found, err := sut.Find(ctx, dynamo.K2("foo", "PAGE#bar"), &guardedItem{})
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("success kept guard is checked but not written", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/baz/qux"}
+		keptStub := ownedGuardItem{PK: "foo", SK: "PATH#/baz", Owner: "baz"}
+
+		// system under test
+		sut := dynamo.NewRepo(aws.Config{}, "websites", "").SetDB(dynamotest.NewDB())
+		require.NoError(t, sut.Create(ctx, keptStub))
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub, dynamo.Guards{Add: nil, Remove: nil, Keep: []interface{}{keptStub}})
+
+		// asserts
+		require.NoError(t, err)
+
+		var stored struct {
+			ownedGuardItem
+			Version int64 `dynamodbav:"version"`
+		}
+
+		found, err := sut.Find(ctx, dynamo.K2("foo", "PATH#/baz"), &stored)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, dynamo.InitialVersion, stored.Version)
+	})
+
+	t.Run("fail error in writing items causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/baz"}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, assert.AnError)
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub, dynamo.Guards{Add: nil, Remove: nil, Keep: nil})
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/baz"}
+		guardsStub := dynamo.Guards{Add: []interface{}{guardItem{PK: "foo", SK: "PATH#/baz"}}, Remove: nil, Keep: nil}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+			if len(input.TransactItems) != 2 {
+				return false
+			}
+
+			item, guard := input.TransactItems[0].Put, input.TransactItems[1].Put
+
+			return item != nil && guard != nil &&
+				assert.ObjectsAreEqual(&types.AttributeValueMemberN{Value: "1"}, item.Item["version"]) &&
+				assert.ObjectsAreEqual(&types.AttributeValueMemberS{Value: "PATH#/baz"}, guard.Item["sk"]) &&
+				aws.ToString(item.ConditionExpression) == "attribute_not_exists (#0)" &&
+				aws.ToString(guard.ConditionExpression) == "attribute_not_exists (#0)"
+		})
+		dbMock.On("TransactWriteItems", ctx, inputMatcher).
+			Once().
+			Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub, guardsStub)
+
+		// asserts
+		require.NoError(t, err)
+		dbMock.AssertExpectations(t)
+	})
+}
+
+func TestRepo_PutGuarded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail taken unique value causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PUBLISHED#PAGE#bar", Path: "/baz"}
+		guardsStub := dynamo.Guards{Add: []interface{}{guardItem{PK: "foo", SK: "PUBLISHED#PATH#/baz"}}, Remove: nil, Keep: nil}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(1, 2))
+
+		// execute
+		err := sut.PutGuarded(ctx, itemStub, guardsStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PUBLISHED#PAGE#bar", Path: "/baz"}
+		guardsStub := dynamo.Guards{
+			Add:    []interface{}{guardItem{PK: "foo", SK: "PUBLISHED#PATH#/baz"}},
+			Remove: []dynamo.Key{dynamo.K2("foo", "PUBLISHED#PATH#/qux")},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+			return len(input.TransactItems) == 3 &&
+				input.TransactItems[0].Put.ConditionExpression == nil &&
+				input.TransactItems[1].Put.ConditionExpression != nil &&
+				assert.ObjectsAreEqual(dynamo.K2("foo", "PUBLISHED#PATH#/qux"), input.TransactItems[2].Delete.Key)
+		})
+		dbMock.On("TransactWriteItems", ctx, inputMatcher).
+			Once().
+			Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+		// execute
+		err := sut.PutGuarded(ctx, itemStub, guardsStub)
+
+		// asserts
+		require.NoError(t, err)
+	})
+}
+
+func TestRepo_UpdateGuarded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail stale version causes 412 precondition failed", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/qux"}
+		guardsStub := dynamo.Guards{
+			Add:    []interface{}{guardItem{PK: "foo", SK: "PATH#/qux"}},
+			Remove: []dynamo.Key{dynamo.K2("foo", "PATH#/baz")},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(0, 3))
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(&dynamodb.GetItemOutput{Item: dynamo.Key{"version": &types.AttributeValueMemberN{Value: "4"}}}, nil)
+
+		// execute
+		_, err := sut.UpdateGuarded(ctx, itemStub, 3, guardsStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail taken unique value causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/qux"}
+		guardsStub := dynamo.Guards{
+			Add:    []interface{}{guardItem{PK: "foo", SK: "PATH#/qux"}},
+			Remove: []dynamo.Key{dynamo.K2("foo", "PATH#/baz")},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(1, 3))
+
+		// execute
+		_, err := sut.UpdateGuarded(ctx, itemStub, 3, guardsStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := guardedItem{PK: "foo", SK: "PAGE#bar", Path: "/qux"}
+		guardsStub := dynamo.Guards{
+			Add:    []interface{}{guardItem{PK: "foo", SK: "PATH#/qux"}},
+			Remove: []dynamo.Key{dynamo.K2("foo", "PATH#/baz")},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+			return len(input.TransactItems) == 3 &&
+				input.TransactItems[0].Put != nil &&
+				input.TransactItems[1].Put != nil &&
+				input.TransactItems[2].Delete != nil &&
+				assert.ObjectsAreEqual(&types.AttributeValueMemberN{Value: "4"}, input.TransactItems[0].Put.Item["version"]) &&
+				assert.ObjectsAreEqual(dynamo.K2("foo", "PATH#/baz"), input.TransactItems[2].Delete.Key)
+		})
+		dbMock.On("TransactWriteItems", ctx, inputMatcher).
+			Once().
+			Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+		// execute
+		version, err := sut.UpdateGuarded(ctx, itemStub, 3, guardsStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), version)
+	})
+}
+
+func TestRepo_DeleteGuarded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail error in writing items causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, assert.AnError)
+
+		// execute
+		err := sut.DeleteGuarded(ctx, dynamo.K2("foo", "PAGE#bar"), []dynamo.Key{dynamo.K2("foo", "PATH#/baz")})
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+			return len(input.TransactItems) == 2 &&
+				assert.ObjectsAreEqual(dynamo.K2("foo", "PAGE#bar"), input.TransactItems[0].Delete.Key) &&
+				assert.ObjectsAreEqual(dynamo.K2("foo", "PATH#/baz"), input.TransactItems[1].Delete.Key)
+		})
+		dbMock.On("TransactWriteItems", ctx, inputMatcher).
+			Once().
+			Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+		// execute
+		err := sut.DeleteGuarded(ctx, dynamo.K2("foo", "PAGE#bar"), []dynamo.Key{dynamo.K2("foo", "PATH#/baz")})
+
+		// asserts
+		require.NoError(t, err)
+	})
+}
+
+func TestRepo_TrashGuarded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail missing or trashed item causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(0, 2))
+
+		// execute
+		err := sut.TrashGuarded(ctx, dynamo.K2("foo", "WEBSITE"), dynamo.Keys{}, time.Now(), time.Time{}, []dynamo.Key{dynamo.K2("HOST#foo.com", "HOST")})
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+			return len(input.TransactItems) == 2 &&
+				assert.ObjectsAreEqual(dynamo.K2("foo", "WEBSITE"), input.TransactItems[0].Update.Key) &&
+				assert.ObjectsAreEqual(dynamo.K2("HOST#foo.com", "HOST"), input.TransactItems[1].Delete.Key)
+		})
+		dbMock.On("TransactWriteItems", ctx, inputMatcher).
+			Once().
+			Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+		// execute
+		err := sut.TrashGuarded(ctx, dynamo.K2("foo", "WEBSITE"), dynamo.Keys{}, time.Now(), time.Time{}, []dynamo.Key{dynamo.K2("HOST#foo.com", "HOST")})
+
+		// asserts
+		require.NoError(t, err)
+	})
+}
+
+func TestRepo_RestoreGuarded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail item not in trash causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		guardsStub := []interface{}{guardItem{PK: "HOST#foo.com", SK: "HOST"}}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(0, 2))
+
+		// execute
+		err := sut.RestoreGuarded(ctx, dynamo.K2("foo", "WEBSITE"), dynamo.Keys{}, guardsStub, &guardedItem{})
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail taken unique value causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		guardsStub := []interface{}{guardItem{PK: "HOST#foo.com", SK: "HOST"}}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(1, 2))
+
+		// execute
+		err := sut.RestoreGuarded(ctx, dynamo.K2("foo", "WEBSITE"), dynamo.Keys{}, guardsStub, &guardedItem{})
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K2("foo", "WEBSITE")
+		guardsStub := []interface{}{guardItem{PK: "HOST#foo.com", SK: "HOST"}}
+		outputStub := &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: "foo"},
+				"sk": &types.AttributeValueMemberS{Value: "WEBSITE"},
+			},
+		}
+		expectedResult := guardedItem{PK: "foo", SK: "WEBSITE", Path: ""}
+		actualResult := guardedItem{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+			return len(input.TransactItems) == 2 &&
+				assert.ObjectsAreEqual(keyStub, input.TransactItems[0].Update.Key) &&
+				input.TransactItems[1].Put != nil
+		})
+		dbMock.On("TransactWriteItems", ctx, inputMatcher).
+			Once().
+			Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(outputStub, nil)
+
+		// execute
+		err := sut.RestoreGuarded(ctx, keyStub, dynamo.Keys{}, guardsStub, &actualResult)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedResult, actualResult)
+	})
+}
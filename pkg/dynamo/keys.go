@@ -0,0 +1,83 @@
+package dynamo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	sortKey = "sk"
+
+	// GSI1 is the global secondary index partitioning items by entity type.
+	GSI1 = "gsi1"
+
+	gsi1PartitionKey = "gsi1pk"
+	gsi1SortKey      = "gsi1sk"
+)
+
+// Keys contains the sort key and index key attributes of an item in the single table design.
+// They are storage details, therefore they are never exposed to clients.
+type Keys struct {
+	SK     string `json:"-" dynamodbav:"sk"`
+	GSI1PK string `json:"-" dynamodbav:"gsi1pk,omitempty"`
+	GSI1SK string `json:"-" dynamodbav:"gsi1sk,omitempty"`
+}
+
+// K2 converts a partition key and a sort key into a Key for DynamoDB.
+func K2(pk, sk string) Key {
+	return Key{
+		privateKey: &types.AttributeValueMemberS{Value: pk},
+		sortKey:    &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+// IndexKey converts a table key and the key of an index into a Key usable to continue a query on the index.
+func IndexKey(tableKey Key, index, pk, sk string) Key {
+	partitionName, sortName := KeyNames(index)
+
+	key := Key{
+		partitionName: &types.AttributeValueMemberS{Value: pk},
+		sortName:      &types.AttributeValueMemberS{Value: sk},
+	}
+
+	for name, value := range tableKey {
+		key[name] = value
+	}
+
+	return key
+}
+
+// keyOf extracts the table key of an item.
+func keyOf(item Key) Key {
+	key := Key{
+		privateKey: item[privateKey],
+	}
+
+	if sk, ok := item[sortKey]; ok {
+		key[sortKey] = sk
+	}
+
+	return key
+}
+
+// indexKeyOf extracts the key of an item usable to continue a query on the table or one of its indexes.
+func indexKeyOf(item Key, index string) Key {
+	key := keyOf(item)
+	if index == "" {
+		return key
+	}
+
+	partitionName, sortName := KeyNames(index)
+	key[partitionName] = item[partitionName]
+	key[sortName] = item[sortName]
+
+	return key
+}
+
+// KeyNames returns the attribute names of the partition and sort key of the table or one of its indexes.
+func KeyNames(index string) (string, string) {
+	if index == GSI1 {
+		return gsi1PartitionKey, gsi1SortKey
+	}
+
+	return privateKey, sortKey
+}
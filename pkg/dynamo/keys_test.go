@@ -0,0 +1,38 @@
+package dynamo_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+func TestK2(t *testing.T) {
+	t.Parallel()
+
+	// execute
+	got := dynamo.K2("foo", "bar")
+
+	// asserts
+	assert.Equal(t, dynamo.Key{
+		"pk": &types.AttributeValueMemberS{Value: "foo"},
+		"sk": &types.AttributeValueMemberS{Value: "bar"},
+	}, got)
+}
+
+func TestIndexKey(t *testing.T) {
+	t.Parallel()
+
+	// execute
+	got := dynamo.IndexKey(dynamo.K2("foo", "bar"), dynamo.GSI1, "baz", "qux")
+
+	// asserts
+	assert.Equal(t, dynamo.Key{
+		"pk":     &types.AttributeValueMemberS{Value: "foo"},
+		"sk":     &types.AttributeValueMemberS{Value: "bar"},
+		"gsi1pk": &types.AttributeValueMemberS{Value: "baz"},
+		"gsi1sk": &types.AttributeValueMemberS{Value: "qux"},
+	}, got)
+}
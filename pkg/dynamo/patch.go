@@ -0,0 +1,149 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+)
+
+const (
+	validationException = "ValidationException"
+
+	errPatchingItem       = "failed to patch item"
+	errPatchNotApplicable = "patch cannot be applied to the stored item"
+	errInvalidPatchPath   = "invalid patch path: %s"
+)
+
+// Patch applies the operations to the existing record in the table assigned to the repository using a single UpdateItem.
+// The write only succeeds if the record exists and is still at the given version, the updated record is stored in result
+// and the new version is returned.
+func (r *Repo) Patch(ctx context.Context, key Key, version int64, ops []patch.Operation, result interface{}) (int64, error) {
+	expr, err := patchExpression(version, ops)
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := r.db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		Key:                       key,
+		TableName:                 aws.String(r.tableName),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+
+	if isConditionalCheckFailed(err) {
+		return 0, r.conditionFailure(ctx, key, version, err)
+	}
+
+	if isValidationException(err) {
+		return 0, lhttp.WrapProblem(err, http.StatusUnprocessableEntity, errPatchNotApplicable)
+	}
+
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errPatchingItem)
+	}
+
+	if out == nil {
+		return 0, lhttp.NewProblem(http.StatusInternalServerError, errPatchingItem)
+	}
+
+	err = attributevalue.UnmarshalMap(out.Attributes, result)
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	return version + 1, nil
+}
+
+func patchExpression(version int64, ops []patch.Operation) (expression.Expression, error) {
+	update := expression.Set(expression.Name(versionKey), expression.Name(versionKey).Plus(expression.Value(1)))
+	conditions := []expression.ConditionBuilder{
+		expression.Name(versionKey).Equal(expression.Value(version)),
+	}
+
+	for _, op := range ops {
+		name, err := patchName(op.Path)
+		if err != nil {
+			return expression.Expression{}, err
+		}
+
+		switch op.Op {
+		case patch.OpSet:
+			update = update.Set(name, expression.Value(op.Value))
+		case patch.OpReplace:
+			update = update.Set(name, expression.Value(op.Value))
+			conditions = append(conditions, expression.AttributeExists(name))
+		case patch.OpRemove:
+			update = update.Remove(name)
+			conditions = append(conditions, expression.AttributeExists(name))
+		case patch.OpUnset:
+			update = update.Remove(name)
+		case patch.OpAppend:
+			update = update.Set(name, expression.ListAppend(
+				expression.IfNotExists(name, expression.Value([]interface{}{})),
+				expression.Value([]interface{}{op.Value}),
+			))
+		case patch.OpTest:
+			conditions = append(conditions, name.Equal(expression.Value(op.Value)))
+		}
+	}
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.And(
+			expression.AttributeExists(expression.Name(privateKey)),
+			expression.AttributeNotExists(expression.Name(deletedAtKey)),
+			conditions...,
+		)).
+		Build()
+	if err != nil {
+		return expression.Expression{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
+	}
+
+	return expr, nil
+}
+
+// patchName converts a path into a document path, rejecting segments which would be parsed as separators.
+func patchName(path patch.Path) (expression.NameBuilder, error) {
+	var sb strings.Builder
+
+	for i, segment := range path {
+		if index, ok := patch.Index(segment); ok && i > 0 {
+			sb.WriteString(fmt.Sprintf("[%d]", index))
+
+			continue
+		}
+
+		if segment == "" || strings.ContainsAny(segment, ".[]") || segment == patch.AppendSegment {
+			return expression.NameBuilder{}, lhttp.NewProblem(http.StatusUnprocessableEntity, errInvalidPatchPath, path.String())
+		}
+
+		if i > 0 {
+			sb.WriteString(".")
+		}
+
+		sb.WriteString(segment)
+	}
+
+	return expression.Name(sb.String()), nil
+}
+
+func isValidationException(err error) bool {
+	var apiErr smithy.APIError
+
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == validationException
+}
@@ -0,0 +1,219 @@
+package dynamo_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+)
+
+func TestRepo_Patch(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	type T struct {
+		Foo string `dynamodbav:"foo"`
+	}
+
+	t.Run("fail invalid path causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		opsStub := []patch.Operation{
+			{Op: patch.OpSet, Path: patch.Path{"foo.bar"}, Value: "baz"},
+		}
+		actualResult := T{}
+
+		// system under test
+		sut, _ := createTestRepo()
+
+		// execute
+		_, err := sut.Patch(ctx, keyStub, 3, opsStub, &actualResult)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail error in updating item causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		opsStub := []patch.Operation{
+			{Op: patch.OpSet, Path: patch.Path{"foo"}, Value: "baz"},
+		}
+		actualResult := T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("UpdateItem", ctx, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+			Once().
+			Return(nil, assert.AnError)
+
+		// execute
+		_, err := sut.Patch(ctx, keyStub, 3, opsStub, &actualResult)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail patch not applicable to stored item causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		opsStub := []patch.Operation{
+			{Op: patch.OpSet, Path: patch.Path{"foo", "bar"}, Value: "baz"},
+		}
+		errStub := &smithy.GenericAPIError{Code: "ValidationException"}
+		actualResult := T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("UpdateItem", ctx, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+			Once().
+			Return(nil, errStub)
+
+		// execute
+		_, err := sut.Patch(ctx, keyStub, 3, opsStub, &actualResult)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail test operation causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		opsStub := []patch.Operation{
+			{Op: patch.OpTest, Path: patch.Path{"foo"}, Value: "bar"},
+			{Op: patch.OpSet, Path: patch.Path{"foo"}, Value: "baz"},
+		}
+		errStub := &types.ConditionalCheckFailedException{}
+		getItemStub := &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"version": &types.AttributeValueMemberN{Value: "3"},
+			},
+		}
+		actualResult := T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("UpdateItem", ctx, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+			Once().
+			Return(nil, errStub)
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(getItemStub, nil)
+
+		// execute
+		_, err := sut.Patch(ctx, keyStub, 3, opsStub, &actualResult)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail stale version causes 412 precondition failed", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		opsStub := []patch.Operation{
+			{Op: patch.OpSet, Path: patch.Path{"foo"}, Value: "baz"},
+		}
+		errStub := &types.ConditionalCheckFailedException{}
+		getItemStub := &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"version": &types.AttributeValueMemberN{Value: "4"},
+			},
+		}
+		actualResult := T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("UpdateItem", ctx, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+			Once().
+			Return(nil, errStub)
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(getItemStub, nil)
+
+		// execute
+		_, err := sut.Patch(ctx, keyStub, 3, opsStub, &actualResult)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		opsStub := []patch.Operation{
+			{Op: patch.OpReplace, Path: patch.Path{"foo"}, Value: "baz"},
+			{Op: patch.OpRemove, Path: patch.Path{"bar"}},
+			{Op: patch.OpAppend, Path: patch.Path{"tags"}, Value: "qux"},
+			{Op: patch.OpSet, Path: patch.Path{"meta", "items", "0"}, Value: "quux"},
+		}
+		outputStub := &dynamodb.UpdateItemOutput{
+			Attributes: map[string]types.AttributeValue{
+				"foo": &types.AttributeValueMemberS{Value: "baz"},
+			},
+		}
+		expectedResult := T{Foo: "baz"}
+		actualResult := T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			names := make(map[string]bool)
+			for _, name := range input.ExpressionAttributeNames {
+				names[name] = true
+			}
+
+			return assert.ObjectsAreEqual(keyStub, input.Key) &&
+				input.UpdateExpression != nil &&
+				input.ConditionExpression != nil &&
+				input.ReturnValues == types.ReturnValueAllNew &&
+				assert.ObjectsAreEqual(map[string]bool{"pk": true, "deleted_at": true, "version": true, "foo": true, "bar": true, "tags": true, "meta": true, "items": true}, names)
+		})
+		dbMock.On("UpdateItem", ctx, inputMatcher).
+			Once().
+			Return(outputStub, nil)
+
+		// execute
+		version, err := sut.Patch(ctx, keyStub, 3, opsStub, &actualResult)
+
+		// asserts
+		require.NoError(t, err, "Patch() error = %v", err)
+		assert.Equal(t, int64(4), version)
+		assert.Equal(t, expectedResult, actualResult)
+	})
+}
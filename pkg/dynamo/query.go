@@ -0,0 +1,266 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	maxQueryRequests = 10
+	maxCountRequests = 10
+
+	errCountingItems = "failed to count items"
+)
+
+// KeyOp is a comparison usable in a sort key condition.
+type KeyOp string
+
+const (
+	KeyEqual            KeyOp = "="
+	KeyLessThan         KeyOp = "<"
+	KeyLessThanEqual    KeyOp = "<="
+	KeyGreaterThan      KeyOp = ">"
+	KeyGreaterThanEqual KeyOp = ">="
+	KeyBeginsWith       KeyOp = "begins_with"
+	KeyBetween          KeyOp = "between"
+)
+
+// SortKeyCondition narrows down the items of a partition by their sort key.
+// Upper is only used by KeyBetween.
+type SortKeyCondition struct {
+	Op    KeyOp
+	Value string
+	Upper string
+}
+
+// FilterOp is a comparison usable in a filter.
+type FilterOp string
+
+const (
+	FilterEqual       FilterOp = "="
+	FilterNotEqual    FilterOp = "<>"
+	FilterLessThan    FilterOp = "<"
+	FilterGreaterThan FilterOp = ">"
+	FilterBeginsWith  FilterOp = "begins_with"
+	FilterContains    FilterOp = "contains"
+	FilterExists      FilterOp = "attribute_exists"
+	FilterNotExists   FilterOp = "attribute_not_exists"
+)
+
+// Filter narrows down the items read by a query by any of their attributes.
+// Value is ignored by FilterExists and FilterNotExists.
+type Filter struct {
+	Op    FilterOp
+	Name  string
+	Value interface{}
+}
+
+// Query describes a query of a single partition of the table or one of its indexes.
+// Filters are applied after items are read, all of them must hold for an item to be returned.
+type Query struct {
+	Index             string
+	Partition         string
+	SortKey           *SortKeyCondition
+	Filters           []Filter
+	Limit             int32
+	ExclusiveStartKey Key
+	Descending        bool
+}
+
+// SortKeyBeginsWith creates a sort key condition matching sort keys with the given prefix.
+func SortKeyBeginsWith(prefix string) *SortKeyCondition {
+	return &SortKeyCondition{Op: KeyBeginsWith, Value: prefix, Upper: ""}
+}
+
+// Page describes where a page of query results ends.
+// Next is the key to continue the query with, it is empty if there are no more results.
+type Page struct {
+	Next    Key
+	HasMore bool
+}
+
+// Query lists records in a partition of the table assigned to the repository or one of its indexes.
+// DynamoDB may return short pages, therefore requests are repeated until the page is full or the partition is exhausted.
+// One more record than requested is read to tell whether more results follow.
+func (r *Repo) Query(ctx context.Context, query Query, result interface{}) (Page, error) {
+	params, err := r.queryInput(query)
+	if err != nil {
+		return Page{}, err
+	}
+
+	var (
+		items []Key
+		page  Page
+	)
+
+	for i := 0; i < maxQueryRequests; i++ {
+		if query.Limit > 0 {
+			params.Limit = aws.Int32(query.Limit + 1 - int32(len(items)))
+		}
+
+		out, err := r.db.Query(ctx, params)
+		if err != nil {
+			return Page{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItems)
+		}
+
+		if out == nil {
+			return Page{}, lhttp.NewProblem(http.StatusInternalServerError, errFetchingItems)
+		}
+
+		items = append(items, out.Items...)
+		page = Page{Next: out.LastEvaluatedKey, HasMore: len(out.LastEvaluatedKey) > 0}
+		params.ExclusiveStartKey = out.LastEvaluatedKey
+
+		if !page.HasMore || (query.Limit > 0 && int32(len(items)) > query.Limit) {
+			break
+		}
+	}
+
+	if query.Limit > 0 && int32(len(items)) > query.Limit {
+		items = items[:query.Limit]
+		page = Page{Next: indexKeyOf(items[len(items)-1], query.Index), HasMore: true}
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(items, result)
+	if err != nil {
+		return Page{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItems)
+	}
+
+	return page, nil
+}
+
+// Count counts the records matching a query, giving up after reading maxCountRequests pages of results.
+// The count returned is exact only if the second return value is true, otherwise it is a lower bound.
+func (r *Repo) Count(ctx context.Context, query Query) (int32, bool, error) {
+	params, err := r.queryInput(query)
+	if err != nil {
+		return 0, false, err
+	}
+
+	params.Select = types.SelectCount
+
+	var count int32
+
+	for i := 0; i < maxCountRequests; i++ {
+		out, err := r.db.Query(ctx, params)
+		if err != nil {
+			return 0, false, lhttp.WrapProblem(err, http.StatusInternalServerError, errCountingItems)
+		}
+
+		if out == nil {
+			return 0, false, lhttp.NewProblem(http.StatusInternalServerError, errCountingItems)
+		}
+
+		count += out.Count
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return count, true, nil
+		}
+
+		params.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	return count, false, nil
+}
+
+func (r *Repo) queryInput(query Query) (*dynamodb.QueryInput, error) {
+	builder := expression.NewBuilder().WithKeyCondition(keyCondition(query))
+	if len(query.Filters) > 0 {
+		builder = builder.WithFilter(filterCondition(query.Filters))
+	}
+
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
+	}
+
+	params := &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(!query.Descending),
+	}
+	if query.Index != "" {
+		params.IndexName = aws.String(query.Index)
+	}
+
+	if len(query.ExclusiveStartKey) > 0 {
+		params.ExclusiveStartKey = query.ExclusiveStartKey
+	}
+
+	return params, nil
+}
+
+func keyCondition(query Query) expression.KeyConditionBuilder {
+	partitionName, sortName := KeyNames(query.Index)
+
+	condition := expression.Key(partitionName).Equal(expression.Value(query.Partition))
+	if query.SortKey == nil {
+		return condition
+	}
+
+	sk := expression.Key(sortName)
+	value := expression.Value(query.SortKey.Value)
+
+	switch query.SortKey.Op {
+	case KeyLessThan:
+		return condition.And(sk.LessThan(value))
+	case KeyLessThanEqual:
+		return condition.And(sk.LessThanEqual(value))
+	case KeyGreaterThan:
+		return condition.And(sk.GreaterThan(value))
+	case KeyGreaterThanEqual:
+		return condition.And(sk.GreaterThanEqual(value))
+	case KeyBeginsWith:
+		return condition.And(sk.BeginsWith(query.SortKey.Value))
+	case KeyBetween:
+		return condition.And(sk.Between(value, expression.Value(query.SortKey.Upper)))
+	default:
+		return condition.And(sk.Equal(value))
+	}
+}
+
+func filterCondition(filters []Filter) expression.ConditionBuilder {
+	conditions := make([]expression.ConditionBuilder, 0, len(filters))
+
+	for _, f := range filters {
+		name := expression.Name(f.Name)
+		value := expression.Value(f.Value)
+
+		switch f.Op {
+		case FilterNotEqual:
+			conditions = append(conditions, name.NotEqual(value))
+		case FilterLessThan:
+			conditions = append(conditions, name.LessThan(value))
+		case FilterGreaterThan:
+			conditions = append(conditions, name.GreaterThan(value))
+		case FilterBeginsWith:
+			conditions = append(conditions, name.BeginsWith(fmt.Sprint(f.Value)))
+		case FilterContains:
+			conditions = append(conditions, name.Contains(fmt.Sprint(f.Value)))
+		case FilterExists:
+			conditions = append(conditions, name.AttributeExists())
+		case FilterNotExists:
+			conditions = append(conditions, name.AttributeNotExists())
+		default:
+			conditions = append(conditions, name.Equal(value))
+		}
+	}
+
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+
+	return expression.And(conditions[0], conditions[1], conditions[2:]...)
+}
@@ -0,0 +1,366 @@
+package dynamo_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+func TestRepo_Query(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	type T struct {
+		Foo string
+	}
+
+	t.Run("fail returning nil from DynamoDB causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{Partition: "foo", Limit: 25}
+		var itemStub *dynamodb.QueryOutput
+		actualList := []T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("Query", ctx, mock.AnythingOfType("*dynamodb.QueryInput")).
+			Once().
+			Return(itemStub, nil)
+
+		// execute
+		_, err := sut.Query(ctx, queryStub, &actualList)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail error in retrieving items causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{Partition: "foo", Limit: 25}
+		actualList := []T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("Query", ctx, mock.AnythingOfType("*dynamodb.QueryInput")).
+			Once().
+			Return(nil, assert.AnError)
+
+		// execute
+		_, err := sut.Query(ctx, queryStub, &actualList)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success on table", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{
+			Partition: "foo",
+			SortKey:   dynamo.SortKeyBeginsWith("PAGE#"),
+		}
+		itemStubs := &dynamodb.QueryOutput{}
+		actualList := []T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.IndexName == nil &&
+				input.Limit == nil &&
+				input.ExclusiveStartKey == nil &&
+				*input.ScanIndexForward &&
+				*input.KeyConditionExpression == "(#0 = :0) AND (begins_with (#1, :1))" &&
+				assert.ObjectsAreEqual(map[string]string{"#0": "pk", "#1": "sk"}, input.ExpressionAttributeNames)
+		})
+		dbMock.On("Query", ctx, inputMatcher).
+			Once().
+			Return(itemStubs, nil)
+
+		// execute
+		_, err := sut.Query(ctx, queryStub, &actualList)
+
+		// asserts
+		require.NoError(t, err, "Query() error = %v", err)
+		assert.Empty(t, actualList)
+	})
+
+	t.Run("success on index", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		exclusiveStartKeyStub := dynamo.IndexKey(dynamo.K2("foo", "WEBSITE"), dynamo.GSI1, "WEBSITE", "foo")
+		queryStub := dynamo.Query{
+			Index:             dynamo.GSI1,
+			Partition:         "WEBSITE",
+			SortKey:           &dynamo.SortKeyCondition{Op: dynamo.KeyGreaterThan, Value: "bar"},
+			Limit:             25,
+			ExclusiveStartKey: exclusiveStartKeyStub,
+			Descending:        true,
+		}
+
+		itemStubs := &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				{
+					"Foo": &types.AttributeValueMemberS{Value: "bar"},
+				},
+				{
+					"Foo": &types.AttributeValueMemberS{Value: "baz"},
+				},
+			},
+		}
+		expectedResult := []T{{Foo: "bar"}, {Foo: "baz"}}
+		actualList := []T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return *input.IndexName == dynamo.GSI1 &&
+				*input.Limit == 26 &&
+				!*input.ScanIndexForward &&
+				assert.ObjectsAreEqual(exclusiveStartKeyStub, input.ExclusiveStartKey) &&
+				*input.KeyConditionExpression == "(#0 = :0) AND (#1 > :1)" &&
+				assert.ObjectsAreEqual(map[string]string{"#0": "gsi1pk", "#1": "gsi1sk"}, input.ExpressionAttributeNames)
+		})
+		dbMock.On("Query", ctx, inputMatcher).
+			Once().
+			Return(itemStubs, nil)
+
+		// execute
+		page, err := sut.Query(ctx, queryStub, &actualList)
+
+		// asserts
+		require.NoError(t, err, "Query() error = %v", err)
+		assert.False(t, page.HasMore)
+		assert.Empty(t, page.Next)
+		assert.Equal(t, expectedResult, actualList)
+	})
+
+	t.Run("success with filters", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{
+			Partition: "foo",
+			Filters: []dynamo.Filter{
+				{Op: dynamo.FilterBeginsWith, Name: "name", Value: "ba"},
+				{Op: dynamo.FilterEqual, Name: "status", Value: "active"},
+				{Op: dynamo.FilterNotExists, Name: "deleted_at"},
+			},
+		}
+		itemStubs := &dynamodb.QueryOutput{}
+		actualList := []T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.FilterExpression != nil &&
+				*input.FilterExpression == "(begins_with (#0, :0)) AND (#1 = :1) AND (attribute_not_exists (#2))" &&
+				assert.ObjectsAreEqual(map[string]string{"#0": "name", "#1": "status", "#2": "deleted_at", "#3": "pk"}, input.ExpressionAttributeNames)
+		})
+		dbMock.On("Query", ctx, inputMatcher).
+			Once().
+			Return(itemStubs, nil)
+
+		// execute
+		_, err := sut.Query(ctx, queryStub, &actualList)
+
+		// asserts
+		require.NoError(t, err, "Query() error = %v", err)
+	})
+
+	t.Run("success filling short pages", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{Partition: "foo", Limit: 2}
+		lastEvaluatedKey := dynamo.K2("foo", "PAGE#1")
+		firstStub := &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				{"Foo": &types.AttributeValueMemberS{Value: "bar"}},
+			},
+			LastEvaluatedKey: lastEvaluatedKey,
+		}
+		secondStub := &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				{"Foo": &types.AttributeValueMemberS{Value: "baz"}},
+			},
+		}
+		expectedResult := []T{{Foo: "bar"}, {Foo: "baz"}}
+		actualList := []T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("Query", ctx, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return *input.Limit == 3 && input.ExclusiveStartKey == nil
+		})).
+			Once().
+			Return(firstStub, nil)
+		dbMock.On("Query", ctx, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return *input.Limit == 2 && assert.ObjectsAreEqual(lastEvaluatedKey, input.ExclusiveStartKey)
+		})).
+			Once().
+			Return(secondStub, nil)
+
+		// execute
+		page, err := sut.Query(ctx, queryStub, &actualList)
+
+		// asserts
+		require.NoError(t, err, "Query() error = %v", err)
+		assert.False(t, page.HasMore)
+		assert.Empty(t, page.Next)
+		assert.Equal(t, expectedResult, actualList)
+	})
+
+	t.Run("success trimming extra item", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{Index: dynamo.GSI1, Partition: "WEBSITE", Limit: 1}
+		itemStubs := &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				{
+					"pk":     &types.AttributeValueMemberS{Value: "bar"},
+					"sk":     &types.AttributeValueMemberS{Value: "WEBSITE"},
+					"gsi1pk": &types.AttributeValueMemberS{Value: "WEBSITE"},
+					"gsi1sk": &types.AttributeValueMemberS{Value: "bar"},
+					"Foo":    &types.AttributeValueMemberS{Value: "bar"},
+				},
+				{
+					"pk":     &types.AttributeValueMemberS{Value: "baz"},
+					"sk":     &types.AttributeValueMemberS{Value: "WEBSITE"},
+					"gsi1pk": &types.AttributeValueMemberS{Value: "WEBSITE"},
+					"gsi1sk": &types.AttributeValueMemberS{Value: "baz"},
+					"Foo":    &types.AttributeValueMemberS{Value: "baz"},
+				},
+			},
+			LastEvaluatedKey: dynamo.IndexKey(dynamo.K2("baz", "WEBSITE"), dynamo.GSI1, "WEBSITE", "baz"),
+		}
+		expectedNext := dynamo.IndexKey(dynamo.K2("bar", "WEBSITE"), dynamo.GSI1, "WEBSITE", "bar")
+		expectedResult := []T{{Foo: "bar"}}
+		actualList := []T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("Query", ctx, mock.AnythingOfType("*dynamodb.QueryInput")).
+			Once().
+			Return(itemStubs, nil)
+
+		// execute
+		page, err := sut.Query(ctx, queryStub, &actualList)
+
+		// asserts
+		require.NoError(t, err, "Query() error = %v", err)
+		assert.True(t, page.HasMore)
+		assert.Equal(t, expectedNext, page.Next)
+		assert.Equal(t, expectedResult, actualList)
+	})
+}
+
+func TestRepo_Count(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail error in counting items causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{Partition: "foo"}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("Query", ctx, mock.AnythingOfType("*dynamodb.QueryInput")).
+			Once().
+			Return(nil, assert.AnError)
+
+		// execute
+		_, _, err := sut.Count(ctx, queryStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success exact", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{Partition: "foo"}
+		firstStub := &dynamodb.QueryOutput{Count: 3, LastEvaluatedKey: dynamo.K2("foo", "bar")}
+		secondStub := &dynamodb.QueryOutput{Count: 2}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("Query", ctx, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.Select == types.SelectCount && input.ExclusiveStartKey == nil
+		})).
+			Once().
+			Return(firstStub, nil)
+		dbMock.On("Query", ctx, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.Select == types.SelectCount && input.ExclusiveStartKey != nil
+		})).
+			Once().
+			Return(secondStub, nil)
+
+		// execute
+		count, exact, err := sut.Count(ctx, queryStub)
+
+		// asserts
+		require.NoError(t, err, "Count() error = %v", err)
+		assert.Equal(t, int32(5), count)
+		assert.True(t, exact)
+	})
+
+	t.Run("success approximate", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{Partition: "foo"}
+		outputStub := &dynamodb.QueryOutput{Count: 3, LastEvaluatedKey: dynamo.K2("foo", "bar")}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("Query", ctx, mock.AnythingOfType("*dynamodb.QueryInput")).
+			Return(outputStub, nil)
+
+		// execute
+		count, exact, err := sut.Count(ctx, queryStub)
+
+		// asserts
+		require.NoError(t, err, "Count() error = %v", err)
+		assert.Equal(t, int32(30), count)
+		assert.False(t, exact)
+	})
+}
@@ -2,10 +2,13 @@ package dynamo
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
@@ -14,6 +17,10 @@ import (
 
 const (
 	privateKey = "pk"
+	versionKey = "version"
+
+	// InitialVersion is the version assigned to newly created records.
+	InitialVersion int64 = 1
 
 	errMarshallItem    = "failed to marshal item"
 	errFetchingItems   = "failed to fetch items"
@@ -23,16 +30,23 @@ const (
 	errDeletingItem    = "failed to delete item"
 	errUnmarshallItems = "failed to unmarshal items"
 	errUnmarshallItem  = "failed to unmarshal item"
+	errBuildingExpr    = "failed to build expression"
+	errVersionMismatch = "item version %d is stale"
+	errItemExists      = "item already exists"
+	errItemNotFound    = "item not found"
+	errConditionFailed = "item does not satisfy the conditions of the change"
 )
 
 // Key represents a key ready to be used to find an entity in DynamoDB.
 type Key = map[string]types.AttributeValue
 
 type DB interface {
-	Scan(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	Query(context.Context, *dynamodb.QueryInput, ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
 	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
 	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	UpdateItem(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	TransactWriteItems(context.Context, *dynamodb.TransactWriteItemsInput, ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 }
 
 // Repo represents a repository capable of returning values for DynamoDB.
@@ -67,90 +81,158 @@ func (r *Repo) SetDB(db DB) *Repo {
 	return r
 }
 
-// List lists existing records in the table assigned to the repository.
-func (r *Repo) List(ctx context.Context, limit int32, exclusiveStartKey *Key, result interface{}) (Key, int32, error) {
-	params := &dynamodb.ScanInput{
-		TableName: aws.String(r.tableName),
-		Limit:     &limit,
-	}
-	if exclusiveStartKey != nil {
-		params.ExclusiveStartKey = *exclusiveStartKey
+// Create creates a new record in the table assigned to the repository.
+// The record is stored with InitialVersion regardless of the version it carries and never overwrites an existing one.
+func (r *Repo) Create(ctx context.Context, item interface{}) error {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusBadRequest, errCreatingItem)
 	}
 
-	out, err := r.db.Scan(ctx, params)
+	itemMarshalled[versionKey] = versionValue(InitialVersion)
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name(privateKey))).
+		Build()
 	if err != nil {
-		return Key{}, 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItems)
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
 	}
 
-	if out == nil {
-		return Key{}, 0, lhttp.NewProblem(http.StatusInternalServerError, errFetchingItem)
+	_, err = r.db.PutItem(ctx, &dynamodb.PutItemInput{
+		Item:                      itemMarshalled,
+		TableName:                 aws.String(r.tableName),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if isConditionalCheckFailed(err) {
+		return lhttp.WrapProblem(err, http.StatusConflict, errItemExists)
 	}
 
-	err = attributevalue.UnmarshalListOfMaps(out.Items, result)
 	if err != nil {
-		return Key{}, 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItems)
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errCreatingItem)
 	}
 
-	return out.LastEvaluatedKey, out.ScannedCount, nil
+	return nil
 }
 
-// Create creates a new record in the table assigned to the repository.
-func (r *Repo) Create(ctx context.Context, item interface{}) error {
-	itemMarshalled, err := attributevalue.MarshalMap(item)
+// Get retrieves a record in the table assigned to the repository by key.
+// Missing records leave result untouched, use TypedRepo to have them reported as ErrNotFound instead.
+func (r *Repo) Get(ctx context.Context, key Key, result interface{}) error {
+	item, err := r.getItem(ctx, key)
 	if err != nil {
-		return lhttp.WrapProblem(err, http.StatusBadRequest, errCreatingItem)
+		return err
 	}
 
-	_, err = r.db.PutItem(ctx, &dynamodb.PutItemInput{
-		Item:      itemMarshalled,
-		TableName: aws.String(r.tableName),
-	})
+	err = attributevalue.UnmarshalMap(item, result)
 	if err != nil {
-		return lhttp.WrapProblem(err, http.StatusInternalServerError, errCreatingItem)
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
 	}
 
 	return nil
 }
 
-// Get retrieves a record in the table assigned to the repository by key.
-func (r *Repo) Get(ctx context.Context, key Key, result interface{}) error {
+// Find retrieves a record in the table assigned to the repository by key and tells whether it exists.
+// Missing records leave result untouched.
+func (r *Repo) Find(ctx context.Context, key Key, result interface{}) (bool, error) {
+	item, err := r.getItem(ctx, key)
+	if err != nil || len(item) == 0 {
+		return false, err
+	}
+
+	err = attributevalue.UnmarshalMap(item, result)
+	if err != nil {
+		return false, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	return true, nil
+}
+
+// getItem reads a record by key, the item returned is empty if no record has the key.
+func (r *Repo) getItem(ctx context.Context, key Key) (Key, error) {
 	out, err := r.db.GetItem(ctx, &dynamodb.GetItemInput{
 		Key:       key,
 		TableName: aws.String(r.tableName),
 	})
 	if err != nil {
-		return lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItem)
+		return nil, lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItem)
 	}
 
 	if out == nil {
-		return lhttp.NewProblem(http.StatusInternalServerError, errFetchingItem)
-	}
-
-	err = attributevalue.UnmarshalMap(out.Item, result)
-	if err != nil {
-		return lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+		return nil, lhttp.NewProblem(http.StatusInternalServerError, errFetchingItem)
 	}
 
-	return nil
+	return out.Item, nil
 }
 
 // Update updates the existing record in the table assigned to the repository.
-func (r *Repo) Update(ctx context.Context, item interface{}) error {
+// The write only succeeds if the record exists and is still at the given version, the new version is returned.
+func (r *Repo) Update(ctx context.Context, item interface{}, version int64) (int64, error) {
 	itemMarshalled, err := attributevalue.MarshalMap(item)
 	if err != nil {
-		return lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+		return 0, lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	itemMarshalled[versionKey] = versionValue(version + 1)
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.And(
+			expression.AttributeExists(expression.Name(privateKey)),
+			expression.AttributeNotExists(expression.Name(deletedAtKey)),
+			expression.Name(versionKey).Equal(expression.Value(version)),
+		)).
+		Build()
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
 	}
 
 	_, err = r.db.PutItem(ctx, &dynamodb.PutItemInput{
-		Item:      itemMarshalled,
-		TableName: aws.String(r.tableName),
+		Item:                      itemMarshalled,
+		TableName:                 aws.String(r.tableName),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
 	})
 
+	if isConditionalCheckFailed(err) {
+		return 0, r.conditionFailure(ctx, keyOf(itemMarshalled), version, err)
+	}
+
 	if err != nil {
-		return lhttp.WrapProblem(err, http.StatusInternalServerError, errUpdatingItem)
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errUpdatingItem)
 	}
 
-	return nil
+	return version + 1, nil
+}
+
+// conditionFailure tells apart a missing or trashed record, a stale version and other failed conditions
+// as DynamoDB reports all of them the same way.
+func (r *Repo) conditionFailure(ctx context.Context, key Key, version int64, err error) error {
+	out, getErr := r.db.GetItem(ctx, &dynamodb.GetItemInput{
+		Key:                      key,
+		TableName:                aws.String(r.tableName),
+		ConsistentRead:           aws.Bool(true),
+		ProjectionExpression:     aws.String("#version, #deleted_at"),
+		ExpressionAttributeNames: map[string]string{"#version": versionKey, "#deleted_at": deletedAtKey},
+	})
+	if getErr != nil {
+		return lhttp.WrapProblem(getErr, http.StatusInternalServerError, errFetchingItem)
+	}
+
+	if out == nil || len(out.Item) == 0 {
+		return lhttp.WrapProblem(err, http.StatusNotFound, errItemNotFound)
+	}
+
+	if _, ok := out.Item[deletedAtKey]; ok {
+		return lhttp.WrapProblem(err, http.StatusNotFound, errItemNotFound)
+	}
+
+	stored, ok := out.Item[versionKey].(*types.AttributeValueMemberN)
+	if ok && stored.Value == strconv.FormatInt(version, 10) {
+		return lhttp.WrapProblem(err, http.StatusConflict, errConditionFailed)
+	}
+
+	return lhttp.WrapProblem(err, http.StatusPreconditionFailed, errVersionMismatch, version)
 }
 
 // Delete deletes an existing record in the table assigned to the repository.
@@ -166,3 +248,13 @@ func (r *Repo) Delete(ctx context.Context, key Key) error {
 
 	return nil
 }
+
+func versionValue(version int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)}
+}
+
+func isConditionalCheckFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+
+	return errors.As(err, &ccf)
+}
@@ -50,123 +50,49 @@ func TestK1(t *testing.T) {
 	}
 }
 
-func TestRepo_List(t *testing.T) {
+func TestRepo_Create(t *testing.T) {
 	ctx := context.WithValue(context.Background(), "foo", "bar")
 
-	type T struct {
-		Foo string
-	}
-
-	t.Run("fail returning nil from DynamoDB causes 500 internal server error", func(t *testing.T) {
+	t.Run("fail marshaling item causes 400 bad request", func(t *testing.T) {
 		t.Parallel()
 
-		// stubs
-		var limitStub int32 = 25
-		var exclusiveStartKeyStub *dynamo.Key
-		var itemStub *dynamodb.ScanOutput
-		actualList := []T{}
-
-		// system under test
-		sut, dbMock := createTestRepo()
-
-		// mocks
-		dbMock.On("Scan", ctx, mock.AnythingOfType("*dynamodb.ScanInput")).
-			Once().
-			Return(itemStub, nil)
-
-		// execute
-		_, _, err := sut.List(ctx, limitStub, exclusiveStartKeyStub, &actualList)
-
-		// asserts
-		require.Error(t, err)
-		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+		// it's unclear if this can ever happen as it calls `attributevalue.MarshalMap` under the hood,
+		// which is designed to always return something
+		t.Skip()
 	})
 
-	t.Run("fail error in retrieving item causes 500 internal server error", func(t *testing.T) {
+	t.Run("fail error in updating item causes 500 internal server error", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
-		var limitStub int32 = 25
-		var exclusiveStartKeyStub *dynamo.Key
-		actualList := []T{}
+		itemStub := map[string]string{
+			"foo": "bar",
+		}
 
 		// system under test
 		sut, dbMock := createTestRepo()
 
 		// mocks
-		dbMock.On("Scan", ctx, mock.AnythingOfType("*dynamodb.ScanInput")).
+		dbMock.On("PutItem", ctx, mock.AnythingOfType("*dynamodb.PutItemInput")).
 			Once().
 			Return(nil, assert.AnError)
 
 		// execute
-		_, _, err := sut.List(ctx, limitStub, exclusiveStartKeyStub, &actualList)
+		err := sut.Create(ctx, itemStub)
 
 		// asserts
 		require.Error(t, err)
 		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
 	})
 
-	t.Run("success", func(t *testing.T) {
-		t.Parallel()
-
-		// stubs
-		var limitStub int32 = 25
-		exclusiveStartKeyStub := dynamo.K1("foo")
-		actualList := []T{}
-
-		var scannedCount int32 = 15
-		exclusiveLastEvaluatedKey := dynamo.K1("foo")
-		itemStubs := &dynamodb.ScanOutput{
-			Items: []map[string]types.AttributeValue{
-				{
-					"Foo": &types.AttributeValueMemberS{Value: "bar"},
-				},
-				{
-					"Foo": &types.AttributeValueMemberS{Value: "baz"},
-				},
-			},
-			ScannedCount:     scannedCount,
-			LastEvaluatedKey: exclusiveLastEvaluatedKey,
-		}
-		expectedResult := []T{{Foo: "bar"}, {Foo: "baz"}}
-
-		// system under test
-		sut, dbMock := createTestRepo()
-
-		// mocks
-		dbMock.On("Scan", ctx, mock.AnythingOfType("*dynamodb.ScanInput")).
-			Once().
-			Return(itemStubs, nil)
-
-		// execute
-		actualLastEvaluatedKey, actualScannedCount, err := sut.List(ctx, limitStub, &exclusiveStartKeyStub, &actualList)
-
-		// asserts
-		require.NoError(t, err, "Get() error = %v", err)
-		assert.Equal(t, exclusiveLastEvaluatedKey, actualLastEvaluatedKey)
-		assert.Equal(t, scannedCount, actualScannedCount)
-		assert.Equal(t, expectedResult, actualList)
-	})
-}
-
-func TestRepo_Create(t *testing.T) {
-	ctx := context.WithValue(context.Background(), "foo", "bar")
-
-	t.Run("fail marshaling item causes 400 bad request", func(t *testing.T) {
-		t.Parallel()
-
-		// it's unclear if this can ever happen as it calls `attributevalue.MarshalMap` under the hood,
-		// which is designed to always return something
-		t.Skip()
-	})
-
-	t.Run("fail error in updating item causes 500 internal server error", func(t *testing.T) {
+	t.Run("fail existing item causes 409 conflict", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		itemStub := map[string]string{
-			"foo": "bar",
+			"pk": "foo",
 		}
+		errStub := &types.ConditionalCheckFailedException{}
 
 		// system under test
 		sut, dbMock := createTestRepo()
@@ -174,14 +100,14 @@ func TestRepo_Create(t *testing.T) {
 		// mocks
 		dbMock.On("PutItem", ctx, mock.AnythingOfType("*dynamodb.PutItemInput")).
 			Once().
-			Return(nil, assert.AnError)
+			Return(nil, errStub)
 
 		// execute
 		err := sut.Create(ctx, itemStub)
 
 		// asserts
 		require.Error(t, err)
-		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
 	})
 
 	t.Run("success", func(t *testing.T) {
@@ -195,7 +121,10 @@ func TestRepo_Create(t *testing.T) {
 		// system under test
 		sut, dbMock := createTestRepo()
 
-		dbMock.On("PutItem", ctx, mock.AnythingOfType("*dynamodb.PutItemInput")).
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+			return input.ConditionExpression != nil && *input.ConditionExpression == "attribute_not_exists (#0)"
+		})
+		dbMock.On("PutItem", ctx, inputMatcher).
 			Once().
 			Return(nil, nil)
 
@@ -316,13 +245,107 @@ func TestRepo_Update(t *testing.T) {
 			Return(nil, assert.AnError)
 
 		// execute
-		err := sut.Update(ctx, itemStub)
+		_, err := sut.Update(ctx, itemStub, 3)
 
 		// asserts
 		require.Error(t, err)
 		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
 	})
 
+	t.Run("fail missing item causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := map[string]string{
+			"pk": "foo",
+		}
+		errStub := &types.ConditionalCheckFailedException{}
+		getItemStub := &dynamodb.GetItemOutput{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("PutItem", ctx, mock.AnythingOfType("*dynamodb.PutItemInput")).
+			Once().
+			Return(nil, errStub)
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(getItemStub, nil)
+
+		// execute
+		_, err := sut.Update(ctx, itemStub, 3)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail trashed item causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := map[string]string{
+			"pk": "foo",
+		}
+		errStub := &types.ConditionalCheckFailedException{}
+		getItemStub := &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"version":    &types.AttributeValueMemberN{Value: "3"},
+				"deleted_at": &types.AttributeValueMemberS{Value: "2022-06-01T10:00:00Z"},
+			},
+		}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("PutItem", ctx, mock.AnythingOfType("*dynamodb.PutItemInput")).
+			Once().
+			Return(nil, errStub)
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(getItemStub, nil)
+
+		// execute
+		_, err := sut.Update(ctx, itemStub, 3)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail stale version causes 412 precondition failed", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := map[string]string{
+			"pk": "foo",
+		}
+		errStub := &types.ConditionalCheckFailedException{}
+		getItemStub := &dynamodb.GetItemOutput{
+			Item: dynamo.K1("foo"),
+		}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("PutItem", ctx, mock.AnythingOfType("*dynamodb.PutItemInput")).
+			Once().
+			Return(nil, errStub)
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(getItemStub, nil)
+
+		// execute
+		_, err := sut.Update(ctx, itemStub, 3)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, lhttp.ToProblem(err).Status)
+	})
+
 	t.Run("success", func(t *testing.T) {
 		t.Parallel()
 
@@ -335,15 +358,21 @@ func TestRepo_Update(t *testing.T) {
 		sut, dbMock := createTestRepo()
 
 		// mocks
-		dbMock.On("PutItem", ctx, mock.AnythingOfType("*dynamodb.PutItemInput")).
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+			return assert.ObjectsAreEqual(&types.AttributeValueMemberN{Value: "4"}, input.Item["version"]) &&
+				input.ConditionExpression != nil &&
+				assert.ObjectsAreEqual(&types.AttributeValueMemberN{Value: "3"}, input.ExpressionAttributeValues[":0"])
+		})
+		dbMock.On("PutItem", ctx, inputMatcher).
 			Once().
 			Return(nil, nil)
 
 		// execute
-		err := sut.Update(ctx, itemStub)
+		version, err := sut.Update(ctx, itemStub, 3)
 
 		// asserts
 		require.NoError(t, err, "Update() error = %v", err)
+		assert.Equal(t, int64(4), version)
 	})
 }
 
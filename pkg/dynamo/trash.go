@@ -0,0 +1,137 @@
+package dynamo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	deletedAtKey = "deleted_at"
+
+	// ExpiresAtKey is the attribute DynamoDB TTL is configured on, trashed records are purged after it passes.
+	ExpiresAtKey = "expires_at"
+
+	errTrashingItem   = "failed to trash item"
+	errRestoringItem  = "failed to restore item"
+	errItemNotTrashed = "item not found in trash"
+)
+
+// Trash marks an existing record as deleted instead of removing it.
+// The record is moved to the given partition of the first index, so that it disappears from regular listings.
+// If expiresAt is not zero, DynamoDB purges the record once it passes.
+func (r *Repo) Trash(ctx context.Context, key Key, index Keys, deletedAt, expiresAt time.Time) error {
+	expr, err := trashExpression(index, deletedAt, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		Key:                       key,
+		TableName:                 aws.String(r.tableName),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	if isConditionalCheckFailed(err) {
+		return lhttp.WrapProblem(err, http.StatusNotFound, errItemNotFound)
+	}
+
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errTrashingItem)
+	}
+
+	return nil
+}
+
+// Restore brings back a trashed record, moving it back to the given partition of the first index.
+// The restored record is unmarshalled into result.
+func (r *Repo) Restore(ctx context.Context, key Key, index Keys, result interface{}) error {
+	expr, err := restoreExpression(index)
+	if err != nil {
+		return err
+	}
+
+	out, err := r.db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		Key:                       key,
+		TableName:                 aws.String(r.tableName),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+
+	if isConditionalCheckFailed(err) {
+		return lhttp.WrapProblem(err, http.StatusNotFound, errItemNotTrashed)
+	}
+
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errRestoringItem)
+	}
+
+	if out == nil {
+		return lhttp.NewProblem(http.StatusInternalServerError, errRestoringItem)
+	}
+
+	err = attributevalue.UnmarshalMap(out.Attributes, result)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	return nil
+}
+
+// trashExpression builds the update marking a record as deleted, failing for missing or trashed records.
+func trashExpression(index Keys, deletedAt, expiresAt time.Time) (expression.Expression, error) {
+	update := expression.
+		Set(expression.Name(deletedAtKey), expression.Value(deletedAt.UTC())).
+		Set(expression.Name(gsi1PartitionKey), expression.Value(index.GSI1PK)).
+		Set(expression.Name(gsi1SortKey), expression.Value(index.GSI1SK)).
+		Set(expression.Name(versionKey), expression.Name(versionKey).Plus(expression.Value(1)))
+
+	if !expiresAt.IsZero() {
+		update = update.Set(expression.Name(ExpiresAtKey), expression.Value(expiresAt.Unix()))
+	}
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.And(
+			expression.AttributeExists(expression.Name(privateKey)),
+			expression.AttributeNotExists(expression.Name(deletedAtKey)),
+		)).
+		Build()
+	if err != nil {
+		return expr, lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
+	}
+
+	return expr, nil
+}
+
+// restoreExpression builds the update bringing back a trashed record, failing for records not in the trash.
+func restoreExpression(index Keys) (expression.Expression, error) {
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.
+			Set(expression.Name(gsi1PartitionKey), expression.Value(index.GSI1PK)).
+			Set(expression.Name(gsi1SortKey), expression.Value(index.GSI1SK)).
+			Set(expression.Name(versionKey), expression.Name(versionKey).Plus(expression.Value(1))).
+			Remove(expression.Name(deletedAtKey)).
+			Remove(expression.Name(ExpiresAtKey))).
+		WithCondition(expression.AttributeExists(expression.Name(deletedAtKey))).
+		Build()
+	if err != nil {
+		return expr, lhttp.WrapProblem(err, http.StatusInternalServerError, errBuildingExpr)
+	}
+
+	return expr, nil
+}
@@ -0,0 +1,180 @@
+package dynamo_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+func TestRepo_Trash(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+	deletedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("fail missing or trashed item causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K2("foo", "WEBSITE")
+		indexStub := dynamo.Keys{GSI1PK: "TRASH#WEBSITE", GSI1SK: "foo"}
+		errStub := &types.ConditionalCheckFailedException{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("UpdateItem", ctx, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+			Once().
+			Return(nil, errStub)
+
+		// execute
+		err := sut.Trash(ctx, keyStub, indexStub, deletedAt, time.Time{})
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail error in updating item causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K2("foo", "WEBSITE")
+		indexStub := dynamo.Keys{GSI1PK: "TRASH#WEBSITE", GSI1SK: "foo"}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("UpdateItem", ctx, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+			Once().
+			Return(nil, assert.AnError)
+
+		// execute
+		err := sut.Trash(ctx, keyStub, indexStub, deletedAt, time.Time{})
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success /w expiry", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K2("foo", "WEBSITE")
+		indexStub := dynamo.Keys{GSI1PK: "TRASH#WEBSITE", GSI1SK: "foo"}
+		expiresAt := deletedAt.Add(30 * 24 * time.Hour)
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			values := make(map[string]bool)
+			for _, value := range input.ExpressionAttributeValues {
+				switch v := value.(type) {
+				case *types.AttributeValueMemberS:
+					values[v.Value] = true
+				case *types.AttributeValueMemberN:
+					values[v.Value] = true
+				}
+			}
+
+			return assert.ObjectsAreEqual(keyStub, input.Key) &&
+				input.ConditionExpression != nil &&
+				values["2022-06-01T10:00:00Z"] &&
+				values["TRASH#WEBSITE"] &&
+				values["1656669600"]
+		})
+		dbMock.On("UpdateItem", ctx, inputMatcher).
+			Once().
+			Return(&dynamodb.UpdateItemOutput{}, nil)
+
+		// execute
+		err := sut.Trash(ctx, keyStub, indexStub, deletedAt, expiresAt)
+
+		// asserts
+		require.NoError(t, err, "Trash() error = %v", err)
+	})
+}
+
+func TestRepo_Restore(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	type T struct {
+		Foo     string `dynamodbav:"foo"`
+		Version int64  `dynamodbav:"version"`
+	}
+
+	t.Run("fail item not in trash causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K2("foo", "WEBSITE")
+		indexStub := dynamo.Keys{GSI1PK: "WEBSITE", GSI1SK: "foo"}
+		errStub := &types.ConditionalCheckFailedException{}
+		actualResult := T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		dbMock.On("UpdateItem", ctx, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+			Once().
+			Return(nil, errStub)
+
+		// execute
+		err := sut.Restore(ctx, keyStub, indexStub, &actualResult)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K2("foo", "WEBSITE")
+		indexStub := dynamo.Keys{GSI1PK: "WEBSITE", GSI1SK: "foo"}
+		outputStub := &dynamodb.UpdateItemOutput{
+			Attributes: map[string]types.AttributeValue{
+				"foo":     &types.AttributeValueMemberS{Value: "bar"},
+				"version": &types.AttributeValueMemberN{Value: "5"},
+			},
+		}
+		expectedResult := T{Foo: "bar", Version: 5}
+		actualResult := T{}
+
+		// system under test
+		sut, dbMock := createTestRepo()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return assert.ObjectsAreEqual(keyStub, input.Key) &&
+				input.ReturnValues == types.ReturnValueAllNew &&
+				*input.ConditionExpression == "attribute_exists (#0)" &&
+				input.ExpressionAttributeNames["#0"] == "deleted_at"
+		})
+		dbMock.On("UpdateItem", ctx, inputMatcher).
+			Once().
+			Return(outputStub, nil)
+
+		// execute
+		err := sut.Restore(ctx, keyStub, indexStub, &actualResult)
+
+		// asserts
+		require.NoError(t, err, "Restore() error = %v", err)
+		assert.Equal(t, expectedResult, actualResult)
+	})
+}
@@ -0,0 +1,132 @@
+package dynamo
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+)
+
+// ErrNotFound is the problem of a record missing from the table.
+// TypedRepo wraps it in the errors it returns, therefore callers can tell missing records apart via errors.Is.
+var ErrNotFound = lhttp.NewProblem(http.StatusNotFound, errItemNotFound)
+
+// Store is the contract of the storage of records shared by Repo and its in-memory counterpart, memory.Store.
+// Implementations are checked against it by the conformance suite in package storetest.
+type Store interface {
+	Find(ctx context.Context, key Key, result interface{}) (bool, error)
+	Get(ctx context.Context, key Key, result interface{}) error
+	Query(ctx context.Context, query Query, result interface{}) (Page, error)
+	Create(ctx context.Context, item interface{}) error
+	Update(ctx context.Context, item interface{}, version int64) (int64, error)
+	Patch(ctx context.Context, key Key, version int64, ops []patch.Operation, result interface{}) (int64, error)
+	Delete(ctx context.Context, key Key) error
+}
+
+// TypedRepo is a Store holding records of a single type, which are returned as values instead of being unmarshalled
+// into pointers provided by callers.
+type TypedRepo[T any] struct {
+	repo Store
+}
+
+// NewTypedRepo creates a new TypedRepo instance on top of a Store, usually a Repo.
+func NewTypedRepo[T any](repo Store) *TypedRepo[T] {
+	return &TypedRepo[T]{
+		repo: repo,
+	}
+}
+
+// Get retrieves a record by key, a missing record results in an error wrapping ErrNotFound.
+func (r *TypedRepo[T]) Get(ctx context.Context, key Key) (T, error) {
+	var result T
+
+	found, err := r.repo.Find(ctx, key, &result)
+	if err != nil {
+		return result, err
+	}
+
+	if !found {
+		return result, lhttp.WrapProblem(ErrNotFound, http.StatusNotFound, errFetchingItem)
+	}
+
+	return result, nil
+}
+
+// Query lists records in a partition of the table or one of its indexes, see Repo.Query.
+// The records returned are never nil, therefore an empty page is marshalled as an empty list.
+func (r *TypedRepo[T]) Query(ctx context.Context, query Query) ([]T, Page, error) {
+	result := []T{}
+
+	page, err := r.repo.Query(ctx, query, &result)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	return result, page, nil
+}
+
+// Create creates a new record, see Repo.Create.
+func (r *TypedRepo[T]) Create(ctx context.Context, item T) error {
+	return r.repo.Create(ctx, item)
+}
+
+// Update updates an existing record at the given version and returns the new version, see Repo.Update.
+func (r *TypedRepo[T]) Update(ctx context.Context, item T, version int64) (int64, error) {
+	return r.repo.Update(ctx, item, version)
+}
+
+// Patch applies the operations to an existing record at the given version, see Repo.Patch.
+// The updated record is returned along with its new version.
+func (r *TypedRepo[T]) Patch(ctx context.Context, key Key, version int64, ops []patch.Operation) (T, int64, error) {
+	var result T
+
+	newVersion, err := r.repo.Patch(ctx, key, version, ops, &result)
+	if err != nil {
+		return result, 0, err
+	}
+
+	return result, newVersion, nil
+}
+
+// Delete deletes an existing record, see Repo.Delete.
+func (r *TypedRepo[T]) Delete(ctx context.Context, key Key) error {
+	return r.repo.Delete(ctx, key)
+}
+
+// GuardedStore is a Store writing records along with their guards, see Guards.
+type GuardedStore interface {
+	Store
+	CreateGuarded(ctx context.Context, item interface{}, guards Guards) error
+	UpdateGuarded(ctx context.Context, item interface{}, version int64, guards Guards) (int64, error)
+	DeleteGuarded(ctx context.Context, key Key, guards []Key) error
+}
+
+// GuardedRepo is a TypedRepo whose records may be written along with guards reserving their unique values.
+type GuardedRepo[T any] struct {
+	*TypedRepo[T]
+	guarded GuardedStore
+}
+
+// NewGuardedRepo creates a new GuardedRepo instance on top of a GuardedStore, usually a Repo.
+func NewGuardedRepo[T any](repo GuardedStore) *GuardedRepo[T] {
+	return &GuardedRepo[T]{
+		TypedRepo: NewTypedRepo[T](repo),
+		guarded:   repo,
+	}
+}
+
+// CreateGuarded creates a new record along with its guards, see Repo.CreateGuarded.
+func (r *GuardedRepo[T]) CreateGuarded(ctx context.Context, item T, guards Guards) error {
+	return r.guarded.CreateGuarded(ctx, item, guards)
+}
+
+// UpdateGuarded updates an existing record at the given version along with its guards, see Repo.UpdateGuarded.
+func (r *GuardedRepo[T]) UpdateGuarded(ctx context.Context, item T, version int64, guards Guards) (int64, error) {
+	return r.guarded.UpdateGuarded(ctx, item, version, guards)
+}
+
+// DeleteGuarded deletes an existing record along with its guards, see Repo.DeleteGuarded.
+func (r *GuardedRepo[T]) DeleteGuarded(ctx context.Context, key Key, guards []Key) error {
+	return r.guarded.DeleteGuarded(ctx, key, guards)
+}
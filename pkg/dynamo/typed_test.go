@@ -0,0 +1,267 @@
+package dynamo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+)
+
+type typedStub struct {
+	ID  string `dynamodbav:"pk"`
+	Foo string `dynamodbav:"foo"`
+}
+
+func TestTypedRepo_Get(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail missing item causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		itemStub := &dynamodb.GetItemOutput{Item: nil}
+
+		// system under test
+		repo, dbMock := createTestRepo()
+		sut := dynamo.NewTypedRepo[typedStub](repo)
+
+		// mocks
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(itemStub, nil)
+
+		// execute
+		_, err := sut.Get(ctx, keyStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, dynamo.ErrNotFound))
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail error in retrieving item causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+
+		// system under test
+		repo, dbMock := createTestRepo()
+		sut := dynamo.NewTypedRepo[typedStub](repo)
+
+		// mocks
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(nil, assert.AnError)
+
+		// execute
+		_, err := sut.Get(ctx, keyStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, dynamo.ErrNotFound))
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		itemStub := &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"pk":  &types.AttributeValueMemberS{Value: "foo"},
+				"foo": &types.AttributeValueMemberS{Value: "bar"},
+			},
+		}
+
+		// system under test
+		repo, dbMock := createTestRepo()
+		sut := dynamo.NewTypedRepo[typedStub](repo)
+
+		// mocks
+		dbMock.On("GetItem", ctx, mock.AnythingOfType("*dynamodb.GetItemInput")).
+			Once().
+			Return(itemStub, nil)
+
+		// execute
+		got, err := sut.Get(ctx, keyStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, typedStub{ID: "foo", Foo: "bar"}, got)
+	})
+}
+
+func TestTypedRepo_Query(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("success empty partition returns empty list", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{
+			Index:             "",
+			Partition:         "foo",
+			SortKey:           nil,
+			Filters:           nil,
+			Limit:             10,
+			ExclusiveStartKey: nil,
+			Descending:        false,
+		}
+		outputStub := &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{}}
+
+		// system under test
+		repo, dbMock := createTestRepo()
+		sut := dynamo.NewTypedRepo[typedStub](repo)
+
+		// mocks
+		dbMock.On("Query", ctx, mock.AnythingOfType("*dynamodb.QueryInput")).
+			Once().
+			Return(outputStub, nil)
+
+		// execute
+		got, page, err := sut.Query(ctx, queryStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Empty(t, got)
+		assert.False(t, page.HasMore)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		queryStub := dynamo.Query{
+			Index:             "",
+			Partition:         "foo",
+			SortKey:           nil,
+			Filters:           nil,
+			Limit:             10,
+			ExclusiveStartKey: nil,
+			Descending:        false,
+		}
+		outputStub := &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				{"pk": &types.AttributeValueMemberS{Value: "foo"}, "foo": &types.AttributeValueMemberS{Value: "bar"}},
+				{"pk": &types.AttributeValueMemberS{Value: "foo"}, "foo": &types.AttributeValueMemberS{Value: "baz"}},
+			},
+		}
+
+		// system under test
+		repo, dbMock := createTestRepo()
+		sut := dynamo.NewTypedRepo[typedStub](repo)
+
+		// mocks
+		dbMock.On("Query", ctx, mock.AnythingOfType("*dynamodb.QueryInput")).
+			Once().
+			Return(outputStub, nil)
+
+		// execute
+		got, _, err := sut.Query(ctx, queryStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []typedStub{{ID: "foo", Foo: "bar"}, {ID: "foo", Foo: "baz"}}, got)
+	})
+}
+
+func TestTypedRepo_Patch(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		keyStub := dynamo.K1("foo")
+		opsStub := []patch.Operation{{Op: patch.OpSet, Path: patch.Path{"foo"}, Value: "baz"}}
+		outputStub := &dynamodb.UpdateItemOutput{
+			Attributes: map[string]types.AttributeValue{
+				"pk":  &types.AttributeValueMemberS{Value: "foo"},
+				"foo": &types.AttributeValueMemberS{Value: "baz"},
+			},
+		}
+
+		// system under test
+		repo, dbMock := createTestRepo()
+		sut := dynamo.NewTypedRepo[typedStub](repo)
+
+		// mocks
+		dbMock.On("UpdateItem", ctx, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+			Once().
+			Return(outputStub, nil)
+
+		// execute
+		got, version, err := sut.Patch(ctx, keyStub, 3, opsStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, typedStub{ID: "foo", Foo: "baz"}, got)
+		assert.Equal(t, int64(4), version)
+	})
+}
+
+func TestGuardedRepo_CreateGuarded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "foo", "bar")
+
+	t.Run("fail taken unique value causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := typedStub{ID: "foo", Foo: "bar"}
+		guardsStub := dynamo.Guards{Add: []interface{}{guardItem{PK: "foo", SK: "PATH#/baz"}}, Remove: nil, Keep: nil}
+
+		// system under test
+		repo, dbMock := createTestRepo()
+		sut := dynamo.NewGuardedRepo[typedStub](repo)
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(nil, canceledAt(1, 2))
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub, guardsStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		itemStub := typedStub{ID: "foo", Foo: "bar"}
+		guardsStub := dynamo.Guards{Add: []interface{}{guardItem{PK: "foo", SK: "PATH#/baz"}}, Remove: nil, Keep: nil}
+
+		// system under test
+		repo, dbMock := createTestRepo()
+		sut := dynamo.NewGuardedRepo[typedStub](repo)
+
+		// mocks
+		dbMock.On("TransactWriteItems", ctx, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).
+			Once().
+			Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub, guardsStub)
+
+		// asserts
+		require.NoError(t, err)
+		dbMock.AssertExpectations(t)
+	})
+}
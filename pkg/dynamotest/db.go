@@ -0,0 +1,522 @@
+// Package dynamotest for running code written against dynamo.DB on an in-memory table, e.g. in unit tests
+package dynamotest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+const (
+	codeValidation       = "ValidationException"
+	reasonNone           = "None"
+	reasonConditionCheck = "ConditionalCheckFailed"
+	reasonValidation     = "ValidationError"
+
+	maxTransactItems = 100
+
+	errConditionFailed = "The conditional request failed"
+	errKeySchema       = "The provided key element does not match the schema"
+)
+
+// DB implements dynamo.DB in memory, evaluating the expressions of requests the way DynamoDB does.
+// Tables are created on first use and share the key schema of the single table design: pk and sk as the table key
+// and gsi1pk and gsi1sk as the key of dynamo.GSI1. Key attributes are strings, like everywhere in the table.
+// Errors are the ones DynamoDB returns, e.g. a ConditionalCheckFailedException for a failed condition, a
+// TransactionCanceledException with cancellation reasons for a failed transaction and a ValidationException for an
+// invalid expression. DB is safe for concurrent use and applies every request, including transactions, atomically.
+type DB struct {
+	mu     sync.Mutex
+	tables map[string]map[string]dynamo.Key
+}
+
+// NewDB creates a new DB instance without any items.
+func NewDB() *DB {
+	return &DB{
+		mu:     sync.Mutex{},
+		tables: map[string]map[string]dynamo.Key{},
+	}
+}
+
+// write is a change of a single item, prepared before the lock is taken.
+// apply returns the item to store in place of the current one, nil to delete it.
+type write struct {
+	table     string
+	id        string
+	condition condition
+	apply     func(current dynamo.Key) (dynamo.Key, error)
+}
+
+// PutItem creates or replaces an item if its condition holds.
+func (db *DB) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	w, err := preparePut(params.TableName, params.Item, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.ReturnValues != "" && params.ReturnValues != types.ReturnValueNone && params.ReturnValues != types.ReturnValueAllOld {
+		return nil, validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	current, _, err := db.commit(w)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = current
+	}
+
+	return out, nil
+}
+
+// GetItem reads an item, the output holds no item if it does not exist.
+func (db *DB) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	table, err := tableName(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := keyID(params.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newParser(params.ExpressionAttributeNames, nil)
+
+	var projection []path
+	if params.ProjectionExpression != nil {
+		projection, err = p.parseProjection(*params.ProjectionExpression)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = p.checkUnused()
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	item, ok := db.tables[table][id]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	if projection != nil {
+		return &dynamodb.GetItemOutput{Item: project(item, projection)}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: attr.CloneItem(item)}, nil
+}
+
+// DeleteItem deletes an item if its condition holds, deleting a missing item is not an error.
+func (db *DB) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	w, err := prepareDelete(params.TableName, params.Key, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.ReturnValues != "" && params.ReturnValues != types.ReturnValueNone && params.ReturnValues != types.ReturnValueAllOld {
+		return nil, validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	current, _, err := db.commit(w)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = current
+	}
+
+	return out, nil
+}
+
+// UpdateItem applies an update expression to an item if its condition holds, a missing item is created from its key.
+func (db *DB) UpdateItem(_ context.Context, params *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	w, actions, err := prepareUpdate(params.TableName, params.Key, params.UpdateExpression, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	current, updated, err := db.commit(w)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.UpdateItemOutput{}
+
+	switch params.ReturnValues {
+	case types.ReturnValueAllNew:
+		out.Attributes = attr.CloneItem(updated)
+	case types.ReturnValueAllOld:
+		out.Attributes = current
+	case types.ReturnValueUpdatedNew:
+		out.Attributes = project(updated, topLevelPaths(actions))
+	case types.ReturnValueUpdatedOld:
+		out.Attributes = project(current, topLevelPaths(actions))
+	}
+
+	return out, nil
+}
+
+// TransactWriteItems applies all the changes or none of them.
+// Every condition is checked before anything is changed, if one of them fails the transaction is canceled with a
+// cancellation reason for each item, telling which ones failed their conditions.
+func (db *DB) TransactWriteItems(_ context.Context, params *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > maxTransactItems {
+		return nil, validationError("Member must have length less than or equal to %d and greater than or equal to 1", maxTransactItems)
+	}
+
+	writes := make([]write, 0, len(params.TransactItems))
+	seen := map[string]bool{}
+
+	for _, item := range params.TransactItems {
+		w, err := prepareTransactItem(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[w.table+"\x00"+w.id] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+
+		seen[w.table+"\x00"+w.id] = true
+		writes = append(writes, w)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var (
+		reasons  = make([]types.CancellationReason, len(writes))
+		updated  = make([]dynamo.Key, len(writes))
+		canceled = false
+	)
+
+	for i, w := range writes {
+		reasons[i] = types.CancellationReason{Code: aws.String(reasonNone)}
+
+		current := db.tables[w.table][w.id]
+
+		ok, err := holds(current, w.condition)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			reasons[i] = types.CancellationReason{Code: aws.String(reasonConditionCheck), Message: aws.String(errConditionFailed)}
+			canceled = true
+
+			continue
+		}
+
+		updated[i], err = w.apply(current)
+		if err != nil {
+			reasons[i] = types.CancellationReason{Code: aws.String(reasonValidation), Message: aws.String(err.Error())}
+			canceled = true
+		}
+	}
+
+	if canceled {
+		return nil, transactionCanceled(reasons)
+	}
+
+	for i, w := range writes {
+		db.store(w, updated[i])
+	}
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func prepareTransactItem(item types.TransactWriteItem) (write, error) {
+	switch {
+	case item.Put != nil:
+		p := item.Put
+
+		return preparePut(p.TableName, p.Item, p.ConditionExpression, p.ExpressionAttributeNames, p.ExpressionAttributeValues)
+	case item.Update != nil:
+		u := item.Update
+		w, _, err := prepareUpdate(u.TableName, u.Key, u.UpdateExpression, u.ConditionExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues)
+
+		return w, err
+	case item.Delete != nil:
+		d := item.Delete
+
+		return prepareDelete(d.TableName, d.Key, d.ConditionExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues)
+	case item.ConditionCheck != nil:
+		c := item.ConditionCheck
+		if c.ConditionExpression == nil {
+			return write{}, validationError("ConditionCheck requires a ConditionExpression")
+		}
+
+		w, err := prepareDelete(c.TableName, c.Key, c.ConditionExpression, c.ExpressionAttributeNames, c.ExpressionAttributeValues)
+		w.apply = func(current dynamo.Key) (dynamo.Key, error) { return current, nil }
+
+		return w, err
+	default:
+		return write{}, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+}
+
+func preparePut(table *string, item dynamo.Key, expr *string, names map[string]string, values map[string]types.AttributeValue) (write, error) {
+	name, err := tableName(table)
+	if err != nil {
+		return write{}, err
+	}
+
+	id, err := itemID(item)
+	if err != nil {
+		return write{}, err
+	}
+
+	c, err := parseWriteCondition(expr, names, values)
+	if err != nil {
+		return write{}, err
+	}
+
+	stored := attr.CloneItem(item)
+
+	return write{
+		table:     name,
+		id:        id,
+		condition: c,
+		apply:     func(dynamo.Key) (dynamo.Key, error) { return stored, nil },
+	}, nil
+}
+
+func prepareDelete(table *string, key dynamo.Key, expr *string, names map[string]string, values map[string]types.AttributeValue) (write, error) {
+	name, err := tableName(table)
+	if err != nil {
+		return write{}, err
+	}
+
+	id, err := keyID(key)
+	if err != nil {
+		return write{}, err
+	}
+
+	c, err := parseWriteCondition(expr, names, values)
+	if err != nil {
+		return write{}, err
+	}
+
+	return write{
+		table:     name,
+		id:        id,
+		condition: c,
+		apply:     func(dynamo.Key) (dynamo.Key, error) { return nil, nil },
+	}, nil
+}
+
+func prepareUpdate(table *string, key dynamo.Key, update, expr *string, names map[string]string, values map[string]types.AttributeValue) (write, []action, error) {
+	name, err := tableName(table)
+	if err != nil {
+		return write{}, nil, err
+	}
+
+	id, err := keyID(key)
+	if err != nil {
+		return write{}, nil, err
+	}
+
+	if update == nil || *update == "" {
+		return write{}, nil, validationError("UpdateExpression is required")
+	}
+
+	p := newParser(names, values)
+
+	actions, err := p.parseUpdate(*update)
+	if err != nil {
+		return write{}, nil, err
+	}
+
+	var c condition
+	if expr != nil {
+		c, err = p.parseCondition(*expr)
+		if err != nil {
+			return write{}, nil, err
+		}
+	}
+
+	err = p.checkUnused()
+	if err != nil {
+		return write{}, nil, err
+	}
+
+	apply := func(current dynamo.Key) (dynamo.Key, error) {
+		if current == nil {
+			current = attr.CloneItem(key)
+		}
+
+		return applyUpdate(current, actions)
+	}
+
+	return write{table: name, id: id, condition: c, apply: apply}, actions, nil
+}
+
+func parseWriteCondition(expr *string, names map[string]string, values map[string]types.AttributeValue) (condition, error) {
+	p := newParser(names, values)
+
+	var (
+		c   condition
+		err error
+	)
+
+	if expr != nil {
+		c, err = p.parseCondition(*expr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, p.checkUnused()
+}
+
+// commit applies a single write, it returns the item before and after the write.
+// The caller must hold the lock.
+func (db *DB) commit(w write) (dynamo.Key, dynamo.Key, error) {
+	current := db.tables[w.table][w.id]
+
+	ok, err := holds(current, w.condition)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !ok {
+		return nil, nil, &types.ConditionalCheckFailedException{Message: aws.String(errConditionFailed)}
+	}
+
+	updated, err := w.apply(current)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db.store(w, updated)
+
+	if current == nil {
+		return nil, updated, nil
+	}
+
+	return attr.CloneItem(current), updated, nil
+}
+
+func (db *DB) store(w write, item dynamo.Key) {
+	table, ok := db.tables[w.table]
+	if !ok {
+		table = map[string]dynamo.Key{}
+		db.tables[w.table] = table
+	}
+
+	if item == nil {
+		delete(table, w.id)
+
+		return
+	}
+
+	table[w.id] = item
+}
+
+// holds tells whether the condition of a write holds for the current item, missing items have no attributes.
+func holds(current dynamo.Key, c condition) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+
+	if current == nil {
+		current = dynamo.Key{}
+	}
+
+	return evalCondition(current, c)
+}
+
+func tableName(name *string) (string, error) {
+	if aws.ToString(name) == "" {
+		return "", validationError("TableName is required")
+	}
+
+	return *name, nil
+}
+
+// keyID returns the identifier of the item a key refers to, the key must consist of the key attributes of the table.
+func keyID(key dynamo.Key) (string, error) {
+	if len(key) != 2 {
+		return "", validationError(errKeySchema)
+	}
+
+	return itemID(key)
+}
+
+// itemID returns the identifier of an item, which is made of its partition and sort key.
+// Like DynamoDB, it rejects items with missing or empty keys and non-string index keys.
+func itemID(item dynamo.Key) (string, error) {
+	partitionName, sortName := dynamo.KeyNames("")
+	indexPartitionName, indexSortName := dynamo.KeyNames(dynamo.GSI1)
+
+	for _, name := range []string{indexPartitionName, indexSortName} {
+		if value, ok := item[name]; ok {
+			if s, ok := value.(*types.AttributeValueMemberS); !ok || s.Value == "" {
+				return "", validationError("One or more parameter values were invalid: Type mismatch for Index Key %s", name)
+			}
+		}
+	}
+
+	pk, ok := item[partitionName].(*types.AttributeValueMemberS)
+	sk, ok2 := item[sortName].(*types.AttributeValueMemberS)
+
+	if !ok || !ok2 || pk.Value == "" || sk.Value == "" {
+		return "", validationError(errKeySchema)
+	}
+
+	return pk.Value + "\x00" + sk.Value, nil
+}
+
+// topLevelPaths returns the top-level attributes changed by an update, as returned by UPDATED_NEW and UPDATED_OLD.
+func topLevelPaths(actions []action) []path {
+	paths := make([]path, 0, len(actions))
+
+	for _, a := range actions {
+		paths = append(paths, a.path[:1])
+	}
+
+	return paths
+}
+
+func validationError(format string, args ...interface{}) error {
+	return &smithy.GenericAPIError{Code: codeValidation, Message: fmt.Sprintf(format, args...), Fault: smithy.FaultClient}
+}
+
+func transactionCanceled(reasons []types.CancellationReason) error {
+	codes := make([]string, len(reasons))
+	for i, reason := range reasons {
+		codes[i] = aws.ToString(reason.Code)
+	}
+
+	return &types.TransactionCanceledException{
+		Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+		CancellationReasons: reasons,
+	}
+}
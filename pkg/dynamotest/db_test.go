@@ -0,0 +1,380 @@
+package dynamotest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/dynamotest"
+)
+
+const tableStub = "websites"
+
+func str(s string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: s}
+}
+
+func num(n string) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: n}
+}
+
+func list(values ...types.AttributeValue) types.AttributeValue {
+	return &types.AttributeValueMemberL{Value: values}
+}
+
+// itemStub creates an item with the given key and attributes.
+func itemStub(pk, sk string, attributes dynamo.Key) dynamo.Key {
+	item := dynamo.K2(pk, sk)
+	for name, value := range attributes {
+		item[name] = value
+	}
+
+	return item
+}
+
+// createTestDB creates a DB holding the given items.
+func createTestDB(t *testing.T, items ...dynamo.Key) *dynamotest.DB {
+	t.Helper()
+
+	db := dynamotest.NewDB()
+
+	for _, item := range items {
+		_, err := db.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(tableStub), Item: item})
+		require.NoError(t, err)
+	}
+
+	return db
+}
+
+func getItem(t *testing.T, db *dynamotest.DB, key dynamo.Key) dynamo.Key {
+	t.Helper()
+
+	out, err := db.GetItem(context.Background(), &dynamodb.GetItemInput{TableName: aws.String(tableStub), Key: key})
+	require.NoError(t, err)
+
+	return out.Item
+}
+
+func assertValidation(t *testing.T, err error) {
+	t.Helper()
+
+	var apiErr smithy.APIError
+
+	require.True(t, errors.As(err, &apiErr), "expected an API error, got %v", err)
+	assert.Equal(t, "ValidationException", apiErr.ErrorCode())
+}
+
+func assertConditionalCheckFailed(t *testing.T, err error) {
+	t.Helper()
+
+	var ccf *types.ConditionalCheckFailedException
+
+	assert.True(t, errors.As(err, &ccf), "expected a ConditionalCheckFailedException, got %v", err)
+}
+
+func TestDB_PutItem(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success replaces the item and returns the old one", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		oldStub := itemStub("foo", "bar", dynamo.Key{"name": str("old")})
+		newStub := itemStub("foo", "bar", dynamo.Key{"name": str("new")})
+
+		// system under test
+		sut := createTestDB(t, oldStub)
+
+		// execute
+		out, err := sut.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:    aws.String(tableStub),
+			Item:         newStub,
+			ReturnValues: types.ReturnValueAllOld,
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, oldStub, out.Attributes)
+		assert.Equal(t, newStub, getItem(t, sut, dynamo.K2("foo", "bar")))
+	})
+
+	t.Run("fail condition of existing item", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		oldStub := itemStub("foo", "bar", dynamo.Key{"name": str("old")})
+
+		// system under test
+		sut := createTestDB(t, oldStub)
+
+		// execute
+		_, err := sut.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                aws.String(tableStub),
+			Item:                     itemStub("foo", "bar", nil),
+			ConditionExpression:      aws.String("attribute_not_exists (#0)"),
+			ExpressionAttributeNames: map[string]string{"#0": "pk"},
+		})
+
+		// asserts
+		assertConditionalCheckFailed(t, err)
+		assert.Equal(t, oldStub, getItem(t, sut, dynamo.K2("foo", "bar")))
+	})
+
+	t.Run("fail unused placeholder", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t)
+
+		// execute
+		_, err := sut.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                aws.String(tableStub),
+			Item:                     itemStub("foo", "bar", nil),
+			ConditionExpression:      aws.String("attribute_not_exists (#0)"),
+			ExpressionAttributeNames: map[string]string{"#0": "pk", "#1": "sk"},
+		})
+
+		// asserts
+		assertValidation(t, err)
+	})
+
+	t.Run("fail missing sort key", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t)
+
+		// execute
+		_, err := sut.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(tableStub),
+			Item:      dynamo.K1("foo"),
+		})
+
+		// asserts
+		assertValidation(t, err)
+	})
+}
+
+func TestDB_GetItem(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success missing item", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t)
+
+		// execute
+		out, err := sut.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(tableStub), Key: dynamo.K2("foo", "bar")})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Nil(t, out.Item)
+	})
+
+	t.Run("success projection", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		stored := itemStub("foo", "bar", dynamo.Key{
+			"version": num("3"),
+			"meta":    &types.AttributeValueMemberM{Value: dynamo.Key{"title": str("foo"), "body": str("bar")}},
+			"tags":    list(str("a"), str("b"), str("c")),
+		})
+		expected := dynamo.Key{
+			"version": num("3"),
+			"meta":    &types.AttributeValueMemberM{Value: dynamo.Key{"title": str("foo")}},
+			"tags":    list(str("c")),
+		}
+
+		// system under test
+		sut := createTestDB(t, stored)
+
+		// execute
+		out, err := sut.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:                aws.String(tableStub),
+			Key:                      dynamo.K2("foo", "bar"),
+			ProjectionExpression:     aws.String("#version, meta.title, tags[2], missing"),
+			ExpressionAttributeNames: map[string]string{"#version": "version"},
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expected, out.Item)
+	})
+
+	t.Run("fail key not matching the schema", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t)
+
+		// execute
+		_, err := sut.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(tableStub), Key: dynamo.K1("foo")})
+
+		// asserts
+		assertValidation(t, err)
+	})
+}
+
+func TestDB_DeleteItem(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t, itemStub("foo", "bar", dynamo.Key{"version": num("1")}))
+
+		// execute
+		_, err := sut.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:                 aws.String(tableStub),
+			Key:                       dynamo.K2("foo", "bar"),
+			ConditionExpression:       aws.String("#0 = :0"),
+			ExpressionAttributeNames:  map[string]string{"#0": "version"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":0": num("1.0")},
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Nil(t, getItem(t, sut, dynamo.K2("foo", "bar")))
+	})
+
+	t.Run("fail condition", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t, itemStub("foo", "bar", dynamo.Key{"version": num("2")}))
+
+		// execute
+		_, err := sut.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:                 aws.String(tableStub),
+			Key:                       dynamo.K2("foo", "bar"),
+			ConditionExpression:       aws.String("#0 = :0"),
+			ExpressionAttributeNames:  map[string]string{"#0": "version"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":0": num("1")},
+		})
+
+		// asserts
+		assertConditionalCheckFailed(t, err)
+		assert.NotNil(t, getItem(t, sut, dynamo.K2("foo", "bar")))
+	})
+}
+
+func TestDB_ConditionExpression(t *testing.T) {
+	ctx := context.Background()
+
+	stored := itemStub("foo", "bar", dynamo.Key{
+		"name":    str("foo bar"),
+		"rank":    num("3"),
+		"tags":    list(str("a"), str("b")),
+		"labels":  &types.AttributeValueMemberSS{Value: []string{"x", "y"}},
+		"meta":    &types.AttributeValueMemberM{Value: dynamo.Key{"title": str("baz")}},
+		"deleted": &types.AttributeValueMemberNULL{Value: true},
+	})
+
+	values := map[string]types.AttributeValue{
+		":foo":   str("foo"),
+		":bar":   str("bar"),
+		":baz":   str("baz"),
+		":one":   num("1"),
+		":two":   num("2"),
+		":five":  num("5"),
+		":three": num("3.00"),
+		":x":     str("x"),
+		":type":  str("NULL"),
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{name: "equal number", expr: "#rank = :three", want: true},
+		{name: "greater than", expr: "#rank > :two", want: true},
+		{name: "comparison of different types", expr: "#rank > :foo", want: false},
+		{name: "comparison of missing attribute", expr: "missing <> :foo", want: false},
+		{name: "between", expr: "#rank BETWEEN :two AND :five", want: true},
+		{name: "in", expr: "#rank IN (:one, :two)", want: false},
+		{name: "begins with", expr: "begins_with(#name, :foo)", want: true},
+		{name: "contains substring", expr: "contains(#name, :bar)", want: true},
+		{name: "contains set member", expr: "contains(labels, :x)", want: true},
+		{name: "contains list element", expr: "contains(tags, :foo)", want: false},
+		{name: "nested path", expr: "meta.title = :baz AND tags[1] <> :foo", want: true},
+		{name: "size", expr: "size(tags) = :two AND size(#name) > :five", want: true},
+		{name: "attribute type", expr: "attribute_type(deleted, :type)", want: true},
+		{name: "precedence of and over or", expr: "#rank = :one OR #rank = :two AND attribute_exists(tags)", want: false},
+		{name: "parentheses", expr: "(#rank = :one OR attribute_exists(tags)) AND NOT attribute_exists(missing)", want: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		// every placeholder has to be used, the expression is or-ed with ones which never hold
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// system under test
+			sut := createTestDB(t, stored)
+
+			// execute
+			_, err := sut.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName:                 aws.String(tableStub),
+				Item:                      stored,
+				ConditionExpression:       aws.String(tt.expr + " OR #name = #rank OR :foo IN (:bar, :baz, :x, :type, :one, :two, :three, :five)"),
+				ExpressionAttributeNames:  map[string]string{"#name": "name", "#rank": "rank"},
+				ExpressionAttributeValues: values,
+			})
+
+			// asserts
+			if tt.want {
+				assert.NoError(t, err)
+			} else {
+				assertConditionalCheckFailed(t, err)
+			}
+		})
+	}
+
+	t.Run("fail undefined placeholder", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t, stored)
+
+		// execute
+		_, err := sut.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           aws.String(tableStub),
+			Item:                stored,
+			ConditionExpression: aws.String("#rank = :one"),
+		})
+
+		// asserts
+		assertValidation(t, err)
+	})
+
+	t.Run("fail syntax error", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t, stored)
+
+		// execute
+		_, err := sut.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                 aws.String(tableStub),
+			Item:                      stored,
+			ConditionExpression:       aws.String("rank = = :one"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":one": num("1")},
+		})
+
+		// asserts
+		assertValidation(t, err)
+	})
+}
@@ -0,0 +1,302 @@
+package dynamotest
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+// resolve returns the attribute at a document path of an item.
+func resolve(item dynamo.Key, p path) (types.AttributeValue, bool) {
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+
+	for _, st := range p {
+		next, ok := child(current, st)
+		if !ok {
+			return nil, false
+		}
+
+		current = next
+	}
+
+	return current, true
+}
+
+func child(parent types.AttributeValue, st step) (types.AttributeValue, bool) {
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if st.isIndex {
+			return nil, false
+		}
+
+		value, ok := v.Value[st.name]
+
+		return value, ok
+	case *types.AttributeValueMemberL:
+		if !st.isIndex || st.index >= len(v.Value) {
+			return nil, false
+		}
+
+		return v.Value[st.index], true
+	default:
+		return nil, false
+	}
+}
+
+// evalCondition tells whether an item satisfies a condition, the item is empty if it does not exist.
+func evalCondition(item dynamo.Key, c condition) (bool, error) {
+	switch c := c.(type) {
+	case andCondition:
+		left, err := evalCondition(item, c.left)
+		if err != nil || !left {
+			return false, err
+		}
+
+		return evalCondition(item, c.right)
+	case orCondition:
+		left, err := evalCondition(item, c.left)
+		if err != nil || left {
+			return left, err
+		}
+
+		return evalCondition(item, c.right)
+	case notCondition:
+		result, err := evalCondition(item, c.condition)
+
+		return !result, err
+	case compareCondition:
+		return evalCompare(item, c)
+	case betweenCondition:
+		value, lower, upper := evalOperand(item, c.value), evalOperand(item, c.lower), evalOperand(item, c.upper)
+		if value == nil || lower == nil || upper == nil {
+			return false, nil
+		}
+
+		above, ok := attr.Compare(value, lower)
+		below, ok2 := attr.Compare(value, upper)
+
+		return ok && ok2 && above >= 0 && below <= 0, nil
+	case inCondition:
+		value := evalOperand(item, c.value)
+
+		for _, option := range c.options {
+			if o := evalOperand(item, option); value != nil && o != nil && attr.Equal(value, o) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	case functionCondition:
+		return evalFunction(item, c)
+	default:
+		return false, validationError("Invalid expression: unsupported condition")
+	}
+}
+
+func evalCompare(item dynamo.Key, c compareCondition) (bool, error) {
+	left, right := evalOperand(item, c.left), evalOperand(item, c.right)
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	switch c.op {
+	case "=":
+		return attr.Equal(left, right), nil
+	case "<>":
+		return !attr.Equal(left, right), nil
+	}
+
+	order, ok := attr.Compare(left, right)
+	if !ok {
+		return false, nil
+	}
+
+	switch c.op {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+func evalFunction(item dynamo.Key, c functionCondition) (bool, error) {
+	target, exists := resolve(item, c.args[0].(pathOperand).path)
+
+	switch c.name {
+	case "attribute_exists":
+		return exists, nil
+	case "attribute_not_exists":
+		return !exists, nil
+	case "attribute_type":
+		want, ok := evalOperand(item, c.args[1]).(*types.AttributeValueMemberS)
+		if !ok || !isTypeName(want.Value) {
+			return false, validationError("Invalid ConditionExpression: Invalid attribute type name found in operand of attribute_type")
+		}
+
+		return exists && typeName(target) == want.Value, nil
+	case "begins_with":
+		return exists && beginsWith(target, evalOperand(item, c.args[1])), nil
+	default:
+		needle := evalOperand(item, c.args[1])
+
+		return exists && needle != nil && attr.Contains(target, needle), nil
+	}
+}
+
+// evalOperand evaluates an operand of a condition, it returns nil for a path missing in the item.
+func evalOperand(item dynamo.Key, op operand) types.AttributeValue {
+	switch op := op.(type) {
+	case valueOperand:
+		return op.value
+	case pathOperand:
+		value, _ := resolve(item, op.path)
+
+		return value
+	case sizeOperand:
+		value, ok := resolve(item, op.path)
+		if !ok {
+			return nil
+		}
+
+		size, ok := sizeOf(value)
+		if !ok {
+			return nil
+		}
+
+		return &types.AttributeValueMemberN{Value: strconv.Itoa(size)}
+	default:
+		return nil
+	}
+}
+
+func beginsWith(value, prefix types.AttributeValue) bool {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		p, ok := prefix.(*types.AttributeValueMemberS)
+
+		return ok && strings.HasPrefix(v.Value, p.Value)
+	case *types.AttributeValueMemberB:
+		p, ok := prefix.(*types.AttributeValueMemberB)
+
+		return ok && bytes.HasPrefix(v.Value, p.Value)
+	default:
+		return false
+	}
+}
+
+// sizeOf returns the size of a value like the size function, which does not apply to numbers, booleans and nulls.
+func sizeOf(value types.AttributeValue) (int, bool) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberB:
+		return len(v.Value), true
+	case *types.AttributeValueMemberSS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberNS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberBS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberL:
+		return len(v.Value), true
+	case *types.AttributeValueMemberM:
+		return len(v.Value), true
+	default:
+		return 0, false
+	}
+}
+
+func isTypeName(name string) bool {
+	switch name {
+	case "S", "SS", "N", "NS", "B", "BS", "BOOL", "NULL", "L", "M":
+		return true
+	default:
+		return false
+	}
+}
+
+func typeName(value types.AttributeValue) string {
+	switch value.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	default:
+		return ""
+	}
+}
+
+// project copies the attributes at the given paths of an item, keeping the documents and lists containing them.
+// Projected list elements are compacted in the order of the paths, like DynamoDB does.
+func project(item dynamo.Key, paths []path) dynamo.Key {
+	result := dynamo.Key{}
+
+	for _, p := range paths {
+		value, ok := resolve(item, p)
+		if !ok {
+			continue
+		}
+
+		var target types.AttributeValue = &types.AttributeValueMemberM{Value: result}
+
+		for i := range p[:len(p)-1] {
+			target = placeContainer(target, p[i], p[i+1])
+		}
+
+		place(target, p[len(p)-1], attr.Clone(value))
+	}
+
+	return result
+}
+
+// placeContainer places the container of the next step of a path in a projected container, unless it is already there.
+func placeContainer(target types.AttributeValue, st, next step) types.AttributeValue {
+	if t, ok := target.(*types.AttributeValueMemberM); ok {
+		if existing, ok := t.Value[st.name]; ok {
+			return existing
+		}
+	}
+
+	var container types.AttributeValue = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+	if next.isIndex {
+		container = &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+	}
+
+	place(target, st, container)
+
+	return container
+}
+
+func place(target types.AttributeValue, st step, value types.AttributeValue) {
+	switch t := target.(type) {
+	case *types.AttributeValueMemberM:
+		t.Value[st.name] = value
+	case *types.AttributeValueMemberL:
+		t.Value = append(t.Value, value)
+	}
+}
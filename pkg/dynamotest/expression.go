@@ -0,0 +1,812 @@
+package dynamotest
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenName
+	tokenValue
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits an expression into tokens, names and values keep their # and : prefixes.
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':' || isWordChar(c):
+			j := i + 1
+			for j < len(expr) && isWordChar(rune(expr[j])) {
+				j++
+			}
+
+			kind := tokenIdentifier
+			switch {
+			case c == '#':
+				kind = tokenName
+			case c == ':':
+				kind = tokenValue
+			case unicode.IsDigit(c):
+				kind = tokenNumber
+			}
+
+			if j == i+1 && kind != tokenIdentifier && kind != tokenNumber {
+				return nil, validationError("Invalid expression: syntax error near %q", expr[i:])
+			}
+
+			tokens = append(tokens, token{kind: kind, text: expr[i:j]})
+			i = j
+		case strings.HasPrefix(expr[i:], "<>") || strings.HasPrefix(expr[i:], "<=") || strings.HasPrefix(expr[i:], ">="):
+			tokens = append(tokens, token{kind: tokenSymbol, text: expr[i : i+2]})
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", c):
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(c)})
+			i++
+		default:
+			return nil, validationError("Invalid expression: unexpected character %q", string(c))
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, text: ""}), nil
+}
+
+func isWordChar(c rune) bool {
+	return c == '_' || c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c))
+}
+
+// step is an element of a document path, either the name of an attribute or a list index.
+type step struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// path is a document path with its placeholders already substituted.
+type path []step
+
+func (p path) String() string {
+	var sb strings.Builder
+
+	for i, st := range p {
+		switch {
+		case st.isIndex:
+			sb.WriteString("[" + strconv.Itoa(st.index) + "]")
+		case i > 0:
+			sb.WriteString("." + st.name)
+		default:
+			sb.WriteString(st.name)
+		}
+	}
+
+	return sb.String()
+}
+
+// overlaps tells whether one of two paths is the same as or contains the other.
+func (p path) overlaps(other path) bool {
+	n := len(p)
+	if len(other) < n {
+		n = len(other)
+	}
+
+	for i := 0; i < n; i++ {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// operand is a value used in an expression, it is evaluated against an item.
+type operand interface {
+	isOperand()
+}
+
+type (
+	pathOperand  struct{ path path }
+	valueOperand struct{ value types.AttributeValue }
+	sizeOperand  struct{ path path }
+
+	ifNotExistsOperand struct {
+		path     path
+		fallback operand
+	}
+	listAppendOperand struct{ first, second operand }
+	arithmeticOperand struct {
+		op          string
+		left, right operand
+	}
+)
+
+func (pathOperand) isOperand()        {}
+func (valueOperand) isOperand()       {}
+func (sizeOperand) isOperand()        {}
+func (ifNotExistsOperand) isOperand() {}
+func (listAppendOperand) isOperand()  {}
+func (arithmeticOperand) isOperand()  {}
+
+// condition is a boolean expression, as used by condition, filter and key condition expressions.
+type condition interface {
+	isCondition()
+}
+
+type (
+	andCondition     struct{ left, right condition }
+	orCondition      struct{ left, right condition }
+	notCondition     struct{ condition condition }
+	compareCondition struct {
+		op          string
+		left, right operand
+	}
+	betweenCondition struct{ value, lower, upper operand }
+	inCondition      struct {
+		value   operand
+		options []operand
+	}
+	functionCondition struct {
+		name string
+		args []operand
+	}
+)
+
+func (andCondition) isCondition()      {}
+func (orCondition) isCondition()       {}
+func (notCondition) isCondition()      {}
+func (compareCondition) isCondition()  {}
+func (betweenCondition) isCondition()  {}
+func (inCondition) isCondition()       {}
+func (functionCondition) isCondition() {}
+
+// action is a single change of an update expression.
+type action struct {
+	clause string
+	path   path
+	value  operand
+}
+
+const (
+	clauseSet    = "SET"
+	clauseRemove = "REMOVE"
+	clauseAdd    = "ADD"
+	clauseDelete = "DELETE"
+)
+
+// parser parses the expressions of a single request, keeping track of the placeholders used by them,
+// as DynamoDB rejects requests defining placeholders they never use.
+type parser struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+
+	tokens []token
+	pos    int
+}
+
+func newParser(names map[string]string, values map[string]types.AttributeValue) *parser {
+	return &parser{
+		names:      names,
+		values:     values,
+		usedNames:  map[string]bool{},
+		usedValues: map[string]bool{},
+		tokens:     nil,
+		pos:        0,
+	}
+}
+
+// checkUnused fails if the request defines placeholders none of its expressions use.
+func (p *parser) checkUnused() error {
+	for name := range p.names {
+		if !p.usedNames[name] {
+			return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", name)
+		}
+	}
+
+	for name := range p.values {
+		if !p.usedValues[name] {
+			return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", name)
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) start(expr string) error {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return err
+	}
+
+	p.tokens, p.pos = tokens, 0
+
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) isSymbol(text string) bool {
+	t := p.peek()
+
+	return t.kind == tokenSymbol && t.text == text
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+
+	return t.kind == tokenIdentifier && strings.EqualFold(t.text, keyword)
+}
+
+// isFunction tells whether the next tokens are a call of the given function.
+func (p *parser) isFunction(name string) bool {
+	next := p.tokens[p.pos+1:]
+
+	return p.peek().kind == tokenIdentifier && p.peek().text == name &&
+		len(next) > 0 && next[0].kind == tokenSymbol && next[0].text == "("
+}
+
+func (p *parser) expect(text string) error {
+	if !p.isSymbol(text) {
+		return p.syntaxError()
+	}
+
+	p.next()
+
+	return nil
+}
+
+func (p *parser) expectEOF() error {
+	if p.peek().kind != tokenEOF {
+		return p.syntaxError()
+	}
+
+	return nil
+}
+
+func (p *parser) syntaxError() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return validationError("Invalid expression: syntax error; unexpected end of expression")
+	}
+
+	return validationError("Invalid expression: syntax error; token: %q", t.text)
+}
+
+// parseCondition parses a complete condition expression.
+func (p *parser) parseCondition(expr string) (condition, error) {
+	err := p.start(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, p.expectEOF()
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("OR") {
+		p.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = orCondition{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("AND") {
+		p.next()
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = andCondition{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if !p.isKeyword("NOT") {
+		return p.parsePrimary()
+	}
+
+	p.next()
+
+	c, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	return notCondition{condition: c}, nil
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.isSymbol("(") {
+		p.next()
+
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		return c, p.expect(")")
+	}
+
+	for _, name := range []string{"attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains"} {
+		if p.isFunction(name) {
+			return p.parseFunction(name)
+		}
+	}
+
+	left, err := p.parseConditionOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isKeyword("BETWEEN"):
+		return p.parseBetween(left)
+	case p.isKeyword("IN"):
+		return p.parseIn(left)
+	}
+
+	op := p.peek()
+	if op.kind != tokenSymbol || !isComparator(op.text) {
+		return nil, p.syntaxError()
+	}
+
+	p.next()
+
+	right, err := p.parseConditionOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return compareCondition{op: op.text, left: left, right: right}, nil
+}
+
+func isComparator(s string) bool {
+	switch s {
+	case "=", "<>", "<", "<=", ">", ">=":
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *parser) parseFunction(name string) (condition, error) {
+	p.next()
+	p.next()
+
+	args := []operand{}
+
+	for {
+		arg, err := p.parseConditionOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+
+		if !p.isSymbol(",") {
+			break
+		}
+
+		p.next()
+	}
+
+	err := p.expect(")")
+	if err != nil {
+		return nil, err
+	}
+
+	want := 2
+	if name == "attribute_exists" || name == "attribute_not_exists" {
+		want = 1
+	}
+
+	if len(args) != want {
+		return nil, validationError("Invalid ConditionExpression: Incorrect number of operands for operator or function; operator or function: %s, number of operands: %d", name, len(args))
+	}
+
+	if _, ok := args[0].(pathOperand); !ok {
+		return nil, validationError("Invalid ConditionExpression: Operator or function requires a document path; operator or function: %s", name)
+	}
+
+	return functionCondition{name: name, args: args}, nil
+}
+
+func (p *parser) parseBetween(value operand) (condition, error) {
+	p.next()
+
+	lower, err := p.parseConditionOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isKeyword("AND") {
+		return nil, p.syntaxError()
+	}
+
+	p.next()
+
+	upper, err := p.parseConditionOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return betweenCondition{value: value, lower: lower, upper: upper}, nil
+}
+
+func (p *parser) parseIn(value operand) (condition, error) {
+	p.next()
+
+	err := p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+
+	var options []operand
+
+	for {
+		option, err := p.parseConditionOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, option)
+
+		if !p.isSymbol(",") {
+			break
+		}
+
+		p.next()
+	}
+
+	return inCondition{value: value, options: options}, p.expect(")")
+}
+
+// parseConditionOperand parses the operands allowed in conditions: paths, values and the size function.
+func (p *parser) parseConditionOperand() (operand, error) {
+	if p.isFunction("size") {
+		p.next()
+		p.next()
+
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+
+		return sizeOperand{path: pth}, p.expect(")")
+	}
+
+	if p.peek().kind == tokenValue {
+		return p.parseValue()
+	}
+
+	pth, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	return pathOperand{path: pth}, nil
+}
+
+func (p *parser) parseValue() (operand, error) {
+	t := p.peek()
+	if t.kind != tokenValue {
+		return nil, p.syntaxError()
+	}
+
+	p.next()
+
+	value, ok := p.values[t.text]
+	if !ok {
+		return nil, validationError("Invalid expression: An expression attribute value used in expression is not defined; attribute value: %s", t.text)
+	}
+
+	p.usedValues[t.text] = true
+
+	return valueOperand{value: value}, nil
+}
+
+// parsePath parses a document path, substituting name placeholders.
+func (p *parser) parsePath() (path, error) {
+	first, err := p.parsePathName()
+	if err != nil {
+		return nil, err
+	}
+
+	pth := path{first}
+
+	for {
+		switch {
+		case p.isSymbol("."):
+			p.next()
+
+			st, err := p.parsePathName()
+			if err != nil {
+				return nil, err
+			}
+
+			pth = append(pth, st)
+		case p.isSymbol("["):
+			p.next()
+
+			t := p.peek()
+			if t.kind != tokenNumber {
+				return nil, p.syntaxError()
+			}
+
+			p.next()
+
+			index, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, validationError("Invalid expression: invalid list index %q", t.text)
+			}
+
+			pth = append(pth, step{name: "", index: index, isIndex: true})
+
+			err = p.expect("]")
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return pth, nil
+		}
+	}
+}
+
+func (p *parser) parsePathName() (step, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokenIdentifier:
+		p.next()
+
+		return step{name: t.text, index: 0, isIndex: false}, nil
+	case tokenName:
+		p.next()
+
+		name, ok := p.names[t.text]
+		if !ok {
+			return step{}, validationError("Invalid expression: An expression attribute name used in the document path is not defined; attribute name: %s", t.text)
+		}
+
+		p.usedNames[t.text] = true
+
+		return step{name: name, index: 0, isIndex: false}, nil
+	default:
+		return step{}, p.syntaxError()
+	}
+}
+
+// parseUpdate parses a complete update expression, each of its clauses may only be used once.
+func (p *parser) parseUpdate(expr string) ([]action, error) {
+	err := p.start(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		actions []action
+		seen    = map[string]bool{}
+	)
+
+	for p.peek().kind != tokenEOF {
+		t := p.peek()
+		clause := strings.ToUpper(t.text)
+
+		if t.kind != tokenIdentifier || (clause != clauseSet && clause != clauseRemove && clause != clauseAdd && clause != clauseDelete) {
+			return nil, p.syntaxError()
+		}
+
+		p.next()
+
+		if seen[clause] {
+			return nil, validationError("Invalid UpdateExpression: The %q section can only be used once in an update expression", clause)
+		}
+
+		seen[clause] = true
+
+		for {
+			a, err := p.parseAction(clause)
+			if err != nil {
+				return nil, err
+			}
+
+			actions = append(actions, a)
+
+			if !p.isSymbol(",") {
+				break
+			}
+
+			p.next()
+		}
+	}
+
+	if len(actions) == 0 {
+		return nil, p.syntaxError()
+	}
+
+	return actions, nil
+}
+
+func (p *parser) parseAction(clause string) (action, error) {
+	pth, err := p.parsePath()
+	if err != nil {
+		return action{}, err
+	}
+
+	switch clause {
+	case clauseRemove:
+		return action{clause: clause, path: pth, value: nil}, nil
+	case clauseSet:
+		err = p.expect("=")
+		if err != nil {
+			return action{}, err
+		}
+
+		value, err := p.parseSetValue()
+
+		return action{clause: clause, path: pth, value: value}, err
+	default:
+		value, err := p.parseValue()
+
+		return action{clause: clause, path: pth, value: value}, err
+	}
+}
+
+// parseSetValue parses the right hand side of a SET action, which may add or subtract two operands.
+func (p *parser) parseSetValue() (operand, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isSymbol("+") && !p.isSymbol("-") {
+		return left, nil
+	}
+
+	op := p.next().text
+
+	right, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return arithmeticOperand{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseSetOperand() (operand, error) {
+	switch {
+	case p.isFunction("if_not_exists"):
+		p.next()
+		p.next()
+
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+
+		fallback, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return ifNotExistsOperand{path: pth, fallback: fallback}, p.expect(")")
+	case p.isFunction("list_append"):
+		p.next()
+		p.next()
+
+		first, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+
+		second, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return listAppendOperand{first: first, second: second}, p.expect(")")
+	case p.peek().kind == tokenValue:
+		return p.parseValue()
+	default:
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+
+		return pathOperand{path: pth}, nil
+	}
+}
+
+// parseProjection parses a projection expression, a list of paths.
+func (p *parser) parseProjection(expr string) ([]path, error) {
+	err := p.start(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []path
+
+	for {
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, pth)
+
+		if !p.isSymbol(",") {
+			break
+		}
+
+		p.next()
+	}
+
+	return paths, p.expectEOF()
+}
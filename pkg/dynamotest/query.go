@@ -0,0 +1,334 @@
+package dynamotest
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+// Query reads the items of a partition of a table or dynamo.GSI1 in the order of their sort key.
+// Like DynamoDB, Limit caps the number of items read rather than returned, the filter is applied afterwards, and
+// LastEvaluatedKey is set whenever the limit is reached, even if no more items follow.
+// Items sharing a sort key in the index are ordered by their table key.
+func (db *DB) Query(_ context.Context, params *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	table, err := tableName(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	index := aws.ToString(params.IndexName)
+	if index != "" && index != dynamo.GSI1 {
+		return nil, validationError("The table does not have the specified index: %s", index)
+	}
+
+	q, err := prepareQuery(params, index)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	items, err := q.items(db.tables[table])
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out  = &dynamodb.QueryOutput{}
+		last dynamo.Key
+	)
+
+	for _, item := range items {
+		if params.Limit != nil && out.ScannedCount == *params.Limit {
+			break
+		}
+
+		out.ScannedCount++
+		last = item
+
+		ok, err := holds(item, q.filter)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		out.Count++
+
+		if params.Select != types.SelectCount {
+			out.Items = append(out.Items, q.present(item))
+		}
+	}
+
+	if params.Limit != nil && out.ScannedCount == *params.Limit {
+		out.LastEvaluatedKey = q.keyOf(last)
+	}
+
+	return out, nil
+}
+
+// query is a parsed query, prepared before the lock is taken.
+type query struct {
+	partitionName, sortName string
+	keyCondition, filter    condition
+	projection              []path
+	exclusiveStartKey       dynamo.Key
+	descending              bool
+}
+
+func prepareQuery(params *dynamodb.QueryInput, index string) (query, error) {
+	partitionName, sortName := dynamo.KeyNames(index)
+
+	q := query{
+		partitionName:     partitionName,
+		sortName:          sortName,
+		keyCondition:      nil,
+		filter:            nil,
+		projection:        nil,
+		exclusiveStartKey: params.ExclusiveStartKey,
+		descending:        params.ScanIndexForward != nil && !*params.ScanIndexForward,
+	}
+
+	if aws.ToString(params.KeyConditionExpression) == "" {
+		return q, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request")
+	}
+
+	if params.Limit != nil && *params.Limit < 1 {
+		return q, validationError("Limit must be greater than or equal to 1")
+	}
+
+	p := newParser(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+
+	var err error
+
+	q.keyCondition, err = p.parseCondition(*params.KeyConditionExpression)
+	if err != nil {
+		return q, err
+	}
+
+	if !q.isKeyCondition(q.keyCondition) {
+		return q, validationError("Query key condition not supported")
+	}
+
+	if params.FilterExpression != nil {
+		q.filter, err = p.parseCondition(*params.FilterExpression)
+		if err != nil {
+			return q, err
+		}
+
+		for _, name := range []string{partitionName, sortName} {
+			if references(q.filter, name) {
+				return q, validationError("Filter Expression can only contain non-primary key attributes: Primary key attribute: %s", name)
+			}
+		}
+	}
+
+	if params.ProjectionExpression != nil {
+		q.projection, err = p.parseProjection(*params.ProjectionExpression)
+		if err != nil {
+			return q, err
+		}
+	}
+
+	err = p.checkUnused()
+	if err != nil {
+		return q, err
+	}
+
+	if len(q.exclusiveStartKey) > 0 && !q.isStartKey(q.exclusiveStartKey) {
+		return q, validationError("The provided starting key is invalid")
+	}
+
+	return q, nil
+}
+
+// items returns the items of a table matching the key condition which follow the exclusive start key, in order.
+func (q query) items(table map[string]dynamo.Key) ([]dynamo.Key, error) {
+	var items []dynamo.Key
+
+	for _, item := range table {
+		if !q.isIndexed(item) {
+			continue
+		}
+
+		ok, err := evalCondition(item, q.keyCondition)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok && (len(q.exclusiveStartKey) == 0 || q.less(q.exclusiveStartKey, item)) {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return q.less(items[i], items[j])
+	})
+
+	return items, nil
+}
+
+// present returns the attributes of an item a query returns.
+func (q query) present(item dynamo.Key) dynamo.Key {
+	if q.projection != nil {
+		return project(item, q.projection)
+	}
+
+	return attr.CloneItem(item)
+}
+
+// isIndexed tells whether an item is part of the table or index queried, which requires both of its key attributes.
+func (q query) isIndexed(item dynamo.Key) bool {
+	_, ok := item[q.partitionName].(*types.AttributeValueMemberS)
+	_, ok2 := item[q.sortName].(*types.AttributeValueMemberS)
+
+	return ok && ok2
+}
+
+// less tells whether an item comes before another in the order of the query.
+func (q query) less(a, b dynamo.Key) bool {
+	partitionName, sortName := dynamo.KeyNames("")
+
+	for _, name := range []string{q.sortName, partitionName, sortName} {
+		if c := strings.Compare(stringOf(a[name]), stringOf(b[name])); c != 0 {
+			return (c < 0) != q.descending
+		}
+	}
+
+	return false
+}
+
+// keyOf returns the key of an item usable as ExclusiveStartKey, made of the keys of the table and of the index queried.
+func (q query) keyOf(item dynamo.Key) dynamo.Key {
+	partitionName, sortName := dynamo.KeyNames("")
+	key := dynamo.Key{}
+
+	for _, name := range []string{partitionName, sortName, q.partitionName, q.sortName} {
+		key[name] = item[name]
+	}
+
+	return key
+}
+
+func (q query) isStartKey(key dynamo.Key) bool {
+	partitionName, sortName := dynamo.KeyNames("")
+	names := map[string]bool{partitionName: true, sortName: true, q.partitionName: true, q.sortName: true}
+
+	if len(key) != len(names) {
+		return false
+	}
+
+	for name := range names {
+		if _, ok := key[name].(*types.AttributeValueMemberS); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isKeyCondition tells whether a condition is supported as a key condition: an equality of the partition key,
+// optionally combined with a single condition of the sort key.
+func (q query) isKeyCondition(c condition) bool {
+	if and, ok := c.(andCondition); ok {
+		return (q.isPartitionCondition(and.left) && q.isSortCondition(and.right)) ||
+			(q.isPartitionCondition(and.right) && q.isSortCondition(and.left))
+	}
+
+	return q.isPartitionCondition(c)
+}
+
+func (q query) isPartitionCondition(c condition) bool {
+	compare, ok := c.(compareCondition)
+
+	return ok && compare.op == "=" && isKeyComparison(compare.left, compare.right, q.partitionName)
+}
+
+func (q query) isSortCondition(c condition) bool {
+	switch c := c.(type) {
+	case compareCondition:
+		return c.op != "<>" && isKeyComparison(c.left, c.right, q.sortName)
+	case betweenCondition:
+		_, ok := c.lower.(valueOperand)
+		_, ok2 := c.upper.(valueOperand)
+
+		return ok && ok2 && isKeyPath(c.value, q.sortName)
+	case functionCondition:
+		_, ok := c.args[len(c.args)-1].(valueOperand)
+
+		return c.name == "begins_with" && ok && isKeyPath(c.args[0], q.sortName)
+	default:
+		return false
+	}
+}
+
+// isKeyComparison tells whether a comparison compares a key attribute with a value, in either order.
+func isKeyComparison(left, right operand, name string) bool {
+	_, leftValue := left.(valueOperand)
+	_, rightValue := right.(valueOperand)
+
+	return (isKeyPath(left, name) && rightValue) || (isKeyPath(right, name) && leftValue)
+}
+
+func isKeyPath(op operand, name string) bool {
+	p, ok := op.(pathOperand)
+
+	return ok && len(p.path) == 1 && p.path[0].name == name
+}
+
+// references tells whether a condition refers to an attribute.
+func references(c condition, name string) bool {
+	switch c := c.(type) {
+	case andCondition:
+		return references(c.left, name) || references(c.right, name)
+	case orCondition:
+		return references(c.left, name) || references(c.right, name)
+	case notCondition:
+		return references(c.condition, name)
+	case compareCondition:
+		return refersTo(name, c.left, c.right)
+	case betweenCondition:
+		return refersTo(name, c.value, c.lower, c.upper)
+	case inCondition:
+		return refersTo(name, append([]operand{c.value}, c.options...)...)
+	case functionCondition:
+		return refersTo(name, c.args...)
+	default:
+		return false
+	}
+}
+
+func refersTo(name string, operands ...operand) bool {
+	for _, op := range operands {
+		switch op := op.(type) {
+		case pathOperand:
+			if op.path[0].name == name {
+				return true
+			}
+		case sizeOperand:
+			if op.path[0].name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func stringOf(value types.AttributeValue) string {
+	if s, ok := value.(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+
+	return ""
+}
@@ -0,0 +1,219 @@
+package dynamotest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/dynamotest"
+)
+
+// createQueryTestDB creates a DB holding pages a to e of partition foo, which are indexed by their rank in reverse order,
+// except for page e. Partition bar holds another page.
+func createQueryTestDB(t *testing.T) *dynamotest.DB {
+	t.Helper()
+
+	pages := []string{"a", "b", "c", "d", "e"}
+	items := make([]dynamo.Key, 0, len(pages)+1)
+
+	for i, name := range pages {
+		item := itemStub("foo", "PAGE#"+name, dynamo.Key{"rank": num(string(rune('4' - i)))})
+		if name != "e" {
+			item["gsi1pk"] = str("foo#index")
+			item["gsi1sk"] = str(string(rune('4' - i)))
+		}
+
+		items = append(items, item)
+	}
+
+	return createTestDB(t, append(items, itemStub("bar", "PAGE#a", nil))...)
+}
+
+// sortKeys returns the sort keys of items.
+func sortKeys(items []dynamo.Key) []string {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item["sk"].(*types.AttributeValueMemberS).Value)
+	}
+
+	return keys
+}
+
+func TestDB_Query(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success sort key condition", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createQueryTestDB(t)
+
+		// execute
+		out, err := sut.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(tableStub),
+			KeyConditionExpression:    aws.String("#0 = :0 AND #1 BETWEEN :1 AND :2"),
+			ExpressionAttributeNames:  map[string]string{"#0": "pk", "#1": "sk"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo"), ":1": str("PAGE#b"), ":2": str("PAGE#d")},
+			ScanIndexForward:          aws.Bool(false),
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []string{"PAGE#d", "PAGE#c", "PAGE#b"}, sortKeys(out.Items))
+		assert.Nil(t, out.LastEvaluatedKey)
+	})
+
+	t.Run("success limit is applied before the filter", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		params := &dynamodb.QueryInput{
+			TableName:                 aws.String(tableStub),
+			KeyConditionExpression:    aws.String("#0 = :0"),
+			FilterExpression:          aws.String("#1 > :1"),
+			ExpressionAttributeNames:  map[string]string{"#0": "pk", "#1": "rank"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo"), ":1": num("1")},
+			Limit:                     aws.Int32(2),
+		}
+
+		// system under test
+		sut := createQueryTestDB(t)
+
+		var (
+			pages   [][]string
+			scanned []int32
+		)
+
+		// execute
+		for {
+			out, err := sut.Query(ctx, params)
+			require.NoError(t, err)
+
+			pages = append(pages, sortKeys(out.Items))
+			scanned = append(scanned, out.ScannedCount)
+
+			if len(out.LastEvaluatedKey) == 0 {
+				break
+			}
+
+			params.ExclusiveStartKey = out.LastEvaluatedKey
+		}
+
+		// asserts
+		assert.Equal(t, [][]string{{"PAGE#a", "PAGE#b"}, {"PAGE#c"}, {}}, pages)
+		assert.Equal(t, []int32{2, 2, 1}, scanned)
+	})
+
+	t.Run("success index", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createQueryTestDB(t)
+
+		// execute
+		out, err := sut.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(tableStub),
+			IndexName:                 aws.String(dynamo.GSI1),
+			KeyConditionExpression:    aws.String("#0 = :0"),
+			ExpressionAttributeNames:  map[string]string{"#0": "gsi1pk"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo#index")},
+			Limit:                     aws.Int32(2),
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []string{"PAGE#d", "PAGE#c"}, sortKeys(out.Items))
+		assert.Equal(t, dynamo.IndexKey(dynamo.K2("foo", "PAGE#c"), dynamo.GSI1, "foo#index", "2"), out.LastEvaluatedKey)
+	})
+
+	t.Run("success count", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createQueryTestDB(t)
+
+		// execute
+		out, err := sut.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(tableStub),
+			KeyConditionExpression:    aws.String("pk = :0 AND begins_with(sk, :1)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo"), ":1": str("PAGE#")},
+			Select:                    types.SelectCount,
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), out.Count)
+		assert.Empty(t, out.Items)
+	})
+
+	tests := []struct {
+		name   string
+		params *dynamodb.QueryInput
+	}{
+		{
+			name: "partition key not compared by equality",
+			params: &dynamodb.QueryInput{
+				KeyConditionExpression:    aws.String("pk > :0"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo")},
+			},
+		},
+		{
+			name: "key condition of other attributes",
+			params: &dynamodb.QueryInput{
+				KeyConditionExpression:    aws.String("pk = :0 AND rank = :0"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo")},
+			},
+		},
+		{
+			name: "filter of key attributes",
+			params: &dynamodb.QueryInput{
+				KeyConditionExpression:    aws.String("pk = :0"),
+				FilterExpression:          aws.String("begins_with(sk, :0)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo")},
+			},
+		},
+		{
+			name: "unknown index",
+			params: &dynamodb.QueryInput{
+				IndexName:                 aws.String("gsi2"),
+				KeyConditionExpression:    aws.String("pk = :0"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo")},
+			},
+		},
+		{
+			name: "start key of another index",
+			params: &dynamodb.QueryInput{
+				IndexName:                 aws.String(dynamo.GSI1),
+				KeyConditionExpression:    aws.String("gsi1pk = :0"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":0": str("foo")},
+				ExclusiveStartKey:         dynamo.K2("foo", "PAGE#a"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run("fail "+tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// stubs
+			tt.params.TableName = aws.String(tableStub)
+
+			// system under test
+			sut := createQueryTestDB(t)
+
+			// execute
+			_, err := sut.Query(ctx, tt.params)
+
+			// asserts
+			assertValidation(t, err)
+		})
+	}
+}
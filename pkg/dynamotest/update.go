@@ -0,0 +1,382 @@
+package dynamotest
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+const (
+	// maxNumberDigits is the precision of numbers in DynamoDB.
+	maxNumberDigits = 38
+
+	errInvalidUpdatePath = "The document path provided in the update expression is invalid for update"
+	errMissingOperand    = "The provided expression refers to an attribute that does not exist in the item"
+	errOperandType       = "An operand in the update expression has an incorrect data type"
+)
+
+// applyUpdate applies the actions of an update expression to a copy of an item.
+// Like in DynamoDB, every value is evaluated against the item as it was before the update.
+func applyUpdate(item dynamo.Key, actions []action) (dynamo.Key, error) {
+	err := checkActions(actions)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]types.AttributeValue, len(actions))
+
+	for i, a := range actions {
+		if a.value == nil {
+			continue
+		}
+
+		values[i], err = evalSetOperand(item, a.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updated := attr.CloneItem(item)
+
+	for i, a := range actions {
+		err = applyAction(updated, a, values[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return updated, nil
+}
+
+// checkActions rejects updates DynamoDB would reject before looking at the item: changes of key attributes
+// and several actions changing the same value.
+func checkActions(actions []action) error {
+	partitionName, sortName := dynamo.KeyNames("")
+
+	for i, a := range actions {
+		if len(a.path) == 1 && (a.path[0].name == partitionName || a.path[0].name == sortName) {
+			return validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", a.path[0].name)
+		}
+
+		for _, other := range actions[i+1:] {
+			if a.path.overlaps(other.path) {
+				return validationError("Invalid UpdateExpression: Two document paths overlap with each other; must remove or rewrite one of these paths; path one: [%s], path two: [%s]", a.path, other.path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// evalSetOperand evaluates an operand of an update, referring to a missing attribute is an error.
+func evalSetOperand(item dynamo.Key, op operand) (types.AttributeValue, error) {
+	switch op := op.(type) {
+	case valueOperand:
+		return op.value, nil
+	case pathOperand:
+		value, ok := resolve(item, op.path)
+		if !ok {
+			return nil, validationError(errMissingOperand)
+		}
+
+		return value, nil
+	case ifNotExistsOperand:
+		if value, ok := resolve(item, op.path); ok {
+			return value, nil
+		}
+
+		return evalSetOperand(item, op.fallback)
+	case listAppendOperand:
+		return evalListAppend(item, op)
+	case arithmeticOperand:
+		return evalArithmetic(item, op)
+	default:
+		return nil, validationError("Invalid UpdateExpression: unsupported operand")
+	}
+}
+
+func evalListAppend(item dynamo.Key, op listAppendOperand) (types.AttributeValue, error) {
+	first, err := evalSetOperand(item, op.first)
+	if err != nil {
+		return nil, err
+	}
+
+	second, err := evalSetOperand(item, op.second)
+	if err != nil {
+		return nil, err
+	}
+
+	a, ok := first.(*types.AttributeValueMemberL)
+	b, ok2 := second.(*types.AttributeValueMemberL)
+
+	if !ok || !ok2 {
+		return nil, validationError(errOperandType)
+	}
+
+	list := make([]types.AttributeValue, 0, len(a.Value)+len(b.Value))
+	list = append(list, a.Value...)
+	list = append(list, b.Value...)
+
+	return attr.Clone(&types.AttributeValueMemberL{Value: list}), nil
+}
+
+func evalArithmetic(item dynamo.Key, op arithmeticOperand) (types.AttributeValue, error) {
+	left, err := evalSetOperand(item, op.left)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := evalSetOperand(item, op.right)
+	if err != nil {
+		return nil, err
+	}
+
+	x, ok := parseNumber(left)
+	y, ok2 := parseNumber(right)
+
+	if !ok || !ok2 {
+		return nil, validationError(errOperandType)
+	}
+
+	if op.op == "-" {
+		y.Neg(y)
+	}
+
+	return &types.AttributeValueMemberN{Value: formatNumber(x.Add(x, y))}, nil
+}
+
+func applyAction(item dynamo.Key, a action, value types.AttributeValue) error {
+	parent, ok := resolve(item, a.path[:len(a.path)-1])
+	if !ok {
+		return validationError(errInvalidUpdatePath)
+	}
+
+	last := a.path[len(a.path)-1]
+
+	switch a.clause {
+	case clauseSet:
+		return setChild(parent, last, value)
+	case clauseRemove:
+		return removeChild(parent, last)
+	case clauseAdd:
+		current, exists := child(parent, last)
+		if !exists {
+			current = zeroOf(value)
+		}
+
+		sum, err := add(current, value)
+		if err != nil {
+			return err
+		}
+
+		return setChild(parent, last, sum)
+	default:
+		current, exists := child(parent, last)
+		if !exists {
+			return nil
+		}
+
+		rest, err := subtract(current, value)
+		if err != nil {
+			return err
+		}
+
+		if rest == nil {
+			return removeChild(parent, last)
+		}
+
+		return setChild(parent, last, rest)
+	}
+}
+
+// setChild sets an attribute of a document or an element of a list, indexes past the end of a list append to it.
+func setChild(parent types.AttributeValue, st step, value types.AttributeValue) error {
+	switch p := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if !st.isIndex {
+			p.Value[st.name] = attr.Clone(value)
+
+			return nil
+		}
+	case *types.AttributeValueMemberL:
+		if st.isIndex {
+			if st.index >= len(p.Value) {
+				p.Value = append(p.Value, attr.Clone(value))
+			} else {
+				p.Value[st.index] = attr.Clone(value)
+			}
+
+			return nil
+		}
+	}
+
+	return validationError(errInvalidUpdatePath)
+}
+
+// removeChild removes an attribute of a document or an element of a list, removing a missing one changes nothing.
+func removeChild(parent types.AttributeValue, st step) error {
+	switch p := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if !st.isIndex {
+			delete(p.Value, st.name)
+
+			return nil
+		}
+	case *types.AttributeValueMemberL:
+		if st.isIndex {
+			if st.index < len(p.Value) {
+				p.Value = append(p.Value[:st.index], p.Value[st.index+1:]...)
+			}
+
+			return nil
+		}
+	}
+
+	return validationError(errInvalidUpdatePath)
+}
+
+// add adds a number to a number or the members of a set to a set of the same type, like the ADD action.
+func add(current, value types.AttributeValue) (types.AttributeValue, error) {
+	switch c := current.(type) {
+	case *types.AttributeValueMemberN:
+		x, ok := parseNumber(c)
+		y, ok2 := parseNumber(value)
+
+		if !ok || !ok2 {
+			return nil, addTypeError(value)
+		}
+
+		return &types.AttributeValueMemberN{Value: formatNumber(x.Add(x, y))}, nil
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		if typeName(current) != typeName(value) {
+			return nil, addTypeError(value)
+		}
+
+		return union(current, value), nil
+	default:
+		return nil, addTypeError(current)
+	}
+}
+
+// zeroOf returns the value ADD starts from for a missing attribute, which is the neutral value of the type added.
+func zeroOf(value types.AttributeValue) types.AttributeValue {
+	switch value.(type) {
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: nil}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: nil}
+	case *types.AttributeValueMemberBS:
+		return &types.AttributeValueMemberBS{Value: nil}
+	default:
+		return &types.AttributeValueMemberN{Value: "0"}
+	}
+}
+
+func addTypeError(value types.AttributeValue) error {
+	return validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD, operand type: %s", typeName(value))
+}
+
+// union returns the members of two sets of the same type, which are never changed in place.
+func union(a, b types.AttributeValue) types.AttributeValue {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberSS:
+		members := append([]string{}, x.Value...)
+		for _, member := range b.(*types.AttributeValueMemberSS).Value {
+			if !attr.Contains(x, &types.AttributeValueMemberS{Value: member}) {
+				members = append(members, member)
+			}
+		}
+
+		return &types.AttributeValueMemberSS{Value: members}
+	case *types.AttributeValueMemberNS:
+		members := append([]string{}, x.Value...)
+		for _, member := range b.(*types.AttributeValueMemberNS).Value {
+			if !attr.Contains(x, &types.AttributeValueMemberN{Value: member}) {
+				members = append(members, member)
+			}
+		}
+
+		return &types.AttributeValueMemberNS{Value: members}
+	default:
+		members := append([][]byte{}, a.(*types.AttributeValueMemberBS).Value...)
+		for _, member := range b.(*types.AttributeValueMemberBS).Value {
+			if !attr.Contains(a, &types.AttributeValueMemberB{Value: member}) {
+				members = append(members, member)
+			}
+		}
+
+		return &types.AttributeValueMemberBS{Value: members}
+	}
+}
+
+// subtract removes the members of a set from a set of the same type, like the DELETE action.
+// It returns nil if no member is left, as DynamoDB removes empty sets.
+func subtract(current, value types.AttributeValue) (types.AttributeValue, error) {
+	if t := typeName(current); t != typeName(value) || (t != "SS" && t != "NS" && t != "BS") {
+		return nil, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: DELETE, operand type: %s", typeName(value))
+	}
+
+	var rest types.AttributeValue
+
+	switch c := current.(type) {
+	case *types.AttributeValueMemberSS:
+		var members []string
+		for _, member := range c.Value {
+			if !attr.Contains(value, &types.AttributeValueMemberS{Value: member}) {
+				members = append(members, member)
+			}
+		}
+
+		if len(members) > 0 {
+			rest = &types.AttributeValueMemberSS{Value: members}
+		}
+	case *types.AttributeValueMemberNS:
+		var members []string
+		for _, member := range c.Value {
+			if !attr.Contains(value, &types.AttributeValueMemberN{Value: member}) {
+				members = append(members, member)
+			}
+		}
+
+		if len(members) > 0 {
+			rest = &types.AttributeValueMemberNS{Value: members}
+		}
+	case *types.AttributeValueMemberBS:
+		var members [][]byte
+		for _, member := range c.Value {
+			if !attr.Contains(value, &types.AttributeValueMemberB{Value: member}) {
+				members = append(members, member)
+			}
+		}
+
+		if len(members) > 0 {
+			rest = &types.AttributeValueMemberBS{Value: members}
+		}
+	}
+
+	return rest, nil
+}
+
+func parseNumber(value types.AttributeValue) (*big.Rat, bool) {
+	n, ok := value.(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, false
+	}
+
+	return new(big.Rat).SetString(n.Value)
+}
+
+// formatNumber formats a number without an exponent, so that it can be unmarshalled into integers when it is one.
+func formatNumber(n *big.Rat) string {
+	if n.IsInt() {
+		return n.Num().String()
+	}
+
+	s := n.FloatString(maxNumberDigits)
+
+	return strings.TrimRight(s, "0")
+}
@@ -0,0 +1,244 @@
+package dynamotest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+func TestDB_UpdateItem(t *testing.T) {
+	ctx := context.Background()
+
+	stored := itemStub("foo", "bar", dynamo.Key{
+		"version": num("1"),
+		"price":   num("1.5"),
+		"tags":    list(str("a")),
+		"labels":  &types.AttributeValueMemberSS{Value: []string{"x", "y"}},
+		"meta":    &types.AttributeValueMemberM{Value: dynamo.Key{"title": str("foo"), "body": str("bar")}},
+	})
+
+	t.Run("success evaluates values against the item before the update", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		expected := itemStub("foo", "bar", dynamo.Key{
+			"version": num("2"),
+			"price":   num("1"),
+			"old":     num("1"),
+			"tags":    list(str("a"), str("b")),
+			"new":     list(str("b")),
+			"labels":  &types.AttributeValueMemberSS{Value: []string{"y"}},
+			"count":   num("2"),
+			"meta":    &types.AttributeValueMemberM{Value: dynamo.Key{"body": str("bar"), "draft": &types.AttributeValueMemberBOOL{Value: true}}},
+		})
+
+		// system under test
+		sut := createTestDB(t, stored)
+
+		// execute
+		out, err := sut.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(tableStub),
+			Key:       dynamo.K2("foo", "bar"),
+			UpdateExpression: aws.String("SET #v = #v + :one, old = #v, price = price - :half, " +
+				"tags = list_append(if_not_exists(tags, :empty), :b), new = list_append(if_not_exists(new, :empty), :b), meta.draft = :true " +
+				"REMOVE meta.title ADD #count :two DELETE labels :x"),
+			ExpressionAttributeNames: map[string]string{"#v": "version", "#count": "count"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":one":   num("1"),
+				":two":   num("2"),
+				":half":  num("0.5"),
+				":empty": list(),
+				":b":     list(str("b")),
+				":true":  &types.AttributeValueMemberBOOL{Value: true},
+				":x":     &types.AttributeValueMemberSS{Value: []string{"x"}},
+			},
+			ReturnValues: types.ReturnValueAllNew,
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expected, out.Attributes)
+		assert.Equal(t, expected, getItem(t, sut, dynamo.K2("foo", "bar")))
+	})
+
+	t.Run("success updated values", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t, stored)
+
+		// execute
+		out, err := sut.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(tableStub),
+			Key:                       dynamo.K2("foo", "bar"),
+			UpdateExpression:          aws.String("SET tags[5] = :c"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":c": str("c")},
+			ReturnValues:              types.ReturnValueUpdatedNew,
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.Key{"tags": list(str("a"), str("c"))}, out.Attributes)
+	})
+
+	t.Run("success missing item is created", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t)
+
+		// execute
+		_, err := sut.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(tableStub),
+			Key:                       dynamo.K2("foo", "bar"),
+			UpdateExpression:          aws.String("ADD hits :one"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":one": num("1")},
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, itemStub("foo", "bar", dynamo.Key{"hits": num("1")}), getItem(t, sut, dynamo.K2("foo", "bar")))
+	})
+
+	t.Run("fail condition changes nothing", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t, stored)
+
+		// execute
+		_, err := sut.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(tableStub),
+			Key:                       dynamo.K2("foo", "bar"),
+			UpdateExpression:          aws.String("SET #v = #v + :one"),
+			ConditionExpression:       aws.String("#v = :two"),
+			ExpressionAttributeNames:  map[string]string{"#v": "version"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":one": num("1"), ":two": num("2")},
+		})
+
+		// asserts
+		assertConditionalCheckFailed(t, err)
+		assert.Equal(t, stored, getItem(t, sut, dynamo.K2("foo", "bar")))
+	})
+
+	tests := []struct {
+		name   string
+		update string
+	}{
+		{name: "overlapping paths", update: "SET meta.title = :s REMOVE meta"},
+		{name: "key attribute", update: "SET sk = :s"},
+		{name: "missing parent", update: "SET missing.title = :s"},
+		{name: "index of a document", update: "SET meta[0] = :s"},
+		{name: "missing operand", update: "SET title = missing, body = :s"},
+		{name: "arithmetic of strings", update: "SET title = :s + :s"},
+		{name: "append to a string", update: "SET title = list_append(meta.title, :s)"},
+		{name: "add to a string", update: "ADD meta :s"},
+		{name: "clause used twice", update: "SET title = :s SET body = :s"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run("fail "+tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// system under test
+			sut := createTestDB(t, stored)
+
+			// execute
+			_, err := sut.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:                 aws.String(tableStub),
+				Key:                       dynamo.K2("foo", "bar"),
+				UpdateExpression:          aws.String(tt.update),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":s": str("foo")},
+			})
+
+			// asserts
+			assertValidation(t, err)
+			assert.Equal(t, stored, getItem(t, sut, dynamo.K2("foo", "bar")))
+		})
+	}
+}
+
+func TestDB_TransactWriteItems(t *testing.T) {
+	ctx := context.Background()
+
+	notExists := func(item dynamo.Key) types.TransactWriteItem {
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName:                aws.String(tableStub),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#pk)"),
+			ExpressionAttributeNames: map[string]string{"#pk": "pk"},
+		}}
+	}
+
+	t.Run("success applies every change", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t, itemStub("foo", "old", nil))
+
+		// execute
+		_, err := sut.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				notExists(itemStub("foo", "bar", nil)),
+				{Delete: &types.Delete{TableName: aws.String(tableStub), Key: dynamo.K2("foo", "old")}},
+			},
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.NotNil(t, getItem(t, sut, dynamo.K2("foo", "bar")))
+		assert.Nil(t, getItem(t, sut, dynamo.K2("foo", "old")))
+	})
+
+	t.Run("fail condition cancels the transaction", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t, itemStub("foo", "taken", nil))
+
+		// execute
+		_, err := sut.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				notExists(itemStub("foo", "bar", nil)),
+				notExists(itemStub("foo", "taken", dynamo.Key{"name": str("new")})),
+			},
+		})
+
+		// asserts
+		var tce *types.TransactionCanceledException
+
+		require.ErrorAs(t, err, &tce)
+		require.Len(t, tce.CancellationReasons, 2)
+		assert.Equal(t, "None", aws.ToString(tce.CancellationReasons[0].Code))
+		assert.Equal(t, "ConditionalCheckFailed", aws.ToString(tce.CancellationReasons[1].Code))
+		assert.Nil(t, getItem(t, sut, dynamo.K2("foo", "bar")))
+		assert.Equal(t, itemStub("foo", "taken", nil), getItem(t, sut, dynamo.K2("foo", "taken")))
+	})
+
+	t.Run("fail several changes of an item", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createTestDB(t)
+
+		// execute
+		_, err := sut.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				notExists(itemStub("foo", "bar", nil)),
+				{Delete: &types.Delete{TableName: aws.String(tableStub), Key: dynamo.K2("foo", "bar")}},
+			},
+		})
+
+		// asserts
+		assertValidation(t, err)
+	})
+}
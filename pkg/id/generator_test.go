@@ -2,7 +2,9 @@ package id_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/abtercms/abtercms2/pkg/id"
@@ -39,3 +41,48 @@ func TestGenerator_NewString(t *testing.T) {
 	assert.NotEmpty(t, got1)
 	assert.NotEqual(t, got0, got1)
 }
+
+func TestAfter(t *testing.T) {
+	t.Parallel()
+
+	// stubs
+	now := time.Now()
+	before := ulid.MustNew(ulid.Timestamp(now), ulid.DefaultEntropy()).String()
+	later := ulid.MustNew(ulid.Timestamp(now.Add(time.Millisecond)), ulid.DefaultEntropy()).String()
+
+	// execute
+	got := id.After(now)
+
+	// asserts
+	assert.Greater(t, got, before)
+	assert.LessOrEqual(t, got, later)
+}
+
+func TestTime(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail invalid id", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		_, err := id.Time("foo")
+
+		// asserts
+		assert.Error(t, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		createdAt := time.Date(2022, 6, 1, 10, 0, 0, 123000000, time.UTC)
+		idStub := ulid.MustNew(ulid.Timestamp(createdAt), ulid.DefaultEntropy()).String()
+
+		// execute
+		got, err := id.Time(idStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, createdAt, got)
+	})
+}
@@ -0,0 +1,27 @@
+package id
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// After returns the lowest ULID which is greater than any ULID created at or before the given time.
+// ULIDs sort lexically in creation order, therefore it can be used to compare IDs with points in time.
+func After(t time.Time) string {
+	var id ulid.ULID
+
+	_ = id.SetTime(ulid.Timestamp(t) + 1)
+
+	return id.String()
+}
+
+// Time returns the time a ULID was created at.
+func Time(id string) (time.Time, error) {
+	parsed, err := ulid.ParseStrict(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return ulid.Time(parsed.Time()).UTC(), nil
+}
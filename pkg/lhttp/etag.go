@@ -0,0 +1,74 @@
+package lhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+
+	// AnyVersion is the version expected by clients sending If-Match: *, which matches any version of an existing entity.
+	AnyVersion int64 = 0
+
+	anyETag = "*"
+)
+
+var (
+	errIfMatchMissing = NewProblem(http.StatusPreconditionRequired, "If-Match header is required to modify this resource.")
+	errIfMatchInvalid = NewProblem(http.StatusBadRequest, "If-Match header must contain a single strong entity tag.")
+)
+
+// ETag formats an entity version as a strong entity tag.
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ETagHeaders returns response headers carrying the entity tag of the given version.
+func ETagHeaders(version int64) map[string]string {
+	return map[string]string{
+		headerETag: ETag(version),
+	}
+}
+
+// IfMatch retrieves the entity version expected by the client from the If-Match header.
+// If-Match: * results in AnyVersion, callers then condition the change on the version of the entity they read.
+func IfMatch(headers map[string]string) (int64, error) {
+	value, ok := Header(headers, headerIfMatch)
+	if !ok || value == "" {
+		return 0, errIfMatchMissing
+	}
+
+	if strings.TrimSpace(value) == anyETag {
+		return AnyVersion, nil
+	}
+
+	unquoted, err := strconv.Unquote(strings.TrimSpace(value))
+	if err != nil {
+		return 0, WrapProblem(err, http.StatusBadRequest, "invalid If-Match header: %s", value)
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errIfMatchInvalid
+	}
+
+	return version, nil
+}
+
+// Header retrieves a header value using a case-insensitive lookup as header names may arrive in any case.
+func Header(headers map[string]string, name string) (string, bool) {
+	if value, ok := headers[name]; ok {
+		return value, true
+	}
+
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,102 @@
+package lhttp_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+func TestETag(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `"3"`, lhttp.ETag(3))
+	assert.Equal(t, map[string]string{"ETag": `"3"`}, lhttp.ETagHeaders(3))
+}
+
+func TestIfMatch(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		headers map[string]string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       int64
+		wantStatus int
+	}{
+		{
+			name: "default",
+			args: args{
+				headers: map[string]string{"If-Match": `"3"`},
+			},
+			want: 3,
+		},
+		{
+			name: "lower case header",
+			args: args{
+				headers: map[string]string{"if-match": `"12"`},
+			},
+			want: 12,
+		},
+		{
+			name: "any version",
+			args: args{
+				headers: map[string]string{"If-Match": `*`},
+			},
+			want: lhttp.AnyVersion,
+		},
+		{
+			name: "missing header",
+			args: args{
+				headers: map[string]string{},
+			},
+			wantStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name: "unquoted tag",
+			args: args{
+				headers: map[string]string{"If-Match": `3`},
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "weak tag",
+			args: args{
+				headers: map[string]string{"If-Match": `W/"3"`},
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "non-numeric tag",
+			args: args{
+				headers: map[string]string{"If-Match": `"foo"`},
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// execute
+			got, err := lhttp.IfMatch(tt.args.headers)
+
+			// asserts
+			if tt.wantStatus != 0 {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantStatus, lhttp.ToProblem(err).Status)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
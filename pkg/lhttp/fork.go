@@ -0,0 +1,22 @@
+package lhttp
+
+import (
+	"context"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Fork dispatches requests to the handler registered for the literal value of a path parameter,
+// falling back to next for any other value.
+// lmdrouter matches routes in random order, therefore literal segments like "/trash" can not be routed
+// next to a parameter like "/:id" and have to be told apart by the handler of the parameter instead.
+func Fork(param string, literals map[string]lmdrouter.Handler, next lmdrouter.Handler) lmdrouter.Handler {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if h, ok := literals[req.PathParameters[param]]; ok {
+			return h(ctx, req)
+		}
+
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,59 @@
+package lhttp_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+func TestFork(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	respond := func(status int) lmdrouter.Handler {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{StatusCode: status}, nil
+		}
+	}
+
+	// system under test
+	sut := lhttp.Fork("id", map[string]lmdrouter.Handler{"trash": respond(http.StatusAccepted)}, respond(http.StatusOK))
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{name: "literal", id: "trash", want: http.StatusAccepted},
+		{name: "parameter", id: "foo", want: http.StatusOK},
+		{name: "missing parameter", id: "", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// stubs
+			requestStub := events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"id": tt.id},
+			}
+
+			// execute
+			res, err := sut(ctx, requestStub)
+
+			// asserts
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, res.StatusCode)
+		})
+	}
+}
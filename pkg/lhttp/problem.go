@@ -11,6 +11,8 @@ const (
 	newErrorFormat  = "%s (status %d)"
 	errorWrapFormat = "%s (status %d), err: %w"
 	typeFormat      = "https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/%d"
+
+	errInvalidParams = "request failed validation: %v"
 )
 
 var (
@@ -25,30 +27,54 @@ type Problem struct {
 	Title  string `json:"title"`
 	Status int    `json:"status"`
 	Detail string `json:"detail,omitempty"`
+
+	// InvalidParams is an extension member listing every field which failed validation.
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// InvalidParam describes why a single field of a request failed validation.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
 }
 
 // NewProblem creates a new error with an HTTP status.
 func NewProblem(status int, msg string, args ...interface{}) *Problem {
 	return &Problem{
-		error:  fmt.Errorf(newErrorFormat, fmt.Sprintf(msg, args...), status), // nolint: goerr113
-		Type:   fmt.Sprintf(typeFormat, status),
-		Status: status,
-		Title:  http.StatusText(status),
-		Detail: "",
+		error:         fmt.Errorf(newErrorFormat, fmt.Sprintf(msg, args...), status), // nolint: goerr113
+		Type:          fmt.Sprintf(typeFormat, status),
+		Status:        status,
+		Title:         http.StatusText(status),
+		Detail:        "",
+		InvalidParams: nil,
 	}
 }
 
 // WrapProblem wraps an error with a message and an HTTP status.
 func WrapProblem(err error, status int, msg string, args ...interface{}) *Problem {
 	return &Problem{
-		error:  fmt.Errorf(errorWrapFormat, fmt.Sprintf(msg, args...), status, err),
-		Type:   fmt.Sprintf(typeFormat, status),
-		Status: status,
-		Title:  http.StatusText(status),
-		Detail: "",
+		error:         fmt.Errorf(errorWrapFormat, fmt.Sprintf(msg, args...), status, err),
+		Type:          fmt.Sprintf(typeFormat, status),
+		Status:        status,
+		Title:         http.StatusText(status),
+		Detail:        "",
+		InvalidParams: nil,
 	}
 }
 
+// Unwrap returns the error wrapped by the problem, therefore errors.Is and errors.As see through problems.
+func (p *Problem) Unwrap() error {
+	return errors.Unwrap(p.error)
+}
+
+// NewInvalidParamsProblem creates a new 422 error listing the fields of a request which failed validation.
+func NewInvalidParamsProblem(params []InvalidParam) *Problem {
+	problem := NewProblem(http.StatusUnprocessableEntity, errInvalidParams, params)
+	problem.InvalidParams = params
+
+	return problem
+}
+
 // ToProblem attempts to unwrap the received error to find the wrapped.
 func ToProblem(err error) *Problem {
 	if err == nil {
@@ -62,11 +88,12 @@ func ToProblem(err error) *Problem {
 
 	if problem == nil {
 		problem = &Problem{
-			error:  fmt.Errorf(errorWrapFormat, err.Error(), http.StatusInternalServerError, err),
-			Type:   fmt.Sprintf(typeFormat, http.StatusInternalServerError),
-			Status: http.StatusInternalServerError,
-			Title:  http.StatusText(http.StatusInternalServerError),
-			Detail: "",
+			error:         fmt.Errorf(errorWrapFormat, err.Error(), http.StatusInternalServerError, err),
+			Type:          fmt.Sprintf(typeFormat, http.StatusInternalServerError),
+			Status:        http.StatusInternalServerError,
+			Title:         http.StatusText(http.StatusInternalServerError),
+			Detail:        "",
+			InvalidParams: nil,
 		}
 	}
 
@@ -137,6 +137,24 @@ func TestWrapProblem(t *testing.T) {
 			assert.Contains(t, sut.Type, fmt.Sprintf("%d", tt.args.status))
 			assert.Empty(t, sut.Detail)
 			assert.Contains(t, sut.Error(), tt.args.err.Error())
+			assert.ErrorIs(t, sut, tt.args.err)
 		})
 	}
 }
+
+func TestNewInvalidParamsProblem(t *testing.T) {
+	t.Parallel()
+
+	// stubs
+	paramsStub := []lhttp.InvalidParam{
+		{Name: "name", Reason: "is required"},
+		{Name: "status", Reason: "must be one of: active, inactive"},
+	}
+
+	// execute
+	sut := lhttp.NewInvalidParamsProblem(paramsStub)
+
+	// asserts
+	assert.Equal(t, http.StatusUnprocessableEntity, sut.Status)
+	assert.Equal(t, paramsStub, lhttp.ToProblem(fmt.Errorf("foo: %w", sut)).InvalidParams)
+}
@@ -0,0 +1,278 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/records"
+)
+
+const (
+	errGuardTaken     = "unique value already taken"
+	errGuardReleased  = "required record changed in the meantime"
+	errMarshallGuards = "failed to marshal guard"
+	errItemNotFound   = "item not found"
+	errItemNotTrashed = "item not found in trash"
+	errMissingVersion = "item has no version"
+)
+
+// changes are the records written by a guarded write, they are only applied once every condition is checked.
+// A nil record is a deleted one.
+type changes map[string]dynamo.Key
+
+// CreateGuarded creates a new record along with the guards reserving its unique values.
+// A taken unique value results in a 409 conflict, like an existing record.
+func (s *Store) CreateGuarded(_ context.Context, item interface{}, guards dynamo.Guards) error {
+	itemMarshalled, id, err := marshalItem(item)
+	if err != nil {
+		return err
+	}
+
+	itemMarshalled[records.VersionKey] = records.Version(dynamo.InitialVersion)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[id]; ok {
+		return lhttp.NewProblem(http.StatusConflict, errItemExists)
+	}
+
+	return s.write(changes{id: itemMarshalled}, guards)
+}
+
+// PutGuarded writes a record whether it exists or not, along with adding and removing guards of its unique values.
+// It suits records without versions, such as snapshots replaced as a whole.
+func (s *Store) PutGuarded(_ context.Context, item interface{}, guards dynamo.Guards) error {
+	itemMarshalled, id, err := marshalItem(item)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(changes{id: itemMarshalled}, guards)
+}
+
+// UpdateGuarded updates an existing record like Update, while adding and removing guards of its unique values.
+func (s *Store) UpdateGuarded(_ context.Context, item interface{}, version int64, guards dynamo.Guards) (int64, error) {
+	itemMarshalled, id, err := marshalItem(item)
+	if err != nil {
+		return 0, err
+	}
+
+	itemMarshalled[records.VersionKey] = records.Version(version + 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = records.CheckWritable(s.items[id], version)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.write(changes{id: itemMarshalled}, guards)
+	if err != nil {
+		return 0, err
+	}
+
+	return version + 1, nil
+}
+
+// DeleteGuarded deletes a record along with the guards of its unique values.
+func (s *Store) DeleteGuarded(_ context.Context, key dynamo.Key, guards []dynamo.Key) error {
+	id, ok := storageID(key)
+	if !ok {
+		return lhttp.NewProblem(http.StatusInternalServerError, errMissingKey)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(changes{id: nil}, dynamo.Guards{Add: nil, Remove: guards, Keep: nil})
+}
+
+// TrashGuarded marks an existing record as deleted like dynamo.Repo.Trash, while deleting the guards of its unique values.
+// The record is moved to the given partition of the first index. If expiresAt is not zero, the first PurgeExpired after
+// it deletes the record.
+func (s *Store) TrashGuarded(_ context.Context, key dynamo.Key, index dynamo.Keys, deletedAt, expiresAt time.Time, guards []dynamo.Key) error {
+	deletedAtMarshalled, err := attributevalue.Marshal(deletedAt.UTC())
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallItem)
+	}
+
+	id, item, ok := s.stored(key)
+	if !ok || records.IsTrashed(item) {
+		return lhttp.NewProblem(http.StatusNotFound, errItemNotFound)
+	}
+
+	item[records.DeletedAtKey] = deletedAtMarshalled
+	if !expiresAt.IsZero() {
+		item[dynamo.ExpiresAtKey] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.move(id, item, index, dynamo.Guards{Add: nil, Remove: guards, Keep: nil})
+}
+
+// RestoreGuarded brings back a trashed record like dynamo.Repo.Restore, while taking back the guards of its unique values.
+// Unique values taken by other records in the meantime result in a 409 conflict, the record is kept in the trash then.
+func (s *Store) RestoreGuarded(_ context.Context, key dynamo.Key, index dynamo.Keys, guards []interface{}, result interface{}) error {
+	id, item, ok := s.stored(key)
+	if !ok || !records.IsTrashed(item) {
+		return lhttp.NewProblem(http.StatusNotFound, errItemNotTrashed)
+	}
+
+	delete(item, records.DeletedAtKey)
+	delete(item, dynamo.ExpiresAtKey)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.move(id, item, index, dynamo.Guards{Add: guards, Remove: nil, Keep: nil})
+	if err != nil {
+		return err
+	}
+
+	err = attributevalue.UnmarshalMap(item, result)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes the records whose dynamo.ExpiresAtKey has passed and returns how many were deleted.
+// It takes the place of the TTL of DynamoDB, like sqlstore.Store.PurgeExpired.
+func (s *Store) PurgeExpired(_ context.Context, now time.Time) (int64, error) {
+	var purged int64
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, item := range s.items {
+		n, ok := item[dynamo.ExpiresAtKey].(*types.AttributeValueMemberN)
+		if !ok {
+			continue
+		}
+
+		expiresAt, err := strconv.ParseInt(n.Value, 10, 64)
+		if err == nil && expiresAt <= now.Unix() {
+			delete(s.items, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// stored returns the identifier and a copy of the record with the given key.
+func (s *Store) stored(key dynamo.Key) (string, dynamo.Key, bool) {
+	id, ok := storageID(key)
+	if !ok {
+		return "", nil, false
+	}
+
+	item, ok := s.item(key)
+
+	return id, item, ok
+}
+
+// move stores a record in the given partition of the first index at its next version, along with its guards.
+// The record must still be at the version it was read at, otherwise it changed in the meantime.
+func (s *Store) move(id string, item dynamo.Key, index dynamo.Keys, guards dynamo.Guards) error {
+	n, ok := item[records.VersionKey].(*types.AttributeValueMemberN)
+	if !ok {
+		return lhttp.NewProblem(http.StatusInternalServerError, errMissingVersion)
+	}
+
+	version, err := strconv.ParseInt(n.Value, 10, 64)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errMissingVersion)
+	}
+
+	current, ok := s.items[id]
+	if !ok || !records.Includes(current, dynamo.Key{records.VersionKey: n}) {
+		return lhttp.NewProblem(http.StatusConflict, errGuardReleased)
+	}
+
+	partitionName, sortName := dynamo.KeyNames(dynamo.GSI1)
+	item[partitionName] = &types.AttributeValueMemberS{Value: index.GSI1PK}
+	item[sortName] = &types.AttributeValueMemberS{Value: index.GSI1SK}
+	item[records.VersionKey] = records.Version(version + 1)
+
+	return s.write(changes{id: item}, guards)
+}
+
+// write applies the changes of a record along with its guards, once taken guards and changed guards kept are ruled out.
+// A guard which is already taken, or a kept guard which changed, results in a 409 conflict and nothing is written.
+// Callers must hold the lock of the store.
+func (s *Store) write(pending changes, guards dynamo.Guards) error {
+	for _, guard := range guards.Add {
+		guardMarshalled, id, err := marshalItem(guard)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallGuards)
+		}
+
+		if _, ok := s.items[id]; ok {
+			return lhttp.NewProblem(http.StatusConflict, errGuardTaken)
+		}
+
+		pending[id] = guardMarshalled
+	}
+
+	for _, key := range guards.Remove {
+		id, ok := storageID(key)
+		if !ok {
+			return lhttp.NewProblem(http.StatusInternalServerError, errMissingKey)
+		}
+
+		pending[id] = nil
+	}
+
+	for _, guard := range guards.Keep {
+		guardMarshalled, id, err := marshalItem(guard)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallGuards)
+		}
+
+		stored, ok := s.items[id]
+		if !ok || !records.Includes(stored, guardMarshalled) {
+			return lhttp.NewProblem(http.StatusConflict, errGuardReleased)
+		}
+	}
+
+	for id, item := range pending {
+		if item == nil {
+			delete(s.items, id)
+		} else {
+			s.items[id] = item
+		}
+	}
+
+	return nil
+}
+
+// marshalItem marshals a record and returns the identifier it is kept by.
+func marshalItem(item interface{}) (dynamo.Key, string, error) {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, "", lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	id, ok := storageID(itemMarshalled)
+	if !ok {
+		return nil, "", lhttp.NewProblem(http.StatusInternalServerError, errMissingKey)
+	}
+
+	return itemMarshalled, id, nil
+}
@@ -0,0 +1,369 @@
+package memory_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/memory"
+)
+
+const (
+	itemType  = "ITEM"
+	trashType = "TRASH#ITEM"
+)
+
+type item struct {
+	dynamo.Keys
+	ID        string     `dynamodbav:"pk"`
+	Version   int64      `dynamodbav:"version"`
+	Name      string     `dynamodbav:"name,omitempty"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at,omitempty"`
+}
+
+// itemStub creates an item listed in the index partition of items by its ID.
+func itemStub(id string) item {
+	return item{
+		Keys:      dynamo.Keys{SK: itemType, GSI1PK: itemType, GSI1SK: id},
+		ID:        id,
+		Version:   0,
+		Name:      "",
+		DeletedAt: nil,
+	}
+}
+
+const guardType = "NAME"
+
+type guard struct {
+	Name  string `dynamodbav:"pk"`
+	SK    string `dynamodbav:"sk"`
+	Owner string `dynamodbav:"owner"`
+}
+
+func guardStub(name, owner string) guard {
+	return guard{Name: "NAME#" + name, SK: guardType, Owner: owner}
+}
+
+func guardKey(name string) dynamo.Key {
+	return dynamo.K2("NAME#"+name, guardType)
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+
+	require.Error(t, err)
+	assert.Equal(t, status, lhttp.ToProblem(err).Status)
+}
+
+func TestStore_CreateGuarded(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("success creates the record and its guards", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: []interface{}{guardStub("a", "foo")}, Remove: nil, Keep: nil})
+
+		// asserts
+		require.NoError(t, err)
+
+		var stored guard
+
+		found, err := sut.Find(ctx, guardKey("a"), &stored)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, guardStub("a", "foo"), stored)
+	})
+
+	t.Run("fail taken guard causes 409 conflict and creates nothing", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: []interface{}{guardStub("a", "foo")}, Remove: nil, Keep: nil}))
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub("bar"), dynamo.Guards{
+			Add:    []interface{}{guardStub("b", "bar"), guardStub("a", "bar")},
+			Remove: nil,
+			Keep:   nil,
+		})
+
+		// asserts
+		assertStatus(t, http.StatusConflict, err)
+
+		found, err := sut.Find(ctx, dynamo.K2("bar", itemType), &item{})
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		found, err = sut.Find(ctx, guardKey("b"), &guard{})
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("fail kept guard changed in the meantime causes 409 conflict and creates nothing", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.Create(ctx, guardStub("a", "bar")))
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: nil, Remove: nil, Keep: []interface{}{guardStub("a", "foo")}})
+
+		// asserts
+		assertStatus(t, http.StatusConflict, err)
+
+		found, err := sut.Find(ctx, dynamo.K2("foo", itemType), &item{})
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("success kept guard is checked but not written", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.Create(ctx, guardStub("a", "bar")))
+
+		// execute
+		err := sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: nil, Remove: nil, Keep: []interface{}{guardStub("a", "bar")}})
+
+		// asserts
+		require.NoError(t, err)
+
+		found, err := sut.Find(ctx, dynamo.K2("foo", itemType), &item{})
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+}
+
+func TestStore_UpdateGuarded(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("success swaps guards", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: []interface{}{guardStub("a", "foo")}, Remove: nil, Keep: nil}))
+
+		// execute
+		version, err := sut.UpdateGuarded(ctx, itemStub("foo"), dynamo.InitialVersion, dynamo.Guards{
+			Add:    []interface{}{guardStub("b", "foo")},
+			Remove: []dynamo.Key{guardKey("a")},
+			Keep:   nil,
+		})
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.InitialVersion+1, version)
+
+		found, err := sut.Find(ctx, guardKey("a"), &guard{})
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		found, err = sut.Find(ctx, guardKey("b"), &guard{})
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+
+	t.Run("fail taken guard causes 409 conflict and changes nothing", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		changed := itemStub("foo")
+		changed.Name = "changed"
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: []interface{}{guardStub("a", "foo")}, Remove: nil, Keep: nil}))
+		require.NoError(t, sut.CreateGuarded(ctx, itemStub("bar"), dynamo.Guards{Add: []interface{}{guardStub("b", "bar")}, Remove: nil, Keep: nil}))
+
+		// execute
+		_, err := sut.UpdateGuarded(ctx, changed, dynamo.InitialVersion, dynamo.Guards{
+			Add:    []interface{}{guardStub("b", "foo")},
+			Remove: []dynamo.Key{guardKey("a")},
+			Keep:   nil,
+		})
+
+		// asserts
+		assertStatus(t, http.StatusConflict, err)
+
+		var stored item
+
+		_, err = sut.Find(ctx, dynamo.K2("foo", itemType), &stored)
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.InitialVersion, stored.Version)
+		assert.Empty(t, stored.Name)
+
+		found, err := sut.Find(ctx, guardKey("a"), &guard{})
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+}
+
+func TestStore_TrashGuarded(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deletedAt := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	trash := dynamo.Keys{SK: itemType, GSI1PK: trashType, GSI1SK: "foo"}
+
+	t.Run("success moves the record to the trash and releases its guards", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: []interface{}{guardStub("a", "foo")}, Remove: nil, Keep: nil}))
+
+		// execute
+		err := sut.TrashGuarded(ctx, dynamo.K2("foo", itemType), trash, deletedAt, deletedAt.Add(time.Hour), []dynamo.Key{guardKey("a")})
+
+		// asserts
+		require.NoError(t, err)
+
+		var listed []item
+
+		_, err = sut.Query(ctx, dynamo.Query{Index: dynamo.GSI1, Partition: trashType}, &listed)
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, dynamo.InitialVersion+1, listed[0].Version)
+		assert.Equal(t, &deletedAt, listed[0].DeletedAt)
+
+		count, _, err := sut.Count(ctx, dynamo.Query{Index: dynamo.GSI1, Partition: itemType})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		found, err := sut.Find(ctx, guardKey("a"), &guard{})
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("success expired records are purged", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.Create(ctx, itemStub("foo")))
+		require.NoError(t, sut.Create(ctx, itemStub("bar")))
+		require.NoError(t, sut.TrashGuarded(ctx, dynamo.K2("foo", itemType), trash, deletedAt, deletedAt.Add(time.Hour), nil))
+		require.NoError(t, sut.TrashGuarded(ctx, dynamo.K2("bar", itemType), trash, deletedAt, time.Time{}, nil))
+
+		// execute
+		purged, err := sut.PurgeExpired(ctx, deletedAt.Add(time.Hour))
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+
+		found, err := sut.Find(ctx, dynamo.K2("foo", itemType), &item{})
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		found, err = sut.Find(ctx, dynamo.K2("bar", itemType), &item{})
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+
+	t.Run("fail trashed record causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.Create(ctx, itemStub("foo")))
+		require.NoError(t, sut.TrashGuarded(ctx, dynamo.K2("foo", itemType), trash, deletedAt, time.Time{}, nil))
+
+		// execute
+		err := sut.TrashGuarded(ctx, dynamo.K2("foo", itemType), trash, deletedAt, time.Time{}, nil)
+
+		// asserts
+		assertStatus(t, http.StatusNotFound, err)
+	})
+}
+
+func TestStore_RestoreGuarded(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deletedAt := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	trash := dynamo.Keys{SK: itemType, GSI1PK: trashType, GSI1SK: "foo"}
+	active := dynamo.Keys{SK: itemType, GSI1PK: itemType, GSI1SK: "foo"}
+
+	t.Run("success brings back the record and its guards", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: []interface{}{guardStub("a", "foo")}, Remove: nil, Keep: nil}))
+		require.NoError(t, sut.TrashGuarded(ctx, dynamo.K2("foo", itemType), trash, deletedAt, deletedAt.Add(time.Hour), []dynamo.Key{guardKey("a")}))
+
+		// execute
+		var restored item
+
+		err := sut.RestoreGuarded(ctx, dynamo.K2("foo", itemType), active, []interface{}{guardStub("a", "foo")}, &restored)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.InitialVersion+2, restored.Version)
+		assert.Nil(t, restored.DeletedAt)
+
+		purged, err := sut.PurgeExpired(ctx, deletedAt.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		count, _, err := sut.Count(ctx, dynamo.Query{Index: dynamo.GSI1, Partition: itemType})
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), count)
+	})
+
+	t.Run("fail taken guard causes 409 conflict and keeps the record in the trash", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.CreateGuarded(ctx, itemStub("foo"), dynamo.Guards{Add: []interface{}{guardStub("a", "foo")}, Remove: nil, Keep: nil}))
+		require.NoError(t, sut.TrashGuarded(ctx, dynamo.K2("foo", itemType), trash, deletedAt, time.Time{}, []dynamo.Key{guardKey("a")}))
+		require.NoError(t, sut.CreateGuarded(ctx, itemStub("bar"), dynamo.Guards{Add: []interface{}{guardStub("a", "bar")}, Remove: nil, Keep: nil}))
+
+		// execute
+		err := sut.RestoreGuarded(ctx, dynamo.K2("foo", itemType), active, []interface{}{guardStub("a", "foo")}, &item{})
+
+		// asserts
+		assertStatus(t, http.StatusConflict, err)
+
+		var stored item
+
+		_, err = sut.Find(ctx, dynamo.K2("foo", itemType), &stored)
+		require.NoError(t, err)
+		assert.NotNil(t, stored.DeletedAt)
+		assert.Equal(t, trashType, stored.GSI1PK)
+	})
+
+	t.Run("fail record not in the trash causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := memory.NewStore()
+		require.NoError(t, sut.Create(ctx, itemStub("foo")))
+
+		// execute
+		err := sut.RestoreGuarded(ctx, dynamo.K2("foo", itemType), active, nil, &item{})
+
+		// asserts
+		assertStatus(t, http.StatusNotFound, err)
+	})
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/records"
+)
+
+// Patch applies the operations to an existing record like dynamo.Repo does in a single UpdateItem.
+// The write only succeeds if the record exists and is still at the given version, the updated record is stored in result
+// and the new version is returned.
+func (s *Store) Patch(_ context.Context, key dynamo.Key, version int64, ops []patch.Operation, result interface{}) (int64, error) {
+	id, ok := storageID(key)
+	if !ok {
+		return 0, lhttp.NewProblem(http.StatusInternalServerError, errMissingKey)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, err := records.Patch(s.items[id], version, ops)
+	if err != nil {
+		return 0, err
+	}
+
+	err = attributevalue.UnmarshalMap(item, result)
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	s.items[id] = item
+
+	return version + 1, nil
+}
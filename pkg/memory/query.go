@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/records"
+)
+
+// Query lists records in a partition of the table or one of its indexes, ordered by their sort key.
+// Records without the keys of an index are not part of it. Filters are applied before the page is cut,
+// therefore a page is only short if it is the last one, and Next continues the query after its last record.
+func (s *Store) Query(_ context.Context, query dynamo.Query, result interface{}) (dynamo.Page, error) {
+	var (
+		page    = make([]dynamo.Key, 0)
+		hasMore = false
+	)
+
+	for _, item := range s.matching(query) {
+		if query.Limit > 0 && int32(len(page)) == query.Limit {
+			hasMore = true
+
+			break
+		}
+
+		page = append(page, item)
+	}
+
+	err := attributevalue.UnmarshalListOfMaps(page, result)
+	if err != nil {
+		return dynamo.Page{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItems)
+	}
+
+	if !hasMore {
+		return dynamo.Page{Next: nil, HasMore: false}, nil
+	}
+
+	return dynamo.Page{Next: records.NextKey(page[len(page)-1], query.Index), HasMore: true}, nil
+}
+
+// Count counts the records matching a query, the count is always exact.
+func (s *Store) Count(_ context.Context, query dynamo.Query) (int32, bool, error) {
+	return int32(len(s.matching(query))), true, nil
+}
+
+// matching returns copies of the records matching a query following its exclusive start key, in the order of the query.
+func (s *Store) matching(query dynamo.Query) []dynamo.Key {
+	partitionName, sortName := dynamo.KeyNames(query.Index)
+
+	s.mu.RLock()
+	items := make([]dynamo.Key, 0)
+
+	for _, item := range s.items {
+		if inPartition(item, partitionName, query.Partition) && matchesSortKey(item[sortName], query.SortKey) {
+			items = append(items, attr.CloneItem(item))
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		less := compareItems(items[i], items[j], sortName) < 0
+		if query.Descending {
+			return !less
+		}
+
+		return less
+	})
+
+	result := make([]dynamo.Key, 0, len(items))
+
+	for _, item := range items {
+		if isAfter(item, query.ExclusiveStartKey, sortName, query.Descending) && records.MatchesFilters(item, query.Filters) {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+func inPartition(item dynamo.Key, partitionName, partition string) bool {
+	pk, ok := item[partitionName].(*types.AttributeValueMemberS)
+
+	return ok && pk.Value == partition
+}
+
+// matchesSortKey tells whether a sort key satisfies a sort key condition, sort keys are always strings.
+func matchesSortKey(value types.AttributeValue, condition *dynamo.SortKeyCondition) bool {
+	sk, ok := value.(*types.AttributeValueMemberS)
+	if !ok {
+		return false
+	}
+
+	if condition == nil {
+		return true
+	}
+
+	switch condition.Op {
+	case dynamo.KeyLessThan:
+		return sk.Value < condition.Value
+	case dynamo.KeyLessThanEqual:
+		return sk.Value <= condition.Value
+	case dynamo.KeyGreaterThan:
+		return sk.Value > condition.Value
+	case dynamo.KeyGreaterThanEqual:
+		return sk.Value >= condition.Value
+	case dynamo.KeyBeginsWith:
+		return strings.HasPrefix(sk.Value, condition.Value)
+	case dynamo.KeyBetween:
+		return sk.Value >= condition.Value && sk.Value <= condition.Upper
+	default:
+		return sk.Value == condition.Value
+	}
+}
+
+// compareItems orders records by a sort key, records sharing it in an index are ordered by their table key.
+func compareItems(a, b dynamo.Key, sortName string) int {
+	partitionName, tableSortName := dynamo.KeyNames("")
+
+	for _, name := range []string{sortName, partitionName, tableSortName} {
+		if c := strings.Compare(records.StringValue(a[name]), records.StringValue(b[name])); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// isAfter tells whether a record follows the record an exclusive start key was taken from in the order of the query.
+func isAfter(item, exclusiveStartKey dynamo.Key, sortName string, descending bool) bool {
+	if len(exclusiveStartKey) == 0 {
+		return true
+	}
+
+	c := compareItems(item, exclusiveStartKey, sortName)
+	if descending {
+		return c < 0
+	}
+
+	return c > 0
+}
@@ -0,0 +1,169 @@
+// Package memory for keeping the records of the single table in memory, e.g. as a test double of dynamo.Repo or
+// when running locally via table.Open
+package memory
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/records"
+)
+
+const (
+	errMarshallItem    = "failed to marshal item"
+	errUnmarshallItem  = "failed to unmarshal item"
+	errUnmarshallItems = "failed to unmarshal items"
+	errMissingKey      = "item has no table key"
+	errItemExists      = "item already exists"
+)
+
+// Store keeps records in memory, it implements dynamo.Store with the semantics of dynamo.Repo.
+// Records are kept marshalled to attribute values, therefore they are stored, filtered and ordered like in DynamoDB.
+// Store is safe for concurrent use, every change is applied atomically.
+type Store struct {
+	mu    sync.RWMutex
+	items map[string]dynamo.Key
+}
+
+// NewStore creates a new empty Store instance.
+func NewStore() *Store {
+	return &Store{
+		mu:    sync.RWMutex{},
+		items: map[string]dynamo.Key{},
+	}
+}
+
+// Create creates a new record.
+// The record is stored with dynamo.InitialVersion regardless of the version it carries and never overwrites an existing one.
+func (s *Store) Create(_ context.Context, item interface{}) error {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	id, ok := storageID(itemMarshalled)
+	if !ok {
+		return lhttp.NewProblem(http.StatusInternalServerError, errMissingKey)
+	}
+
+	itemMarshalled[records.VersionKey] = records.Version(dynamo.InitialVersion)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[id]; ok {
+		return lhttp.NewProblem(http.StatusConflict, errItemExists)
+	}
+
+	s.items[id] = itemMarshalled
+
+	return nil
+}
+
+// Find retrieves a record by key and tells whether it exists, missing records leave result untouched.
+func (s *Store) Find(_ context.Context, key dynamo.Key, result interface{}) (bool, error) {
+	item, ok := s.item(key)
+	if !ok {
+		return false, nil
+	}
+
+	err := attributevalue.UnmarshalMap(item, result)
+	if err != nil {
+		return false, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	return true, nil
+}
+
+// Get retrieves a record by key, missing records leave result untouched.
+func (s *Store) Get(ctx context.Context, key dynamo.Key, result interface{}) error {
+	_, err := s.Find(ctx, key, result)
+
+	return err
+}
+
+// Update updates an existing record.
+// The write only succeeds if the record exists, is not trashed and is still at the given version, the new version is returned.
+func (s *Store) Update(_ context.Context, item interface{}, version int64) (int64, error) {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	id, ok := storageID(itemMarshalled)
+	if !ok {
+		return 0, lhttp.NewProblem(http.StatusInternalServerError, errMissingKey)
+	}
+
+	itemMarshalled[records.VersionKey] = records.Version(version + 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = records.CheckWritable(s.items[id], version)
+	if err != nil {
+		return 0, err
+	}
+
+	s.items[id] = itemMarshalled
+
+	return version + 1, nil
+}
+
+// Delete deletes a record, deleting a missing record is not an error.
+func (s *Store) Delete(_ context.Context, key dynamo.Key) error {
+	id, ok := storageID(key)
+	if !ok {
+		return lhttp.NewProblem(http.StatusInternalServerError, errMissingKey)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, id)
+
+	return nil
+}
+
+// item returns a copy of the record with the given key.
+func (s *Store) item(key dynamo.Key) (dynamo.Key, bool) {
+	id, ok := storageID(key)
+	if !ok {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return nil, false
+	}
+
+	return attr.CloneItem(item), true
+}
+
+// storageID returns the identifier a record is kept by, which is made of the partition and the sort key of the table.
+func storageID(item dynamo.Key) (string, bool) {
+	partitionName, sortName := dynamo.KeyNames("")
+
+	pk, ok := item[partitionName].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", false
+	}
+
+	id := pk.Value + "\x00"
+
+	if sk, ok := item[sortName].(*types.AttributeValueMemberS); ok {
+		id += sk.Value
+	}
+
+	return id, true
+}
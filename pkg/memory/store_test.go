@@ -0,0 +1,77 @@
+package memory_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/memory"
+	"github.com/abtercms/abtercms2/pkg/storetest"
+)
+
+func TestStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, memory.NewStore())
+}
+
+func TestStore_Update(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success only one of concurrent updates of a version wins", func(t *testing.T) {
+		t.Parallel()
+
+		type item struct {
+			dynamo.Keys
+			ID      string `dynamodbav:"pk"`
+			Version int64  `dynamodbav:"version"`
+		}
+
+		// stubs
+		ctx := context.Background()
+		itemStub := item{Keys: dynamo.Keys{SK: "ITEM#foo", GSI1PK: "", GSI1SK: ""}, ID: "foo", Version: 0}
+		writers := 20
+
+		// system under test
+		sut := memory.NewStore()
+		assert.NoError(t, sut.Create(ctx, itemStub))
+
+		// execute
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+			stale     int
+		)
+
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				_, err := sut.Update(ctx, itemStub, dynamo.InitialVersion)
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				if err == nil {
+					succeeded++
+				} else if lhttp.ToProblem(err).Status == http.StatusPreconditionFailed {
+					stale++
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// asserts
+		assert.Equal(t, 1, succeeded)
+		assert.Equal(t, writers-1, stale)
+	})
+}
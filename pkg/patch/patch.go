@@ -0,0 +1,331 @@
+// Package patch for parsing JSON Merge Patch and JSON Patch documents into storage agnostic operations
+package patch
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	// ContentTypeMergePatch is the media type of JSON Merge Patch documents.
+	// https://datatracker.ietf.org/doc/html/rfc7396
+	ContentTypeMergePatch = "application/merge-patch+json"
+	// ContentTypeJSONPatch is the media type of JSON Patch documents.
+	// https://datatracker.ietf.org/doc/html/rfc6902
+	ContentTypeJSONPatch = "application/json-patch+json"
+
+	// AppendSegment is the last segment of a path pointing past the end of a list.
+	AppendSegment = "-"
+
+	errUnsupportedMediaType = "unsupported patch media type: %s"
+	errParsingDocument      = "failed to parse patch document"
+	errMergePatchNotObject  = "merge patch document must be an object"
+	errEmptyPatch           = "patch document contains no changes"
+	errUnsupportedOperation = "unsupported patch operation: %s"
+	errInvalidPointer       = "invalid JSON pointer: %s"
+	errMissingValue         = "patch operation %s on %s requires a value"
+	errMarshallDocument     = "failed to marshal the patched document"
+
+	reasonNothingToRemove = "no value to remove at this path"
+)
+
+// Op is a kind of change to be applied to a document.
+type Op string
+
+const (
+	// OpSet sets the value at the path, replacing any existing value.
+	OpSet Op = "set"
+	// OpReplace sets the value at the path, which must already exist.
+	OpReplace Op = "replace"
+	// OpRemove removes the value at the path, which must already exist.
+	OpRemove Op = "remove"
+	// OpUnset removes the value at the path if there is one, like members set to null by JSON Merge Patch.
+	OpUnset Op = "unset"
+	// OpAppend appends the value to the list at the path.
+	OpAppend Op = "append"
+	// OpTest requires the value at the path to be equal to the value.
+	OpTest Op = "test"
+)
+
+// Path addresses a value in a document, numeric segments address list elements.
+type Path []string
+
+// Field returns the top-level field the path points into.
+func (p Path) Field() string {
+	if len(p) == 0 {
+		return ""
+	}
+
+	return p[0]
+}
+
+// String returns the path as a JSON pointer.
+func (p Path) String() string {
+	var sb strings.Builder
+
+	for _, segment := range p {
+		sb.WriteString("/")
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(segment))
+	}
+
+	return sb.String()
+}
+
+// Index returns the list index addressed by the segment or false if it is not a list index.
+func Index(segment string) (int, bool) {
+	index, err := strconv.Atoi(segment)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+
+	return index, true
+}
+
+// Operation is a single change to be applied to a document.
+type Operation struct {
+	Op    Op
+	Path  Path
+	Value interface{}
+}
+
+// Parse parses a patch document based on its media type.
+func Parse(contentType string, body []byte) ([]Operation, error) {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+
+	switch strings.ToLower(mediaType) {
+	case ContentTypeMergePatch:
+		return MergePatch(body)
+	case ContentTypeJSONPatch:
+		return JSONPatch(body)
+	}
+
+	return nil, lhttp.NewProblem(http.StatusUnsupportedMediaType, errUnsupportedMediaType, contentType)
+}
+
+// MergePatch parses a JSON Merge Patch document.
+// Members set to null are removed, nested objects are merged member by member.
+func MergePatch(body []byte) ([]Operation, error) {
+	var doc interface{}
+
+	err := json.Unmarshal(body, &doc)
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusBadRequest, errParsingDocument)
+	}
+
+	members, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errMergePatchNotObject)
+	}
+
+	ops := mergeOperations(nil, members)
+	if len(ops) == 0 {
+		return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errEmptyPatch)
+	}
+
+	return ops, nil
+}
+
+func mergeOperations(parent Path, members map[string]interface{}) []Operation {
+	ops := make([]Operation, 0, len(members))
+
+	for name, value := range members {
+		path := append(append(Path{}, parent...), name)
+
+		switch v := value.(type) {
+		case nil:
+			ops = append(ops, Operation{Op: OpUnset, Path: path, Value: nil})
+		case map[string]interface{}:
+			ops = append(ops, mergeOperations(path, v)...)
+		default:
+			ops = append(ops, Operation{Op: OpSet, Path: path, Value: v})
+		}
+	}
+
+	return ops
+}
+
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// JSONPatch parses a JSON Patch document.
+// Operations which cannot be applied without reading the document first (move, copy, insert into a list) are rejected.
+func JSONPatch(body []byte) ([]Operation, error) {
+	var doc []jsonPatchOperation
+
+	err := json.Unmarshal(body, &doc)
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusBadRequest, errParsingDocument)
+	}
+
+	if len(doc) == 0 {
+		return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errEmptyPatch)
+	}
+
+	ops := make([]Operation, 0, len(doc))
+
+	for _, o := range doc {
+		op, err := o.toOperation()
+		if err != nil {
+			return nil, err
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+func (o jsonPatchOperation) toOperation() (Operation, error) {
+	path, err := ParsePointer(o.Path)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	if o.Op == "remove" {
+		return Operation{Op: OpRemove, Path: path, Value: nil}, nil
+	}
+
+	value, err := o.value(path)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	switch o.Op {
+	case "add":
+		return addOperation(path, value)
+	case "replace":
+		return Operation{Op: OpReplace, Path: path, Value: value}, nil
+	case "test":
+		return Operation{Op: OpTest, Path: path, Value: value}, nil
+	}
+
+	return Operation{}, lhttp.NewProblem(http.StatusUnprocessableEntity, errUnsupportedOperation, o.Op)
+}
+
+func (o jsonPatchOperation) value(path Path) (interface{}, error) {
+	if o.Value == nil {
+		return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errMissingValue, o.Op, path.String())
+	}
+
+	var value interface{}
+
+	err := json.Unmarshal(*o.Value, &value)
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusBadRequest, errParsingDocument)
+	}
+
+	return value, nil
+}
+
+func addOperation(path Path, value interface{}) (Operation, error) {
+	last := path[len(path)-1]
+
+	if last == AppendSegment {
+		return Operation{Op: OpAppend, Path: path[:len(path)-1], Value: value}, nil
+	}
+
+	// inserting in the middle of a list would need the list to be read first
+	if _, ok := Index(last); ok {
+		return Operation{}, lhttp.NewProblem(http.StatusUnprocessableEntity, errUnsupportedOperation, "add into list at index")
+	}
+
+	return Operation{Op: OpSet, Path: path, Value: value}, nil
+}
+
+// HasRemovals tells whether any of the operations removes a value which must exist, see CheckRemovals.
+func HasRemovals(ops []Operation) bool {
+	for _, op := range ops {
+		if op.Op == OpRemove {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckRemovals makes sure the values removed by the operations exist in the JSON representation of a document.
+// Removing a missing value fails the whole patch as per RFC 6902 section 4.2, every missing value is reported in a
+// single 422 problem named after its JSON pointer. Values unset by JSON Merge Patch need not exist.
+func CheckRemovals(document interface{}, ops []Operation) error {
+	var (
+		doc    interface{}
+		params []lhttp.InvalidParam
+	)
+
+	raw, err := json.Marshal(document)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallDocument)
+	}
+
+	err = json.Unmarshal(raw, &doc)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallDocument)
+	}
+
+	for _, op := range ops {
+		if op.Op == OpRemove && !exists(doc, op.Path) {
+			params = append(params, lhttp.InvalidParam{Name: op.Path.String(), Reason: reasonNothingToRemove})
+		}
+	}
+
+	if len(params) > 0 {
+		return lhttp.NewInvalidParamsProblem(params)
+	}
+
+	return nil
+}
+
+// exists tells whether a path points to a value of a decoded JSON document.
+func exists(doc interface{}, path Path) bool {
+	current := doc
+
+	for _, segment := range path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return false
+			}
+
+			current = next
+		case []interface{}:
+			index, ok := Index(segment)
+			if !ok || index >= len(v) {
+				return false
+			}
+
+			current = v[index]
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParsePointer parses a JSON pointer into a path.
+// https://datatracker.ietf.org/doc/html/rfc6901
+func ParsePointer(pointer string) (Path, error) {
+	if !strings.HasPrefix(pointer, "/") || len(pointer) < 2 {
+		return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errInvalidPointer, pointer)
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+
+	segments := strings.Split(pointer[1:], "/")
+	path := make(Path, 0, len(segments))
+
+	for _, segment := range segments {
+		path = append(path, unescape.Replace(segment))
+	}
+
+	return path, nil
+}
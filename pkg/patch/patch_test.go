@@ -0,0 +1,235 @@
+package patch_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		contentType string
+		body        string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       []patch.Operation
+		wantStatus int
+	}{
+		{
+			name: "merge patch",
+			args: args{
+				contentType: "application/merge-patch+json",
+				body:        `{"name":"foo"}`,
+			},
+			want: []patch.Operation{
+				{Op: patch.OpSet, Path: patch.Path{"name"}, Value: "foo"},
+			},
+		},
+		{
+			name: "merge patch with charset",
+			args: args{
+				contentType: "application/merge-patch+json; charset=UTF-8",
+				body:        `{"name":null}`,
+			},
+			want: []patch.Operation{
+				{Op: patch.OpUnset, Path: patch.Path{"name"}},
+			},
+		},
+		{
+			name: "merge patch nested object",
+			args: args{
+				contentType: "application/merge-patch+json",
+				body:        `{"meta":{"title":"foo"}}`,
+			},
+			want: []patch.Operation{
+				{Op: patch.OpSet, Path: patch.Path{"meta", "title"}, Value: "foo"},
+			},
+		},
+		{
+			name: "json patch",
+			args: args{
+				contentType: "application/json-patch+json",
+				body: `[
+					{"op":"test","path":"/name","value":"foo"},
+					{"op":"replace","path":"/name","value":"bar"},
+					{"op":"add","path":"/tags/-","value":"baz"},
+					{"op":"remove","path":"/tags/0"},
+					{"op":"add","path":"/a~1b","value":1}
+				]`,
+			},
+			want: []patch.Operation{
+				{Op: patch.OpTest, Path: patch.Path{"name"}, Value: "foo"},
+				{Op: patch.OpReplace, Path: patch.Path{"name"}, Value: "bar"},
+				{Op: patch.OpAppend, Path: patch.Path{"tags"}, Value: "baz"},
+				{Op: patch.OpRemove, Path: patch.Path{"tags", "0"}},
+				{Op: patch.OpSet, Path: patch.Path{"a/b"}, Value: float64(1)},
+			},
+		},
+		{
+			name: "unsupported media type",
+			args: args{
+				contentType: "application/json",
+				body:        `{"name":"foo"}`,
+			},
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "invalid json",
+			args: args{
+				contentType: "application/merge-patch+json",
+				body:        `{"name":`,
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "merge patch not an object",
+			args: args{
+				contentType: "application/merge-patch+json",
+				body:        `["foo"]`,
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "empty merge patch",
+			args: args{
+				contentType: "application/merge-patch+json",
+				body:        `{}`,
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "json patch move is not supported",
+			args: args{
+				contentType: "application/json-patch+json",
+				body:        `[{"op":"move","from":"/name","path":"/title"}]`,
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "json patch insert into list is not supported",
+			args: args{
+				contentType: "application/json-patch+json",
+				body:        `[{"op":"add","path":"/tags/1","value":"foo"}]`,
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "json patch missing value",
+			args: args{
+				contentType: "application/json-patch+json",
+				body:        `[{"op":"replace","path":"/name"}]`,
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "json patch invalid pointer",
+			args: args{
+				contentType: "application/json-patch+json",
+				body:        `[{"op":"remove","path":"name"}]`,
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// execute
+			got, err := patch.Parse(tt.args.contentType, []byte(tt.args.body))
+
+			// asserts
+			if tt.wantStatus != 0 {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantStatus, lhttp.ToProblem(err).Status)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckRemovals(t *testing.T) {
+	t.Parallel()
+
+	type document struct {
+		Name string            `json:"name"`
+		Tags []string          `json:"tags"`
+		Meta map[string]string `json:"meta"`
+	}
+
+	documentStub := document{Name: "foo", Tags: []string{"bar"}, Meta: map[string]string{"title": "baz"}}
+
+	tests := []struct {
+		name       string
+		ops        []patch.Operation
+		wantParams []lhttp.InvalidParam
+	}{
+		{
+			name: "success existing values",
+			ops: []patch.Operation{
+				{Op: patch.OpRemove, Path: patch.Path{"name"}},
+				{Op: patch.OpRemove, Path: patch.Path{"tags", "0"}},
+				{Op: patch.OpRemove, Path: patch.Path{"meta", "title"}},
+			},
+		},
+		{
+			name: "success unsetting missing value",
+			ops: []patch.Operation{
+				{Op: patch.OpUnset, Path: patch.Path{"meta", "subtitle"}},
+			},
+		},
+		{
+			name: "fail removing missing values causes 422 unprocessable entity",
+			ops: []patch.Operation{
+				{Op: patch.OpRemove, Path: patch.Path{"meta", "subtitle"}},
+				{Op: patch.OpRemove, Path: patch.Path{"tags", "1"}},
+				{Op: patch.OpRemove, Path: patch.Path{"name", "first"}},
+			},
+			wantParams: []lhttp.InvalidParam{
+				{Name: "/meta/subtitle", Reason: "no value to remove at this path"},
+				{Name: "/tags/1", Reason: "no value to remove at this path"},
+				{Name: "/name/first", Reason: "no value to remove at this path"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// execute
+			err := patch.CheckRemovals(documentStub, tt.ops)
+
+			// asserts
+			if tt.wantParams != nil {
+				require.Error(t, err)
+				assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+				assert.Equal(t, tt.wantParams, lhttp.ToProblem(err).InvalidParams)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPath_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/a~1b/c~0d/0", patch.Path{"a/b", "c~d", "0"}.String())
+	assert.Equal(t, "a/b", patch.Path{"a/b", "c"}.Field())
+}
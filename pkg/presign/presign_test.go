@@ -0,0 +1,117 @@
+package presign_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/presign"
+)
+
+func TestSigner_Verify(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	// query returns the query parameters of a signed URL.
+	query := func(t *testing.T, signed string) map[string]string {
+		t.Helper()
+
+		parsed, err := url.Parse(signed)
+		require.NoError(t, err)
+
+		params := map[string]string{}
+		for name := range parsed.Query() {
+			params[name] = parsed.Query().Get(name)
+		}
+
+		return params
+	}
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := presign.NewSigner([]byte("secret"), time.Hour)
+
+		// execute
+		signed, expiresAt := sut.Sign(http.MethodGet, "/websites/abc/media/def/content", now)
+		err := sut.Verify(http.MethodGet, "/websites/abc/media/def/content", query(t, signed), now.Add(time.Minute))
+
+		// asserts
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(signed, "/websites/abc/media/def/content?"))
+		assert.Equal(t, now.Add(time.Hour), expiresAt)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		query  func(map[string]string) map[string]string
+		now    time.Time
+	}{
+		{
+			name:   "fail missing signature causes 403 forbidden",
+			method: http.MethodGet,
+			path:   "/websites/abc/media/def/content",
+			query:  func(map[string]string) map[string]string { return nil },
+			now:    now,
+		},
+		{
+			name:   "fail other method causes 403 forbidden",
+			method: http.MethodPut,
+			path:   "/websites/abc/media/def/content",
+			query:  func(q map[string]string) map[string]string { return q },
+			now:    now,
+		},
+		{
+			name:   "fail other path causes 403 forbidden",
+			method: http.MethodGet,
+			path:   "/websites/abc/media/ghi/content",
+			query:  func(q map[string]string) map[string]string { return q },
+			now:    now,
+		},
+		{
+			name:   "fail extended expiry causes 403 forbidden",
+			method: http.MethodGet,
+			path:   "/websites/abc/media/def/content",
+			query: func(q map[string]string) map[string]string {
+				q[presign.ExpiresParam] = "4102444800"
+
+				return q
+			},
+			now: now,
+		},
+		{
+			name:   "fail expired signature causes 403 forbidden",
+			method: http.MethodGet,
+			path:   "/websites/abc/media/def/content",
+			query:  func(q map[string]string) map[string]string { return q },
+			now:    now.Add(time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// system under test
+			sut := presign.NewSigner([]byte("secret"), time.Hour)
+
+			// execute
+			signed, _ := sut.Sign(http.MethodGet, "/websites/abc/media/def/content", now)
+			err := sut.Verify(tt.method, tt.path, tt.query(query(t, signed)), tt.now)
+
+			// asserts
+			require.Error(t, err)
+			assert.Equal(t, http.StatusForbidden, lhttp.ToProblem(err).Status)
+		})
+	}
+}
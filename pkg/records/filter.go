@@ -0,0 +1,67 @@
+package records
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+// MatchesFilters tells whether a record satisfies every filter of a query.
+func MatchesFilters(item dynamo.Key, filters []dynamo.Filter) bool {
+	for _, f := range filters {
+		if !matchesFilter(item, f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchesFilter(item dynamo.Key, f dynamo.Filter) bool {
+	value, exists := lookup(item, f.Name)
+
+	switch f.Op {
+	case dynamo.FilterExists:
+		return exists
+	case dynamo.FilterNotExists:
+		return !exists
+	}
+
+	if !exists {
+		return false
+	}
+
+	switch f.Op {
+	case dynamo.FilterBeginsWith:
+		s, ok := value.(*types.AttributeValueMemberS)
+
+		return ok && strings.HasPrefix(s.Value, fmt.Sprint(f.Value))
+	case dynamo.FilterContains:
+		return attr.Contains(value, &types.AttributeValueMemberS{Value: fmt.Sprint(f.Value)})
+	}
+
+	expected, err := attributevalue.Marshal(f.Value)
+	if err != nil {
+		return false
+	}
+
+	switch f.Op {
+	case dynamo.FilterNotEqual:
+		return !attr.Equal(value, expected)
+	case dynamo.FilterLessThan:
+		c, ok := attr.Compare(value, expected)
+
+		return ok && c < 0
+	case dynamo.FilterGreaterThan:
+		c, ok := attr.Compare(value, expected)
+
+		return ok && c > 0
+	default:
+		return attr.Equal(value, expected)
+	}
+}
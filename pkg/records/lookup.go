@@ -0,0 +1,71 @@
+package records
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+// lookup returns the attribute at a document path of an item, e.g. "meta.title" or "tags[1]".
+func lookup(item dynamo.Key, name string) (types.AttributeValue, bool) {
+	var (
+		current types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+		ok      bool
+	)
+
+	for _, segment := range strings.Split(name, ".") {
+		field, indexes := splitIndexes(segment)
+
+		document, isDocument := current.(*types.AttributeValueMemberM)
+		if !isDocument {
+			return nil, false
+		}
+
+		current, ok = document.Value[field]
+		if !ok {
+			return nil, false
+		}
+
+		for _, index := range indexes {
+			list, isList := current.(*types.AttributeValueMemberL)
+			if !isList || index >= len(list.Value) {
+				return nil, false
+			}
+
+			current = list.Value[index]
+		}
+	}
+
+	return current, true
+}
+
+// splitIndexes splits a segment of a document path into the name of an attribute and the list indexes following it.
+func splitIndexes(segment string) (string, []int) {
+	start := strings.IndexByte(segment, '[')
+	if start < 0 {
+		return segment, nil
+	}
+
+	var indexes []int
+
+	for _, part := range strings.Split(segment[start+1:], "[") {
+		index, ok := parseIndex(strings.TrimSuffix(part, "]"))
+		if ok {
+			indexes = append(indexes, index)
+		}
+	}
+
+	return segment[:start], indexes
+}
+
+func parseIndex(s string) (int, bool) {
+	index, err := strconv.Atoi(s)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+
+	return index, true
+}
@@ -0,0 +1,270 @@
+package records
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+)
+
+const (
+	errPatchNotApplicable = "patch cannot be applied to the stored item"
+	errInvalidPatchPath   = "invalid patch path: %s"
+)
+
+// step is a segment of a path resolved to either the name of an attribute or a list index.
+type step struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// Patch applies the operations to a copy of the stored record like dynamo.Repo does in a single UpdateItem, a nil record
+// is a missing one. The conditions of the operations are checked against the stored record before any of them is
+// applied, the patch only applies if the record exists and is still at the given version. The patched record is returned
+// at the next version, the stored record is left untouched.
+func Patch(stored dynamo.Key, version int64, ops []patch.Operation) (dynamo.Key, error) {
+	paths := make([][]step, len(ops))
+	values := make([]types.AttributeValue, len(ops))
+
+	for i, op := range ops {
+		path, err := resolvePath(op.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := attributevalue.Marshal(op.Value)
+		if err != nil {
+			return nil, lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+		}
+
+		paths[i], values[i] = path, value
+	}
+
+	if overlap(ops, paths) {
+		return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errPatchNotApplicable)
+	}
+
+	err := CheckWritable(stored, version)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if !holds(stored, op.Op, paths[i], values[i]) {
+			return nil, lhttp.NewProblem(http.StatusConflict, errConditionFailed)
+		}
+	}
+
+	item := attr.CloneItem(stored)
+
+	for i, op := range ops {
+		if !apply(item, op.Op, paths[i], values[i]) {
+			return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errPatchNotApplicable)
+		}
+	}
+
+	item[VersionKey] = Version(version + 1)
+
+	return item, nil
+}
+
+// resolvePath converts a path into steps, rejecting the same segments dynamo.Repo rejects.
+func resolvePath(path patch.Path) ([]step, error) {
+	steps := make([]step, 0, len(path))
+
+	for i, segment := range path {
+		if index, ok := patch.Index(segment); ok && i > 0 {
+			steps = append(steps, step{name: "", index: index, isIndex: true})
+
+			continue
+		}
+
+		if segment == "" || strings.ContainsAny(segment, ".[]") || segment == patch.AppendSegment {
+			return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errInvalidPatchPath, path.String())
+		}
+
+		steps = append(steps, step{name: segment, index: 0, isIndex: false})
+	}
+
+	if len(steps) == 0 {
+		return nil, lhttp.NewProblem(http.StatusUnprocessableEntity, errInvalidPatchPath, path.String())
+	}
+
+	return steps, nil
+}
+
+// overlap tells whether two operations change the same value or one of them changes a value containing the other,
+// which DynamoDB rejects in a single update expression. Tests only read values, therefore they never overlap.
+func overlap(ops []patch.Operation, paths [][]step) bool {
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if ops[i].Op != patch.OpTest && ops[j].Op != patch.OpTest && isPrefix(paths[i], paths[j]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isPrefix tells whether the shorter of two paths is a prefix of the longer one.
+func isPrefix(a, b []step) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// holds tells whether the condition of an operation holds on the stored record.
+func holds(item dynamo.Key, op patch.Op, path []step, value types.AttributeValue) bool {
+	switch op {
+	case patch.OpReplace, patch.OpRemove:
+		_, ok := resolve(item, path)
+
+		return ok
+	case patch.OpTest:
+		current, ok := resolve(item, path)
+
+		return ok && attr.Equal(current, value)
+	default:
+		return true
+	}
+}
+
+// apply applies an operation to a record, it returns false if the path can not be changed like DynamoDB would.
+func apply(item dynamo.Key, op patch.Op, path []step, value types.AttributeValue) bool {
+	parent, ok := resolve(item, path[:len(path)-1])
+	if !ok {
+		return false
+	}
+
+	last := path[len(path)-1]
+
+	switch op {
+	case patch.OpSet, patch.OpReplace:
+		return setChild(parent, last, value)
+	case patch.OpRemove, patch.OpUnset:
+		return removeChild(parent, last)
+	case patch.OpAppend:
+		current, exists := child(parent, last)
+		if !exists {
+			return setChild(parent, last, &types.AttributeValueMemberL{Value: []types.AttributeValue{value}})
+		}
+
+		list, isList := current.(*types.AttributeValueMemberL)
+		if !isList {
+			return false
+		}
+
+		list.Value = append(list.Value, value)
+
+		return true
+	default:
+		return true
+	}
+}
+
+// resolve returns the attribute at a path, the empty path resolves to the record itself.
+func resolve(item dynamo.Key, path []step) (types.AttributeValue, bool) {
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+
+	for _, st := range path {
+		next, ok := child(current, st)
+		if !ok {
+			return nil, false
+		}
+
+		current = next
+	}
+
+	return current, true
+}
+
+func child(parent types.AttributeValue, st step) (types.AttributeValue, bool) {
+	switch p := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if st.isIndex {
+			return nil, false
+		}
+
+		value, ok := p.Value[st.name]
+
+		return value, ok
+	case *types.AttributeValueMemberL:
+		if !st.isIndex || st.index >= len(p.Value) {
+			return nil, false
+		}
+
+		return p.Value[st.index], true
+	default:
+		return nil, false
+	}
+}
+
+// setChild sets an attribute of a document or an element of a list, indexes past the end of a list append to it.
+func setChild(parent types.AttributeValue, st step, value types.AttributeValue) bool {
+	switch p := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if st.isIndex {
+			return false
+		}
+
+		p.Value[st.name] = value
+
+		return true
+	case *types.AttributeValueMemberL:
+		if !st.isIndex {
+			return false
+		}
+
+		if st.index >= len(p.Value) {
+			p.Value = append(p.Value, value)
+		} else {
+			p.Value[st.index] = value
+		}
+
+		return true
+	default:
+		return false
+	}
+}
+
+// removeChild removes an attribute of a document or an element of a list, removing a missing one changes nothing.
+func removeChild(parent types.AttributeValue, st step) bool {
+	switch p := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if st.isIndex {
+			return false
+		}
+
+		delete(p.Value, st.name)
+
+		return true
+	case *types.AttributeValueMemberL:
+		if !st.isIndex {
+			return false
+		}
+
+		if st.index < len(p.Value) {
+			p.Value = append(p.Value[:st.index], p.Value[st.index+1:]...)
+		}
+
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+// Package records for evaluating filters, patches and versions on the items of the single table the way dynamo.Repo
+// does, which lets stores other than DynamoDB share its semantics
+package records
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/attr"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	// VersionKey and DeletedAtKey are the attributes dynamo.Repo checks in its conditional writes.
+	VersionKey   = "version"
+	DeletedAtKey = "deleted_at"
+
+	errMarshallItem    = "failed to marshal item"
+	errVersionMismatch = "item version %d is stale"
+	errItemNotFound    = "item not found"
+	errConditionFailed = "item does not satisfy the conditions of the change"
+)
+
+// Version returns the attribute value of a version.
+func Version(version int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)}
+}
+
+// CheckWritable tells apart a missing or trashed record and a stale version, like dynamo.Repo does after a failed write.
+// A nil record is a missing one.
+func CheckWritable(stored dynamo.Key, version int64) error {
+	if stored == nil {
+		return lhttp.NewProblem(http.StatusNotFound, errItemNotFound)
+	}
+
+	if IsTrashed(stored) {
+		return lhttp.NewProblem(http.StatusNotFound, errItemNotFound)
+	}
+
+	if !attr.Equal(stored[VersionKey], Version(version)) {
+		return lhttp.NewProblem(http.StatusPreconditionFailed, errVersionMismatch, version)
+	}
+
+	return nil
+}
+
+// IsTrashed tells whether a record is in the trash.
+func IsTrashed(item dynamo.Key) bool {
+	_, ok := item[DeletedAtKey]
+
+	return ok
+}
+
+// NextKey returns the key to continue a query after a record, like the LastEvaluatedKey of DynamoDB.
+func NextKey(item dynamo.Key, index string) dynamo.Key {
+	partitionName, sortName := dynamo.KeyNames("")
+	tableKey := dynamo.K2(StringValue(item[partitionName]), StringValue(item[sortName]))
+
+	if index == "" {
+		return tableKey
+	}
+
+	indexPartitionName, indexSortName := dynamo.KeyNames(index)
+
+	return dynamo.IndexKey(tableKey, index, StringValue(item[indexPartitionName]), StringValue(item[indexSortName]))
+}
+
+// StringValue returns the value of a string attribute, keys of the single table are always strings.
+// Missing attributes and attributes of other types result in an empty string.
+func StringValue(value types.AttributeValue) string {
+	if s, ok := value.(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+
+	return ""
+}
+
+// Includes tells whether a stored record holds every attribute of another record with the same value.
+func Includes(stored, attributes dynamo.Key) bool {
+	for name, value := range attributes {
+		current, ok := stored[name]
+		if !ok || !attr.Equal(current, value) {
+			return false
+		}
+	}
+
+	return true
+}
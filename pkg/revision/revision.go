@@ -0,0 +1,191 @@
+// Package revision for the history of content entities, every saved version of an entity is kept as a revision
+package revision
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/id"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	// keyPrefix prefixes the sort key of revisions, followed by the sort key of their entity and the revision id.
+	keyPrefix = "REVISION#"
+
+	// DefaultLimit is the number of revisions kept per entity, older revisions are pruned when new ones are recorded.
+	DefaultLimit = 20
+
+	// batchSize is the number of revisions read at once when pruning and purging revisions.
+	batchSize int32 = 100
+
+	errMarshallEntity   = "failed to marshal entity"
+	errUnmarshallEntity = "failed to unmarshal revision: %s"
+	errRevisionNotFound = "revision not found in storage: %s"
+)
+
+// Revision is an immutable copy of an entity as it was saved at a version.
+// Revisions are stored next to their entity and ordered by their ULIDs, which makes the latest revision the last one.
+type Revision struct {
+	Partition string          `json:"-" dynamodbav:"pk"`
+	SK        string          `json:"-" dynamodbav:"sk"`
+	ID        string          `json:"id" dynamodbav:"revision_id"`
+	Version   int64           `json:"version" dynamodbav:"entity_version"`
+	SavedAt   time.Time       `json:"saved_at" dynamodbav:"saved_at"`
+	SavedBy   string          `json:"saved_by" dynamodbav:"saved_by"`
+	Data      json.RawMessage `json:"data" dynamodbav:"data"`
+}
+
+// Decode decodes the entity saved in a revision.
+func (r *Revision) Decode(entity interface{}) error {
+	err := json.Unmarshal(r.Data, entity)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallEntity, r.ID)
+	}
+
+	return nil
+}
+
+// Repo is the storage of revisions.
+type Repo interface {
+	dynamo.Store
+}
+
+// Store records and retrieves the revisions of entities, which are identified by their table key.
+type Store struct {
+	repo      Repo
+	revisions *dynamo.TypedRepo[Revision]
+	ids       *id.Generator
+	limit     int
+}
+
+// NewStore creates a new Store instance keeping at most limit revisions per entity.
+func NewStore(repo Repo, limit int) *Store {
+	return &Store{
+		repo:      repo,
+		revisions: dynamo.NewTypedRepo[Revision](repo),
+		ids:       id.NewGenerator(),
+		limit:     limit,
+	}
+}
+
+// prefix returns the prefix of the sort keys of the revisions of an entity.
+func prefix(sortKey string) string {
+	return keyPrefix + sortKey + "#"
+}
+
+// query returns the query listing the revisions of an entity, latest first.
+func query(partition, sortKey string, limit int32, exclusiveStartKey dynamo.Key) dynamo.Query {
+	return dynamo.Query{
+		Index:             "",
+		Partition:         partition,
+		SortKey:           dynamo.SortKeyBeginsWith(prefix(sortKey)),
+		Filters:           nil,
+		Limit:             limit,
+		ExclusiveStartKey: exclusiveStartKey,
+		Descending:        true,
+	}
+}
+
+// ListQuery returns the query List reads the revisions of an entity with, which cursors of the listing are bound to.
+func ListQuery(partition, sortKey string) dynamo.Query {
+	return query(partition, sortKey, 0, nil)
+}
+
+// Record stores the entity saved at version as its latest revision, then prunes revisions beyond the limit.
+// Revisions are recorded after their entity is saved, therefore a failure leaves the saved version without a revision.
+func (s *Store) Record(ctx context.Context, partition, sortKey string, version int64, actor string, entity interface{}) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallEntity)
+	}
+
+	revisionID := s.ids.NewString()
+
+	err = s.repo.Create(ctx, Revision{
+		Partition: partition,
+		SK:        prefix(sortKey) + revisionID,
+		ID:        revisionID,
+		Version:   version,
+		SavedAt:   time.Now().UTC(),
+		SavedBy:   actor,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.prune(ctx, partition, sortKey)
+}
+
+// prune deletes the revisions of an entity beyond the limit, oldest first.
+func (s *Store) prune(ctx context.Context, partition, sortKey string) error {
+	var revisions []Revision
+
+	_, err := s.repo.Query(ctx, query(partition, sortKey, int32(s.limit)+batchSize, nil), &revisions)
+	if err != nil {
+		return err
+	}
+
+	for i := s.limit; i < len(revisions); i++ {
+		err = s.repo.Delete(ctx, dynamo.K2(partition, revisions[i].SK))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List retrieves a page of the revisions of an entity, latest first.
+func (s *Store) List(ctx context.Context, partition, sortKey string, limit int32, exclusiveStartKey dynamo.Key) ([]Revision, dynamo.Page, error) {
+	revisions := []Revision{}
+
+	page, err := s.repo.Query(ctx, query(partition, sortKey, limit, exclusiveStartKey), &revisions)
+	if err != nil {
+		return nil, dynamo.Page{}, err
+	}
+
+	return revisions, page, nil
+}
+
+// Get retrieves a revision of an entity, missing revisions result in a 404 not found.
+func (s *Store) Get(ctx context.Context, partition, sortKey, revisionID string) (Revision, error) {
+	revision, err := s.revisions.Get(ctx, dynamo.K2(partition, prefix(sortKey)+revisionID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return revision, lhttp.WrapProblem(err, http.StatusNotFound, errRevisionNotFound, revisionID)
+	}
+
+	return revision, err
+}
+
+// Purge deletes all revisions of an entity, it is meant to be called once the entity is deleted.
+func (s *Store) Purge(ctx context.Context, partition, sortKey string) error {
+	var exclusiveStartKey dynamo.Key
+
+	for {
+		var revisions []Revision
+
+		page, err := s.repo.Query(ctx, query(partition, sortKey, batchSize, exclusiveStartKey), &revisions)
+		if err != nil {
+			return err
+		}
+
+		for _, revision := range revisions {
+			err = s.repo.Delete(ctx, dynamo.K2(partition, revision.SK))
+			if err != nil {
+				return err
+			}
+		}
+
+		if !page.HasMore {
+			return nil
+		}
+
+		exclusiveStartKey = page.Next
+	}
+}
@@ -0,0 +1,192 @@
+package revision_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/mocks"
+	"github.com/abtercms/abtercms2/pkg/revision"
+)
+
+func TestStore_Record(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail error in creating revision", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+
+		// mocks
+		repoMock := &mocks.Repo{}
+		repoMock.On("Create", ctx, mock.AnythingOfType("revision.Revision")).
+			Once().
+			Return(assert.AnError)
+
+		// system under test
+		sut := revision.NewStore(repoMock, 2)
+
+		// execute
+		err := sut.Record(ctx, "abc", "PAGE#def", 3, "alice", map[string]string{"title": "Blog"})
+
+		// asserts
+		assert.Error(t, err)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w pruning revisions beyond limit", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+
+		// mocks
+		revisionMatcher := mock.MatchedBy(func(input revision.Revision) bool {
+			return input.Partition == "abc" &&
+				input.SK == "REVISION#PAGE#def#"+input.ID &&
+				input.Version == 3 &&
+				input.SavedBy == "alice" &&
+				string(input.Data) == `{"title":"Blog"}`
+		})
+		revisionsModifier := mock.MatchedBy(func(input *[]revision.Revision) bool {
+			*input = []revision.Revision{
+				{SK: "REVISION#PAGE#def#3"},
+				{SK: "REVISION#PAGE#def#2"},
+				{SK: "REVISION#PAGE#def#1"},
+				{SK: "REVISION#PAGE#def#0"},
+			}
+
+			return true
+		})
+		queryMatcher := mock.MatchedBy(func(input dynamo.Query) bool {
+			return input.Partition == "abc" && input.SortKey.Value == "REVISION#PAGE#def#" && input.Descending
+		})
+		repoMock := &mocks.Repo{}
+		repoMock.On("Create", ctx, revisionMatcher).
+			Once().
+			Return(nil)
+		repoMock.On("Query", ctx, queryMatcher, revisionsModifier).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Delete", ctx, dynamo.K2("abc", "REVISION#PAGE#def#1")).
+			Once().
+			Return(nil)
+		repoMock.On("Delete", ctx, dynamo.K2("abc", "REVISION#PAGE#def#0")).
+			Once().
+			Return(nil)
+
+		// system under test
+		sut := revision.NewStore(repoMock, 2)
+
+		// execute
+		err := sut.Record(ctx, "abc", "PAGE#def", 3, "alice", map[string]string{"title": "Blog"})
+
+		// asserts
+		require.NoError(t, err)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestStore_Get(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail missing revision causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+
+		// mocks
+		repoMock := &mocks.Repo{}
+		repoMock.On("Find", ctx, dynamo.K2("abc", "REVISION#PAGE#def#ghi"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// system under test
+		sut := revision.NewStore(repoMock, revision.DefaultLimit)
+
+		// execute
+		_, err := sut.Get(ctx, "abc", "PAGE#def", "ghi")
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+
+		// mocks
+		revisionModifier := mock.MatchedBy(func(input *revision.Revision) bool {
+			*input = revision.Revision{ID: "ghi", Version: 2, Data: json.RawMessage(`{"title":"Blog"}`)}
+
+			return true
+		})
+		repoMock := &mocks.Repo{}
+		repoMock.On("Find", ctx, dynamo.K2("abc", "REVISION#PAGE#def#ghi"), revisionModifier).
+			Once().
+			Return(true, nil)
+
+		// system under test
+		sut := revision.NewStore(repoMock, revision.DefaultLimit)
+
+		// execute
+		got, err := sut.Get(ctx, "abc", "PAGE#def", "ghi")
+
+		// asserts
+		require.NoError(t, err)
+
+		var entity struct {
+			Title string `json:"title"`
+		}
+		require.NoError(t, got.Decode(&entity))
+		assert.Equal(t, "Blog", entity.Title)
+	})
+}
+
+func TestStore_Purge(t *testing.T) {
+	t.Parallel()
+
+	// stubs
+	ctx := context.Background()
+	nextStub := dynamo.K2("abc", "REVISION#PAGE#def#1")
+
+	// mocks
+	firstModifier := mock.MatchedBy(func(input *[]revision.Revision) bool {
+		*input = []revision.Revision{{SK: "REVISION#PAGE#def#1"}}
+
+		return true
+	})
+	repoMock := &mocks.Repo{}
+	// the next batch is expected first, as matching calls against the first batch would run its modifier
+	repoMock.On("Query", ctx, mock.MatchedBy(func(input dynamo.Query) bool { return input.ExclusiveStartKey != nil }), mock.Anything).
+		Once().
+		Return(dynamo.Page{}, nil)
+	repoMock.On("Query", ctx, mock.MatchedBy(func(input dynamo.Query) bool { return input.ExclusiveStartKey == nil }), firstModifier).
+		Once().
+		Return(dynamo.Page{Next: nextStub, HasMore: true}, nil)
+	repoMock.On("Delete", ctx, nextStub).
+		Once().
+		Return(nil)
+
+	// system under test
+	sut := revision.NewStore(repoMock, revision.DefaultLimit)
+
+	// execute
+	err := sut.Purge(ctx, "abc", "PAGE#def")
+
+	// asserts
+	require.NoError(t, err)
+	repoMock.AssertExpectations(t)
+}
@@ -0,0 +1,152 @@
+// Package site renders the published snapshots of pages as HTML, it is shared by everything serving websites.
+package site
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/osteele/liquid"
+	"github.com/russross/blackfriday/v2"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+const (
+	// templateType prefixes the sort key of templates, which are stored in the partition of their website.
+	templateType = "TEMPLATE#"
+
+	// blockType prefixes the sort key of blocks, which are identified by their name.
+	blockType = "BLOCK#"
+
+	errLayoutNotFound  = "layout of page %s not found in storage: %s"
+	errParsingLayout   = "failed to parse layout: %s"
+	errRenderingLayout = "failed to render layout: %s"
+)
+
+// Website holds the fields of the published snapshot of a website available to layouts.
+type Website struct {
+	ID   string `dynamodbav:"pk"`
+	Name string `dynamodbav:"name"`
+}
+
+// Page holds the fields of the published snapshot of a page needed to render it.
+type Page struct {
+	ID         string `dynamodbav:"page_id"`
+	ParentID   string `dynamodbav:"parent_id,omitempty"`
+	Path       string `dynamodbav:"path"`
+	Title      string `dynamodbav:"title"`
+	Body       string `dynamodbav:"body"`
+	TemplateID string `dynamodbav:"template_id,omitempty"`
+}
+
+// Template holds the Liquid source of a layout.
+type Template struct {
+	ID   string `dynamodbav:"template_id"`
+	Body string `dynamodbav:"body"`
+}
+
+// Block holds the Liquid source of a block included by layouts.
+type Block struct {
+	Name string `dynamodbav:"name"`
+	Body string `dynamodbav:"body"`
+}
+
+// TemplateKey returns the table key of a template.
+func TemplateKey(websiteID, templateID string) dynamo.Key {
+	return dynamo.K2(websiteID, templateType+templateID)
+}
+
+// BlockKey returns the table key of a block.
+func BlockKey(websiteID, name string) dynamo.Key {
+	return dynamo.K2(websiteID, blockType+name)
+}
+
+// Reader is the table layouts and blocks are read from.
+type Reader interface {
+	dynamo.Store
+}
+
+// Renderer renders pages into their layouts, loading layouts and blocks from the table.
+type Renderer struct {
+	templates *dynamo.TypedRepo[Template]
+	blocks    *dynamo.TypedRepo[Block]
+	engine    *liquid.Engine
+}
+
+func NewRenderer(reader Reader, engine *liquid.Engine) *Renderer {
+	return &Renderer{
+		templates: dynamo.NewTypedRepo[Template](reader),
+		blocks:    dynamo.NewTypedRepo[Block](reader),
+		engine:    engine,
+	}
+}
+
+// Render converts the Markdown body of a page to HTML and renders it into the layout of the page, if it has one.
+// Layouts can refer to the website, the page and the converted body as content, and include blocks of the website by name.
+// Pages are checked against their layout whenever they are saved, therefore a layout which does not exist any more
+// results in a 500 internal server error naming the layout instead of blaming the client.
+func (r *Renderer) Render(ctx context.Context, owner Website, entity Page) (string, error) {
+	content := string(blackfriday.Run([]byte(entity.Body)))
+
+	if entity.TemplateID == "" {
+		return content, nil
+	}
+
+	layout, err := r.templates.Get(ctx, TemplateKey(owner.ID, entity.TemplateID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return "", lhttp.WrapProblem(err, http.StatusInternalServerError, errLayoutNotFound, entity.ID, entity.TemplateID)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	// templates are parsed before they are stored, a syntax error is therefore a server error here
+	parsed, err := tmpl.Parse(r.engine, "body", layout.Body)
+	if err != nil {
+		return "", lhttp.WrapProblem(err, http.StatusInternalServerError, errParsingLayout, layout.ID)
+	}
+
+	bindings := map[string]interface{}{
+		"website": map[string]interface{}{
+			"id":   owner.ID,
+			"name": owner.Name,
+		},
+		"page": map[string]interface{}{
+			"id":        entity.ID,
+			"parent_id": entity.ParentID,
+			"path":      entity.Path,
+			"title":     entity.Title,
+			"content":   content,
+		},
+		"content": content,
+	}
+
+	blocks := tmpl.NewBlocks(ctx, r.engine, r.loadBlock(owner.ID))
+
+	html, renderErr := parsed.RenderString(blocks.Bind(bindings))
+	if renderErr != nil {
+		return "", lhttp.WrapProblem(renderErr, http.StatusInternalServerError, errRenderingLayout, layout.ID)
+	}
+
+	return html, nil
+}
+
+// loadBlock returns a loader reading the blocks of a website from the table.
+func (r *Renderer) loadBlock(websiteID string) tmpl.Loader {
+	return func(ctx context.Context, name string) (string, bool, error) {
+		entity, err := r.blocks.Get(ctx, BlockKey(websiteID, name))
+		if errors.Is(err, dynamo.ErrNotFound) {
+			return "", false, nil
+		}
+
+		if err != nil {
+			return "", false, err
+		}
+
+		return entity.Body, true, nil
+	}
+}
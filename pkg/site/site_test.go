@@ -0,0 +1,108 @@
+package site_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/mocks"
+	"github.com/abtercms/abtercms2/pkg/site"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+func TestRenderer_Render(t *testing.T) {
+	t.Parallel()
+
+	owner := site.Website{ID: "abc", Name: "Foo"}
+
+	t.Run("fail missing layout causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		pageStub := site.Page{ID: "def", ParentID: "", Path: "/blog", Title: "Posts", Body: "# Hello", TemplateID: "ghi"}
+
+		// system under test
+		sut, readerMock := createTestRenderer()
+
+		// mocks
+		readerMock.On("Find", ctx, site.TemplateKey("abc", "ghi"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		_, err := sut.Render(ctx, owner, pageStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+		assert.Contains(t, lhttp.ToProblem(err).Detail, "ghi")
+	})
+
+	t.Run("success w/o layout", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		pageStub := site.Page{ID: "def", ParentID: "", Path: "/", Title: "Home", Body: "Some *text*", TemplateID: ""}
+
+		// system under test
+		sut, readerMock := createTestRenderer()
+
+		// execute
+		got, err := sut.Render(ctx, owner, pageStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, "<p>Some <em>text</em></p>\n", got)
+		readerMock.AssertExpectations(t)
+	})
+
+	t.Run("success with layout and block", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		pageStub := site.Page{ID: "def", ParentID: "", Path: "/blog", Title: "Posts", Body: "Some *text*", TemplateID: "ghi"}
+
+		// system under test
+		sut, readerMock := createTestRenderer()
+
+		// mocks
+		readerMock.On("Find", ctx, site.TemplateKey("abc", "ghi"), mock.MatchedBy(func(input *site.Template) bool {
+			*input = site.Template{ID: "ghi", Body: `<title>{{ page.title }} - {{ website.name }}</title>{{ content }}{% block "footer" %}`}
+
+			return true
+		})).
+			Once().
+			Return(true, nil)
+		readerMock.On("Find", ctx, site.BlockKey("abc", "footer"), mock.MatchedBy(func(input *site.Block) bool {
+			*input = site.Block{Name: "footer", Body: "<footer>{{ page.path }}</footer>"}
+
+			return true
+		})).
+			Once().
+			Return(true, nil)
+
+		// execute
+		got, err := sut.Render(ctx, owner, pageStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, "<title>Posts - Foo</title><p>Some <em>text</em></p>\n<footer>/blog</footer>", got)
+		readerMock.AssertExpectations(t)
+	})
+}
+
+func createTestRenderer() (*site.Renderer, *mocks.Reader) {
+	readerMock := &mocks.Reader{}
+
+	sut := site.NewRenderer(readerMock, tmpl.NewEngine())
+
+	return sut, readerMock
+}
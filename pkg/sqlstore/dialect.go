@@ -0,0 +1,67 @@
+package sqlstore
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect adapts the statements of Store to a database engine.
+type Dialect struct {
+	// keyType is the column type of keys, which must compare them by their bytes like DynamoDB compares strings.
+	keyType string
+	// documentType is the column type of the JSON documents of records.
+	documentType string
+	// numbered tells whether placeholders are numbered, e.g. $1, instead of being question marks.
+	numbered bool
+	// lockRow is appended to reads preceding a write of the same row in a transaction.
+	lockRow string
+	// lockMigrations is executed before reading the applied migrations, it keeps concurrent migrations apart.
+	lockMigrations string
+}
+
+var (
+	// SQLite stores records in SQLite 3.24 or later.
+	// Transactions should take the write lock when they begin, e.g. via the _txlock=immediate option of
+	// github.com/mattn/go-sqlite3, otherwise concurrent writes of a record fail instead of waiting for each other.
+	SQLite = Dialect{
+		keyType:        "TEXT",
+		documentType:   "TEXT",
+		numbered:       false,
+		lockRow:        "",
+		lockMigrations: "",
+	}
+
+	// Postgres stores records in PostgreSQL 9.5 or later, documents are kept as JSONB.
+	Postgres = Dialect{
+		keyType:        `TEXT COLLATE "C"`,
+		documentType:   "JSONB",
+		numbered:       true,
+		lockRow:        " FOR UPDATE",
+		lockMigrations: "LOCK TABLE " + migrationsTable + " IN EXCLUSIVE MODE",
+	}
+)
+
+// bind replaces the question marks of a statement with the placeholders of the dialect.
+func (d Dialect) bind(statement string) string {
+	if !d.numbered {
+		return statement
+	}
+
+	var sb strings.Builder
+
+	n := 0
+
+	for _, r := range statement {
+		if r != '?' {
+			sb.WriteRune(r)
+
+			continue
+		}
+
+		n++
+
+		sb.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return sb.String()
+}
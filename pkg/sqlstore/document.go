@@ -0,0 +1,174 @@
+package sqlstore
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+var errUnsupportedValue = errors.New("unsupported attribute value")
+
+// value is an attribute value in the JSON format of the DynamoDB API, e.g. {"N":"1.5"}.
+// Unlike plain JSON it keeps numbers exact and tells apart sets and lists, therefore records are read back unchanged.
+// Pointers keep empty lists, documents and binaries apart from missing ones.
+type value struct {
+	S    *string           `json:"S,omitempty"`
+	N    *string           `json:"N,omitempty"`
+	B    *[]byte           `json:"B,omitempty"`
+	BOOL *bool             `json:"BOOL,omitempty"`
+	NULL *bool             `json:"NULL,omitempty"`
+	L    *[]value          `json:"L,omitempty"`
+	M    *map[string]value `json:"M,omitempty"`
+	SS   *[]string         `json:"SS,omitempty"`
+	NS   *[]string         `json:"NS,omitempty"`
+	BS   *[][]byte         `json:"BS,omitempty"`
+}
+
+// encodeDocument encodes a record into the JSON document stored for it.
+func encodeDocument(item dynamo.Key) ([]byte, error) {
+	document, err := encodeMap(item)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(document)
+}
+
+// decodeDocument decodes the JSON document stored for a record.
+func decodeDocument(data []byte) (dynamo.Key, error) {
+	var document map[string]value
+
+	err := json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMap(document)
+}
+
+func encodeMap(item map[string]types.AttributeValue) (map[string]value, error) {
+	document := make(map[string]value, len(item))
+
+	for name, attribute := range item {
+		v, err := encodeValue(attribute)
+		if err != nil {
+			return nil, err
+		}
+
+		document[name] = v
+	}
+
+	return document, nil
+}
+
+func encodeValue(attribute types.AttributeValue) (value, error) {
+	var v value
+
+	switch a := attribute.(type) {
+	case *types.AttributeValueMemberS:
+		v.S = &a.Value
+	case *types.AttributeValueMemberN:
+		v.N = &a.Value
+	case *types.AttributeValueMemberB:
+		v.B = &a.Value
+	case *types.AttributeValueMemberBOOL:
+		v.BOOL = &a.Value
+	case *types.AttributeValueMemberNULL:
+		v.NULL = &a.Value
+	case *types.AttributeValueMemberSS:
+		v.SS = &a.Value
+	case *types.AttributeValueMemberNS:
+		v.NS = &a.Value
+	case *types.AttributeValueMemberBS:
+		v.BS = &a.Value
+	case *types.AttributeValueMemberL:
+		list := make([]value, 0, len(a.Value))
+
+		for _, element := range a.Value {
+			e, err := encodeValue(element)
+			if err != nil {
+				return v, err
+			}
+
+			list = append(list, e)
+		}
+
+		v.L = &list
+	case *types.AttributeValueMemberM:
+		document, err := encodeMap(a.Value)
+		if err != nil {
+			return v, err
+		}
+
+		v.M = &document
+	default:
+		return v, errUnsupportedValue
+	}
+
+	return v, nil
+}
+
+func decodeMap(document map[string]value) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(document))
+
+	for name, v := range document {
+		attribute, err := decodeValue(v)
+		if err != nil {
+			return nil, err
+		}
+
+		item[name] = attribute
+	}
+
+	return item, nil
+}
+
+func decodeValue(v value) (types.AttributeValue, error) {
+	switch {
+	case v.S != nil:
+		return &types.AttributeValueMemberS{Value: *v.S}, nil
+	case v.N != nil:
+		return &types.AttributeValueMemberN{Value: *v.N}, nil
+	case v.B != nil:
+		return &types.AttributeValueMemberB{Value: *v.B}, nil
+	case v.BOOL != nil:
+		return &types.AttributeValueMemberBOOL{Value: *v.BOOL}, nil
+	case v.NULL != nil:
+		return &types.AttributeValueMemberNULL{Value: *v.NULL}, nil
+	case v.SS != nil:
+		return &types.AttributeValueMemberSS{Value: *v.SS}, nil
+	case v.NS != nil:
+		return &types.AttributeValueMemberNS{Value: *v.NS}, nil
+	case v.BS != nil:
+		return &types.AttributeValueMemberBS{Value: *v.BS}, nil
+	case v.L != nil:
+		return decodeList(*v.L)
+	case v.M != nil:
+		document, err := decodeMap(*v.M)
+		if err != nil {
+			return nil, err
+		}
+
+		return &types.AttributeValueMemberM{Value: document}, nil
+	default:
+		return nil, errUnsupportedValue
+	}
+}
+
+func decodeList(list []value) (types.AttributeValue, error) {
+	elements := make([]types.AttributeValue, 0, len(list))
+
+	for _, e := range list {
+		element, err := decodeValue(e)
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, element)
+	}
+
+	return &types.AttributeValueMemberL{Value: elements}, nil
+}
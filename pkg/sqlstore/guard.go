@@ -0,0 +1,261 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/records"
+)
+
+const (
+	errGuardTaken     = "unique value already taken"
+	errGuardReleased  = "required record changed in the meantime"
+	errMarshallGuards = "failed to marshal guard"
+	errPuttingItem    = "failed to put item"
+	errTrashingItem   = "failed to trash item"
+	errRestoringItem  = "failed to restore item"
+	errItemNotFound   = "item not found"
+	errItemNotTrashed = "item not found in trash"
+	errMissingVersion = "item has no version"
+)
+
+// CreateGuarded creates a new record along with the guards reserving its unique values.
+// A taken unique value results in a 409 conflict, like an existing record.
+func (s *Store) CreateGuarded(ctx context.Context, item interface{}, guards dynamo.Guards) error {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusBadRequest, errCreatingItem)
+	}
+
+	itemMarshalled[records.VersionKey] = records.Version(dynamo.InitialVersion)
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		inserted, err := s.insert(ctx, tx, itemMarshalled)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errCreatingItem)
+		}
+
+		if !inserted {
+			return lhttp.NewProblem(http.StatusConflict, errItemExists)
+		}
+
+		return s.writeGuards(ctx, tx, guards, errCreatingItem)
+	})
+}
+
+// PutGuarded writes a record whether it exists or not, along with adding and removing guards of its unique values.
+// It suits records without versions, such as snapshots replaced as a whole.
+func (s *Store) PutGuarded(ctx context.Context, item interface{}, guards dynamo.Guards) error {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		err := s.save(ctx, tx, itemMarshalled)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errPuttingItem)
+		}
+
+		return s.writeGuards(ctx, tx, guards, errPuttingItem)
+	})
+}
+
+// UpdateGuarded updates an existing record like Update, while adding and removing guards of its unique values.
+func (s *Store) UpdateGuarded(ctx context.Context, item interface{}, version int64, guards dynamo.Guards) (int64, error) {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	itemMarshalled[records.VersionKey] = records.Version(version + 1)
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		stored, err := s.load(ctx, tx, itemMarshalled, true)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItem)
+		}
+
+		err = records.CheckWritable(stored, version)
+		if err != nil {
+			return err
+		}
+
+		err = s.save(ctx, tx, itemMarshalled)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errUpdatingItem)
+		}
+
+		return s.writeGuards(ctx, tx, guards, errUpdatingItem)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version + 1, nil
+}
+
+// DeleteGuarded deletes an existing record along with the guards of its unique values.
+func (s *Store) DeleteGuarded(ctx context.Context, key dynamo.Key, guards []dynamo.Key) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, k := range append([]dynamo.Key{key}, guards...) {
+			err := s.remove(ctx, tx, k)
+			if err != nil {
+				return lhttp.WrapProblem(err, http.StatusInternalServerError, errDeletingItem)
+			}
+		}
+
+		return nil
+	})
+}
+
+// TrashGuarded marks an existing record as deleted like dynamo.Repo.Trash, while deleting the guards of its unique values.
+// The record is moved to the given partition of the first index. If expiresAt is not zero, the first PurgeExpired after
+// it deletes the record.
+func (s *Store) TrashGuarded(ctx context.Context, key dynamo.Key, index dynamo.Keys, deletedAt, expiresAt time.Time, guards []dynamo.Key) error {
+	deletedAtMarshalled, err := attributevalue.Marshal(deletedAt.UTC())
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errTrashingItem)
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		item, err := s.load(ctx, tx, key, true)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItem)
+		}
+
+		if item == nil || records.IsTrashed(item) {
+			return lhttp.NewProblem(http.StatusNotFound, errItemNotFound)
+		}
+
+		item[records.DeletedAtKey] = deletedAtMarshalled
+		if !expiresAt.IsZero() {
+			item[dynamo.ExpiresAtKey] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)}
+		}
+
+		err = s.move(ctx, tx, item, index)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errTrashingItem)
+		}
+
+		return s.writeGuards(ctx, tx, dynamo.Guards{Add: nil, Remove: guards, Keep: nil}, errTrashingItem)
+	})
+}
+
+// RestoreGuarded brings back a trashed record like dynamo.Repo.Restore, while taking back the guards of its unique values.
+// Unique values taken by other records in the meantime result in a 409 conflict, the record is kept in the trash then.
+func (s *Store) RestoreGuarded(ctx context.Context, key dynamo.Key, index dynamo.Keys, guards []interface{}, result interface{}) error {
+	var item dynamo.Key
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		item, err = s.load(ctx, tx, key, true)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItem)
+		}
+
+		if item == nil || !records.IsTrashed(item) {
+			return lhttp.NewProblem(http.StatusNotFound, errItemNotTrashed)
+		}
+
+		delete(item, records.DeletedAtKey)
+		delete(item, dynamo.ExpiresAtKey)
+
+		err = s.move(ctx, tx, item, index)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errRestoringItem)
+		}
+
+		return s.writeGuards(ctx, tx, dynamo.Guards{Add: guards, Remove: nil, Keep: nil}, errRestoringItem)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = attributevalue.UnmarshalMap(item, result)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	return nil
+}
+
+// move stores a record in the given partition of the first index at its next version.
+func (s *Store) move(ctx context.Context, tx *sql.Tx, item dynamo.Key, index dynamo.Keys) error {
+	n, ok := item[records.VersionKey].(*types.AttributeValueMemberN)
+	if !ok {
+		return lhttp.NewProblem(http.StatusInternalServerError, errMissingVersion)
+	}
+
+	version, err := strconv.ParseInt(n.Value, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	partitionName, sortName := dynamo.KeyNames(dynamo.GSI1)
+	item[partitionName] = &types.AttributeValueMemberS{Value: index.GSI1PK}
+	item[sortName] = &types.AttributeValueMemberS{Value: index.GSI1SK}
+	item[records.VersionKey] = records.Version(version + 1)
+
+	return s.save(ctx, tx, item)
+}
+
+// writeGuards creates and deletes guards in the transaction of the record owning them, and checks the guards kept.
+// A guard which is already taken, or a kept guard which changed, results in a 409 conflict, which rolls back the
+// transaction.
+func (s *Store) writeGuards(ctx context.Context, tx *sql.Tx, guards dynamo.Guards, errWriting string) error {
+	for _, guard := range guards.Add {
+		guardMarshalled, err := attributevalue.MarshalMap(guard)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallGuards)
+		}
+
+		inserted, err := s.insert(ctx, tx, guardMarshalled)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errWriting)
+		}
+
+		if !inserted {
+			return lhttp.NewProblem(http.StatusConflict, errGuardTaken)
+		}
+	}
+
+	for _, key := range guards.Remove {
+		err := s.remove(ctx, tx, key)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errWriting)
+		}
+	}
+
+	return s.checkGuards(ctx, tx, guards.Keep, errWriting)
+}
+
+// checkGuards checks that guards are stored as they are, locking them until the transaction ends.
+func (s *Store) checkGuards(ctx context.Context, tx *sql.Tx, guards []interface{}, errWriting string) error {
+	for _, guard := range guards {
+		guardMarshalled, err := attributevalue.MarshalMap(guard)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errMarshallGuards)
+		}
+
+		stored, err := s.load(ctx, tx, guardMarshalled, true)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errWriting)
+		}
+
+		if stored == nil || !records.Includes(stored, guardMarshalled) {
+			return lhttp.NewProblem(http.StatusConflict, errGuardReleased)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	itemsTable      = "items"
+	migrationsTable = "schema_migrations"
+
+	errMigrating = "failed to migrate schema to version %d"
+)
+
+// migrations build the statements changing the schema, migration n brings it to version n+1.
+// They are applied in order and never changed once released, changes to the schema are added as new migrations.
+var migrations = []func(Dialect) string{
+	func(d Dialect) string {
+		return fmt.Sprintf(`CREATE TABLE %s (
+	pk %s NOT NULL,
+	sk %s NOT NULL,
+	gsi1pk %s,
+	gsi1sk %s,
+	expires_at BIGINT,
+	document %s NOT NULL,
+	PRIMARY KEY (pk, sk)
+)`, itemsTable, d.keyType, d.keyType, d.keyType, d.keyType, d.documentType)
+	},
+	func(Dialect) string {
+		return fmt.Sprintf("CREATE INDEX %s_gsi1 ON %s (gsi1pk, gsi1sk, pk, sk)", itemsTable, itemsTable)
+	},
+	func(Dialect) string {
+		return fmt.Sprintf("CREATE INDEX %s_expires_at ON %s (expires_at)", itemsTable, itemsTable)
+	},
+}
+
+// Migrate brings the schema to the latest version, applying the migrations it is missing in a single transaction.
+// It is safe to call on every start, a schema already at the latest version is left untouched.
+func (s *Store) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY)", migrationsTable,
+	))
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errMigrating, 0)
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if s.dialect.lockMigrations != "" {
+			_, err := tx.ExecContext(ctx, s.dialect.lockMigrations)
+			if err != nil {
+				return lhttp.WrapProblem(err, http.StatusInternalServerError, errMigrating, 0)
+			}
+		}
+
+		var version int
+
+		err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", migrationsTable)).
+			Scan(&version)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errMigrating, version)
+		}
+
+		for ; version < len(migrations); version++ {
+			_, err = tx.ExecContext(ctx, migrations[version](s.dialect))
+			if err != nil {
+				return lhttp.WrapProblem(err, http.StatusInternalServerError, errMigrating, version+1)
+			}
+
+			_, err = tx.ExecContext(ctx, s.dialect.bind(fmt.Sprintf("INSERT INTO %s (version) VALUES (?)", migrationsTable)), version+1)
+			if err != nil {
+				return lhttp.WrapProblem(err, http.StatusInternalServerError, errMigrating, version+1)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,239 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/records"
+)
+
+const (
+	countColumns = "COUNT(*)"
+
+	errFetchingItems = "failed to fetch items"
+	errCountingItems = "failed to count items"
+	errUnknownIndex  = "unknown index: %s"
+)
+
+// Query lists records in a partition of the table or one of its indexes, ordered by their sort key.
+// Records sharing a sort key in an index are ordered by their table key, records without the keys of an index are not
+// part of it. Pages are read by keyset pagination, continuing after the key of the last row read. Filters are applied
+// before the page is cut, therefore a page is only short if it is the last one, and Next continues the query after its
+// last record.
+func (s *Store) Query(ctx context.Context, query dynamo.Query, result interface{}) (dynamo.Page, error) {
+	var (
+		page  = make([]dynamo.Key, 0)
+		start = query.ExclusiveStartKey
+		batch = int32(0)
+	)
+
+	// one more record than requested is read to tell whether more results follow
+	if query.Limit > 0 {
+		batch = query.Limit + 1
+	}
+
+	for {
+		items, err := s.read(ctx, query, start, batch)
+		if err != nil {
+			return dynamo.Page{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItems)
+		}
+
+		for _, item := range items {
+			if records.MatchesFilters(item, query.Filters) {
+				page = append(page, item)
+			}
+		}
+
+		// without a limit every row is read at once, a short batch is the end of the partition
+		if batch == 0 || int32(len(items)) < batch || int32(len(page)) > query.Limit {
+			break
+		}
+
+		start = records.NextKey(items[len(items)-1], query.Index)
+	}
+
+	var next dynamo.Key
+
+	if query.Limit > 0 && int32(len(page)) > query.Limit {
+		page = page[:query.Limit]
+		next = records.NextKey(page[len(page)-1], query.Index)
+	}
+
+	err := attributevalue.UnmarshalListOfMaps(page, result)
+	if err != nil {
+		return dynamo.Page{}, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItems)
+	}
+
+	return dynamo.Page{Next: next, HasMore: next != nil}, nil
+}
+
+// Count counts the records matching a query, the count is always exact.
+// Queries without filters are counted by the database, others need their records to be read.
+func (s *Store) Count(ctx context.Context, query dynamo.Query) (int32, bool, error) {
+	if len(query.Filters) == 0 {
+		statement, args, err := s.selectStatement(countColumns, query, nil, 0)
+		if err != nil {
+			return 0, false, lhttp.WrapProblem(err, http.StatusInternalServerError, errCountingItems)
+		}
+
+		var count int32
+
+		err = s.db.QueryRowContext(ctx, statement, args...).Scan(&count)
+		if err != nil {
+			return 0, false, lhttp.WrapProblem(err, http.StatusInternalServerError, errCountingItems)
+		}
+
+		return count, true, nil
+	}
+
+	items, err := s.read(ctx, query, query.ExclusiveStartKey, 0)
+	if err != nil {
+		return 0, false, lhttp.WrapProblem(err, http.StatusInternalServerError, errCountingItems)
+	}
+
+	var count int32
+
+	for _, item := range items {
+		if records.MatchesFilters(item, query.Filters) {
+			count++
+		}
+	}
+
+	return count, true, nil
+}
+
+// read reads at most limit records matching the key condition of a query following the start key, in order.
+// A limit of zero reads every record.
+func (s *Store) read(ctx context.Context, query dynamo.Query, start dynamo.Key, limit int32) ([]dynamo.Key, error) {
+	statement, args, err := s.selectStatement("document", query, start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []dynamo.Key
+
+	for rows.Next() {
+		var document []byte
+
+		err = rows.Scan(&document)
+		if err != nil {
+			return nil, err
+		}
+
+		item, err := decodeDocument(document)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// selectStatement builds the statement selecting columns of the rows matching the key condition of a query.
+// Rows are ordered by the sort key of the index queried and then by their table key, rows following the start key in
+// this order are selected only, which makes pages cheap to read however deep they are.
+func (s *Store) selectStatement(columns string, query dynamo.Query, start dynamo.Key, limit int32) (string, []interface{}, error) {
+	if query.Index != "" && query.Index != dynamo.GSI1 {
+		return "", nil, lhttp.NewProblem(http.StatusInternalServerError, errUnknownIndex, query.Index)
+	}
+
+	partitionName, sortName := dynamo.KeyNames(query.Index)
+	order := orderColumns(query.Index)
+
+	conditions := []string{partitionName + " = ?", sortName + " IS NOT NULL"}
+	args := []interface{}{query.Partition}
+
+	if query.SortKey != nil {
+		condition, values := sortKeyCondition(sortName, query.SortKey)
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if len(start) > 0 {
+		condition, values := keysetCondition(order, start, query.Descending)
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	statement := fmt.Sprintf("SELECT %s FROM %s WHERE %s", columns, itemsTable, strings.Join(conditions, " AND "))
+
+	if columns != countColumns {
+		statement += orderBy(order, query.Descending)
+	}
+
+	if limit > 0 {
+		statement += " LIMIT ?"
+
+		args = append(args, limit)
+	}
+
+	return s.dialect.bind(statement), args, nil
+}
+
+// orderColumns returns the columns rows of the table or one of its indexes are ordered by.
+func orderColumns(index string) []string {
+	partitionName, sortName := dynamo.KeyNames("")
+	if index == "" {
+		return []string{sortName}
+	}
+
+	_, indexSortName := dynamo.KeyNames(index)
+
+	return []string{indexSortName, partitionName, sortName}
+}
+
+// keysetCondition selects the rows following the row a start key was taken from in the order of a query.
+func keysetCondition(order []string, start dynamo.Key, descending bool) (string, []interface{}) {
+	op := ">"
+	if descending {
+		op = "<"
+	}
+
+	values := make([]interface{}, 0, len(order))
+	for _, name := range order {
+		values = append(values, records.StringValue(start[name]))
+	}
+
+	return fmt.Sprintf("(%s) %s (%s)", strings.Join(order, ", "), op, placeholders(len(order))), values
+}
+
+func orderBy(order []string, descending bool) string {
+	direction := " ASC"
+	if descending {
+		direction = " DESC"
+	}
+
+	return " ORDER BY " + strings.Join(order, direction+", ") + direction
+}
+
+func sortKeyCondition(column string, condition *dynamo.SortKeyCondition) (string, []interface{}) {
+	switch condition.Op {
+	case dynamo.KeyLessThan, dynamo.KeyLessThanEqual, dynamo.KeyGreaterThan, dynamo.KeyGreaterThanEqual:
+		return fmt.Sprintf("%s %s ?", column, condition.Op), []interface{}{condition.Value}
+	case dynamo.KeyBeginsWith:
+		return fmt.Sprintf("SUBSTR(%s, 1, ?) = ?", column), []interface{}{utf8.RuneCountInString(condition.Value), condition.Value}
+	case dynamo.KeyBetween:
+		return column + " BETWEEN ? AND ?", []interface{}{condition.Value, condition.Upper}
+	default:
+		return column + " = ?", []interface{}{condition.Value}
+	}
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
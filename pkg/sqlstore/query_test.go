@@ -0,0 +1,180 @@
+package sqlstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/sqlstore"
+)
+
+func TestStore_Query(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// items a to f share their index sort key in pairs, only b and e are named
+	createItems := func(t *testing.T) *sqlstore.Store {
+		t.Helper()
+
+		sut := createTestStore(t)
+
+		for i, id := range []string{"a", "b", "c", "d", "e", "f"} {
+			it := itemStub(id)
+			it.GSI1SK = string(rune('1' + i/2))
+
+			if id == "b" || id == "e" {
+				it.Name = "named"
+			}
+
+			require.NoError(t, sut.Create(ctx, it))
+		}
+
+		return sut
+	}
+
+	t.Run("success pages of an index continue among records sharing a sort key", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		query := dynamo.Query{Index: dynamo.GSI1, Partition: itemType, Limit: 3, Descending: true}
+
+		// system under test
+		sut := createItems(t)
+
+		// execute
+		var first, second []item
+
+		page, err := sut.Query(ctx, query, &first)
+		require.NoError(t, err)
+
+		query.ExclusiveStartKey = page.Next
+		last, err := sut.Query(ctx, query, &second)
+		require.NoError(t, err)
+
+		// asserts
+		assert.True(t, page.HasMore)
+		assert.Equal(t, []string{"f", "e", "d"}, idsOf(first))
+		assert.False(t, last.HasMore)
+		assert.Equal(t, []string{"c", "b", "a"}, idsOf(second))
+	})
+
+	t.Run("success filtered records are read until the page is full", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		query := dynamo.Query{
+			Index:     dynamo.GSI1,
+			Partition: itemType,
+			Filters:   []dynamo.Filter{{Op: dynamo.FilterExists, Name: "name", Value: nil}},
+			Limit:     1,
+		}
+
+		// system under test
+		sut := createItems(t)
+
+		// execute
+		var first, second []item
+
+		page, err := sut.Query(ctx, query, &first)
+		require.NoError(t, err)
+
+		query.ExclusiveStartKey = page.Next
+		last, err := sut.Query(ctx, query, &second)
+		require.NoError(t, err)
+
+		// asserts
+		assert.True(t, page.HasMore)
+		assert.Equal(t, []string{"b"}, idsOf(first))
+		assert.False(t, last.HasMore)
+		assert.Equal(t, []string{"e"}, idsOf(second))
+	})
+
+	t.Run("success count", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := createItems(t)
+
+		// execute
+		all, exact, err := sut.Count(ctx, dynamo.Query{Index: dynamo.GSI1, Partition: itemType, SortKey: &dynamo.SortKeyCondition{Op: dynamo.KeyGreaterThan, Value: "1"}})
+		require.NoError(t, err)
+
+		named, _, err := sut.Count(ctx, dynamo.Query{
+			Index:     dynamo.GSI1,
+			Partition: itemType,
+			Filters:   []dynamo.Filter{{Op: dynamo.FilterEqual, Name: "name", Value: "named"}},
+		})
+		require.NoError(t, err)
+
+		// asserts
+		assert.True(t, exact)
+		assert.Equal(t, int32(4), all)
+		assert.Equal(t, int32(2), named)
+	})
+}
+
+func TestStore_Find(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success documents keep the types of attributes", func(t *testing.T) {
+		t.Parallel()
+
+		type document struct {
+			ID      string                `dynamodbav:"pk"`
+			SK      string                `dynamodbav:"sk"`
+			Version int64                 `dynamodbav:"version"`
+			Price   attributevalue.Number `dynamodbav:"price"`
+			Tags    []string              `dynamodbav:"tags,stringset"`
+			Ranks   []int                 `dynamodbav:"ranks,numberset"`
+			Data    []byte                `dynamodbav:"data"`
+			Empty   []interface{}         `dynamodbav:"empty"`
+			Meta    map[string]string     `dynamodbav:"meta"`
+			Draft   bool                  `dynamodbav:"draft"`
+			Nothing *string               `dynamodbav:"nothing,nullempty"`
+		}
+
+		// stubs
+		ctx := context.Background()
+		documentStub := document{
+			ID:      "foo",
+			SK:      "DOCUMENT",
+			Version: dynamo.InitialVersion,
+			Price:   "12345678901234567890.5",
+			Tags:    []string{"a", "b"},
+			Ranks:   []int{1, 2},
+			Data:    []byte{0, 1, 2},
+			Empty:   []interface{}{},
+			Meta:    map[string]string{"title": "foo"},
+			Draft:   false,
+			Nothing: nil,
+		}
+
+		// system under test
+		sut := createTestStore(t)
+		require.NoError(t, sut.Create(ctx, documentStub))
+
+		// execute
+		var stored document
+
+		found, err := sut.Find(ctx, dynamo.K2("foo", "DOCUMENT"), &stored)
+
+		// asserts
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, documentStub, stored)
+	})
+}
+
+func idsOf(items []item) []string {
+	ids := make([]string, 0, len(items))
+	for _, it := range items {
+		ids = append(ids, it.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,332 @@
+// Package sqlstore for keeping the records of the single table in a SQL database, for deployments without DynamoDB
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/records"
+)
+
+const (
+	errMarshallItem    = "failed to marshal item"
+	errFetchingItem    = "failed to fetch item"
+	errCreatingItem    = "failed to create item"
+	errUpdatingItem    = "failed to update item"
+	errPatchingItem    = "failed to patch item"
+	errDeletingItem    = "failed to delete item"
+	errPurgingItems    = "failed to purge items"
+	errBeginningTx     = "failed to begin transaction"
+	errCommittingTx    = "failed to commit transaction"
+	errUnmarshallItem  = "failed to unmarshal item"
+	errUnmarshallItems = "failed to unmarshal items"
+	errMissingKey      = "item has no table key"
+	errItemExists      = "item already exists"
+)
+
+// Store keeps records in a SQL database, it implements dynamo.Store and the guarded writes of dynamo.Repo with the same
+// semantics. Each record is a row keyed like in the single table, holding the record as a JSON document, therefore
+// records are filtered and patched like in DynamoDB. Writes of several rows are applied in a transaction.
+// The schema is created and kept up to date by Migrate.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewStore creates a new Store instance keeping records in a database of the given dialect.
+func NewStore(db *sql.DB, dialect Dialect) *Store {
+	return &Store{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// Create creates a new record.
+// The record is stored with dynamo.InitialVersion regardless of the version it carries and never overwrites an existing one.
+func (s *Store) Create(ctx context.Context, item interface{}) error {
+	itemMarshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusBadRequest, errMarshallItem)
+	}
+
+	itemMarshalled[records.VersionKey] = records.Version(dynamo.InitialVersion)
+
+	inserted, err := s.insert(ctx, s.db, itemMarshalled)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errCreatingItem)
+	}
+
+	if !inserted {
+		return lhttp.NewProblem(http.StatusConflict, errItemExists)
+	}
+
+	return nil
+}
+
+// Find retrieves a record by key and tells whether it exists, missing records leave result untouched.
+func (s *Store) Find(ctx context.Context, key dynamo.Key, result interface{}) (bool, error) {
+	item, err := s.load(ctx, s.db, key, false)
+	if err != nil {
+		return false, lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItem)
+	}
+
+	if item == nil {
+		return false, nil
+	}
+
+	err = attributevalue.UnmarshalMap(item, result)
+	if err != nil {
+		return false, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	return true, nil
+}
+
+// Get retrieves a record by key, missing records leave result untouched.
+func (s *Store) Get(ctx context.Context, key dynamo.Key, result interface{}) error {
+	_, err := s.Find(ctx, key, result)
+
+	return err
+}
+
+// Update updates an existing record.
+// The write only succeeds if the record exists, is not trashed and is still at the given version, the new version is returned.
+func (s *Store) Update(ctx context.Context, item interface{}, version int64) (int64, error) {
+	return s.UpdateGuarded(ctx, item, version, dynamo.Guards{Add: nil, Remove: nil, Keep: nil})
+}
+
+// Patch applies the operations to an existing record like dynamo.Repo does in a single UpdateItem.
+// The write only succeeds if the record exists and is still at the given version, the updated record is stored in result
+// and the new version is returned.
+func (s *Store) Patch(ctx context.Context, key dynamo.Key, version int64, ops []patch.Operation, result interface{}) (int64, error) {
+	var item dynamo.Key
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		stored, err := s.load(ctx, tx, key, true)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingItem)
+		}
+
+		item, err = records.Patch(stored, version, ops)
+		if err != nil {
+			return err
+		}
+
+		err = s.save(ctx, tx, item)
+		if err != nil {
+			return lhttp.WrapProblem(err, http.StatusInternalServerError, errPatchingItem)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = attributevalue.UnmarshalMap(item, result)
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errUnmarshallItem)
+	}
+
+	return version + 1, nil
+}
+
+// Delete deletes a record, deleting a missing record is not an error.
+func (s *Store) Delete(ctx context.Context, key dynamo.Key) error {
+	return s.DeleteGuarded(ctx, key, nil)
+}
+
+// PurgeExpired deletes the records whose dynamo.ExpiresAtKey has passed and returns how many were deleted.
+// It takes the place of the TTL of DynamoDB, which purges trashed records, therefore the scheduler calls it on every sweep.
+func (s *Store) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.dialect.bind(fmt.Sprintf(
+		"DELETE FROM %s WHERE expires_at <= ?", itemsTable,
+	)), now.Unix())
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errPurgingItems)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, lhttp.WrapProblem(err, http.StatusInternalServerError, errPurgingItems)
+	}
+
+	return n, nil
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise.
+func (s *Store) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errBeginningTx)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errCommittingTx)
+	}
+
+	return nil
+}
+
+// load reads the record with the given key, a missing record results in a nil item.
+// If lock is true, the row is locked until the end of the transaction, which must then write it.
+func (s *Store) load(ctx context.Context, q querier, key dynamo.Key, lock bool) (dynamo.Key, error) {
+	pk, sk, err := tableKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := fmt.Sprintf("SELECT document FROM %s WHERE pk = ? AND sk = ?", itemsTable)
+	if lock {
+		statement += s.dialect.lockRow
+	}
+
+	var document []byte
+
+	err = q.QueryRowContext(ctx, s.dialect.bind(statement), pk, sk).Scan(&document)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeDocument(document)
+}
+
+// insert stores a new record, it returns false if a record with the same key exists.
+func (s *Store) insert(ctx context.Context, q querier, item dynamo.Key) (bool, error) {
+	args, err := rowOf(item)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := q.ExecContext(ctx, s.dialect.bind(fmt.Sprintf(
+		"INSERT INTO %s (pk, sk, gsi1pk, gsi1sk, expires_at, document) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (pk, sk) DO NOTHING",
+		itemsTable,
+	)), args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// save stores a record, replacing the record with the same key if there is one.
+func (s *Store) save(ctx context.Context, q querier, item dynamo.Key) error {
+	args, err := rowOf(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, s.dialect.bind(fmt.Sprintf(
+		"INSERT INTO %s (pk, sk, gsi1pk, gsi1sk, expires_at, document) VALUES (?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT (pk, sk) DO UPDATE SET gsi1pk = excluded.gsi1pk, gsi1sk = excluded.gsi1sk, "+
+			"expires_at = excluded.expires_at, document = excluded.document",
+		itemsTable,
+	)), args...)
+
+	return err
+}
+
+// remove deletes the record with the given key if there is one.
+func (s *Store) remove(ctx context.Context, q querier, key dynamo.Key) error {
+	pk, sk, err := tableKey(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, s.dialect.bind(fmt.Sprintf("DELETE FROM %s WHERE pk = ? AND sk = ?", itemsTable)), pk, sk)
+
+	return err
+}
+
+// rowOf returns the columns of the row of a record: its keys, its expiry and its document.
+// Index keys are only set if the record has them, records without them are not part of the index.
+func rowOf(item dynamo.Key) ([]interface{}, error) {
+	pk, sk, err := tableKey(item)
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := encodeDocument(item)
+	if err != nil {
+		return nil, err
+	}
+
+	partitionName, sortName := dynamo.KeyNames(dynamo.GSI1)
+
+	return []interface{}{
+		pk,
+		sk,
+		nullString(item[partitionName]),
+		nullString(item[sortName]),
+		expiresAt(item),
+		string(document),
+	}, nil
+}
+
+// tableKey returns the partition and sort key of a record, a missing sort key is stored as an empty one.
+func tableKey(item dynamo.Key) (string, string, error) {
+	partitionName, sortName := dynamo.KeyNames("")
+
+	pk, ok := item[partitionName].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", "", lhttp.NewProblem(http.StatusInternalServerError, errMissingKey)
+	}
+
+	return pk.Value, records.StringValue(item[sortName]), nil
+}
+
+func nullString(value types.AttributeValue) sql.NullString {
+	s, ok := value.(*types.AttributeValueMemberS)
+	if !ok {
+		return sql.NullString{String: "", Valid: false}
+	}
+
+	return sql.NullString{String: s.Value, Valid: true}
+}
+
+// expiresAt returns the time a record is purged at in seconds since the epoch, like the TTL of DynamoDB expects it.
+func expiresAt(item dynamo.Key) sql.NullInt64 {
+	n, ok := item[dynamo.ExpiresAtKey].(*types.AttributeValueMemberN)
+	if !ok {
+		return sql.NullInt64{Int64: 0, Valid: false}
+	}
+
+	seconds, err := strconv.ParseInt(n.Value, 10, 64)
+	if err != nil {
+		return sql.NullInt64{Int64: 0, Valid: false}
+	}
+
+	return sql.NullInt64{Int64: seconds, Valid: true}
+}
@@ -0,0 +1,142 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/sqlstore"
+	"github.com/abtercms/abtercms2/pkg/storetest"
+)
+
+const (
+	itemType  = "ITEM"
+	trashType = "TRASH#ITEM"
+)
+
+type item struct {
+	dynamo.Keys
+	ID        string     `dynamodbav:"pk"`
+	Version   int64      `dynamodbav:"version"`
+	Name      string     `dynamodbav:"name,omitempty"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at,omitempty"`
+}
+
+// itemStub creates an item listed in the index partition of items by its ID.
+func itemStub(id string) item {
+	return item{
+		Keys:      dynamo.Keys{SK: itemType, GSI1PK: itemType, GSI1SK: id},
+		ID:        id,
+		Version:   0,
+		Name:      "",
+		DeletedAt: nil,
+	}
+}
+
+// createTestStore creates a Store in a new SQLite database, which takes the write lock when a transaction begins and
+// waits for it to be released like a server would.
+func createTestStore(t *testing.T) *sqlstore.Store {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "websites.db")+"?_txlock=immediate&_busy_timeout=10000")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	store := sqlstore.NewStore(db, sqlstore.SQLite)
+	require.NoError(t, store.Migrate(context.Background()))
+
+	return store
+}
+
+func TestStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, createTestStore(t))
+}
+
+func TestStore_Migrate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success migrating again changes nothing", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+
+		// system under test
+		sut := createTestStore(t)
+		require.NoError(t, sut.Create(ctx, itemStub("foo")))
+
+		// execute
+		err := sut.Migrate(ctx)
+
+		// asserts
+		require.NoError(t, err)
+
+		found, err := sut.Find(ctx, dynamo.K2("foo", itemType), &item{})
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+}
+
+func TestStore_Update(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success only one of concurrent updates of a version wins", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		writers := 20
+
+		// system under test
+		sut := createTestStore(t)
+		require.NoError(t, sut.Create(ctx, itemStub("foo")))
+
+		// execute
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+			stale     int
+		)
+
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				_, err := sut.Update(ctx, itemStub("foo"), dynamo.InitialVersion)
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				if err == nil {
+					succeeded++
+				} else if lhttp.ToProblem(err).Status == http.StatusPreconditionFailed {
+					stale++
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// asserts
+		assert.Equal(t, 1, succeeded)
+		assert.Equal(t, writers-1, stale)
+	})
+}
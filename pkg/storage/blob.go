@@ -0,0 +1,22 @@
+// Package storage for binary content, like the files of the media library, which does not fit into DynamoDB items
+package storage
+
+import (
+	"context"
+)
+
+const (
+	errStoringBlob  = "failed to store blob"
+	errFetchingBlob = "failed to fetch blob"
+	errDeletingBlob = "failed to delete blob"
+	errBlobNotFound = "blob not found in storage: %s"
+	errInvalidKey   = "invalid blob key: %s"
+)
+
+// BlobStore stores binary content by key, missing blobs result in a 404 not found.
+// Keys are slash separated paths, like "website/media", which every implementation maps to its own storage.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, content []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
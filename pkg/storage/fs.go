@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	dirPerm  fs.FileMode = 0o750
+	filePerm fs.FileMode = 0o600
+)
+
+// FSStore stores blobs as files below a root directory, it is meant for local development like SAM local.
+// Content types are not kept, as the metadata of blobs is stored next to their key anyway.
+type FSStore struct {
+	root string
+}
+
+// NewFSStore creates a new FSStore instance storing blobs below root.
+func NewFSStore(root string) *FSStore {
+	return &FSStore{
+		root: root,
+	}
+}
+
+// filename returns the file of a blob, keys escaping the root directory are rejected.
+func (s *FSStore) filename(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", lhttp.NewProblem(http.StatusBadRequest, errInvalidKey, key)
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put stores a blob, replacing any blob stored with the same key.
+func (s *FSStore) Put(_ context.Context, key, _ string, content []byte) error {
+	filename, err := s.filename(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), dirPerm)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errStoringBlob)
+	}
+
+	err = os.WriteFile(filename, content, filePerm)
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errStoringBlob)
+	}
+
+	return nil
+}
+
+// Get retrieves the content of a blob.
+func (s *FSStore) Get(_ context.Context, key string) ([]byte, error) {
+	filename, err := s.filename(key)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, lhttp.WrapProblem(err, http.StatusNotFound, errBlobNotFound, key)
+	}
+
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingBlob)
+	}
+
+	return content, nil
+}
+
+// Delete deletes a blob, deleting a missing blob is not an error.
+func (s *FSStore) Delete(_ context.Context, key string) error {
+	filename, err := s.filename(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errDeletingBlob)
+	}
+
+	return nil
+}
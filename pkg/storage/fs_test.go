@@ -0,0 +1,73 @@
+package storage_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/storage"
+)
+
+func TestFSStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("success storing, fetching and deleting", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := storage.NewFSStore(t.TempDir())
+
+		// execute
+		err := sut.Put(ctx, "abc/def", "image/png", []byte("foo"))
+		require.NoError(t, err)
+
+		got, err := sut.Get(ctx, "abc/def")
+		require.NoError(t, err)
+
+		err = sut.Delete(ctx, "abc/def")
+		require.NoError(t, err)
+
+		_, errMissing := sut.Get(ctx, "abc/def")
+
+		// asserts
+		assert.Equal(t, []byte("foo"), got)
+		require.Error(t, errMissing)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(errMissing).Status)
+	})
+
+	t.Run("success deleting missing blob", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut := storage.NewFSStore(t.TempDir())
+
+		// execute
+		err := sut.Delete(ctx, "abc/def")
+
+		// asserts
+		require.NoError(t, err)
+	})
+
+	for _, key := range []string{"", "/etc/passwd", "../abc", "abc/../../def", ".."} {
+		key := key
+		t.Run("fail key "+key+" escaping root causes 400 bad request", func(t *testing.T) {
+			t.Parallel()
+
+			// system under test
+			sut := storage.NewFSStore(t.TempDir())
+
+			// execute
+			err := sut.Put(ctx, key, "text/plain", []byte("foo"))
+
+			// asserts
+			require.Error(t, err)
+			assert.Equal(t, http.StatusBadRequest, lhttp.ToProblem(err).Status)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+type S3 interface {
+	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// S3Store stores blobs as objects of an S3 bucket, keys are used as object keys as they are.
+type S3Store struct {
+	client S3
+	bucket string
+}
+
+// NewS3Store creates a new S3Store instance, a custom endpoint switches to path-style addressing used by S3 compatible servers.
+func NewS3Store(sdkConfig aws.Config, bucket, s3Endpoint string) *S3Store {
+	return &S3Store{
+		client: s3.NewFromConfig(sdkConfig, func(o *s3.Options) {
+			if s3Endpoint != "" {
+				o.EndpointResolver = s3.EndpointResolverFromURL(s3Endpoint)
+				o.UsePathStyle = true
+			}
+		}),
+		bucket: bucket,
+	}
+}
+
+// SetClient sets an S3 client.
+func (s *S3Store) SetClient(client S3) *S3Store {
+	s.client = client
+
+	return s
+}
+
+// Put stores a blob, replacing any blob stored with the same key.
+func (s *S3Store) Put(ctx context.Context, key, contentType string, content []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        bytes.NewReader(content),
+	})
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errStoringBlob)
+	}
+
+	return nil
+}
+
+// Get retrieves the content of a blob.
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if isNoSuchKey(err) {
+		return nil, lhttp.WrapProblem(err, http.StatusNotFound, errBlobNotFound, key)
+	}
+
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingBlob)
+	}
+
+	defer out.Body.Close()
+
+	content, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusInternalServerError, errFetchingBlob)
+	}
+
+	return content, nil
+}
+
+// Delete deletes a blob, deleting a missing blob is not an error.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return lhttp.WrapProblem(err, http.StatusInternalServerError, errDeletingBlob)
+	}
+
+	return nil
+}
+
+func isNoSuchKey(err error) bool {
+	var nsk *types.NoSuchKey
+
+	return errors.As(err, &nsk)
+}
@@ -0,0 +1,119 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/mocks"
+	"github.com/abtercms/abtercms2/pkg/storage"
+)
+
+func TestS3Store_Put(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("fail error in putting object causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut, clientMock := createTestS3Store()
+
+		// mocks
+		clientMock.On("PutObject", ctx, mock.AnythingOfType("*s3.PutObjectInput")).
+			Once().
+			Return(nil, assert.AnError)
+
+		// execute
+		err := sut.Put(ctx, "abc/def", "image/png", []byte("foo"))
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut, clientMock := createTestS3Store()
+
+		// mocks
+		inputMatcher := mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+			return *input.Bucket == "media" && *input.Key == "abc/def" && *input.ContentType == "image/png"
+		})
+		clientMock.On("PutObject", ctx, inputMatcher).
+			Once().
+			Return(&s3.PutObjectOutput{}, nil)
+
+		// execute
+		err := sut.Put(ctx, "abc/def", "image/png", []byte("foo"))
+
+		// asserts
+		require.NoError(t, err)
+		clientMock.AssertExpectations(t)
+	})
+}
+
+func TestS3Store_Get(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("fail missing object causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut, clientMock := createTestS3Store()
+
+		// mocks
+		clientMock.On("GetObject", ctx, mock.AnythingOfType("*s3.GetObjectInput")).
+			Once().
+			Return(nil, &types.NoSuchKey{Message: aws.String("missing")})
+
+		// execute
+		_, err := sut.Get(ctx, "abc/def")
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// system under test
+		sut, clientMock := createTestS3Store()
+
+		// mocks
+		clientMock.On("GetObject", ctx, mock.AnythingOfType("*s3.GetObjectInput")).
+			Once().
+			Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("foo"))}, nil)
+
+		// execute
+		got, err := sut.Get(ctx, "abc/def")
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []byte("foo"), got)
+	})
+}
+
+func createTestS3Store() (*storage.S3Store, *mocks.S3) {
+	clientMock := &mocks.S3{}
+
+	sut := storage.NewS3Store(aws.Config{}, "media", "").SetClient(clientMock)
+
+	return sut, clientMock
+}
@@ -0,0 +1,701 @@
+// Package storetest for checking implementations of dynamo.Store against the contract of the repository
+package storetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/id"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+)
+
+const recordType = "RECORD#"
+
+// record is the item the suite stores, it covers the attribute types resources use.
+type record struct {
+	dynamo.Keys
+	Partition string            `dynamodbav:"pk"`
+	Name      string            `dynamodbav:"name"`
+	Rank      int               `dynamodbav:"rank"`
+	Tags      []string          `dynamodbav:"tags"`
+	Meta      map[string]string `dynamodbav:"meta"`
+	DeletedAt *time.Time        `dynamodbav:"deleted_at,omitempty"`
+	Version   int64             `dynamodbav:"version"`
+}
+
+// newRecord creates a record of a partition listed via the table itself.
+func newRecord(partition, name string, rank int) record {
+	return record{
+		Keys:      dynamo.Keys{SK: recordType + name, GSI1PK: "", GSI1SK: ""},
+		Partition: partition,
+		Name:      name,
+		Rank:      rank,
+		Tags:      []string{"foo"},
+		Meta:      map[string]string{"title": name},
+		DeletedAt: nil,
+		Version:   0,
+	}
+}
+
+func recordKey(partition, name string) dynamo.Key {
+	return dynamo.K2(partition, recordType+name)
+}
+
+// newPartition returns a partition no other test uses, therefore stores may be shared by tests, e.g. a DynamoDB table.
+func newPartition() string {
+	return "storetest-" + id.NewGenerator().NewString()
+}
+
+// Run checks a store against the contract of the repository: conditional writes, patches, ordering, filters and pagination.
+// Every test writes to a partition of its own, therefore the store does not need to be empty.
+func Run(t *testing.T, store dynamo.Store) {
+	t.Helper()
+
+	t.Run("create", func(t *testing.T) {
+		t.Parallel()
+
+		runCreate(t, store)
+	})
+
+	t.Run("find", func(t *testing.T) {
+		t.Parallel()
+
+		runFind(t, store)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		t.Parallel()
+
+		runUpdate(t, store)
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		t.Parallel()
+
+		runPatch(t, store)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		t.Parallel()
+
+		runDelete(t, store)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		t.Parallel()
+
+		runQuery(t, store)
+	})
+}
+
+func runCreate(t *testing.T, store dynamo.Store) {
+	ctx := context.Background()
+
+	t.Run("success stores the record at the initial version", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		recordStub := newRecord(partition, "foo", 1)
+		recordStub.Version = 7
+
+		// execute
+		err := store.Create(ctx, recordStub)
+
+		// asserts
+		require.NoError(t, err)
+
+		got, err := dynamo.NewTypedRepo[record](store).Get(ctx, recordKey(partition, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.InitialVersion, got.Version)
+		assert.Equal(t, "foo", got.Name)
+		assert.Equal(t, map[string]string{"title": "foo"}, got.Meta)
+	})
+
+	t.Run("fail existing record causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		// execute
+		err := store.Create(ctx, newRecord(partition, "foo", 2))
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+	})
+}
+
+func runFind(t *testing.T, store dynamo.Store) {
+	ctx := context.Background()
+
+	t.Run("success missing record is reported", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		result := record{Name: "untouched"}
+
+		// execute
+		found, err := store.Find(ctx, recordKey(newPartition(), "foo"), &result)
+
+		// asserts
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.Equal(t, "untouched", result.Name)
+	})
+
+	t.Run("fail typed get of missing record wraps ErrNotFound", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		_, err := dynamo.NewTypedRepo[record](store).Get(ctx, recordKey(newPartition(), "foo"))
+
+		// asserts
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, dynamo.ErrNotFound))
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+}
+
+func runUpdate(t *testing.T, store dynamo.Store) {
+	ctx := context.Background()
+
+	t.Run("success stores the record at the next version", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		recordStub := newRecord(partition, "foo", 1)
+		require.NoError(t, store.Create(ctx, recordStub))
+
+		recordStub.Rank = 2
+
+		// execute
+		version, err := store.Update(ctx, recordStub, dynamo.InitialVersion)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.InitialVersion+1, version)
+
+		got, err := dynamo.NewTypedRepo[record](store).Get(ctx, recordKey(partition, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, 2, got.Rank)
+		assert.Equal(t, version, got.Version)
+	})
+
+	t.Run("fail stale version causes 412 precondition failed", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		recordStub := newRecord(partition, "foo", 1)
+		require.NoError(t, store.Create(ctx, recordStub))
+
+		// execute
+		_, err := store.Update(ctx, recordStub, dynamo.InitialVersion+1)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail missing record causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		_, err := store.Update(ctx, newRecord(newPartition(), "foo", 1), dynamo.InitialVersion)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail trashed record causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		deletedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+		recordStub := newRecord(partition, "foo", 1)
+		recordStub.DeletedAt = &deletedAt
+		require.NoError(t, store.Create(ctx, recordStub))
+
+		// execute
+		_, err := store.Update(ctx, recordStub, dynamo.InitialVersion)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+}
+
+func runPatch(t *testing.T, store dynamo.Store) {
+	ctx := context.Background()
+
+	t.Run("success applies every operation", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{
+			{Op: patch.OpTest, Path: patch.Path{"rank"}, Value: 1},
+			{Op: patch.OpReplace, Path: patch.Path{"rank"}, Value: 2},
+			{Op: patch.OpSet, Path: patch.Path{"meta", "subtitle"}, Value: "bar"},
+			{Op: patch.OpRemove, Path: patch.Path{"meta", "title"}, Value: nil},
+			{Op: patch.OpAppend, Path: patch.Path{"tags"}, Value: "qux"},
+		}
+		expectedRecord := newRecord(partition, "foo", 2)
+		expectedRecord.Tags = []string{"foo", "qux"}
+		expectedRecord.Meta = map[string]string{"subtitle": "bar"}
+		expectedRecord.Version = dynamo.InitialVersion + 1
+
+		// execute
+		var got record
+		version, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.InitialVersion+1, version)
+		assert.Equal(t, expectedRecord, got)
+
+		stored, err := dynamo.NewTypedRepo[record](store).Get(ctx, recordKey(partition, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, expectedRecord, stored)
+	})
+
+	t.Run("success list element", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{{Op: patch.OpSet, Path: patch.Path{"tags", "0"}, Value: "bar"}}
+
+		// execute
+		var got record
+		_, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bar"}, got.Tags)
+	})
+
+	t.Run("fail overlapping paths cause 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{
+			{Op: patch.OpSet, Path: patch.Path{"tags", "0"}, Value: "bar"},
+			{Op: patch.OpAppend, Path: patch.Path{"tags"}, Value: "baz"},
+		}
+
+		// execute
+		var got record
+		_, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail replacing missing attribute causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{{Op: patch.OpReplace, Path: patch.Path{"meta", "subtitle"}, Value: "bar"}}
+
+		// execute
+		var got record
+		_, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail removing missing attribute causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{{Op: patch.OpRemove, Path: patch.Path{"meta", "subtitle"}, Value: nil}}
+
+		// execute
+		var got record
+		_, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success unsetting missing attribute", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{{Op: patch.OpUnset, Path: patch.Path{"meta", "subtitle"}, Value: nil}}
+
+		// execute
+		var got record
+		version, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, dynamo.InitialVersion+1, version)
+	})
+
+	t.Run("fail failed test causes 409 conflict and changes nothing", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{
+			{Op: patch.OpSet, Path: patch.Path{"name"}, Value: "bar"},
+			{Op: patch.OpTest, Path: patch.Path{"rank"}, Value: 2},
+		}
+
+		// execute
+		var got record
+		_, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, lhttp.ToProblem(err).Status)
+
+		stored, err := dynamo.NewTypedRepo[record](store).Get(ctx, recordKey(partition, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, "foo", stored.Name)
+		assert.Equal(t, dynamo.InitialVersion, stored.Version)
+	})
+
+	t.Run("fail stale version causes 412 precondition failed", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{{Op: patch.OpSet, Path: patch.Path{"name"}, Value: "bar"}}
+
+		// execute
+		var got record
+		_, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion+1, opsStub, &got)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail missing record causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		opsStub := []patch.Operation{{Op: patch.OpSet, Path: patch.Path{"name"}, Value: "bar"}}
+
+		// execute
+		var got record
+		_, err := store.Patch(ctx, recordKey(newPartition(), "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("fail invalid path causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		opsStub := []patch.Operation{{Op: patch.OpSet, Path: patch.Path{"meta.title"}, Value: "bar"}}
+
+		// execute
+		var got record
+		_, err := store.Patch(ctx, recordKey(partition, "foo"), dynamo.InitialVersion, opsStub, &got)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+	})
+}
+
+func runDelete(t *testing.T, store dynamo.Store) {
+	ctx := context.Background()
+
+	t.Run("success removes the record", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		partition := newPartition()
+		require.NoError(t, store.Create(ctx, newRecord(partition, "foo", 1)))
+
+		// execute
+		err := store.Delete(ctx, recordKey(partition, "foo"))
+
+		// asserts
+		require.NoError(t, err)
+
+		var got record
+		found, err := store.Find(ctx, recordKey(partition, "foo"), &got)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("success missing record", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		err := store.Delete(ctx, recordKey(newPartition(), "foo"))
+
+		// asserts
+		assert.NoError(t, err)
+	})
+}
+
+func runQuery(t *testing.T, store dynamo.Store) {
+	ctx := context.Background()
+
+	// records of a partition are created out of order, along with records of another partition and of another type
+	partition := newPartition()
+	names := []string{"d", "b", "e", "a", "c"}
+
+	for i, name := range names {
+		r := newRecord(partition, name, i)
+		r.GSI1PK = partition + "#index"
+		r.GSI1SK = name
+
+		require.NoError(t, store.Create(ctx, r))
+	}
+
+	require.NoError(t, store.Create(ctx, newRecord(newPartition(), "a", 0)))
+
+	other := newRecord(partition, "a", 0)
+	other.SK = "OTHER#a"
+	require.NoError(t, store.Create(ctx, other))
+
+	unindexed := newRecord(partition, "f", 0)
+	require.NoError(t, store.Create(ctx, unindexed))
+
+	newQuery := func() dynamo.Query {
+		return dynamo.Query{
+			Index:             "",
+			Partition:         partition,
+			SortKey:           dynamo.SortKeyBeginsWith(recordType),
+			Filters:           nil,
+			Limit:             0,
+			ExclusiveStartKey: nil,
+			Descending:        false,
+		}
+	}
+
+	t.Run("success ordered by sort key", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		var got []record
+		page, err := store.Query(ctx, newQuery(), &got)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "d", "e", "f"}, namesOf(got))
+		assert.False(t, page.HasMore)
+	})
+
+	t.Run("success descending", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		query := newQuery()
+		query.Descending = true
+
+		// execute
+		var got []record
+		_, err := store.Query(ctx, query, &got)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []string{"f", "e", "d", "c", "b", "a"}, namesOf(got))
+	})
+
+	t.Run("success sort key between", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		query := newQuery()
+		query.SortKey = &dynamo.SortKeyCondition{Op: dynamo.KeyBetween, Value: recordType + "b", Upper: recordType + "d"}
+
+		// execute
+		var got []record
+		_, err := store.Query(ctx, query, &got)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "c", "d"}, namesOf(got))
+	})
+
+	t.Run("success paginated", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		query := newQuery()
+		query.Limit = 2
+
+		// execute
+		var (
+			got   []string
+			pages int
+		)
+
+		for {
+			var batch []record
+
+			page, err := store.Query(ctx, query, &batch)
+			require.NoError(t, err)
+
+			got = append(got, namesOf(batch)...)
+			pages++
+
+			if !page.HasMore {
+				break
+			}
+
+			require.Len(t, batch, 2)
+			require.Less(t, pages, 10)
+
+			query.ExclusiveStartKey = page.Next
+		}
+
+		// asserts
+		assert.Equal(t, []string{"a", "b", "c", "d", "e", "f"}, got)
+		assert.Equal(t, 3, pages)
+	})
+
+	t.Run("success filters are applied before pages are cut", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		query := newQuery()
+		query.Limit = 2
+		query.Filters = []dynamo.Filter{{Op: dynamo.FilterGreaterThan, Name: "rank", Value: 1}}
+
+		// execute
+		var got []record
+		page, err := store.Query(ctx, query, &got)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "c"}, namesOf(got))
+		assert.True(t, page.HasMore)
+
+		query.ExclusiveStartKey = page.Next
+
+		var rest []record
+		page, err = store.Query(ctx, query, &rest)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e"}, namesOf(rest))
+		assert.False(t, page.HasMore)
+	})
+
+	t.Run("success filters", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name   string
+			filter dynamo.Filter
+			want   []string
+		}{
+			{name: "equal", filter: dynamo.Filter{Op: dynamo.FilterEqual, Name: "name", Value: "c"}, want: []string{"c"}},
+			{name: "not equal", filter: dynamo.Filter{Op: dynamo.FilterNotEqual, Name: "rank", Value: 0}, want: []string{"a", "b", "c", "e"}},
+			{name: "less than", filter: dynamo.Filter{Op: dynamo.FilterLessThan, Name: "rank", Value: 2}, want: []string{"b", "d", "f"}},
+			{name: "begins with", filter: dynamo.Filter{Op: dynamo.FilterBeginsWith, Name: "name", Value: "b"}, want: []string{"b"}},
+			{name: "contains", filter: dynamo.Filter{Op: dynamo.FilterContains, Name: "tags", Value: "foo"}, want: []string{"a", "b", "c", "d", "e", "f"}},
+			{name: "exists", filter: dynamo.Filter{Op: dynamo.FilterExists, Name: "gsi1pk", Value: nil}, want: []string{"a", "b", "c", "d", "e"}},
+			{name: "not exists", filter: dynamo.Filter{Op: dynamo.FilterNotExists, Name: "gsi1pk", Value: nil}, want: []string{"f"}},
+		}
+		for _, tt := range tests {
+			tt := tt
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+
+				// stubs
+				query := newQuery()
+				query.Filters = []dynamo.Filter{tt.filter}
+
+				// execute
+				var got []record
+				_, err := store.Query(ctx, query, &got)
+
+				// asserts
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, namesOf(got))
+			})
+		}
+	})
+
+	t.Run("success index paginated", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		query := dynamo.Query{
+			Index:             dynamo.GSI1,
+			Partition:         partition + "#index",
+			SortKey:           nil,
+			Filters:           nil,
+			Limit:             3,
+			ExclusiveStartKey: nil,
+			Descending:        true,
+		}
+
+		// execute
+		var first, second []record
+		page, err := store.Query(ctx, query, &first)
+		require.NoError(t, err)
+		require.True(t, page.HasMore)
+
+		query.ExclusiveStartKey = page.Next
+		page, err = store.Query(ctx, query, &second)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e", "d", "c"}, namesOf(first))
+		assert.Equal(t, []string{"b", "a"}, namesOf(second))
+		assert.False(t, page.HasMore)
+	})
+}
+
+func namesOf(records []record) []string {
+	names := make([]string, 0, len(records))
+	for _, r := range records {
+		names = append(names, r.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,68 @@
+// Package table opens the single table every function keeps its records in, either in DynamoDB or, for deployments
+// without DynamoDB, in PostgreSQL, or in memory when functions are run locally
+package table
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	_ "github.com/lib/pq" // registers the postgres driver
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/memory"
+	"github.com/abtercms/abtercms2/pkg/sqlstore"
+)
+
+const (
+	// RepositoryMemory selects the in-memory store, which needs neither DynamoDB Local nor a database.
+	RepositoryMemory = "memory"
+
+	driverPostgres = "postgres"
+
+	errConnecting = "failed to connect to postgres"
+)
+
+// Store is the single table as the functions use it, dynamo.Repo, sqlstore.Store and memory.Store implement it.
+type Store interface {
+	dynamo.Store
+	Count(context.Context, dynamo.Query) (int32, bool, error)
+	CreateGuarded(context.Context, interface{}, dynamo.Guards) error
+	UpdateGuarded(context.Context, interface{}, int64, dynamo.Guards) (int64, error)
+	PutGuarded(context.Context, interface{}, dynamo.Guards) error
+	DeleteGuarded(context.Context, dynamo.Key, []dynamo.Key) error
+	TrashGuarded(context.Context, dynamo.Key, dynamo.Keys, time.Time, time.Time, []dynamo.Key) error
+	RestoreGuarded(context.Context, dynamo.Key, dynamo.Keys, []interface{}, interface{}) error
+}
+
+// Open returns a new in-memory table if repository is RepositoryMemory, the table kept in PostgreSQL if databaseURL is
+// set and the DynamoDB table otherwise.
+// Every function must be opened with the same settings, otherwise they do not see the records of each other.
+// In-memory records are not even shared by the containers of a function and are lost with them.
+// The schema of PostgreSQL is migrated before the table is returned, which is safe for functions starting concurrently.
+func Open(ctx context.Context, sdkConfig aws.Config, tableName, dynamoDBEndpoint, databaseURL, repository string) (Store, error) {
+	if repository == RepositoryMemory {
+		return memory.NewStore(), nil
+	}
+
+	if databaseURL == "" {
+		return dynamo.NewRepo(sdkConfig, tableName, dynamoDBEndpoint), nil
+	}
+
+	db, err := sql.Open(driverPostgres, databaseURL)
+	if err != nil {
+		return nil, lhttp.WrapProblem(err, http.StatusInternalServerError, errConnecting)
+	}
+
+	store := sqlstore.NewStore(db, sqlstore.Postgres)
+
+	err = store.Migrate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
@@ -0,0 +1,114 @@
+package tmpl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/osteele/liquid"
+	"github.com/osteele/liquid/render"
+)
+
+const (
+	// BlockTag is the tag including a block by name, e.g. {% block "footer" %}.
+	BlockTag = "block"
+
+	// blocksBinding holds the blocks of a render, it is not a valid Liquid identifier so templates can not refer to it.
+	blocksBinding = "@blocks"
+
+	errBlockName        = "block name must be a non-empty string: %s"
+	errBlocksNotBound   = "blocks are not available while rendering block %s"
+	errBlockCycle       = "blocks include each other: %s"
+	errBlockNotFound    = "block not found: %s"
+	errLoadingBlock     = "failed to load block %s: %w"
+	errRenderingBlock   = "failed to render block %s: %w"
+	blockCycleSeparator = " > "
+)
+
+// Loader loads the Liquid source of a block by name, found is false if the block does not exist.
+type Loader func(ctx context.Context, name string) (source string, found bool, err error)
+
+// Blocks resolves the blocks included by a single render.
+// Every block is loaded and rendered at most once per render, with the bindings of the render.
+type Blocks struct {
+	ctx      context.Context
+	engine   *liquid.Engine
+	load     Loader
+	bindings map[string]interface{}
+	rendered map[string]string
+	stack    []string
+}
+
+// NewBlocks creates the blocks of a single render.
+func NewBlocks(ctx context.Context, engine *liquid.Engine, load Loader) *Blocks {
+	return &Blocks{
+		ctx:      ctx,
+		engine:   engine,
+		load:     load,
+		bindings: nil,
+		rendered: map[string]string{},
+		stack:    nil,
+	}
+}
+
+// Bind makes the blocks available to a render with the given bindings.
+func (b *Blocks) Bind(bindings map[string]interface{}) map[string]interface{} {
+	bindings[blocksBinding] = b
+	b.bindings = bindings
+
+	return bindings
+}
+
+// render renders a block, blocks including themselves directly or via other blocks are reported as an error.
+func (b *Blocks) render(name string) (string, error) {
+	if out, ok := b.rendered[name]; ok {
+		return out, nil
+	}
+
+	for _, pending := range b.stack {
+		if pending == name {
+			return "", fmt.Errorf(errBlockCycle, strings.Join(append(b.stack, name), blockCycleSeparator)) // nolint: goerr113
+		}
+	}
+
+	source, found, err := b.load(b.ctx, name)
+	if err != nil {
+		return "", fmt.Errorf(errLoadingBlock, name, err)
+	}
+
+	if !found {
+		return "", fmt.Errorf(errBlockNotFound, name) // nolint: goerr113
+	}
+
+	b.stack = append(b.stack, name)
+	defer func() { b.stack = b.stack[:len(b.stack)-1] }()
+
+	out, renderErr := b.engine.ParseAndRenderString(source, b.bindings)
+	if renderErr != nil {
+		return "", fmt.Errorf(errRenderingBlock, name, renderErr)
+	}
+
+	b.rendered[name] = out
+
+	return out, nil
+}
+
+// renderBlockTag renders the block named by the argument of a block tag.
+func renderBlockTag(ctx render.Context) (string, error) {
+	value, err := ctx.EvaluateString(ctx.TagArgs())
+	if err != nil {
+		return "", err // nolint: wrapcheck
+	}
+
+	name, ok := value.(string)
+	if !ok || name == "" {
+		return "", fmt.Errorf(errBlockName, ctx.TagArgs()) // nolint: goerr113
+	}
+
+	blocks, ok := ctx.Get(blocksBinding).(*Blocks)
+	if !ok {
+		return "", fmt.Errorf(errBlocksNotBound, name) // nolint: goerr113
+	}
+
+	return blocks.render(name)
+}
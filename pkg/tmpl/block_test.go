@@ -0,0 +1,135 @@
+package tmpl_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+func TestBlocks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail missing block", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		engine := tmpl.NewEngine()
+
+		// system under test
+		sut := tmpl.NewBlocks(ctx, engine, stubLoader(map[string]string{}, nil))
+
+		// execute
+		_, err := engine.ParseAndRenderString(`{% block "footer" %}`, sut.Bind(map[string]interface{}{}))
+
+		// asserts
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "block not found: footer")
+	})
+
+	t.Run("fail loader error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		engine := tmpl.NewEngine()
+
+		// system under test
+		sut := tmpl.NewBlocks(ctx, engine, stubLoader(map[string]string{}, errors.New("foo")))
+
+		// execute
+		_, err := engine.ParseAndRenderString(`{% block "footer" %}`, sut.Bind(map[string]interface{}{}))
+
+		// asserts
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to load block footer")
+	})
+
+	t.Run("fail blocks not bound", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		_, err := tmpl.NewEngine().ParseAndRenderString(`{% block "footer" %}`, map[string]interface{}{})
+
+		// asserts
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "blocks are not available")
+	})
+
+	t.Run("fail blocks including each other", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		engine := tmpl.NewEngine()
+		blocksStub := map[string]string{
+			"a": `a{% block "b" %}`,
+			"b": `b{% block "c" %}`,
+			"c": `c{% block "a" %}`,
+		}
+
+		// system under test
+		sut := tmpl.NewBlocks(ctx, engine, stubLoader(blocksStub, nil))
+
+		// execute
+		_, err := engine.ParseAndRenderString(`{% block "a" %}`, sut.Bind(map[string]interface{}{}))
+
+		// asserts
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "blocks include each other: a > b > c > a")
+	})
+
+	t.Run("success nested blocks are loaded once", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		engine := tmpl.NewEngine()
+		blocksStub := map[string]string{
+			"footer":    `<footer>{% block "copyright" %}</footer>`,
+			"copyright": `(c) {{ website.name }}`,
+		}
+		loads := map[string]int{}
+		loader := func(ctx context.Context, name string) (string, bool, error) {
+			loads[name]++
+
+			return stubLoader(blocksStub, nil)(ctx, name)
+		}
+		bindingsStub := map[string]interface{}{"website": map[string]interface{}{"name": "Foo"}}
+
+		// expectations
+		expectedOut := `<footer>(c) Foo</footer>|(c) Foo|<footer>(c) Foo</footer>`
+
+		// system under test
+		sut := tmpl.NewBlocks(ctx, engine, loader)
+
+		// execute
+		out, err := engine.ParseAndRenderString(
+			`{% block "footer" %}|{% block "copyright" %}|{% block "footer" %}`,
+			sut.Bind(bindingsStub),
+		)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedOut, out)
+		assert.Equal(t, map[string]int{"footer": 1, "copyright": 1}, loads)
+	})
+}
+
+// stubLoader loads blocks from the given map, or fails with the given error.
+func stubLoader(blocks map[string]string, err error) tmpl.Loader {
+	return func(_ context.Context, name string) (string, bool, error) {
+		if err != nil {
+			return "", false, err
+		}
+
+		source, found := blocks[name]
+
+		return source, found, nil
+	}
+}
@@ -0,0 +1,33 @@
+// Package tmpl for parsing Liquid templates
+package tmpl
+
+import (
+	"github.com/osteele/liquid"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+)
+
+const (
+	// firstLine makes the parser count lines from 1, as editors do.
+	firstLine = 1
+)
+
+// NewEngine creates a Liquid engine with the tags and filters available to templates.
+// Templates can include blocks via BlockTag, which are resolved by the Blocks bound to a render.
+func NewEngine() *liquid.Engine {
+	engine := liquid.NewEngine()
+	engine.RegisterTag(BlockTag, renderBlockTag)
+
+	return engine
+}
+
+// Parse parses the Liquid source held by a field.
+// Syntax errors result in a 422 problem naming the field, the reason points to the line of the error.
+func Parse(engine *liquid.Engine, field, source string) (*liquid.Template, error) {
+	template, err := engine.ParseTemplateLocation([]byte(source), "", firstLine)
+	if err != nil {
+		return nil, lhttp.NewInvalidParamsProblem([]lhttp.InvalidParam{{Name: field, Reason: err.Error()}})
+	}
+
+	return template, nil
+}
@@ -0,0 +1,58 @@
+package tmpl_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail syntax error causes 422 unprocessable entity pointing to the line", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		sourceStub := "<html>\n<body>\n{{ page.title | }}\n</body>"
+
+		// execute
+		_, err := tmpl.Parse(tmpl.NewEngine(), "body", sourceStub)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+		require.Len(t, lhttp.ToProblem(err).InvalidParams, 1)
+		assert.Equal(t, "body", lhttp.ToProblem(err).InvalidParams[0].Name)
+		assert.Contains(t, lhttp.ToProblem(err).InvalidParams[0].Reason, "line 3")
+	})
+
+	t.Run("fail unknown tag causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		_, err := tmpl.Parse(tmpl.NewEngine(), "body", "{% foo %}")
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// execute
+		template, err := tmpl.Parse(tmpl.NewEngine(), "body", "<h1>{{ page.title }}</h1>")
+
+		// asserts
+		require.NoError(t, err)
+
+		out, err := template.RenderString(map[string]interface{}{"page": map[string]interface{}{"title": "foo"}})
+		require.NoError(t, err)
+		assert.Equal(t, "<h1>foo</h1>", out)
+	})
+}
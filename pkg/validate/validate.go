@@ -0,0 +1,347 @@
+// Package validate for declarative validation of request entities
+package validate
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+)
+
+// Tag is the struct tag holding the validation rules of a field.
+//
+// Rules are separated by commas:
+//   - required: the field must not be empty
+//   - min=N, max=N: the field must be at least or at most N characters long
+//   - hostname: the field must be a valid hostname
+//   - host: the field must be a valid hostname, a wildcard like *.example.com matching its subdomains, or * matching any host
+//   - enum=a|b|c: the field must be one of the listed values
+//   - pattern=RE: the field must match the regular expression, it must be the last rule as it may contain commas
+//
+// Rules other than required are skipped for empty fields.
+// Rules of string slices apply to each of their elements, which are named like hostnames[0].
+const Tag = "validate"
+
+const (
+	ruleRequired = "required"
+	ruleMin      = "min"
+	ruleMax      = "max"
+	ruleHostname = "hostname"
+	ruleHost     = "host"
+	ruleEnum     = "enum"
+	rulePattern  = "pattern"
+
+	maxHostnameLength = 253
+
+	// AnyHost is the host matching every hostname.
+	AnyHost = "*"
+
+	// WildcardPrefix prefixes hosts matching the subdomains of a hostname.
+	WildcardPrefix = "*."
+
+	reasonRequired = "is required"
+	reasonString   = "must be a string"
+	reasonMin      = "must be at least %d characters long"
+	reasonMax      = "must be at most %d characters long"
+	reasonHostname = "must be a valid hostname"
+	reasonHost     = "must be a valid hostname, a wildcard like *.example.com or *"
+	reasonEnum     = "must be one of: %s"
+	reasonPattern  = "must match pattern %s"
+
+	errInvalidRule = "invalid validation rule of field %s: %s"
+)
+
+// hostnameLabel matches a single label of a hostname as defined by RFC 1123.
+var hostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`) // nolint: gochecknoglobals
+
+// Struct validates the string fields of a struct against their rules.
+// Every failing field is reported in a single 422 problem, fields are named after their JSON names.
+func Struct(v interface{}) error {
+	params, err := check(reflect.Indirect(reflect.ValueOf(v)), "")
+	if err != nil {
+		return err
+	}
+
+	if len(params) > 0 {
+		return lhttp.NewInvalidParamsProblem(params)
+	}
+
+	return nil
+}
+
+// Patch validates the values a list of patch operations would assign to the top level fields of a struct.
+// Removing a required field fails validation as well.
+func Patch(v interface{}, ops []patch.Operation) error {
+	var params []lhttp.InvalidParam
+
+	for _, op := range ops {
+		if len(op.Path) != 1 || op.Op == patch.OpTest {
+			continue
+		}
+
+		field, ok := fieldByJSONName(reflect.Indirect(reflect.ValueOf(v)).Type(), op.Path.Field())
+		if !ok {
+			continue
+		}
+
+		var value interface{}
+		if op.Op != patch.OpRemove && op.Op != patch.OpUnset {
+			value = op.Value
+		}
+
+		s, ok := value.(string)
+		if value != nil && !ok {
+			params = append(params, lhttp.InvalidParam{Name: op.Path.Field(), Reason: reasonString})
+
+			continue
+		}
+
+		reason, err := checkValue(op.Path.Field(), field.Tag.Get(Tag), s)
+		if err != nil {
+			return err
+		}
+
+		if reason != "" {
+			params = append(params, lhttp.InvalidParam{Name: op.Path.Field(), Reason: reason})
+		}
+	}
+
+	if len(params) > 0 {
+		return lhttp.NewInvalidParamsProblem(params)
+	}
+
+	return nil
+}
+
+func check(v reflect.Value, prefix string) ([]lhttp.InvalidParam, error) {
+	var params []lhttp.InvalidParam
+
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := reflect.Indirect(v.Field(i))
+
+		// fields of embedded structs are promoted even if the struct itself is unexported
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		name := jsonName(field)
+		if !field.Anonymous {
+			name = prefix + name
+		}
+
+		if value.Kind() == reflect.Struct {
+			nestedPrefix := prefix
+			if !field.Anonymous {
+				nestedPrefix = name + "."
+			}
+
+			nested, err := check(value, nestedPrefix)
+			if err != nil {
+				return nil, err
+			}
+
+			params = append(params, nested...)
+
+			continue
+		}
+
+		rules, ok := field.Tag.Lookup(Tag)
+		if !ok {
+			continue
+		}
+
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String {
+			for j := 0; j < value.Len(); j++ {
+				elemName := fmt.Sprintf("%s[%d]", name, j)
+
+				reason, err := checkValue(elemName, rules, value.Index(j).String())
+				if err != nil {
+					return nil, err
+				}
+
+				if reason != "" {
+					params = append(params, lhttp.InvalidParam{Name: elemName, Reason: reason})
+				}
+			}
+
+			continue
+		}
+
+		var s string
+		if value.Kind() == reflect.String {
+			s = value.String()
+		}
+
+		reason, err := checkValue(name, rules, s)
+		if err != nil {
+			return nil, err
+		}
+
+		if reason != "" {
+			params = append(params, lhttp.InvalidParam{Name: name, Reason: reason})
+		}
+	}
+
+	return params, nil
+}
+
+// checkValue returns the reason a value fails the rules of a field, or an empty string if it passes them.
+func checkValue(name, rules, value string) (string, error) {
+	if rules == "" {
+		return "", nil
+	}
+
+	for _, rule := range splitRules(rules) {
+		key, arg, _ := strings.Cut(rule, "=")
+
+		if key == ruleRequired {
+			if strings.TrimSpace(value) == "" {
+				return reasonRequired, nil
+			}
+
+			continue
+		}
+
+		if value == "" {
+			continue
+		}
+
+		reason, err := checkRule(key, arg, value)
+		if err != nil {
+			return "", lhttp.WrapProblem(err, http.StatusInternalServerError, errInvalidRule, name, rule)
+		}
+
+		if reason != "" {
+			return reason, nil
+		}
+	}
+
+	return "", nil
+}
+
+func checkRule(key, arg, value string) (string, error) {
+	switch key {
+	case ruleMin, ruleMax:
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			return "", err
+		}
+
+		length := utf8.RuneCountInString(value)
+
+		if key == ruleMin && length < limit {
+			return fmt.Sprintf(reasonMin, limit), nil
+		}
+
+		if key == ruleMax && length > limit {
+			return fmt.Sprintf(reasonMax, limit), nil
+		}
+	case ruleHostname:
+		if !IsHostname(value) {
+			return reasonHostname, nil
+		}
+	case ruleHost:
+		if !IsHost(value) {
+			return reasonHost, nil
+		}
+	case ruleEnum:
+		options := strings.Split(arg, "|")
+		for _, option := range options {
+			if value == option {
+				return "", nil
+			}
+		}
+
+		return fmt.Sprintf(reasonEnum, strings.Join(options, ", ")), nil
+	case rulePattern:
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return "", err
+		}
+
+		if !re.MatchString(value) {
+			return fmt.Sprintf(reasonPattern, arg), nil
+		}
+	default:
+		return "", fmt.Errorf("unknown rule: %s", key) // nolint: goerr113
+	}
+
+	return "", nil
+}
+
+// IsHostname tells whether a string is a valid hostname as defined by RFC 1123.
+func IsHostname(s string) bool {
+	if s == "" || len(s) > maxHostnameLength {
+		return false
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(s, "."), ".") {
+		if !hostnameLabel.MatchString(label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsHost tells whether a string is a valid hostname, a wildcard matching the subdomains of a hostname or AnyHost.
+func IsHost(s string) bool {
+	return s == AnyHost || IsHostname(strings.TrimPrefix(s, WildcardPrefix))
+}
+
+// splitRules splits rules on commas, except for the pattern which takes the rest of the tag.
+func splitRules(rules string) []string {
+	var result []string
+
+	for rules != "" {
+		if strings.HasPrefix(rules, rulePattern+"=") {
+			return append(result, rules)
+		}
+
+		rule, rest, _ := strings.Cut(rules, ",")
+		result = append(result, rule)
+		rules = rest
+	}
+
+	return result
+}
+
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if nested, ok := fieldByJSONName(field.Type, name); ok {
+				return nested, true
+			}
+
+			continue
+		}
+
+		if field.IsExported() && jsonName(field) == name {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
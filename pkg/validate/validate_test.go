@@ -0,0 +1,191 @@
+package validate_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/validate"
+)
+
+type embedded struct {
+	Slug string `json:"slug" validate:"pattern=^[a-z0-9-]{1,3}$"`
+}
+
+type nested struct {
+	Host string `json:"host" validate:"required,hostname"`
+}
+
+type entity struct {
+	embedded
+	Name   string   `json:"name" validate:"required,min=2,max=5"`
+	Status string   `json:"status,omitempty" validate:"enum=active|inactive"`
+	Note   *string  `json:"note" validate:"max=3"`
+	Nested nested   `json:"nested"`
+	Free   string   `json:"free"`
+	Hosts  []string `json:"hosts" validate:"host"`
+}
+
+func TestStruct(t *testing.T) {
+	t.Parallel()
+
+	note := "abcd"
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want []lhttp.InvalidParam
+	}{
+		{
+			name: "valid",
+			v:    entity{embedded: embedded{Slug: "a-1"}, Name: "foo", Status: "active", Nested: nested{Host: "example.com"}, Hosts: []string{"*.example.com", "*"}},
+			want: nil,
+		},
+		{
+			name: "valid pointer /w empty optional fields",
+			v:    &entity{Name: "foo", Nested: nested{Host: "localhost"}},
+			want: nil,
+		},
+		{
+			name: "every failing field is reported",
+			v:    entity{embedded: embedded{Slug: "a,b,c,d"}, Name: " ", Status: "foo", Note: &note, Nested: nested{Host: "-foo.com"}, Hosts: []string{"example.com", "*foo.com"}},
+			want: []lhttp.InvalidParam{
+				{Name: "slug", Reason: "must match pattern ^[a-z0-9-]{1,3}$"},
+				{Name: "name", Reason: "is required"},
+				{Name: "status", Reason: "must be one of: active, inactive"},
+				{Name: "note", Reason: "must be at most 3 characters long"},
+				{Name: "nested.host", Reason: "must be a valid hostname"},
+				{Name: "hosts[1]", Reason: "must be a valid hostname, a wildcard like *.example.com or *"},
+			},
+		},
+		{
+			name: "length is counted in characters",
+			v:    entity{Name: "é", Nested: nested{Host: "example.com"}},
+			want: []lhttp.InvalidParam{
+				{Name: "name", Reason: "must be at least 2 characters long"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// execute
+			err := validate.Struct(tt.v)
+
+			// asserts
+			if tt.want == nil {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+			assert.Equal(t, tt.want, lhttp.ToProblem(err).InvalidParams)
+		})
+	}
+
+	t.Run("fail invalid rule causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		v := struct {
+			Foo string `json:"foo" validate:"bar"`
+		}{Foo: "baz"}
+
+		// execute
+		err := validate.Struct(v)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, lhttp.ToProblem(err).Status)
+	})
+}
+
+func TestPatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ops := []patch.Operation{
+			{Op: patch.OpReplace, Path: patch.Path{"name"}, Value: "foo"},
+			{Op: patch.OpTest, Path: patch.Path{"status"}, Value: "foo"},
+			{Op: patch.OpRemove, Path: patch.Path{"status"}},
+			{Op: patch.OpSet, Path: patch.Path{"slug"}, Value: "a-1"},
+			{Op: patch.OpSet, Path: patch.Path{"nested", "host"}, Value: "-"},
+		}
+
+		// execute
+		err := validate.Patch(entity{}, ops)
+
+		// asserts
+		require.NoError(t, err)
+	})
+
+	t.Run("fail invalid values cause 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ops := []patch.Operation{
+			{Op: patch.OpRemove, Path: patch.Path{"name"}},
+			{Op: patch.OpSet, Path: patch.Path{"status"}, Value: "foo"},
+			{Op: patch.OpSet, Path: patch.Path{"slug"}, Value: 3.0},
+		}
+
+		// execute
+		err := validate.Patch(&entity{}, ops)
+
+		// asserts
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, lhttp.ToProblem(err).Status)
+		assert.Equal(t, []lhttp.InvalidParam{
+			{Name: "name", Reason: "is required"},
+			{Name: "status", Reason: "must be one of: active, inactive"},
+			{Name: "slug", Reason: "must be a string"},
+		}, lhttp.ToProblem(err).InvalidParams)
+	})
+}
+
+func TestIsHostname(t *testing.T) {
+	t.Parallel()
+
+	for s, want := range map[string]bool{
+		"example.com":     true,
+		"www.Example.com": true,
+		"example.com.":    true,
+		"localhost":       true,
+		"":                false,
+		"-example.com":    false,
+		"example..com":    false,
+		"exa_mple.com":    false,
+		"*.example.com":   false,
+	} {
+		assert.Equal(t, want, validate.IsHostname(s), s)
+	}
+}
+
+func TestIsHost(t *testing.T) {
+	t.Parallel()
+
+	for s, want := range map[string]bool{
+		"example.com":     true,
+		"*.example.com":   true,
+		"*":               true,
+		"*.":              false,
+		"*example.com":    false,
+		"www.*.com":       false,
+		"*.*.example.com": false,
+	} {
+		assert.Equal(t, want, validate.IsHost(s), s)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/osteele/liquid"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/site"
+)
+
+type renderParams struct {
+	WebsiteID string `lambda:"path.website"` // a path parameter declared as :website
+}
+
+// website holds the fields of a website needed to tell whether it is served.
+type website struct {
+	ID        string     `dynamodbav:"pk"`
+	Status    string     `dynamodbav:"status"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at,omitempty"`
+}
+
+// pathGuard holds the page a published path is reserved for.
+type pathGuard struct {
+	PageID string `dynamodbav:"page_id"`
+}
+
+// websiteKey returns the table key of a website.
+func websiteKey(websiteID string) dynamo.Key {
+	return dynamo.K2(websiteID, websiteType)
+}
+
+// publishedWebsiteKey returns the table key of the published snapshot of a website.
+func publishedWebsiteKey(websiteID string) dynamo.Key {
+	return dynamo.K2(websiteID, publishedWebsiteType)
+}
+
+// publishedPageKey returns the table key of the published snapshot of a page.
+func publishedPageKey(websiteID, pageID string) dynamo.Key {
+	return dynamo.K2(websiteID, publishedPageType+pageID)
+}
+
+// publishedPathKey returns the table key of the guard reserving a published path.
+func publishedPathKey(websiteID, pagePath string) dynamo.Key {
+	return dynamo.K2(websiteID, publishedPathType+pagePath)
+}
+
+// pagePath returns the path of the page requested, which is whatever follows the website in the request path.
+// Paths are cleaned, therefore trailing slashes and repeated slashes refer to the same page as the clean path.
+func pagePath(req events.APIGatewayProxyRequest, websiteID string) string {
+	return path.Clean(rootPath + strings.TrimPrefix(req.Path, basePath+"/"+websiteID))
+}
+
+type repo interface {
+	dynamo.Store
+}
+
+// Handler is a collection of handlers.
+type Handler struct {
+	websites  *dynamo.TypedRepo[website]
+	snapshots *dynamo.TypedRepo[site.Website]
+	guards    *dynamo.TypedRepo[pathGuard]
+	pages     *dynamo.TypedRepo[site.Page]
+	renderer  *site.Renderer
+}
+
+func NewHandler(repo repo, engine *liquid.Engine) *Handler {
+	return &Handler{
+		websites:  dynamo.NewTypedRepo[website](repo),
+		snapshots: dynamo.NewTypedRepo[site.Website](repo),
+		guards:    dynamo.NewTypedRepo[pathGuard](repo),
+		pages:     dynamo.NewTypedRepo[site.Page](repo),
+		renderer:  site.NewRenderer(repo, engine),
+	}
+}
+
+// RenderPage is a handler to render a page of an active website as HTML.
+// Only the published snapshots of the website and the page are rendered, drafts are never served publicly.
+// The Markdown body of the page is converted to HTML and rendered into the layout of the page, if it has one.
+// Layouts can refer to the website, the page and the converted body as content, and include blocks of the website by name.
+func (h *Handler) RenderPage(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params renderParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	owner, err := h.owner(ctx, params.WebsiteID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	entity, err := h.page(ctx, params.WebsiteID, pagePath(req, params.WebsiteID))
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	html, err := h.renderer.Render(ctx, owner, entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{headerContentType: contentTypeHTML},
+		Body:       html,
+	}, nil
+}
+
+// owner reads the published snapshot of an active website, other websites result in a 404 not found.
+func (h *Handler) owner(ctx context.Context, websiteID string) (site.Website, error) {
+	live, err := h.websites.Get(ctx, websiteKey(websiteID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return site.Website{}, lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotFound, websiteID)
+	}
+
+	if err != nil {
+		return site.Website{}, err
+	}
+
+	if live.DeletedAt != nil || live.Status != statusActive {
+		return site.Website{}, lhttp.NewProblem(http.StatusNotFound, errWebsiteNotFound, websiteID)
+	}
+
+	owner, err := h.snapshots.Get(ctx, publishedWebsiteKey(websiteID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return owner, lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotFound, websiteID)
+	}
+
+	return owner, err
+}
+
+// page reads the published snapshot of the page on a path, paths without a published page result in a 404 not found.
+func (h *Handler) page(ctx context.Context, websiteID, requested string) (site.Page, error) {
+	guard, err := h.guards.Get(ctx, publishedPathKey(websiteID, requested))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return site.Page{}, lhttp.WrapProblem(err, http.StatusNotFound, errPageNotFound, requested)
+	}
+
+	if err != nil {
+		return site.Page{}, err
+	}
+
+	entity, err := h.pages.Get(ctx, publishedPageKey(websiteID, guard.PageID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return entity, lhttp.WrapProblem(err, http.StatusNotFound, errPageNotFound, requested)
+	}
+
+	return entity, err
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/site"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+	"github.com/abtercms/abtercms2/render/mocks"
+)
+
+func TestHandler_RenderPage(t *testing.T) {
+	t.Run("fail inactive website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc/blog",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("inactive")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail unpublished website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc/blog",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail unpublished page causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc/blog",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPathKey("abc", "/blog"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail missing layout causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc/blog",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPathKey("abc", "/blog"), pathGuardModifier("def")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPageKey("abc", "def"), storedPageModifier("def", "/blog", "ghi")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, site.TemplateKey("abc", "ghi"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, "ghi")
+	})
+
+	t.Run("success home page w/o layout", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedBody := "<h1>Hello</h1>\n\n<p>Some <em>text</em></p>\n"
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPathKey("abc", "/"), pathGuardModifier("def")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPageKey("abc", "def"), storedPageModifier("def", "/", "")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, "text/html; charset=UTF-8", res.Headers["Content-Type"])
+		assert.Equal(t, expectedBody, res.Body)
+	})
+
+	t.Run("success trailing slash refers to the same page", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc/blog/",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPathKey("abc", "/blog"), pathGuardModifier("def")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPageKey("abc", "def"), storedPageModifier("def", "/blog", "")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w layout", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc/blog/2022",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+		layoutStub := "<title>{{ page.title }} - {{ website.name }}</title><main>{{ content }}</main>"
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedBody := "<title>Posts - Foo</title><main><h1>Hello</h1>\n\n<p>Some <em>text</em></p>\n</main>"
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPathKey("abc", "/blog/2022"), pathGuardModifier("def")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPageKey("abc", "def"), storedPageModifier("def", "/blog/2022", "ghi")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, site.TemplateKey("abc", "ghi"), storedTemplateModifier("ghi", layoutStub)).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, expectedBody, res.Body)
+	})
+
+	t.Run("fail blocks including each other causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc/blog",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPathKey("abc", "/blog"), pathGuardModifier("def")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPageKey("abc", "def"), storedPageModifier("def", "/blog", "ghi")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, site.TemplateKey("abc", "ghi"), storedTemplateModifier("ghi", `{% block "header" %}`)).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, site.BlockKey("abc", "header"), storedBlockModifier("header", `{% block "menu" %}`)).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, site.BlockKey("abc", "menu"), storedBlockModifier("menu", `{% block "header" %}`)).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w blocks loaded once per render", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/render/abc/blog",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+		layoutStub := `{% block "footer" %}<main>{{ page.title }}</main>{% block "footer" %}`
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedBody := "<footer>(c) Foo</footer><main>Posts</main><footer>(c) Foo</footer>"
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("active")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedWebsiteKey("abc"), publishedWebsiteModifier()).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPathKey("abc", "/blog"), pathGuardModifier("def")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, publishedPageKey("abc", "def"), storedPageModifier("def", "/blog", "ghi")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, site.TemplateKey("abc", "ghi"), storedTemplateModifier("ghi", layoutStub)).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, site.BlockKey("abc", "footer"), storedBlockModifier("footer", "<footer>(c) {{ website.name }}</footer>")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RenderPage(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, expectedBody, res.Body)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func createTestHandler() (*Handler, *mocks.Repo) {
+	repoMock := &mocks.Repo{}
+
+	sut := NewHandler(repoMock, tmpl.NewEngine())
+
+	return sut, repoMock
+}
+
+// storedWebsiteModifier fills the website read from the repository with website abc.
+func storedWebsiteModifier(status string) interface{} {
+	return mock.MatchedBy(func(input *website) bool {
+		*input = website{ID: "abc", Status: status, DeletedAt: nil}
+
+		return true
+	})
+}
+
+// publishedWebsiteModifier fills the snapshot read from the repository with website abc published as Foo.
+func publishedWebsiteModifier() interface{} {
+	return mock.MatchedBy(func(input *site.Website) bool {
+		*input = site.Website{ID: "abc", Name: "Foo"}
+
+		return true
+	})
+}
+
+// pathGuardModifier fills the path guard read from the repository with a guard held by the given page.
+func pathGuardModifier(pageID string) interface{} {
+	return mock.MatchedBy(func(input *pathGuard) bool {
+		input.PageID = pageID
+
+		return true
+	})
+}
+
+// storedPageModifier fills the snapshot read from the repository with a page published as Posts.
+func storedPageModifier(id, pagePath, templateID string) interface{} {
+	return mock.MatchedBy(func(input *site.Page) bool {
+		*input = site.Page{
+			ID:         id,
+			ParentID:   "",
+			Path:       pagePath,
+			Title:      "Posts",
+			Body:       "# Hello\n\nSome *text*",
+			TemplateID: templateID,
+		}
+
+		return true
+	})
+}
+
+// storedTemplateModifier fills the template read from the repository with the given layout.
+func storedTemplateModifier(id, body string) interface{} {
+	return mock.MatchedBy(func(input *site.Template) bool {
+		*input = site.Template{ID: id, Body: body}
+
+		return true
+	})
+}
+
+// storedBlockModifier fills the block read from the repository with the given source.
+func storedBlockModifier(name, body string) interface{} {
+	return mock.MatchedBy(func(input *site.Block) bool {
+		*input = site.Block{Name: name, Body: body}
+
+		return true
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/table"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+const (
+	// basePath prefixes the paths of rendered pages, followed by the website and the path of the page.
+	basePath = "/render"
+
+	// websiteType is the sort key of websites, pages, templates and blocks are stored in the partition of their website.
+	websiteType = "WEBSITE"
+
+	// publishedWebsiteType is the sort key of the published snapshots of websites.
+	publishedWebsiteType = "PUBLISHED#WEBSITE"
+
+	// publishedPageType prefixes the sort key of the published snapshots of pages.
+	publishedPageType = "PUBLISHED#PAGE#"
+
+	// publishedPathType prefixes the sort key of the guards reserving the paths of published snapshots of pages.
+	publishedPathType = "PUBLISHED#PATH#"
+
+	// rootPath is the path of the home page of a website.
+	rootPath = "/"
+
+	statusActive = "active"
+
+	EnvAwsRegion                = "AWS_REGION"
+	EnvTableName                = "TABLE_NAME"
+	EnvAwsSamLocal              = "AWS_SAM_LOCAL"
+	EnvAwsDynamoDBLocalEndpoint = "AWS_DYNAMODB_LOCAL_ENDPOINT"
+	EnvDatabaseURL              = "DATABASE_URL"
+	EnvRepository               = "REPOSITORY"
+
+	trueString = "true"
+
+	headerContentType = "Content-Type"
+	contentTypeHTML   = "text/html; charset=UTF-8"
+
+	errUnmarshallParams = "failed to unmarshal the request, query: %v"
+	errWebsiteNotFound  = "website not found in storage: %s"
+	errPageNotFound     = "page not found in storage: %s"
+)
+
+func main() {
+	var (
+		awsRegion        = os.Getenv(EnvAwsRegion)
+		tableName        = os.Getenv(EnvTableName)
+		databaseURL      = os.Getenv(EnvDatabaseURL)
+		dynamoDBEndpoint = ""
+		repository       = ""
+	)
+
+	if os.Getenv(EnvAwsSamLocal) == trueString {
+		dynamoDBEndpoint = os.Getenv(EnvAwsDynamoDBLocalEndpoint)
+		repository = os.Getenv(EnvRepository)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO(), func(o *config.LoadOptions) error {
+		o.Region = awsRegion
+
+		return nil
+	})
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvAwsRegion, awsRegion).
+			Str(EnvTableName, tableName).
+			Msg("cannot establish connection with dynamodb")
+	}
+
+	repo, err := table.Open(context.TODO(), sdkConfig, tableName, dynamoDBEndpoint, databaseURL, repository)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvTableName, tableName).
+			Msg("cannot open the table")
+	}
+
+	lambda.Start(NewRouter(NewHandler(repo, tmpl.NewEngine())).Handler)
+}
+
+type handler interface {
+	RenderPage(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}
+
+func NewRouter(h handler) *lmdrouter.Router {
+	router := lmdrouter.NewRouter(basePath, lhttp.LoggerMiddleware)
+	router.Route(http.MethodGet, "/:website", h.RenderPage)
+	// segments other than parameters are copied into the pattern of the route, therefore ".+" matches paths of any depth
+	router.Route(http.MethodGet, "/:website/.+", h.RenderPage)
+
+	return router
+}
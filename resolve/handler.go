@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/validate"
+)
+
+// website holds the fields of a website returned by the lookup.
+type website struct {
+	ID        string     `dynamodbav:"pk"`
+	Name      string     `dynamodbav:"name"`
+	Status    string     `dynamodbav:"status"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at,omitempty"`
+}
+
+// hostGuard holds the website a hostname is reserved for.
+type hostGuard struct {
+	WebsiteID string `dynamodbav:"website_id"`
+}
+
+type resolveResponse struct {
+	WebsiteID string `json:"website_id"`
+	Name      string `json:"name"`
+	Hostname  string `json:"hostname"`
+}
+
+// websiteKey returns the table key of a website.
+func websiteKey(websiteID string) dynamo.Key {
+	return dynamo.K2(websiteID, websiteType)
+}
+
+// hostKey returns the table key of the guard reserving a hostname.
+func hostKey(hostname string) dynamo.Key {
+	return dynamo.K2(hostType+hostname, hostGuardType)
+}
+
+// requestHost returns the lowercased hostname of the Host header of a request, without port and trailing dot.
+func requestHost(req events.APIGatewayProxyRequest) (string, error) {
+	header, _ := lhttp.Header(req.Headers, headerHost)
+
+	host := header
+	if h, _, err := net.SplitHostPort(header); err == nil {
+		host = h
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if !validate.IsHostname(host) {
+		return "", lhttp.NewProblem(http.StatusBadRequest, errInvalidHost, header)
+	}
+
+	return host, nil
+}
+
+// candidates returns the hostnames a host may be reserved by, from the most to the least specific one.
+// The host itself comes first, then wildcards of its parent domains, finally the default matching any host.
+// Wildcards of top level domains, like *.com, are never tried.
+func candidates(host string) []string {
+	labels := strings.Split(host, ".")
+	result := []string{host}
+
+	for i := 1; i < len(labels)-1; i++ {
+		result = append(result, validate.WildcardPrefix+strings.Join(labels[i:], "."))
+	}
+
+	return append(result, validate.AnyHost)
+}
+
+type repo interface {
+	dynamo.Store
+}
+
+// Handler is a collection of handlers.
+type Handler struct {
+	guards   *dynamo.TypedRepo[hostGuard]
+	websites *dynamo.TypedRepo[website]
+}
+
+func NewHandler(repo repo) *Handler {
+	return &Handler{
+		guards:   dynamo.NewTypedRepo[hostGuard](repo),
+		websites: dynamo.NewTypedRepo[website](repo),
+	}
+}
+
+// ResolveHost is a handler to find the website served on the host of a request.
+// The most specific hostname reserved for the host wins, an inactive website does not give way to less specific ones.
+func (h *Handler) ResolveHost(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	host, err := requestHost(req)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	for _, hostname := range candidates(host) {
+		guard, err := h.guards.Get(ctx, hostKey(hostname))
+		if errors.Is(err, dynamo.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return lhttp.HandleError(err, nil)
+		}
+
+		owner, err := h.owner(ctx, host, guard.WebsiteID)
+		if err != nil {
+			return lhttp.HandleError(err, nil)
+		}
+
+		return lmdrouter.MarshalResponse(http.StatusOK, nil, resolveResponse{WebsiteID: owner.ID, Name: owner.Name, Hostname: hostname})
+	}
+
+	return lhttp.HandleError(lhttp.NewProblem(http.StatusNotFound, errHostNotFound, host), nil)
+}
+
+// owner reads the website a host is reserved by, missing, deleted and inactive websites result in a 404 not found.
+func (h *Handler) owner(ctx context.Context, host, websiteID string) (website, error) {
+	owner, err := h.websites.Get(ctx, websiteKey(websiteID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return owner, lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotFound, host, websiteID)
+	}
+
+	if err != nil {
+		return owner, err
+	}
+
+	if owner.DeletedAt != nil || owner.Status != statusActive {
+		return owner, lhttp.NewProblem(http.StatusNotFound, errWebsiteNotFound, host, websiteID)
+	}
+
+	return owner, nil
+}
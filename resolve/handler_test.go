@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/resolve/mocks"
+)
+
+func TestCandidates(t *testing.T) {
+	t.Parallel()
+
+	for host, want := range map[string][]string{
+		"localhost":           {"localhost", "*"},
+		"example.com":         {"example.com", "*"},
+		"www.example.com":     {"www.example.com", "*.example.com", "*"},
+		"a.b.example.co.uk":   {"a.b.example.co.uk", "*.b.example.co.uk", "*.example.co.uk", "*.co.uk", "*"},
+		"shop.eu.example.com": {"shop.eu.example.com", "*.eu.example.com", "*.example.com", "*"},
+	} {
+		assert.Equal(t, want, candidates(host), host)
+	}
+}
+
+func TestHandler_ResolveHost(t *testing.T) {
+	t.Run("fail missing host causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/resolve",
+			HTTPMethod: http.MethodGet,
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.ResolveHost(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail unknown host w/o default causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/resolve",
+			HTTPMethod: http.MethodGet,
+			Headers:    map[string]string{"Host": "www.example.com"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, mock.Anything, mock.Anything).
+			Times(3).
+			Return(false, nil)
+
+		// execute
+		res, err := sut.ResolveHost(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail inactive website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/resolve",
+			HTTPMethod: http.MethodGet,
+			Headers:    map[string]string{"Host": "example.com"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, hostKey("example.com"), hostGuardModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("abc", "inactive")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.ResolveHost(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success exact hostname", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/resolve",
+			HTTPMethod: http.MethodGet,
+			Headers:    map[string]string{"host": "Example.com."},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedBody := `{"website_id":"abc","name":"Foo","hostname":"example.com"}`
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, hostKey("example.com"), hostGuardModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("abc", "active")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.ResolveHost(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.JSONEq(t, expectedBody, res.Body)
+	})
+
+	t.Run("success wildcard hostname", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/resolve",
+			HTTPMethod: http.MethodGet,
+			Headers:    map[string]string{"Host": "shop.eu.example.com:8443"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedBody := `{"website_id":"abc","name":"Foo","hostname":"*.example.com"}`
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, hostKey("shop.eu.example.com"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("Find", ctx, hostKey("*.eu.example.com"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("Find", ctx, hostKey("*.example.com"), hostGuardModifier("abc")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, websiteKey("abc"), storedWebsiteModifier("abc", "active")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.ResolveHost(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.JSONEq(t, expectedBody, res.Body)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success default website", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/resolve",
+			HTTPMethod: http.MethodGet,
+			Headers:    map[string]string{"Host": "localhost:3000"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedBody := `{"website_id":"def","name":"Foo","hostname":"*"}`
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, hostKey("localhost"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("Find", ctx, hostKey("*"), hostGuardModifier("def")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, websiteKey("def"), storedWebsiteModifier("def", "active")).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.ResolveHost(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.JSONEq(t, expectedBody, res.Body)
+	})
+}
+
+func createTestHandler() (*Handler, *mocks.Repo) {
+	repoMock := &mocks.Repo{}
+
+	sut := NewHandler(repoMock)
+
+	return sut, repoMock
+}
+
+// hostGuardModifier fills the host guard read from the repository with a guard held by the given website.
+func hostGuardModifier(websiteID string) interface{} {
+	return mock.MatchedBy(func(input *hostGuard) bool {
+		input.WebsiteID = websiteID
+
+		return true
+	})
+}
+
+// storedWebsiteModifier fills the website read from the repository with a website named Foo.
+func storedWebsiteModifier(id, status string) interface{} {
+	return mock.MatchedBy(func(input *website) bool {
+		*input = website{ID: id, Name: "Foo", Status: status, DeletedAt: nil}
+
+		return true
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/table"
+)
+
+const (
+	// basePath is the path of the lookup, the host to resolve is taken from the Host header.
+	basePath = "/resolve"
+
+	// websiteType is the sort key of websites.
+	websiteType = "WEBSITE"
+
+	// hostType prefixes the partition of the guards reserving hostnames, which are unique across websites.
+	hostType = "HOST#"
+
+	// hostGuardType is the sort key of the guards reserving hostnames.
+	hostGuardType = "HOST"
+
+	statusActive = "active"
+
+	EnvAwsRegion                = "AWS_REGION"
+	EnvTableName                = "TABLE_NAME"
+	EnvAwsSamLocal              = "AWS_SAM_LOCAL"
+	EnvAwsDynamoDBLocalEndpoint = "AWS_DYNAMODB_LOCAL_ENDPOINT"
+	EnvDatabaseURL              = "DATABASE_URL"
+	EnvRepository               = "REPOSITORY"
+
+	trueString = "true"
+
+	headerHost = "Host"
+
+	errInvalidHost     = "host header is missing or invalid: %q"
+	errHostNotFound    = "no website is served on host: %s"
+	errWebsiteNotFound = "website of host %s not found in storage: %s"
+)
+
+func main() {
+	var (
+		awsRegion        = os.Getenv(EnvAwsRegion)
+		tableName        = os.Getenv(EnvTableName)
+		databaseURL      = os.Getenv(EnvDatabaseURL)
+		dynamoDBEndpoint = ""
+		repository       = ""
+	)
+
+	if os.Getenv(EnvAwsSamLocal) == trueString {
+		dynamoDBEndpoint = os.Getenv(EnvAwsDynamoDBLocalEndpoint)
+		repository = os.Getenv(EnvRepository)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO(), func(o *config.LoadOptions) error {
+		o.Region = awsRegion
+
+		return nil
+	})
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvAwsRegion, awsRegion).
+			Str(EnvTableName, tableName).
+			Msg("cannot establish connection with dynamodb")
+	}
+
+	repo, err := table.Open(context.TODO(), sdkConfig, tableName, dynamoDBEndpoint, databaseURL, repository)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvTableName, tableName).
+			Msg("cannot open the table")
+	}
+
+	lambda.Start(NewRouter(NewHandler(repo)).Handler)
+}
+
+type handler interface {
+	ResolveHost(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}
+
+func NewRouter(h handler) *lmdrouter.Router {
+	router := lmdrouter.NewRouter(basePath, lhttp.LoggerMiddleware)
+	router.Route(http.MethodGet, "/", h.ResolveHost)
+
+	return router
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rs/zerolog/log"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+)
+
+// due is an item due when a page is to be published or unpublished.
+type due struct {
+	WebsiteID string    `dynamodbav:"pk"`
+	SK        string    `dynamodbav:"sk"`
+	PageID    string    `dynamodbav:"page_id"`
+	Action    string    `dynamodbav:"action"`
+	DueAt     time.Time `dynamodbav:"due_at"`
+}
+
+// page holds the fields of a page needed to publish it.
+type page struct {
+	WebsiteID   string     `dynamodbav:"pk"`
+	ID          string     `dynamodbav:"page_id"`
+	ParentID    string     `dynamodbav:"parent_id,omitempty"`
+	Path        string     `dynamodbav:"path"`
+	Title       string     `dynamodbav:"title"`
+	Body        string     `dynamodbav:"body"`
+	TemplateID  string     `dynamodbav:"template_id,omitempty"`
+	Version     int64      `dynamodbav:"version"`
+	PublishAt   *time.Time `dynamodbav:"publish_at,omitempty"`
+	UnpublishAt *time.Time `dynamodbav:"unpublish_at,omitempty"`
+}
+
+// snapshot is the published copy of a page.
+type snapshot struct {
+	WebsiteID   string    `dynamodbav:"pk"`
+	SK          string    `dynamodbav:"sk"`
+	ID          string    `dynamodbav:"page_id"`
+	ParentID    string    `dynamodbav:"parent_id,omitempty"`
+	Path        string    `dynamodbav:"path"`
+	Title       string    `dynamodbav:"title"`
+	Body        string    `dynamodbav:"body"`
+	TemplateID  string    `dynamodbav:"template_id,omitempty"`
+	Version     int64     `dynamodbav:"version"`
+	PublishedAt time.Time `dynamodbav:"published_at"`
+	PublishedBy string    `dynamodbav:"published_by"`
+}
+
+// pathGuard reserves the path of a published snapshot within its website.
+type pathGuard struct {
+	WebsiteID string `dynamodbav:"pk"`
+	SK        string `dynamodbav:"sk"`
+	PageID    string `dynamodbav:"page_id"`
+}
+
+// pageKey returns the table key of a page.
+func pageKey(websiteID, pageID string) dynamo.Key {
+	return dynamo.K2(websiteID, pageType+pageID)
+}
+
+// snapshotKey returns the table key of the published snapshot of a page.
+func snapshotKey(websiteID, pageID string) dynamo.Key {
+	return dynamo.K2(websiteID, publishedPageType+pageID)
+}
+
+// publishedPathKey returns the table key of the guard reserving a published path.
+func publishedPathKey(websiteID, pagePath string) dynamo.Key {
+	return dynamo.K2(websiteID, publishedPathType+pagePath)
+}
+
+// key returns the table key of a due item.
+func (d *due) key() dynamo.Key {
+	return dynamo.K2(d.WebsiteID, d.SK)
+}
+
+// isDue tells whether a due item is still the one scheduled for its page, items of pages rescheduled since are stale.
+func (d *due) isDue(entity page) bool {
+	scheduledAt := entity.PublishAt
+	if d.Action == actionUnpublish {
+		scheduledAt = entity.UnpublishAt
+	}
+
+	return scheduledAt != nil && scheduledAt.Equal(d.DueAt)
+}
+
+// dueQuery returns the query listing the items due at or before now, oldest first.
+func dueQuery(now time.Time) dynamo.Query {
+	return dynamo.Query{
+		Index:     dynamo.GSI1,
+		Partition: dueType,
+		// index sort keys start with the due time followed by "#", which sorts before "~"
+		SortKey:           &dynamo.SortKeyCondition{Op: dynamo.KeyLessThan, Value: now.UTC().Format(dueFormat) + "~", Upper: ""},
+		Filters:           nil,
+		Limit:             batchSize,
+		ExclusiveStartKey: nil,
+		Descending:        false,
+	}
+}
+
+// snapshot returns the published copy of a page.
+func (p *page) snapshot(now time.Time) snapshot {
+	return snapshot{
+		WebsiteID:   p.WebsiteID,
+		SK:          publishedPageType + p.ID,
+		ID:          p.ID,
+		ParentID:    p.ParentID,
+		Path:        p.Path,
+		Title:       p.Title,
+		Body:        p.Body,
+		TemplateID:  p.TemplateID,
+		Version:     p.Version,
+		PublishedAt: now.UTC(),
+		PublishedBy: actor,
+	}
+}
+
+type repo interface {
+	dynamo.Store
+	PutGuarded(context.Context, interface{}, dynamo.Guards) error
+	DeleteGuarded(context.Context, dynamo.Key, []dynamo.Key) error
+}
+
+// expirer deletes expired records, it is needed by stores which unlike DynamoDB do not expire records by themselves.
+type expirer interface {
+	PurgeExpired(context.Context, time.Time) (int64, error)
+}
+
+// Handler is a collection of handlers.
+// A nil expirer leaves expired records to the store, like to the TTL of DynamoDB.
+type Handler struct {
+	repo      repo
+	expirer   expirer
+	pages     *dynamo.TypedRepo[page]
+	snapshots *dynamo.TypedRepo[snapshot]
+}
+
+func NewHandler(repo repo, expirer expirer) *Handler {
+	return &Handler{
+		repo:      repo,
+		expirer:   expirer,
+		pages:     dynamo.NewTypedRepo[page](repo),
+		snapshots: dynamo.NewTypedRepo[snapshot](repo),
+	}
+}
+
+// Sweep is a handler of schedule events publishing and unpublishing the pages due at the time of the event.
+// Due items are deleted along with their transition, a failed transition keeps its item to be retried by the next sweep.
+// Transitions are idempotent, items of deleted or rescheduled pages are dropped without any change.
+// Every sweep purges the records expired by then as well, such as trashed websites past their retention.
+func (h *Handler) Sweep(ctx context.Context, event events.CloudWatchEvent) error {
+	now := event.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var (
+		query  = dueQuery(now)
+		total  int
+		failed int
+	)
+
+	for {
+		var items []due
+
+		result, err := h.repo.Query(ctx, query, &items)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Time("now", now).
+				Msg("failed to list due items")
+
+			return err
+		}
+
+		for _, item := range items {
+			total++
+
+			outcome, err := h.transition(ctx, item)
+			if err != nil {
+				failed++
+
+				log.Error().
+					Err(err).
+					Str("website_id", item.WebsiteID).
+					Str("page_id", item.PageID).
+					Str("action", item.Action).
+					Time("due_at", item.DueAt).
+					Msg("failed to transition page")
+
+				continue
+			}
+
+			log.Info().
+				Str("website_id", item.WebsiteID).
+				Str("page_id", item.PageID).
+				Str("action", item.Action).
+				Time("due_at", item.DueAt).
+				Str("result", outcome).
+				Msg("transitioned page")
+		}
+
+		if !result.HasMore {
+			break
+		}
+
+		query.ExclusiveStartKey = result.Next
+	}
+
+	log.Info().
+		Time("now", now).
+		Int("total", total).
+		Int("failed", failed).
+		Msg("sweep finished")
+
+	purgeErr := h.purgeExpired(ctx, now)
+
+	if failed > 0 {
+		return fmt.Errorf(errTransitionsFailed, failed, total)
+	}
+
+	return purgeErr
+}
+
+// purgeExpired deletes the records expired by now, unless the store expires records by itself.
+func (h *Handler) purgeExpired(ctx context.Context, now time.Time) error {
+	if h.expirer == nil {
+		return nil
+	}
+
+	purged, err := h.expirer.PurgeExpired(ctx, now)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Time("now", now).
+			Msg("failed to purge expired records")
+
+		return err
+	}
+
+	log.Info().
+		Time("now", now).
+		Int64("purged", purged).
+		Msg("purged expired records")
+
+	return nil
+}
+
+// transition publishes or unpublishes the page of a due item and returns the outcome.
+func (h *Handler) transition(ctx context.Context, item due) (string, error) {
+	entity, err := h.pages.Get(ctx, pageKey(item.WebsiteID, item.PageID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return resultSkipped, h.repo.DeleteGuarded(ctx, item.key(), nil)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if !item.isDue(entity) {
+		return resultSkipped, h.repo.DeleteGuarded(ctx, item.key(), nil)
+	}
+
+	switch item.Action {
+	case actionPublish:
+		return resultPublished, h.publish(ctx, item, entity)
+	case actionUnpublish:
+		return h.unpublish(ctx, item)
+	default:
+		return "", fmt.Errorf(errUnknownAction, item.Action)
+	}
+}
+
+// publish replaces the published snapshot of a page like publishing it via the API, while deleting the due item.
+func (h *Handler) publish(ctx context.Context, item due, entity page) error {
+	published, err := h.snapshots.Get(ctx, snapshotKey(entity.WebsiteID, entity.ID))
+
+	found := !errors.Is(err, dynamo.ErrNotFound)
+	if found && err != nil {
+		return err
+	}
+
+	current := entity.snapshot(time.Now())
+	guards := dynamo.Guards{Add: nil, Remove: []dynamo.Key{item.key()}, Keep: nil}
+
+	if !found || published.Path != current.Path {
+		guards.Add = []interface{}{pathGuard{WebsiteID: current.WebsiteID, SK: publishedPathType + current.Path, PageID: current.ID}}
+	}
+
+	if found && published.Path != current.Path {
+		guards.Remove = append(guards.Remove, publishedPathKey(published.WebsiteID, published.Path))
+	}
+
+	return h.repo.PutGuarded(ctx, current, guards)
+}
+
+// unpublish deletes the published snapshot of a page along with the due item, pages not published are skipped.
+func (h *Handler) unpublish(ctx context.Context, item due) (string, error) {
+	published, err := h.snapshots.Get(ctx, snapshotKey(item.WebsiteID, item.PageID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return resultSkipped, h.repo.DeleteGuarded(ctx, item.key(), nil)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	err = h.repo.DeleteGuarded(ctx, snapshotKey(item.WebsiteID, item.PageID), []dynamo.Key{publishedPathKey(item.WebsiteID, published.Path), item.key()})
+	if err != nil {
+		return "", err
+	}
+
+	return resultUnpublished, nil
+}
@@ -0,0 +1,378 @@
+//go:generate mockery-latest --all --exported --case underscore
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/sqlstore"
+	"github.com/abtercms/abtercms2/scheduler/mocks"
+)
+
+func TestHandler_Sweep(t *testing.T) {
+	// hack needed because zerolog gets a global log builder
+	{
+		l := log.Logger
+
+		log.Logger = zerolog.Nop()
+		defer func() {
+			log.Logger = l
+		}()
+	}
+
+	t.Run("fail error in listing due items", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		eventStub := createTestEvent()
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, dueQuery(eventStub.Time), mock.Anything).
+			Once().
+			Return(dynamo.Page{}, assert.AnError)
+
+		// execute
+		err := sut.Sweep(ctx, eventStub)
+
+		// asserts
+		assert.Error(t, err)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail error in purging expired records", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		eventStub := createTestEvent()
+
+		// mocks
+		repoMock := &mocks.Repo{}
+		repoMock.On("Query", ctx, dueQuery(eventStub.Time), mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		expirerMock := &mocks.Expirer{}
+		expirerMock.On("PurgeExpired", ctx, eventStub.Time).
+			Once().
+			Return(int64(0), assert.AnError)
+
+		// system under test
+		sut := NewHandler(repoMock, expirerMock)
+
+		// execute
+		err := sut.Sweep(ctx, eventStub)
+
+		// asserts
+		assert.ErrorIs(t, err, assert.AnError)
+		repoMock.AssertExpectations(t)
+		expirerMock.AssertExpectations(t)
+	})
+
+	t.Run("success purging expired records", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		eventStub := createTestEvent()
+
+		// mocks
+		repoMock := &mocks.Repo{}
+		repoMock.On("Query", ctx, dueQuery(eventStub.Time), mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		expirerMock := &mocks.Expirer{}
+		expirerMock.On("PurgeExpired", ctx, eventStub.Time).
+			Once().
+			Return(int64(2), nil)
+
+		// system under test
+		sut := NewHandler(repoMock, expirerMock)
+
+		// execute
+		err := sut.Sweep(ctx, eventStub)
+
+		// asserts
+		require.NoError(t, err)
+		repoMock.AssertExpectations(t)
+		expirerMock.AssertExpectations(t)
+	})
+
+	t.Run("success publishing", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		eventStub := createTestEvent()
+		itemStub := createTestDue(actionPublish)
+
+		// expectations
+		expectedGuards := dynamo.Guards{
+			Add:    []interface{}{pathGuard{WebsiteID: "abc", SK: "PUBLISHED#PATH#/blog", PageID: "def"}},
+			Remove: []dynamo.Key{itemStub.key()},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		snapshotMatcher := mock.MatchedBy(func(input snapshot) bool {
+			return input.SK == "PUBLISHED#PAGE#def" && input.Version == 3 && input.PublishedBy == actor
+		})
+		repoMock.On("Query", ctx, dueQuery(eventStub.Time), dueModifier(itemStub)).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("/blog")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("PutGuarded", ctx, snapshotMatcher, expectedGuards).
+			Once().
+			Return(nil)
+
+		// execute
+		err := sut.Sweep(ctx, eventStub)
+
+		// asserts
+		require.NoError(t, err)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success unpublishing", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		eventStub := createTestEvent()
+		itemStub := createTestDue(actionUnpublish)
+
+		// expectations
+		expectedGuards := []dynamo.Key{publishedPathKey("abc", "/news"), itemStub.key()}
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		snapshotModifier := mock.MatchedBy(func(input *snapshot) bool {
+			input.ID = "def"
+			input.Path = "/news"
+
+			return true
+		})
+		repoMock.On("Query", ctx, dueQuery(eventStub.Time), dueModifier(itemStub)).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("/blog")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), snapshotModifier).
+			Once().
+			Return(true, nil)
+		repoMock.On("DeleteGuarded", ctx, snapshotKey("abc", "def"), expectedGuards).
+			Once().
+			Return(nil)
+
+		// execute
+		err := sut.Sweep(ctx, eventStub)
+
+		// asserts
+		require.NoError(t, err)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success dropping items of rescheduled pages", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		eventStub := createTestEvent()
+		itemStub := createTestDue(actionPublish)
+		itemStub.DueAt = itemStub.DueAt.Add(-time.Hour)
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, dueQuery(eventStub.Time), dueModifier(itemStub)).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("/blog")).
+			Once().
+			Return(true, nil)
+		repoMock.On("DeleteGuarded", ctx, itemStub.key(), []dynamo.Key(nil)).
+			Once().
+			Return(nil)
+
+		// execute
+		err := sut.Sweep(ctx, eventStub)
+
+		// asserts
+		require.NoError(t, err)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("success dropping items of deleted pages", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		eventStub := createTestEvent()
+		itemStub := createTestDue(actionUnpublish)
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, dueQuery(eventStub.Time), dueModifier(itemStub)).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("DeleteGuarded", ctx, itemStub.key(), []dynamo.Key(nil)).
+			Once().
+			Return(nil)
+
+		// execute
+		err := sut.Sweep(ctx, eventStub)
+
+		// asserts
+		require.NoError(t, err)
+		repoMock.AssertExpectations(t)
+	})
+
+	t.Run("fail taken path keeps item and continues with the next batch", func(t *testing.T) {
+		// t.Parallel() commented out because the log hack should not be run concurrently
+
+		// stubs
+		ctx := context.Background()
+		eventStub := createTestEvent()
+		itemStub := createTestDue(actionPublish)
+		nextStub := dynamo.K2("abc", itemStub.SK)
+		nextQueryStub := dueQuery(eventStub.Time)
+		nextQueryStub.ExclusiveStartKey = nextStub
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		// the next batch is expected first, as matching calls against the first batch would run its modifier
+		repoMock.On("Query", ctx, nextQueryStub, mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Query", ctx, dueQuery(eventStub.Time), dueModifier(itemStub)).
+			Once().
+			Return(dynamo.Page{Next: nextStub, HasMore: true}, nil)
+		repoMock.On("Find", ctx, pageKey("abc", "def"), storedPageModifier("/blog")).
+			Once().
+			Return(true, nil)
+		repoMock.On("Find", ctx, snapshotKey("abc", "def"), mock.Anything).
+			Once().
+			Return(false, nil)
+		repoMock.On("PutGuarded", ctx, mock.AnythingOfType("main.snapshot"), mock.AnythingOfType("dynamo.Guards")).
+			Once().
+			Return(lhttp.NewProblem(http.StatusConflict, "unique value already taken"))
+
+		// execute
+		err := sut.Sweep(ctx, eventStub)
+
+		// asserts
+		assert.Error(t, err)
+		repoMock.AssertExpectations(t)
+	})
+}
+
+func TestDueQuery(t *testing.T) {
+	t.Parallel()
+
+	query := dueQuery(time.Date(2022, 7, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)))
+
+	assert.Equal(t, dynamo.GSI1, query.Index)
+	assert.Equal(t, dueType, query.Partition)
+	assert.Equal(t, dynamo.KeyLessThan, query.SortKey.Op)
+	assert.Less(t, "2022-07-01T10:00:00Z#abc#def#publish", query.SortKey.Value)
+	assert.Greater(t, "2022-07-01T10:00:01Z#abc#def#publish", query.SortKey.Value)
+}
+
+func TestExpirer(t *testing.T) {
+	t.Parallel()
+
+	// the SQL store is purged by the sweep, while DynamoDB expires records by itself
+	assert.Implements(t, (*expirer)(nil), &sqlstore.Store{})
+	_, ok := interface{}(&dynamo.Repo{}).(expirer)
+	assert.False(t, ok)
+}
+
+func createTestHandler() (*Handler, *mocks.Repo) {
+	repoMock := &mocks.Repo{}
+
+	sut := NewHandler(repoMock, nil)
+
+	return sut, repoMock
+}
+
+// createTestEvent returns a schedule event sent an hour after the test pages are due.
+func createTestEvent() events.CloudWatchEvent {
+	return events.CloudWatchEvent{DetailType: "Scheduled Event", Time: time.Date(2022, 7, 1, 11, 0, 0, 0, time.UTC)}
+}
+
+// createTestDue returns an item due when page def of website abc is scheduled to be published or unpublished.
+func createTestDue(action string) due {
+	dueAt := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	return due{
+		WebsiteID: "abc",
+		SK:        "SCHEDULE#def#" + action + "#" + dueAt.Format(dueFormat),
+		PageID:    "def",
+		Action:    action,
+		DueAt:     dueAt,
+	}
+}
+
+// dueModifier fills the due items read from the repository with the given item.
+func dueModifier(item due) interface{} {
+	return mock.MatchedBy(func(input *[]due) bool {
+		*input = []due{item}
+
+		return true
+	})
+}
+
+// storedPageModifier fills the page read from the repository with page def scheduled like createTestDue.
+func storedPageModifier(pagePath string) interface{} {
+	return mock.MatchedBy(func(input *page) bool {
+		dueAt := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+
+		*input = page{
+			WebsiteID:   "abc",
+			ID:          "def",
+			ParentID:    "",
+			Path:        pagePath,
+			Title:       "Blog",
+			Body:        "",
+			TemplateID:  "",
+			Version:     3,
+			PublishAt:   &dueAt,
+			UnpublishAt: &dueAt,
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/abtercms/abtercms2/pkg/table"
+)
+
+const (
+	// pageType prefixes the sort key of pages, pages are stored in the partition of their website.
+	pageType = "PAGE#"
+
+	// publishedPageType prefixes the sort key of the published snapshots of pages.
+	publishedPageType = "PUBLISHED#PAGE#"
+
+	// publishedPathType prefixes the sort key of the guards reserving the paths of published snapshots.
+	publishedPathType = "PUBLISHED#PATH#"
+
+	// dueType is the partition of the index listing scheduled items by the time they are due.
+	dueType = "DUE"
+
+	// dueFormat is the format of due times in sort keys, UTC to the second so that they sort chronologically.
+	dueFormat = "2006-01-02T15:04:05Z"
+
+	// batchSize is the number of due items read at once, a sweep reads batches until no due items are left.
+	batchSize int32 = 100
+
+	// actor is recorded as the publisher of pages published when they are due.
+	actor = "scheduler"
+
+	actionPublish   = "publish"
+	actionUnpublish = "unpublish"
+
+	resultPublished   = "published"
+	resultUnpublished = "unpublished"
+	resultSkipped     = "skipped"
+
+	EnvAwsRegion                = "AWS_REGION"
+	EnvTableName                = "TABLE_NAME"
+	EnvAwsSamLocal              = "AWS_SAM_LOCAL"
+	EnvAwsDynamoDBLocalEndpoint = "AWS_DYNAMODB_LOCAL_ENDPOINT"
+	EnvDatabaseURL              = "DATABASE_URL"
+	EnvRepository               = "REPOSITORY"
+
+	trueString = "true"
+
+	errUnknownAction     = "unknown scheduled action: %s"
+	errTransitionsFailed = "%d of %d due items failed, they are retried by the next sweep"
+)
+
+func main() {
+	var (
+		awsRegion        = os.Getenv(EnvAwsRegion)
+		tableName        = os.Getenv(EnvTableName)
+		databaseURL      = os.Getenv(EnvDatabaseURL)
+		dynamoDBEndpoint = ""
+		repository       = ""
+	)
+
+	if os.Getenv(EnvAwsSamLocal) == trueString {
+		dynamoDBEndpoint = os.Getenv(EnvAwsDynamoDBLocalEndpoint)
+		repository = os.Getenv(EnvRepository)
+	}
+
+	// UNIX Time is faster and smaller than most timestamps
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO(), func(o *config.LoadOptions) error {
+		o.Region = awsRegion
+
+		return nil
+	})
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvAwsRegion, awsRegion).
+			Str(EnvTableName, tableName).
+			Msg("cannot establish connection with dynamodb")
+	}
+
+	repo, err := table.Open(context.TODO(), sdkConfig, tableName, dynamoDBEndpoint, databaseURL, repository)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvTableName, tableName).
+			Msg("cannot open the table")
+	}
+
+	// DynamoDB expires records by itself, other stores are purged by every sweep
+	expired, _ := repo.(expirer)
+
+	lambda.Start(NewHandler(repo, expired).Sweep)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+
+	"github.com/osteele/liquid"
+
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/revision"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+)
+
+// template is a Liquid template stored in the partition of its website.
+// Its body is parsed before every write, therefore stored templates are always free of syntax errors.
+type template struct {
+	crud.Meta
+	WebsiteID string `json:"website_id" dynamodbav:"pk"`
+	ID        string `json:"id" dynamodbav:"template_id"`
+	Name      string `json:"name" dynamodbav:"name" validate:"required,max=100"`
+	Body      string `json:"body" dynamodbav:"body" validate:"required,max=65536"`
+}
+
+func (t *template) Identity() (string, string) {
+	return t.WebsiteID, t.ID
+}
+
+func (t *template) SetIdentity(websiteID, id string) {
+	t.WebsiteID = websiteID
+	t.ID = id
+}
+
+// templateKey returns the table key of a template.
+func templateKey(websiteID, templateID string) dynamo.Key {
+	return dynamo.K2(websiteID, templateType+templateID)
+}
+
+// isPatchable tells whether clients may change a template field via PATCH.
+func isPatchable(field string) bool {
+	switch field {
+	case "name", bodyField:
+		return true
+	default:
+		return false
+	}
+}
+
+type cursors interface {
+	Encode(dynamo.Query, dynamo.Key) (string, error)
+	Decode(dynamo.Query, string) (dynamo.Key, error)
+}
+
+type revisions interface {
+	Record(context.Context, string, string, int64, string, interface{}) error
+	List(context.Context, string, string, int32, dynamo.Key) ([]revision.Revision, dynamo.Page, error)
+	Get(context.Context, string, string, string) (revision.Revision, error)
+	Purge(context.Context, string, string) error
+}
+
+// Handler is a collection of handlers.
+// Every saved version of a template is recorded as a revision, which the template can be rolled back to.
+type Handler = crud.Handler[template, *template]
+
+func NewHandler(repo crud.Repository[template], websites crud.Websites, cursors cursors, revisions revisions, engine *liquid.Engine) *Handler {
+	resource := crud.Resource[template]{
+		Name:          "template",
+		Type:          templateType,
+		IDParam:       idParam,
+		Key:           templateKey,
+		GenerateID:    true,
+		Validate:      validateBody(engine),
+		Patchable:     isPatchable,
+		ValidatePatch: validatePatchedBody(engine),
+		Prepare:       nil,
+		Guard:         nil,
+		Deleting:      nil,
+		Present:       nil,
+		Deleted:       nil,
+	}
+
+	return crud.NewHandler(resource, repo, websites, cursors, revisions)
+}
+
+// validateBody returns a hook parsing the body of a template to reject syntax errors.
+func validateBody(engine *liquid.Engine) func(template) error {
+	return func(entity template) error {
+		_, err := tmpl.Parse(engine, bodyField, entity.Body)
+
+		return err
+	}
+}
+
+// validatePatchedBody returns a hook parsing the body a patch assigns to a template to reject syntax errors.
+func validatePatchedBody(engine *liquid.Engine) func(context.Context, string, []patch.Operation) error {
+	return func(_ context.Context, _ string, ops []patch.Operation) error {
+		for _, op := range ops {
+			if body, ok := op.Value.(string); ok && op.Path.Field() == bodyField && op.Op != patch.OpTest {
+				_, err := tmpl.Parse(engine, bodyField, body)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/crud"
+	"github.com/abtercms/abtercms2/pkg/cursor"
+	"github.com/abtercms/abtercms2/pkg/dynamo"
+	pkgmocks "github.com/abtercms/abtercms2/pkg/mocks"
+	"github.com/abtercms/abtercms2/pkg/revision"
+	"github.com/abtercms/abtercms2/pkg/tmpl"
+	"github.com/abtercms/abtercms2/templates/mocks"
+)
+
+func TestHandler_RetrieveCollection(t *testing.T) {
+	t.Run("fail limit out of range causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:                  "/websites/abc/templates",
+			HTTPMethod:            http.MethodGet,
+			PathParameters:        map[string]string{"website": "abc"},
+			QueryStringParameters: map[string]string{"limit": "101"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedQuery := dynamo.Query{
+			Index:             "",
+			Partition:         "abc",
+			SortKey:           dynamo.SortKeyBeginsWith("TEMPLATE#"),
+			Filters:           nil,
+			Limit:             crud.DefaultLimit,
+			ExclusiveStartKey: nil,
+			Descending:        false,
+		}
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, expectedQuery).
+			Once().
+			Return([]template{}, dynamo.Page{}, nil)
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+}
+
+func TestHandler_CreateEntity(t *testing.T) {
+	t.Run("fail syntax error causes 422 unprocessable entity pointing to the line", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"layout","body":"<html>\n{% if page.title %}\n<h1>{{ page.title }}</h1>\n</html>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"body"`)
+		assert.Contains(t, res.Body, "line 2")
+	})
+
+	t.Run("fail missing website causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"layout","body":"<h1>{{ page.title }}</h1>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, _, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{}, dynamo.ErrNotFound)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		websitesMock.AssertExpectations(t)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc"},
+			Body:           `{"name":"layout","body":"<h1>{{ page.title }}</h1>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusCreated
+
+		// system under test
+		sut, repoMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		templateMatcher := mock.MatchedBy(func(input template) bool {
+			return input.WebsiteID == "abc" && input.ID != "" && input.SK == "TEMPLATE#"+input.ID
+		})
+		repoMock.On("Create", ctx, templateMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RetrieveEntity(t *testing.T) {
+	t.Run("fail missing entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, templateKey("abc", "def")).
+			Once().
+			Return(template{}, dynamo.ErrNotFound)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, _ := createTestHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, templateKey("abc", "def")).
+			Once().
+			Return(storedTemplate("def"), nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, `"3"`, res.Headers["ETag"])
+	})
+}
+
+func TestHandler_UpdateEntity(t *testing.T) {
+	t.Run("fail syntax error causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"id":"def","name":"layout","body":"{% for %}{% endfor %}"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`},
+			Body:           `{"id":"def","name":"layout","body":"<h2>{{ page.title }}</h2>"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, templateKey("abc", "def")).
+			Once().
+			Return(storedTemplate("def"), nil)
+		templateMatcher := mock.MatchedBy(func(input template) bool {
+			return input.Body == "<h2>{{ page.title }}</h2>" && input.CreatedBy == "alice"
+		})
+		repoMock.On("Update", ctx, templateMatcher, int64(3)).
+			Once().
+			Return(int64(4), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_PatchEntity(t *testing.T) {
+	t.Run("fail syntax error causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`, "Content-Type": "application/merge-patch+json"},
+			Body:           `{"body":"{{ page.title | }}"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _, _ := createTestHandler()
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def",
+			HTTPMethod:     http.MethodPatch,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+			Headers:        map[string]string{"If-Match": `"3"`, "Content-Type": "application/merge-patch+json"},
+			Body:           `{"body":"{{ page.title | upcase }}"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Patch", ctx, templateKey("abc", "def"), int64(3), mock.Anything).
+			Once().
+			Return(storedTemplate("def"), int64(4), nil)
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_DeleteEntity(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def",
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"website": "abc", "id": "def"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNoContent
+
+		// system under test
+		sut, repoMock, websitesMock := createTestHandler()
+
+		// mocks
+		websitesMock.On("Get", ctx, crud.WebsiteKey("abc")).
+			Once().
+			Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+		repoMock.On("Get", ctx, templateKey("abc", "def")).
+			Once().
+			Return(storedTemplate("def"), nil)
+		repoMock.On("Delete", ctx, templateKey("abc", "def")).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		websitesMock.AssertExpectations(t)
+	})
+}
+
+func TestHandler_RestoreRevision(t *testing.T) {
+	t.Run("fail missing entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def/revisions/ghi/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def", "rev": "ghi"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		repoMock.On("Get", ctx, templateKey("abc", "def")).
+			Once().
+			Return(template{}, dynamo.ErrNotFound)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		revisionsMock.AssertExpectations(t)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/abc/templates/def/revisions/ghi/restore",
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"website": "abc", "id": "def", "rev": "ghi"},
+		}
+		revisionStub := revision.Revision{ID: "ghi", Version: 1, Data: json.RawMessage(`{"website_id":"abc","id":"def","name":"old layout","body":"<h1>{{ page.title | upcase }}</h1>","version":1}`)}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		templateMatcher := mock.MatchedBy(func(input template) bool {
+			return input.Name == "old layout" && input.SK == "TEMPLATE#def" && input.CreatedBy == "alice"
+		})
+		repoMock.On("Get", ctx, templateKey("abc", "def")).
+			Once().
+			Return(storedTemplate("def"), nil)
+		revisionsMock.On("Get", ctx, "abc", "TEMPLATE#def", "ghi").
+			Once().
+			Return(revisionStub, nil)
+		repoMock.On("Update", ctx, templateMatcher, int64(3)).
+			Once().
+			Return(int64(4), nil)
+		revisionsMock.On("Record", ctx, "abc", "TEMPLATE#def", int64(4), "anonymous", templateMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		require.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
+		revisionsMock.AssertExpectations(t)
+	})
+}
+
+func createTestHandler() (*Handler, *pkgmocks.Repository[template], *pkgmocks.Websites) {
+	repoMock := &pkgmocks.Repository[template]{}
+	websitesMock := &pkgmocks.Websites{}
+	revisionsMock := &mocks.Revisions{}
+	revisionsMock.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Maybe().
+		Return(nil)
+	revisionsMock.On("Purge", mock.Anything, mock.Anything, mock.Anything).
+		Maybe().
+		Return(nil)
+
+	sut := NewHandler(repoMock, websitesMock, cursor.NewCodec([]byte("secret")), revisionsMock, tmpl.NewEngine())
+
+	return sut, repoMock, websitesMock
+}
+
+func createTestRevisionsHandler() (*Handler, *pkgmocks.Repository[template], *mocks.Revisions) {
+	repoMock := &pkgmocks.Repository[template]{}
+	websitesMock := &pkgmocks.Websites{}
+	websitesMock.On("Get", mock.Anything, mock.Anything).
+		Maybe().
+		Return(crud.Website{ID: "abc", DeletedAt: nil}, nil)
+	revisionsMock := &mocks.Revisions{}
+
+	sut := NewHandler(repoMock, websitesMock, cursor.NewCodec([]byte("secret")), revisionsMock, tmpl.NewEngine())
+
+	return sut, repoMock, revisionsMock
+}
+
+// storedTemplate returns a stored template of website abc created by alice.
+func storedTemplate(id string) template {
+	createdAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	return template{
+		Meta: crud.Meta{
+			Fields:  audit.Fields{CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "alice", UpdatedBy: "alice"},
+			Version: 3,
+		},
+		WebsiteID: "abc",
+		ID:        id,
+		Name:      "layout",
+		Body:      "<h1>{{ page.title }}</h1>",
+	}
+}
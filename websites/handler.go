@@ -2,61 +2,367 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/aquasecurity/lmdrouter"
 	"github.com/aws/aws-lambda-go/events"
 
+	"github.com/abtercms/abtercms2/pkg/audit"
 	"github.com/abtercms/abtercms2/pkg/dynamo"
 	"github.com/abtercms/abtercms2/pkg/id"
 	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/revision"
+	"github.com/abtercms/abtercms2/pkg/validate"
 )
 
 type listParams struct {
-	ExclusiveStartKey string `lambda:"query.exclusive_start_key"` // a query parameter named "exclusive_start_key"
+	Cursor       string `lambda:"query.cursor"`        // a query parameter named "cursor"
+	Limit        int32  `lambda:"query.limit"`         // a query parameter named "limit"
+	Total        bool   `lambda:"query.total"`         // a query parameter named "total"
+	Sort         string `lambda:"query.sort"`          // a query parameter named "sort"
+	NamePrefix   string `lambda:"query.name_prefix"`   // a query parameter named "name_prefix"
+	CreatedAfter string `lambda:"query.created_after"` // a query parameter named "created_after"
+	Status       string `lambda:"query.status"`        // a query parameter named "status"
 }
 
 type listResponse struct {
 	Items            interface{} `json:"items"`
-	LastEvaluatedKey dynamo.Key  `json:"last_evaluated_key,omitempty"`
-	ScannedCount     int32       `json:"scanned_count,omitempty"`
+	NextCursor       string      `json:"next_cursor,omitempty"`
+	HasMore          bool        `json:"has_more"`
+	Total            *int32      `json:"total,omitempty"`
+	TotalApproximate bool        `json:"total_approximate,omitempty"`
+}
+
+// isListParam tells whether a query parameter is supported when retrieving the collection.
+func isListParam(name string) bool {
+	switch name {
+	case "cursor", limitParam, "total", "sort", "name_prefix", "created_after", "status":
+		return true
+	default:
+		return false
+	}
+}
+
+// collectionQuery translates the query parameters of a collection request into a query of the websites index.
+// Websites are listed in creation order via their ULIDs, therefore sorting and creation time use the sort key.
+func collectionQuery(req events.APIGatewayProxyRequest, params listParams) (dynamo.Query, error) {
+	query := dynamo.Query{
+		Index:             dynamo.GSI1,
+		Partition:         websiteType,
+		SortKey:           nil,
+		Filters:           nil,
+		Limit:             0,
+		ExclusiveStartKey: nil,
+		Descending:        false,
+	}
+
+	for name := range req.QueryStringParameters {
+		if !isListParam(name) {
+			return query, lhttp.NewProblem(http.StatusBadRequest, errUnsupportedParam, name)
+		}
+	}
+
+	pageSize, err := pageLimit(req, params)
+	if err != nil {
+		return query, err
+	}
+
+	query.Limit = pageSize
+
+	switch params.Sort {
+	case "", sortCreatedAt:
+	case "-" + sortCreatedAt:
+		query.Descending = true
+	default:
+		return query, lhttp.NewProblem(http.StatusBadRequest, errUnsupportedSort, params.Sort)
+	}
+
+	if params.CreatedAfter != "" {
+		createdAfter, err := time.Parse(time.RFC3339, params.CreatedAfter)
+		if err != nil {
+			return query, lhttp.WrapProblem(err, http.StatusBadRequest, errInvalidCreatedAfter, params.CreatedAfter)
+		}
+
+		query.SortKey = &dynamo.SortKeyCondition{Op: dynamo.KeyGreaterThanEqual, Value: id.After(createdAfter), Upper: ""}
+	}
+
+	if params.NamePrefix != "" {
+		query.Filters = append(query.Filters, dynamo.Filter{Op: dynamo.FilterBeginsWith, Name: "name", Value: params.NamePrefix})
+	}
+
+	if params.Status != "" {
+		if !isStatus(params.Status) {
+			return query, lhttp.NewProblem(http.StatusBadRequest, errInvalidStatus, params.Status)
+		}
+
+		query.Filters = append(query.Filters, dynamo.Filter{Op: dynamo.FilterEqual, Name: "status", Value: params.Status})
+	}
+
+	return query, nil
+}
+
+// pageLimit returns the page size requested by the client, falling back to defaultLimit if none was requested.
+func pageLimit(req events.APIGatewayProxyRequest, params listParams) (int32, error) {
+	if _, ok := req.QueryStringParameters[limitParam]; !ok {
+		return defaultLimit, nil
+	}
+
+	if params.Limit < minLimit || params.Limit > maxLimit {
+		return 0, lhttp.NewProblem(http.StatusBadRequest, errInvalidLimit, params.Limit, minLimit, maxLimit)
+	}
+
+	return params.Limit, nil
 }
 
 type entityParams struct {
-	ID string `lambda:"path.id"` // a path parameter declared as :id
+	ID string `lambda:"path.website"` // a path parameter declared as :website
+}
+
+type revisionParams struct {
+	ID         string `lambda:"path.website"` // a path parameter declared as :website
+	RevisionID string `lambda:"path.rev"`     // a path parameter declared as :rev
+}
+
+type deleteParams struct {
+	ID    string `lambda:"path.website"` // a path parameter declared as :website
+	Purge bool   `lambda:"query.purge"`  // a query parameter named "purge"
 }
 
+// website is a website, the hostnames it is served on are reserved by guards, so that no two websites share one.
+// Hostnames can be exact, wildcards like *.example.com matching every subdomain, or * to serve any other host.
 type website struct {
-	ID   string `json:"pk"`
-	Name string `json:"name"`
+	dynamo.Keys
+	audit.Fields
+	ID        string   `json:"pk" dynamodbav:"pk"`
+	Name      string   `json:"name" dynamodbav:"name" validate:"required,max=100"`
+	Status    string   `json:"status" dynamodbav:"status" validate:"required,enum=active|inactive"`
+	Hostnames []string `json:"hostnames,omitempty" dynamodbav:"hostnames,omitempty" validate:"host"`
+	Version   int64    `json:"version" dynamodbav:"version"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty" dynamodbav:"deleted_at,omitempty"`
+}
+
+// snapshot is the published copy of a website, public pages are only rendered for published websites.
+// It is replaced as a whole whenever the website is published again, its version is the one of the website published.
+type snapshot struct {
+	ID          string    `json:"pk" dynamodbav:"pk"`
+	SK          string    `json:"-" dynamodbav:"sk"`
+	Name        string    `json:"name" dynamodbav:"name"`
+	Version     int64     `json:"version" dynamodbav:"version"`
+	PublishedAt time.Time `json:"published_at" dynamodbav:"published_at"`
+	PublishedBy string    `json:"published_by" dynamodbav:"published_by"`
+}
+
+// hostGuard reserves a hostname for a website.
+type hostGuard struct {
+	Hostname  string `dynamodbav:"pk"`
+	SK        string `dynamodbav:"sk"`
+	WebsiteID string `dynamodbav:"website_id"`
+}
+
+// isStatus tells whether a string is a known website status.
+func isStatus(status string) bool {
+	return status == statusActive || status == statusInactive
+}
+
+// validate defaults the status of a website to active and checks the website against its validation rules.
+// Hostnames are case-insensitive, therefore they are lowercased and deduplicated first.
+func (w *website) validate() error {
+	if w.Status == "" {
+		w.Status = statusActive
+	}
+
+	w.Hostnames = normalizeHostnames(w.Hostnames)
+
+	if len(w.Hostnames) > maxHostnames {
+		return lhttp.NewInvalidParamsProblem([]lhttp.InvalidParam{{Name: "hostnames", Reason: fmt.Sprintf(reasonMaxHostnames, maxHostnames)}})
+	}
+
+	return validate.Struct(w)
+}
+
+// normalizeHostnames lowercases hostnames and drops trailing dots and duplicates, keeping their order.
+func normalizeHostnames(hostnames []string) []string {
+	var (
+		result []string
+		seen   = map[string]bool{}
+	)
+
+	for _, hostname := range hostnames {
+		hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+		if seen[hostname] {
+			continue
+		}
+
+		seen[hostname] = true
+		result = append(result, hostname)
+	}
+
+	return result
+}
+
+// hostKey returns the table key of the guard reserving a hostname.
+func hostKey(hostname string) dynamo.Key {
+	return dynamo.K2(hostType+hostname, hostGuardType)
+}
+
+// hostGuards returns the guards reserving hostnames for a website.
+func hostGuards(websiteID string, hostnames []string) []interface{} {
+	guards := make([]interface{}, 0, len(hostnames))
+
+	for _, hostname := range hostnames {
+		guards = append(guards, hostGuard{Hostname: hostType + hostname, SK: hostGuardType, WebsiteID: websiteID})
+	}
+
+	return guards
+}
+
+// hostKeys returns the keys of the guards reserving hostnames.
+func hostKeys(hostnames []string) []dynamo.Key {
+	keys := make([]dynamo.Key, 0, len(hostnames))
+
+	for _, hostname := range hostnames {
+		keys = append(keys, hostKey(hostname))
+	}
+
+	return keys
+}
+
+// without returns the hostnames not contained in others.
+func without(hostnames, others []string) []string {
+	var result []string
+
+	for _, hostname := range hostnames {
+		found := false
+
+		for _, other := range others {
+			if hostname == other {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			result = append(result, hostname)
+		}
+	}
+
+	return result
+}
+
+// websiteKey returns the table key of a website.
+func websiteKey(id string) dynamo.Key {
+	return dynamo.K2(id, websiteType)
+}
+
+// snapshotKey returns the table key of the published snapshot of a website.
+func snapshotKey(id string) dynamo.Key {
+	return dynamo.K2(id, publishedType)
+}
+
+// snapshot returns the published copy of a website.
+func (w *website) snapshot(actor string, now time.Time) snapshot {
+	return snapshot{
+		ID:          w.ID,
+		SK:          publishedType,
+		Name:        w.Name,
+		Version:     w.Version,
+		PublishedAt: now.UTC(),
+		PublishedBy: actor,
+	}
+}
+
+// websiteKeys returns the storage keys of a website, websites are listed in creation order via their ULIDs.
+func websiteKeys(id string) dynamo.Keys {
+	return dynamo.Keys{
+		SK:     websiteType,
+		GSI1PK: websiteType,
+		GSI1SK: id,
+	}
+}
+
+// setKeys sets the storage keys of a website.
+func (w *website) setKeys() {
+	w.Keys = websiteKeys(w.ID)
+}
+
+// trashKeys returns the storage keys of a trashed website, trashed websites are listed in a partition of their own.
+func trashKeys(id string) dynamo.Keys {
+	return dynamo.Keys{
+		SK:     websiteType,
+		GSI1PK: trashType,
+		GSI1SK: id,
+	}
+}
+
+// isPatchable tells whether clients may change a website field via PATCH, keys and versions are managed by the server.
+// Hostnames are excluded as changing them requires their guards to be replaced, PUT does that.
+func isPatchable(field string) bool {
+	switch field {
+	case "name", "status":
+		return true
+	default:
+		return false
+	}
 }
 
 type repo interface {
-	Get(context.Context, dynamo.Key, interface{}) error
-	List(context.Context, int32, *dynamo.Key, interface{}) (dynamo.Key, int32, error)
-	Create(context.Context, interface{}) error
-	Update(context.Context, interface{}) error
-	Delete(context.Context, dynamo.Key) error
+	dynamo.Store
+	Count(context.Context, dynamo.Query) (int32, bool, error)
+	CreateGuarded(context.Context, interface{}, dynamo.Guards) error
+	UpdateGuarded(context.Context, interface{}, int64, dynamo.Guards) (int64, error)
+	DeleteGuarded(context.Context, dynamo.Key, []dynamo.Key) error
+	PutGuarded(context.Context, interface{}, dynamo.Guards) error
+	TrashGuarded(context.Context, dynamo.Key, dynamo.Keys, time.Time, time.Time, []dynamo.Key) error
+	RestoreGuarded(context.Context, dynamo.Key, dynamo.Keys, []interface{}, interface{}) error
+}
+
+type cursors interface {
+	Encode(dynamo.Query, dynamo.Key) (string, error)
+	Decode(dynamo.Query, string) (dynamo.Key, error)
+}
+
+type revisions interface {
+	Record(context.Context, string, string, int64, string, interface{}) error
+	List(context.Context, string, string, int32, dynamo.Key) ([]revision.Revision, dynamo.Page, error)
+	Get(context.Context, string, string, string) (revision.Revision, error)
+	Purge(context.Context, string, string) error
 }
 
 // Handler is a collection of handlers.
+// Trashed websites are purged automatically after retention, a zero retention keeps them until purged explicitly.
+// Every saved version of a website is recorded as a revision, which the website can be rolled back to.
 type Handler struct {
-	repo repo
+	repo      repo
+	websites  *dynamo.TypedRepo[website]
+	snapshots *dynamo.TypedRepo[snapshot]
+	cursors   cursors
+	revisions revisions
+	retention time.Duration
 }
 
-func NewHandler(repo repo) *Handler {
+func NewHandler(repo repo, cursors cursors, revisions revisions, retention time.Duration) *Handler {
 	return &Handler{
-		repo: repo,
+		repo:      repo,
+		websites:  dynamo.NewTypedRepo[website](repo),
+		snapshots: dynamo.NewTypedRepo[snapshot](repo),
+		cursors:   cursors,
+		revisions: revisions,
+		retention: retention,
 	}
 }
 
 // RetrieveCollection is a handler to retrieve a collection.
 func (h *Handler) RetrieveCollection(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	var (
-		exclusiveStartKey *dynamo.Key
-		params            listParams
-		collection        = []website{}
+		params     listParams
+		collection = []website{}
 	)
 
 	err := lmdrouter.UnmarshalRequest(req, false, &params)
@@ -64,20 +370,90 @@ func (h *Handler) RetrieveCollection(ctx context.Context, req events.APIGatewayP
 		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
 	}
 
-	if params.ExclusiveStartKey != "" {
-		esk := dynamo.K1(params.ExclusiveStartKey)
-		exclusiveStartKey = &esk
+	query, err := collectionQuery(req, params)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	query.ExclusiveStartKey, err = h.cursors.Decode(query, params.Cursor)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
 	}
 
-	lastEvaluatedKey, scannedCount, err := h.repo.List(ctx, limit, exclusiveStartKey, &collection)
+	page, err := h.repo.Query(ctx, query, &collection)
 	if err != nil {
 		return lhttp.HandleError(err, nil)
 	}
 
-	return lmdrouter.MarshalResponse(http.StatusOK, nil, listResponse{Items: collection, LastEvaluatedKey: lastEvaluatedKey, ScannedCount: scannedCount})
+	nextCursor, err := h.cursors.Encode(query, page.Next)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	response := listResponse{Items: collection, NextCursor: nextCursor, HasMore: page.HasMore, Total: nil, TotalApproximate: false}
+
+	if params.Total {
+		// the total is independent of the page requested
+		query.ExclusiveStartKey = nil
+
+		total, exact, err := h.repo.Count(ctx, query)
+		if err != nil {
+			return lhttp.HandleError(err, nil)
+		}
+
+		response.Total = &total
+		response.TotalApproximate = !exact
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, response)
 }
 
-// CreateEntity is a handler to create a new entity.
+// RetrieveTrash is a handler to retrieve the collection of trashed entities.
+func (h *Handler) RetrieveTrash(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var (
+		params     listParams
+		collection = []website{}
+	)
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	pageSize, err := pageLimit(req, params)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	query := dynamo.Query{
+		Index:             dynamo.GSI1,
+		Partition:         trashType,
+		SortKey:           nil,
+		Filters:           nil,
+		Limit:             pageSize,
+		ExclusiveStartKey: nil,
+		Descending:        false,
+	}
+
+	query.ExclusiveStartKey, err = h.cursors.Decode(query, params.Cursor)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	page, err := h.repo.Query(ctx, query, &collection)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	nextCursor, err := h.cursors.Encode(query, page.Next)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, listResponse{Items: collection, NextCursor: nextCursor, HasMore: page.HasMore, Total: nil, TotalApproximate: false})
+}
+
+// CreateEntity is a handler to create a new entity, hostnames already taken by other websites result in a 409 conflict.
 func (h *Handler) CreateEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	var (
 		entity website
@@ -92,22 +468,55 @@ func (h *Handler) CreateEntity(ctx context.Context, req events.APIGatewayProxyRe
 		return lhttp.HandleError(fmt.Errorf(errPrimaryKeyNotAllowedDetail, entity.ID, errPrimaryKeyNotAllowed), nil)
 	}
 
+	err = entity.validate()
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
 	entity.ID = id.NewGenerator().NewString()
+	entity.Version = dynamo.InitialVersion
+	entity.DeletedAt = nil
+	entity.setKeys()
+
+	entity.Fields, err = audit.Create(entity.ID, audit.Actor(req))
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.repo.CreateGuarded(ctx, entity, dynamo.Guards{Add: hostGuards(entity.ID, entity.Hostnames), Remove: nil, Keep: nil})
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
 
-	err = h.repo.Create(ctx, entity)
+	err = h.revisions.Record(ctx, entity.ID, websiteType, entity.Version, audit.Actor(req), entity)
 	if err != nil {
 		return lhttp.HandleError(err, nil)
 	}
 
-	return lmdrouter.MarshalResponse(http.StatusCreated, nil, entity)
+	return lmdrouter.MarshalResponse(http.StatusCreated, lhttp.ETagHeaders(entity.Version), entity)
+}
+
+// get reads a website, missing and trashed websites result in a 404 not found.
+func (h *Handler) get(ctx context.Context, websiteID string) (website, error) {
+	entity, err := h.websites.Get(ctx, websiteKey(websiteID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return entity, lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotFound)
+	}
+
+	if err != nil {
+		return entity, err
+	}
+
+	if entity.DeletedAt != nil {
+		return entity, lhttp.NewProblem(http.StatusNotFound, errWebsiteNotFound)
+	}
+
+	return entity, nil
 }
 
 // RetrieveEntity is a handler to retrieve an entity.
 func (h *Handler) RetrieveEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	var (
-		params entityParams
-		entity website
-	)
+	var params entityParams
 
 	err := lmdrouter.UnmarshalRequest(req, false, &params)
 	if err != nil {
@@ -118,19 +527,15 @@ func (h *Handler) RetrieveEntity(ctx context.Context, req events.APIGatewayProxy
 		return lhttp.HandleError(lhttp.WrapProblem(errInvalidID, http.StatusBadRequest, errInvalidIDDetail, params.ID, "", errInvalidID.Error()), nil)
 	}
 
-	err = h.repo.Get(ctx, dynamo.K1(params.ID), &entity)
+	entity, err := h.get(ctx, params.ID)
 	if err != nil {
 		return lhttp.HandleError(err, nil)
 	}
 
-	if entity.ID == "" {
-		return lhttp.HandleError(lhttp.NewProblem(http.StatusNotFound, "website not found in storage"), nil)
-	}
-
-	return lmdrouter.MarshalResponse(http.StatusOK, nil, entity)
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(entity.Version), entity)
 }
 
-// UpdateEntity is a handler to update an existing entity.
+// UpdateEntity is a handler to update an existing entity, hostnames added are reserved and hostnames removed are released.
 func (h *Handler) UpdateEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	var (
 		entity website
@@ -151,18 +556,218 @@ func (h *Handler) UpdateEntity(ctx context.Context, req events.APIGatewayProxyRe
 		return lhttp.HandleError(lhttp.WrapProblem(errInvalidID, http.StatusBadRequest, errInvalidIDDetail, params.ID, entity.ID, errInvalidID.Error()), nil)
 	}
 
-	err = h.repo.Update(ctx, entity)
+	version, err := lhttp.IfMatch(req.Headers)
 	if err != nil {
 		return lhttp.HandleError(err, nil)
 	}
 
-	return lmdrouter.MarshalResponse(http.StatusOK, nil, entity)
+	err = entity.validate()
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	// audit fields are read-only, therefore they are taken from the stored entity instead of the request
+	stored, err := h.get(ctx, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	if version == lhttp.AnyVersion {
+		version = stored.Version
+	}
+
+	entity, err = h.update(ctx, audit.Actor(req), entity, stored, version)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(entity.Version), entity)
 }
 
-// DeleteEntity is a handler to delete an existing entity.
+// update replaces a stored website by a validated entity and records the revision saved.
+func (h *Handler) update(ctx context.Context, actor string, entity, stored website, version int64) (website, error) {
+	var err error
+
+	entity.ID = stored.ID
+	entity.Fields = stored.Fields.Update(actor, time.Now())
+	entity.DeletedAt = nil
+	entity.setKeys()
+
+	guards := dynamo.Guards{
+		Add:    hostGuards(entity.ID, without(entity.Hostnames, stored.Hostnames)),
+		Remove: hostKeys(without(stored.Hostnames, entity.Hostnames)),
+		Keep:   nil,
+	}
+
+	entity.Version, err = h.repo.UpdateGuarded(ctx, entity, version, guards)
+	if err != nil {
+		return entity, err
+	}
+
+	return entity, h.revisions.Record(ctx, entity.ID, websiteType, entity.Version, actor, entity)
+}
+
+// PatchEntity is a handler to partially update an existing entity using JSON Merge Patch or JSON Patch.
+func (h *Handler) PatchEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var (
+		entity website
+		params entityParams
+	)
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	if params.ID == "" {
+		return lhttp.HandleError(lhttp.WrapProblem(errInvalidID, http.StatusBadRequest, errInvalidIDDetail, params.ID, "", errInvalidID.Error()), nil)
+	}
+
+	version, err := lhttp.IfMatch(req.Headers)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	contentType, _ := lhttp.Header(req.Headers, headerContentType)
+
+	ops, err := patch.Parse(contentType, []byte(req.Body))
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	for _, op := range ops {
+		if !isPatchable(op.Path.Field()) {
+			return lhttp.HandleError(lhttp.NewProblem(http.StatusUnprocessableEntity, errFieldNotPatchable, op.Path.String()), nil)
+		}
+	}
+
+	err = validate.Patch(entity, ops)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	version, err = h.checkStored(ctx, params.ID, version, ops)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	actor := audit.Actor(req)
+	ops = append(ops,
+		patch.Operation{Op: patch.OpSet, Path: patch.Path{"updated_at"}, Value: time.Now().UTC()},
+		patch.Operation{Op: patch.OpSet, Path: patch.Path{"updated_by"}, Value: actor},
+	)
+
+	entity.Version, err = h.repo.Patch(ctx, websiteKey(params.ID), version, ops, &entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.revisions.Record(ctx, params.ID, websiteType, entity.Version, actor, entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(entity.Version), entity)
+}
+
+// checkStored checks a patch against the stored website and returns the version it is conditioned on. The values
+// removed by a JSON Patch must exist, and If-Match: * conditions the patch on the version stored, patches needing
+// neither spare reading the website. The version condition makes sure the website checked is the one patched.
+func (h *Handler) checkStored(ctx context.Context, websiteID string, version int64, ops []patch.Operation) (int64, error) {
+	if version != lhttp.AnyVersion && !patch.HasRemovals(ops) {
+		return version, nil
+	}
+
+	stored, err := h.get(ctx, websiteID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = patch.CheckRemovals(stored, ops)
+	if err != nil {
+		return 0, err
+	}
+
+	if version == lhttp.AnyVersion {
+		version = stored.Version
+	}
+
+	return version, nil
+}
+
+// DeleteEntity is a handler to move an existing entity to the trash, or to delete it permanently if purge is requested.
+// Either way its hostnames are released, trashed websites take them back when they are restored.
+// Purging deletes the published snapshot and the revisions as well, trashed websites keep them but are not rendered.
 func (h *Handler) DeleteEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params deleteParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	if params.Purge {
+		err = h.purge(ctx, params.ID)
+		if err != nil {
+			return lhttp.HandleError(err, nil)
+		}
+
+		return lmdrouter.MarshalResponse(http.StatusNoContent, nil, nil)
+	}
+
+	stored, err := h.get(ctx, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	var (
+		deletedAt = time.Now()
+		expiresAt time.Time
+	)
+
+	if h.retention > 0 {
+		expiresAt = deletedAt.Add(h.retention)
+	}
+
+	err = h.repo.TrashGuarded(ctx, websiteKey(params.ID), trashKeys(params.ID), deletedAt, expiresAt, hostKeys(stored.Hostnames))
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusNoContent, nil, nil)
+}
+
+// purge deletes a website permanently along with its published snapshot and revisions, trashed websites included.
+func (h *Handler) purge(ctx context.Context, websiteID string) error {
+	stored, err := h.websites.Get(ctx, websiteKey(websiteID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotFound)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// trashed websites released their hostnames already, which may have been taken by others since
+	guards := []dynamo.Key{snapshotKey(websiteID)}
+	if stored.DeletedAt == nil {
+		guards = append(guards, hostKeys(stored.Hostnames)...)
+	}
+
+	err = h.repo.DeleteGuarded(ctx, websiteKey(websiteID), guards)
+	if err != nil {
+		return err
+	}
+
+	return h.revisions.Purge(ctx, websiteID, websiteType)
+}
+
+// RestoreEntity is a handler to restore an entity from the trash.
+// Hostnames taken by other websites while it was trashed result in a 409 conflict.
+func (h *Handler) RestoreEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	var (
 		params entityParams
+		entity website
 	)
 
 	err := lmdrouter.UnmarshalRequest(req, false, &params)
@@ -170,10 +775,177 @@ func (h *Handler) DeleteEntity(ctx context.Context, req events.APIGatewayProxyRe
 		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
 	}
 
-	err = h.repo.Delete(ctx, dynamo.K1(params.ID))
+	if params.ID == "" {
+		return lhttp.HandleError(lhttp.WrapProblem(errInvalidID, http.StatusBadRequest, errInvalidIDDetail, params.ID, "", errInvalidID.Error()), nil)
+	}
+
+	stored, err := h.websites.Get(ctx, websiteKey(params.ID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotTrashed), nil)
+	}
+
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	if stored.DeletedAt == nil {
+		return lhttp.HandleError(lhttp.NewProblem(http.StatusNotFound, errWebsiteNotTrashed), nil)
+	}
+
+	err = h.repo.RestoreGuarded(ctx, websiteKey(params.ID), websiteKeys(params.ID), hostGuards(params.ID, stored.Hostnames), &entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(entity.Version), entity)
+}
+
+// PublishEntity is a handler to publish the current state of a website, replacing its previously published snapshot.
+func (h *Handler) PublishEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params entityParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	stored, err := h.get(ctx, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	entity := stored.snapshot(audit.Actor(req), time.Now())
+
+	err = h.repo.PutGuarded(ctx, entity, dynamo.Guards{Add: nil, Remove: nil, Keep: nil})
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, entity)
+}
+
+// UnpublishEntity is a handler to take down the published snapshot of a website, the website itself is kept.
+func (h *Handler) UnpublishEntity(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params entityParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	_, err = h.snapshots.Get(ctx, snapshotKey(params.ID))
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusNotFound, errWebsiteNotPublished), nil)
+	}
+
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = h.repo.DeleteGuarded(ctx, snapshotKey(params.ID), nil)
 	if err != nil {
 		return lhttp.HandleError(err, nil)
 	}
 
 	return lmdrouter.MarshalResponse(http.StatusNoContent, nil, nil)
 }
+
+// RetrieveRevisions is a handler to retrieve the revisions of a website, latest first.
+func (h *Handler) RetrieveRevisions(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var (
+		params     listParams
+		entityPath entityParams
+	)
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	err = lmdrouter.UnmarshalRequest(req, false, &entityPath)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	limit, err := pageLimit(req, params)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	scope := revision.ListQuery(entityPath.ID, websiteType)
+
+	exclusiveStartKey, err := h.cursors.Decode(scope, params.Cursor)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	collection, page, err := h.revisions.List(ctx, entityPath.ID, websiteType, limit, exclusiveStartKey)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	nextCursor, err := h.cursors.Encode(scope, page.Next)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, listResponse{Items: collection, NextCursor: nextCursor, HasMore: page.HasMore, Total: nil, TotalApproximate: false})
+}
+
+// RetrieveRevision is a handler to retrieve a revision of a website.
+func (h *Handler) RetrieveRevision(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var params revisionParams
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	entity, err := h.revisions.Get(ctx, params.ID, websiteType, params.RevisionID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, nil, entity)
+}
+
+// RestoreRevision is a handler to roll a website back to one of its revisions, which is saved as the latest version.
+// The website is saved like an update, therefore the hostnames of the revision must still be available.
+func (h *Handler) RestoreRevision(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var (
+		params revisionParams
+		entity website
+	)
+
+	err := lmdrouter.UnmarshalRequest(req, false, &params)
+	if err != nil {
+		return lhttp.HandleError(lhttp.WrapProblem(err, http.StatusBadRequest, errUnmarshallParams, req.QueryStringParameters), nil)
+	}
+
+	stored, err := h.get(ctx, params.ID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	saved, err := h.revisions.Get(ctx, params.ID, websiteType, params.RevisionID)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = saved.Decode(&entity)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	err = entity.validate()
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	entity, err = h.update(ctx, audit.Actor(req), entity, stored, stored.Version)
+	if err != nil {
+		return lhttp.HandleError(err, nil)
+	}
+
+	return lmdrouter.MarshalResponse(http.StatusOK, lhttp.ETagHeaders(entity.Version), entity)
+}
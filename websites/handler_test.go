@@ -2,19 +2,121 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
+	"github.com/abtercms/abtercms2/pkg/audit"
+	"github.com/abtercms/abtercms2/pkg/cursor"
 	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/id"
+	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/patch"
+	"github.com/abtercms/abtercms2/pkg/revision"
 	"github.com/abtercms/abtercms2/websites/mocks"
 )
 
 func TestHandler_RetrieveCollection(t *testing.T) {
-	t.Run("fail error in retrieving collection causes 500 internal server error", func(t *testing.T) {
+	t.Run("fail invalid cursor causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodGet,
+			QueryStringParameters: map[string]string{
+				"cursor": "qux",
+			},
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail limit out of range causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		for _, limitStub := range []string{"0", "101", "-1"} {
+			// stubs
+			ctx := context.Background()
+			requestStub := events.APIGatewayProxyRequest{
+				Path:       "/websites",
+				HTTPMethod: http.MethodGet,
+				QueryStringParameters: map[string]string{
+					"limit": limitStub,
+				},
+			}
+
+			// expectations
+			expectedStatus := http.StatusBadRequest
+
+			// system under test
+			sut, _ := createTestHandler()
+
+			// execute
+			res, err := sut.RetrieveCollection(ctx, requestStub)
+
+			// asserts
+			assert.Error(t, err)
+			assert.Equal(t, expectedStatus, res.StatusCode, "limit = %s", limitStub)
+		}
+	})
+
+	t.Run("fail unsupported query parameters cause 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		paramStubs := []map[string]string{
+			{"color": "red"},
+			{"sort": "name"},
+			{"sort": "-updated_at"},
+			{"created_after": "yesterday"},
+			{"status": "deleted"},
+		}
+
+		for _, paramStub := range paramStubs {
+			// stubs
+			ctx := context.Background()
+			requestStub := events.APIGatewayProxyRequest{
+				Path:                  "/websites",
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: paramStub,
+			}
+
+			// expectations
+			expectedStatus := http.StatusBadRequest
+
+			// system under test
+			sut, _ := createTestHandler()
+
+			// execute
+			res, err := sut.RetrieveCollection(ctx, requestStub)
+
+			// asserts
+			assert.Error(t, err)
+			assert.Equal(t, expectedStatus, res.StatusCode, "params = %v", paramStub)
+		}
+	})
+
+	t.Run("fail error in counting collection causes 500 internal server error", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
@@ -23,13 +125,9 @@ func TestHandler_RetrieveCollection(t *testing.T) {
 			Path:       "/websites",
 			HTTPMethod: http.MethodGet,
 			QueryStringParameters: map[string]string{
-				"exclusive_start_key": "qux",
+				"total": "true",
 			},
 		}
-		esk := dynamo.K1("qux")
-		exclusiveStartKey := &esk
-		scannedCountStub := int32(0)
-		lastEvaluatedKeyStub := dynamo.Key{}
 
 		// expectations
 		expectedStatus := http.StatusInternalServerError
@@ -38,9 +136,41 @@ func TestHandler_RetrieveCollection(t *testing.T) {
 		sut, repoMock := createTestHandler()
 
 		// mocks
-		repoMock.On("List", ctx, limit, exclusiveStartKey, mock.Anything).
+		repoMock.On("Query", ctx, mock.AnythingOfType("dynamo.Query"), mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Count", ctx, mock.AnythingOfType("dynamo.Query")).
+			Once().
+			Return(int32(0), false, assert.AnError)
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail error in retrieving collection causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodGet,
+		}
+
+		// expectations
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, mock.AnythingOfType("dynamo.Query"), mock.Anything).
 			Once().
-			Return(lastEvaluatedKeyStub, scannedCountStub, assert.AnError)
+			Return(dynamo.Page{}, assert.AnError)
 
 		// execute
 		res, err := sut.RetrieveCollection(ctx, requestStub)
@@ -50,22 +180,93 @@ func TestHandler_RetrieveCollection(t *testing.T) {
 		assert.Equal(t, expectedStatus, res.StatusCode)
 	})
 
-	t.Run("success w/o exclusive start key", func(t *testing.T) {
+	t.Run("success /w cursor", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		exclusiveStartKey := dynamo.IndexKey(websiteKey("qux"), dynamo.GSI1, websiteType, "qux")
+		cursorStub, _ := createTestCursors().Encode(dynamo.Query{Index: dynamo.GSI1, Partition: websiteType}, exclusiveStartKey)
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodGet,
+			QueryStringParameters: map[string]string{
+				"cursor": cursorStub,
+			},
+		}
+		queryStub := dynamo.Query{
+			Index:             dynamo.GSI1,
+			Partition:         websiteType,
+			Limit:             defaultLimit,
+			ExclusiveStartKey: exclusiveStartKey,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, queryStub, mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.NotContains(t, res.Body, "next_cursor")
+		assert.NotContains(t, res.Body, "total")
+		assert.Contains(t, res.Body, `"has_more":false`)
+	})
+
+	t.Run("fail cursor of the trash causes 400 bad request", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
+		exclusiveStartKey := dynamo.IndexKey(websiteKey("qux"), dynamo.GSI1, trashType, "qux")
+		cursorStub, _ := createTestCursors().Encode(dynamo.Query{Index: dynamo.GSI1, Partition: trashType}, exclusiveStartKey)
 		requestStub := events.APIGatewayProxyRequest{
 			Path:       "/websites",
 			HTTPMethod: http.MethodGet,
 			QueryStringParameters: map[string]string{
-				"exclusive_start_key": "qux",
+				"cursor": cursorStub,
 			},
 		}
-		esk := dynamo.K1("qux")
-		exclusiveStartKey := &esk
-		scannedCountStub := int32(30)
-		lastEvaluatedKeyStub := dynamo.K1("foo")
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success w/o cursor", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodGet,
+		}
+		queryStub := dynamo.Query{
+			Index:     dynamo.GSI1,
+			Partition: websiteType,
+			Limit:     defaultLimit,
+		}
+		lastEvaluatedKeyStub := dynamo.IndexKey(websiteKey("foo"), dynamo.GSI1, websiteType, "foo")
 
 		// expectations
 		expectedStatus := http.StatusOK
@@ -74,9 +275,14 @@ func TestHandler_RetrieveCollection(t *testing.T) {
 		sut, repoMock := createTestHandler()
 
 		// mocks
-		repoMock.On("List", ctx, limit, exclusiveStartKey, mock.Anything).
+		websitesModifier := mock.MatchedBy(func(input *[]website) bool {
+			*input = append(*input, website{ID: "foo", Name: "bar", Version: 1})
+
+			return true
+		})
+		repoMock.On("Query", ctx, queryStub, websitesModifier).
 			Once().
-			Return(lastEvaluatedKeyStub, scannedCountStub, nil)
+			Return(dynamo.Page{Next: lastEvaluatedKeyStub, HasMore: true}, nil)
 
 		// execute
 		res, err := sut.RetrieveCollection(ctx, requestStub)
@@ -84,9 +290,72 @@ func TestHandler_RetrieveCollection(t *testing.T) {
 		// asserts
 		assert.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+
+		var body struct {
+			Items      []website `json:"items"`
+			NextCursor string    `json:"next_cursor"`
+			HasMore    bool      `json:"has_more"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(res.Body), &body))
+		assert.Equal(t, []website{{ID: "foo", Name: "bar", Version: 1}}, body.Items)
+		assert.True(t, body.HasMore)
+
+		nextKey, err := createTestCursors().Decode(queryStub, body.NextCursor)
+		require.NoError(t, err)
+		assert.Equal(t, lastEvaluatedKeyStub, nextKey)
 	})
+	t.Run("success /w limit and total", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		exclusiveStartKey := dynamo.IndexKey(websiteKey("qux"), dynamo.GSI1, websiteType, "qux")
+		cursorStub, _ := createTestCursors().Encode(dynamo.Query{Index: dynamo.GSI1, Partition: websiteType}, exclusiveStartKey)
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodGet,
+			QueryStringParameters: map[string]string{
+				"cursor": cursorStub,
+				"limit":  "5",
+				"total":  "true",
+			},
+		}
+		queryStub := dynamo.Query{
+			Index:             dynamo.GSI1,
+			Partition:         websiteType,
+			Limit:             5,
+			ExclusiveStartKey: exclusiveStartKey,
+		}
+		countQueryStub := dynamo.Query{
+			Index:     dynamo.GSI1,
+			Partition: websiteType,
+			Limit:     5,
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
 
-	t.Run("success /w exclusive start key", func(t *testing.T) {
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Query", ctx, queryStub, mock.Anything).
+			Once().
+			Return(dynamo.Page{}, nil)
+		repoMock.On("Count", ctx, countQueryStub).
+			Once().
+			Return(int32(1200), false, nil)
+
+		// execute
+		res, err := sut.RetrieveCollection(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"total":1200`)
+		assert.Contains(t, res.Body, `"total_approximate":true`)
+	})
+	t.Run("success /w filters and sorting", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
@@ -94,10 +363,27 @@ func TestHandler_RetrieveCollection(t *testing.T) {
 		requestStub := events.APIGatewayProxyRequest{
 			Path:       "/websites",
 			HTTPMethod: http.MethodGet,
+			QueryStringParameters: map[string]string{
+				"sort":          "-created_at",
+				"name_prefix":   "foo",
+				"created_after": "2022-06-01T10:00:00Z",
+				"status":        "inactive",
+			},
+		}
+		queryStub := dynamo.Query{
+			Index:     dynamo.GSI1,
+			Partition: websiteType,
+			SortKey: &dynamo.SortKeyCondition{
+				Op:    dynamo.KeyGreaterThanEqual,
+				Value: id.After(time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)),
+			},
+			Filters: []dynamo.Filter{
+				{Op: dynamo.FilterBeginsWith, Name: "name", Value: "foo"},
+				{Op: dynamo.FilterEqual, Name: "status", Value: statusInactive},
+			},
+			Limit:      defaultLimit,
+			Descending: true,
 		}
-		var exclusiveStartKey *dynamo.Key
-		scannedCountStub := int32(30)
-		lastEvaluatedKeyStub := dynamo.K1("foo")
 
 		// expectations
 		expectedStatus := http.StatusOK
@@ -106,9 +392,9 @@ func TestHandler_RetrieveCollection(t *testing.T) {
 		sut, repoMock := createTestHandler()
 
 		// mocks
-		repoMock.On("List", ctx, limit, exclusiveStartKey, mock.Anything).
+		repoMock.On("Query", ctx, queryStub, mock.Anything).
 			Once().
-			Return(lastEvaluatedKeyStub, scannedCountStub, nil)
+			Return(dynamo.Page{}, nil)
 
 		// execute
 		res, err := sut.RetrieveCollection(ctx, requestStub)
@@ -138,92 +424,1205 @@ func TestHandler_CreateEntity(t *testing.T) {
 		sut, _ := createTestHandler()
 
 		// execute
-		res, err := sut.CreateEntity(ctx, requestStub)
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail entity with existing id causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"pk":"foo","name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail unknown status causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"name":"bar","status":"baz"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail invalid fields cause 422 unprocessable entity listing every field", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"name":"","status":"baz"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+		expectedParams := []lhttp.InvalidParam{
+			{Name: "name", Reason: "is required"},
+			{Name: "status", Reason: "must be one of: active, inactive"},
+		}
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+
+		var body lhttp.Problem
+		require.NoError(t, json.Unmarshal([]byte(res.Body), &body))
+		assert.Equal(t, expectedParams, body.InvalidParams)
+	})
+
+	t.Run("fail error in creating entity causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("CreateGuarded", ctx, mock.AnythingOfType("website"), mock.AnythingOfType("dynamo.Guards")).
+			Once().
+			Return(assert.AnError)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"name":"bar","created_at":"2000-01-01T00:00:00Z","created_by":"mallory"}`,
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "alice"}},
+			},
+		}
+
+		// expectations
+		expectedStatus := http.StatusCreated
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		websiteMatcher := mock.MatchedBy(func(input website) bool {
+			createdAt, err := id.Time(input.ID)
+
+			return err == nil &&
+				input.CreatedAt.Equal(createdAt) &&
+				input.UpdatedAt.Equal(createdAt) &&
+				input.CreatedBy == "alice" &&
+				input.UpdatedBy == "alice"
+		})
+		repoMock.On("CreateGuarded", ctx, websiteMatcher, dynamo.Guards{Add: []interface{}{}, Remove: nil, Keep: nil}).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail too many hostnames causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		hostnames := make([]string, 0, maxHostnames+1)
+
+		for i := 0; i <= maxHostnames; i++ {
+			hostnames = append(hostnames, fmt.Sprintf("%q", fmt.Sprintf("site%d.bar.com", i)))
+		}
+
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"name":"bar","hostnames":[` + strings.Join(hostnames, ",") + `]}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"hostnames"`)
+	})
+
+	t.Run("fail invalid hostname causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"name":"bar","hostnames":["bar.com","www.*.bar.com"]}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"name":"hostnames[1]"`)
+	})
+
+	t.Run("fail taken hostname causes 409 conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"name":"bar","hostnames":["bar.com"]}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusConflict
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("CreateGuarded", ctx, mock.AnythingOfType("website"), mock.AnythingOfType("dynamo.Guards")).
+			Once().
+			Return(lhttp.NewProblem(http.StatusConflict, "unique value already taken"))
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success /w hostnames", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites",
+			HTTPMethod: http.MethodPost,
+			Body:       `{"name":"bar","hostnames":["Bar.com","*.bar.com","bar.com."]}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusCreated
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		websiteMatcher := mock.MatchedBy(func(input website) bool {
+			return assert.ObjectsAreEqual([]string{"bar.com", "*.bar.com"}, input.Hostnames)
+		})
+		guardsMatcher := mock.MatchedBy(func(input dynamo.Guards) bool {
+			return len(input.Add) == 2 &&
+				input.Add[0].(hostGuard).Hostname == "HOST#bar.com" &&
+				input.Add[1].(hostGuard).Hostname == "HOST#*.bar.com" &&
+				input.Add[1].(hostGuard).SK == "HOST" &&
+				input.Add[1].(hostGuard).WebsiteID != ""
+		})
+		repoMock.On("CreateGuarded", ctx, websiteMatcher, guardsMatcher).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.CreateEntity(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"hostnames":["bar.com","*.bar.com"]`)
+	})
+}
+
+func TestHandler_RetrieveEntity(t *testing.T) {
+	t.Run("fail missing id in query parameters causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/foo",
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{},
+		}
+
+		// expectation
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail error in retrieving entity causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodGet,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+		}
+		keyStub := websiteKey("foo")
+
+		// expectation
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, keyStub, mock.Anything).
+			Once().
+			Return(false, assert.AnError)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail retrieving trashed entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodGet,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+		}
+		keyStub := websiteKey("foo")
+		deletedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+		// expectation
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		websiteModifier := mock.MatchedBy(func(input *website) bool {
+			*input = website{ID: "foo", Name: "bar", Status: statusActive, Version: 2, DeletedAt: &deletedAt}
+
+			return true
+		})
+		repoMock.On("Find", ctx, keyStub, websiteModifier).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail retrieving empty entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodGet,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+		}
+		keyStub := websiteKey("foo")
+
+		// expectation
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, keyStub, mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodGet,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+		}
+		keyStub := websiteKey("foo")
+
+		// expectation
+		expectedStatus := http.StatusOK
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		websiteModifier := mock.MatchedBy(func(input *website) bool {
+			input.ID = "foo"
+			input.Name = "bar"
+			input.Version = 2
+
+			return true
+		})
+		repoMock.On("Find", ctx, keyStub, websiteModifier).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RetrieveEntity(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, `"2"`, res.Headers["ETag"])
+	})
+}
+
+func TestHandler_UpdateEntity(t *testing.T) {
+	t.Run("fail error parsing request body causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPut,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Body: `{"pk":"foo,"name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail id in request body not matching id in path causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPut,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Body: `{"pk":"foo2","name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail empty id in path causes 400 bad request", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:           "/websites/foo",
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{},
+			Body:           `{"pk":"foo","name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusBadRequest
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail error in updating entity causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPut,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"If-Match": `"3"`,
+			},
+			Body: `{"pk":"foo","name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("foo"), storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("UpdateGuarded", ctx, mock.AnythingOfType("website"), int64(3), mock.AnythingOfType("dynamo.Guards")).
+			Once().
+			Return(int64(0), assert.AnError)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail missing If-Match header causes 428 precondition required", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPut,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Body: `{"pk":"foo","name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusPreconditionRequired
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail missing entity causes 404 not found", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPut,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"If-Match": `"3"`,
+			},
+			Body: `{"pk":"foo","name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusNotFound
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("foo"), mock.Anything).
+			Once().
+			Return(false, nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail stale version causes 412 precondition failed", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPut,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"if-match": `"3"`,
+			},
+			Body: `{"pk":"foo","name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusPreconditionFailed
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("foo"), storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("UpdateGuarded", ctx, mock.AnythingOfType("website"), int64(3), mock.AnythingOfType("dynamo.Guards")).
+			Once().
+			Return(int64(0), lhttp.NewProblem(http.StatusPreconditionFailed, "stale"))
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPut,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"If-Match": `"3"`,
+			},
+			Body: `{"pk":"foo","name":"bar","hostnames":["bar.com","shop.bar.com"],"created_by":"mallory"}`,
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Authorizer: map[string]interface{}{"principalId": "bob"},
+			},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedETag := `"4"`
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, websiteKey("foo"), storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		websiteMatcher := mock.MatchedBy(func(input website) bool {
+			return input.CreatedBy == "alice" &&
+				input.UpdatedBy == "bob" &&
+				input.UpdatedAt.After(input.CreatedAt)
+		})
+		guardsStub := dynamo.Guards{
+			Add:    []interface{}{hostGuard{Hostname: "HOST#shop.bar.com", SK: "HOST", WebsiteID: "foo"}},
+			Remove: []dynamo.Key{hostKey("www.bar.com")},
+			Keep:   nil,
+		}
+		repoMock.On("UpdateGuarded", ctx, websiteMatcher, int64(3), guardsStub).
+			Once().
+			Return(int64(4), nil)
+
+		// execute
+		res, err := sut.UpdateEntity(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, expectedETag, res.Headers["ETag"])
+	})
+}
+
+func TestHandler_PatchEntity(t *testing.T) {
+	t.Run("fail missing If-Match header causes 428 precondition required", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPatch,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"Content-Type": "application/merge-patch+json",
+			},
+			Body: `{"name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusPreconditionRequired
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail unsupported content type causes 415 unsupported media type", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPatch,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+				"If-Match":     `"3"`,
+			},
+			Body: `{"name":"bar"}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnsupportedMediaType
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail patching primary key causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPatch,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"Content-Type": "application/json-patch+json",
+				"If-Match":     `"3"`,
+			},
+			Body: `[{"op":"replace","path":"/pk","value":"bar"}]`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail patching unknown status causes 422 unprocessable entity", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPatch,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"Content-Type": "application/merge-patch+json",
+				"If-Match":     `"3"`,
+			},
+			Body: `{"status":null}`,
+		}
+
+		// expectations
+		expectedStatus := http.StatusUnprocessableEntity
+
+		// system under test
+		sut, _ := createTestHandler()
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail error in patching entity causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPatch,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"Content-Type": "application/merge-patch+json",
+				"If-Match":     `"3"`,
+			},
+			Body: `{"name":"bar"}`,
+		}
+		keyStub := websiteKey("foo")
+
+		// expectations
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Patch", ctx, keyStub, int64(3), mock.Anything, mock.Anything).
+			Once().
+			Return(int64(0), assert.AnError)
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodPatch,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			Headers: map[string]string{
+				"content-type": "application/merge-patch+json",
+				"If-Match":     `"3"`,
+			},
+			Body: `{"name":"bar"}`,
+		}
+		keyStub := websiteKey("foo")
+		opsStub := []patch.Operation{
+			{Op: patch.OpSet, Path: patch.Path{"name"}, Value: "bar"},
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedETag := `"4"`
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		websiteModifier := mock.MatchedBy(func(input *website) bool {
+			input.ID = "foo"
+			input.Name = "bar"
+			input.Status = statusActive
+			input.Fields = createTestAudit()
+
+			return true
+		})
+		opsMatcher := mock.MatchedBy(func(input []patch.Operation) bool {
+			return len(input) == 3 &&
+				assert.ObjectsAreEqual(opsStub, input[:1]) &&
+				input[1].Path.String() == "/updated_at" &&
+				assert.ObjectsAreEqual(patch.Operation{Op: patch.OpSet, Path: patch.Path{"updated_by"}, Value: audit.Anonymous}, input[2])
+		})
+		repoMock.On("Patch", ctx, keyStub, int64(3), opsMatcher, websiteModifier).
+			Once().
+			Return(int64(4), nil)
+
+		// execute
+		res, err := sut.PatchEntity(ctx, requestStub)
+
+		// asserts
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, expectedETag, res.Headers["ETag"])
+		assert.JSONEq(t, `{"pk":"foo","name":"bar","status":"active","version":4,`+auditJSON+`}`, res.Body)
+	})
+}
+
+func TestHandler_DeleteEntity(t *testing.T) {
+	t.Run("fail error in trashing item causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodDelete,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+		}
+		keyStub := websiteKey("foo")
+
+		// expectations
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, keyStub, storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("TrashGuarded", ctx, keyStub, trashKeys("foo"), mock.AnythingOfType("time.Time"), time.Time{}, mock.Anything).
+			Once().
+			Return(assert.AnError)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("fail error in purging item causes 500 internal server error", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodDelete,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			QueryStringParameters: map[string]string{
+				"purge": "true",
+			},
+		}
+		keyStub := websiteKey("foo")
+
+		// expectations
+		expectedStatus := http.StatusInternalServerError
+
+		// system under test
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, keyStub, storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("DeleteGuarded", ctx, keyStub, mock.Anything).
+			Once().
+			Return(assert.AnError)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+	})
+
+	t.Run("success trashing /w retention", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodDelete,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+		}
+		keyStub := websiteKey("foo")
+		retention := 30 * 24 * time.Hour
+
+		// expectations
+		expectedStatus := http.StatusNoContent
+
+		// system under test
+		repoMock := &mocks.Repo{}
+		sut := NewHandler(repoMock, createTestCursors(), &mocks.Revisions{}, retention)
+
+		// mocks
+		var deletedAt time.Time
+
+		deletedAtMatcher := mock.MatchedBy(func(t time.Time) bool {
+			deletedAt = t
+
+			return !t.IsZero()
+		})
+		expiresAtMatcher := mock.MatchedBy(func(t time.Time) bool {
+			return t.Equal(deletedAt.Add(retention))
+		})
+		repoMock.On("Find", ctx, keyStub, storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("TrashGuarded", ctx, keyStub, trashKeys("foo"), deletedAtMatcher, expiresAtMatcher, hostKeys([]string{"bar.com", "www.bar.com"})).
+			Once().
+			Return(nil)
+
+		// execute
+		res, err := sut.DeleteEntity(ctx, requestStub)
 
 		// asserts
-		assert.Error(t, err)
+		assert.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
 	})
 
-	t.Run("fail entity with existing id causes 400 bad request", func(t *testing.T) {
+	t.Run("success purging", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites",
-			HTTPMethod: http.MethodPost,
-			Body:       `{"pk":"foo","name":"bar"}`,
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodDelete,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			QueryStringParameters: map[string]string{
+				"purge": "true",
+			},
 		}
+		keyStub := websiteKey("foo")
 
 		// expectations
-		expectedStatus := http.StatusBadRequest
+		expectedStatus := http.StatusNoContent
 
 		// system under test
-		sut, _ := createTestHandler()
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, keyStub, storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("DeleteGuarded", ctx, keyStub, append([]dynamo.Key{snapshotKey("foo")}, hostKeys([]string{"bar.com", "www.bar.com"})...)).
+			Once().
+			Return(nil)
 
 		// execute
-		res, err := sut.CreateEntity(ctx, requestStub)
+		res, err := sut.DeleteEntity(ctx, requestStub)
 
 		// asserts
-		assert.Error(t, err)
+		assert.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
 	})
 
-	t.Run("fail error in creating entity causes 500 internal server error", func(t *testing.T) {
+	t.Run("fail trashing trashed item causes 404 not found", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites",
-			HTTPMethod: http.MethodPost,
-			Body:       `{"name":"bar"}`,
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodDelete,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
 		}
+		keyStub := websiteKey("foo")
 
 		// expectations
-		expectedStatus := http.StatusInternalServerError
+		expectedStatus := http.StatusNotFound
 
 		// system under test
 		sut, repoMock := createTestHandler()
 
 		// mocks
-		repoMock.On("Create", ctx, mock.AnythingOfType("website")).
+		repoMock.On("Find", ctx, keyStub, trashedWebsiteModifier("foo")).
 			Once().
-			Return(assert.AnError)
+			Return(true, nil)
 
 		// execute
-		res, err := sut.CreateEntity(ctx, requestStub)
+		res, err := sut.DeleteEntity(ctx, requestStub)
 
 		// asserts
 		assert.Error(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
 	})
 
-	t.Run("success", func(t *testing.T) {
+	t.Run("success purging trashed item keeps hostnames released", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites",
-			HTTPMethod: http.MethodPost,
-			Body:       `{"name":"bar"}`,
+			Path:       "/websites/foo",
+			HTTPMethod: http.MethodDelete,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
+			QueryStringParameters: map[string]string{
+				"purge": "true",
+			},
 		}
+		keyStub := websiteKey("foo")
 
 		// expectations
-		expectedStatus := http.StatusCreated
+		expectedStatus := http.StatusNoContent
 
 		// system under test
 		sut, repoMock := createTestHandler()
 
 		// mocks
-		repoMock.On("Create", ctx, mock.AnythingOfType("website")).
+		repoMock.On("Find", ctx, keyStub, trashedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("DeleteGuarded", ctx, keyStub, []dynamo.Key{snapshotKey("foo")}).
 			Once().
 			Return(nil)
 
 		// execute
-		res, err := sut.CreateEntity(ctx, requestStub)
+		res, err := sut.DeleteEntity(ctx, requestStub)
 
 		// asserts
 		assert.NoError(t, err)
@@ -231,92 +1630,104 @@ func TestHandler_CreateEntity(t *testing.T) {
 	})
 }
 
-func TestHandler_RetrieveEntity(t *testing.T) {
-	t.Run("fail missing id in query parameters causes 400 bad request", func(t *testing.T) {
+func TestHandler_RetrieveTrash(t *testing.T) {
+	t.Run("fail limit out of range causes 400 bad request", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:           "/websites/foo",
-			HTTPMethod:     http.MethodGet,
-			PathParameters: map[string]string{},
+			Path:       "/websites/trash",
+			HTTPMethod: http.MethodGet,
+			QueryStringParameters: map[string]string{
+				"limit": "1000",
+			},
 		}
 
-		// expectation
+		// expectations
 		expectedStatus := http.StatusBadRequest
 
 		// system under test
 		sut, _ := createTestHandler()
 
 		// execute
-		res, err := sut.RetrieveEntity(ctx, requestStub)
+		res, err := sut.RetrieveTrash(ctx, requestStub)
 
 		// asserts
 		assert.Error(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
 	})
 
-	t.Run("fail error in retrieving entity causes 500 internal server error", func(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
+			Path:       "/websites/trash",
 			HTTPMethod: http.MethodGet,
-			PathParameters: map[string]string{
-				"id": "foo",
-			},
 		}
-		keyStub := dynamo.K1("foo")
+		queryStub := dynamo.Query{
+			Index:     dynamo.GSI1,
+			Partition: trashType,
+			Limit:     defaultLimit,
+		}
+		deletedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
 
-		// expectation
-		expectedStatus := http.StatusInternalServerError
+		// expectations
+		expectedStatus := http.StatusOK
 
 		// system under test
 		sut, repoMock := createTestHandler()
 
 		// mocks
-		repoMock.On("Get", ctx, keyStub, mock.Anything).
+		websitesModifier := mock.MatchedBy(func(input *[]website) bool {
+			*input = append(*input, website{ID: "foo", Name: "bar", Status: statusActive, Version: 2, DeletedAt: &deletedAt})
+
+			return true
+		})
+		repoMock.On("Query", ctx, queryStub, websitesModifier).
 			Once().
-			Return(assert.AnError)
+			Return(dynamo.Page{}, nil)
 
 		// execute
-		res, err := sut.RetrieveEntity(ctx, requestStub)
+		res, err := sut.RetrieveTrash(ctx, requestStub)
 
 		// asserts
-		assert.Error(t, err)
+		assert.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"deleted_at":"2022-06-01T10:00:00Z"`)
 	})
+}
 
-	t.Run("fail retrieving empty entity causes 404 not found", func(t *testing.T) {
+func TestHandler_RestoreEntity(t *testing.T) {
+	t.Run("fail item not in trash causes 404 not found", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
-			HTTPMethod: http.MethodGet,
+			Path:       "/websites/foo/restore",
+			HTTPMethod: http.MethodPost,
 			PathParameters: map[string]string{
-				"id": "foo",
+				"website": "foo",
 			},
 		}
-		keyStub := dynamo.K1("foo")
+		keyStub := websiteKey("foo")
 
-		// expectation
+		// expectations
 		expectedStatus := http.StatusNotFound
 
 		// system under test
 		sut, repoMock := createTestHandler()
 
 		// mocks
-		repoMock.On("Get", ctx, keyStub, mock.Anything).
+		repoMock.On("Find", ctx, keyStub, storedWebsiteModifier("foo")).
 			Once().
-			Return(nil)
+			Return(true, nil)
 
 		// execute
-		res, err := sut.RetrieveEntity(ctx, requestStub)
+		res, err := sut.RestoreEntity(ctx, requestStub)
 
 		// asserts
 		assert.Error(t, err)
@@ -329,262 +1740,450 @@ func TestHandler_RetrieveEntity(t *testing.T) {
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
-			HTTPMethod: http.MethodGet,
+			Path:       "/websites/foo/restore",
+			HTTPMethod: http.MethodPost,
 			PathParameters: map[string]string{
-				"id": "foo",
+				"website": "foo",
 			},
 		}
-		keyStub := dynamo.K1("foo")
+		keyStub := websiteKey("foo")
+		indexStub := dynamo.Keys{SK: websiteType, GSI1PK: websiteType, GSI1SK: "foo"}
 
-		// expectation
+		// expectations
 		expectedStatus := http.StatusOK
+		expectedETag := `"5"`
 
 		// system under test
 		sut, repoMock := createTestHandler()
 
 		// mocks
 		websiteModifier := mock.MatchedBy(func(input *website) bool {
-			input.ID = "foo"
-			input.Name = "bar"
+			*input = website{ID: "foo", Name: "bar", Status: statusActive, Version: 5, Fields: createTestAudit()}
 
 			return true
 		})
-		repoMock.On("Get", ctx, keyStub, websiteModifier).
+		guardsStub := []interface{}{
+			hostGuard{Hostname: "HOST#bar.com", SK: "HOST", WebsiteID: "foo"},
+			hostGuard{Hostname: "HOST#www.bar.com", SK: "HOST", WebsiteID: "foo"},
+		}
+		repoMock.On("Find", ctx, keyStub, trashedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("RestoreGuarded", ctx, keyStub, indexStub, guardsStub, websiteModifier).
 			Once().
 			Return(nil)
 
 		// execute
-		res, err := sut.RetrieveEntity(ctx, requestStub)
+		res, err := sut.RestoreEntity(ctx, requestStub)
 
 		// asserts
 		assert.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, expectedETag, res.Headers["ETag"])
+		assert.JSONEq(t, `{"pk":"foo","name":"bar","status":"active","version":5,`+auditJSON+`}`, res.Body)
 	})
 }
 
-func TestHandler_UpdateEntity(t *testing.T) {
-	t.Run("fail error parsing request body causes 400 bad request", func(t *testing.T) {
+func TestHandler_PublishEntity(t *testing.T) {
+	t.Run("fail trashed item causes 404 not found", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
-			HTTPMethod: http.MethodPut,
+			Path:       "/websites/foo/publish",
+			HTTPMethod: http.MethodPost,
 			PathParameters: map[string]string{
-				"id": "foo",
+				"website": "foo",
 			},
-			Body: `{"pk":"foo,"name":"bar"}`,
 		}
+		keyStub := websiteKey("foo")
 
 		// expectations
-		expectedStatus := http.StatusBadRequest
+		expectedStatus := http.StatusNotFound
 
 		// system under test
-		sut, _ := createTestHandler()
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, keyStub, trashedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
 
 		// execute
-		res, err := sut.UpdateEntity(ctx, requestStub)
+		res, err := sut.PublishEntity(ctx, requestStub)
 
 		// asserts
 		assert.Error(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
 	})
 
-	t.Run("fail id in request body not matching id in path causes 400 bad request", func(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
-			HTTPMethod: http.MethodPut,
+			Path:       "/websites/foo/publish",
+			HTTPMethod: http.MethodPost,
 			PathParameters: map[string]string{
-				"id": "foo",
+				"website": "foo",
 			},
-			Body: `{"pk":"foo2","name":"bar"}`,
 		}
+		keyStub := websiteKey("foo")
 
 		// expectations
-		expectedStatus := http.StatusBadRequest
+		expectedStatus := http.StatusOK
 
 		// system under test
-		sut, _ := createTestHandler()
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		snapshotMatcher := mock.MatchedBy(func(input snapshot) bool {
+			return input.ID == "foo" && input.SK == publishedType && input.Name == "bar" && input.Version == 3
+		})
+		repoMock.On("Find", ctx, keyStub, storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		repoMock.On("PutGuarded", ctx, snapshotMatcher, dynamo.Guards{}).
+			Once().
+			Return(nil)
 
 		// execute
-		res, err := sut.UpdateEntity(ctx, requestStub)
+		res, err := sut.PublishEntity(ctx, requestStub)
 
 		// asserts
-		assert.Error(t, err)
+		assert.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"published_by":"anonymous"`)
+		assert.NotContains(t, res.Body, publishedType)
+		repoMock.AssertExpectations(t)
 	})
+}
 
-	t.Run("fail empty id in path causes 400 bad request", func(t *testing.T) {
+func TestHandler_UnpublishEntity(t *testing.T) {
+	t.Run("fail unpublished item causes 404 not found", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:           "/websites/foo",
-			HTTPMethod:     http.MethodPut,
-			PathParameters: map[string]string{},
-			Body:           `{"pk":"foo","name":"bar"}`,
+			Path:       "/websites/foo/unpublish",
+			HTTPMethod: http.MethodPost,
+			PathParameters: map[string]string{
+				"website": "foo",
+			},
 		}
 
 		// expectations
-		expectedStatus := http.StatusBadRequest
+		expectedStatus := http.StatusNotFound
 
 		// system under test
-		sut, _ := createTestHandler()
+		sut, repoMock := createTestHandler()
+
+		// mocks
+		repoMock.On("Find", ctx, snapshotKey("foo"), mock.Anything).
+			Once().
+			Return(false, nil)
 
 		// execute
-		res, err := sut.UpdateEntity(ctx, requestStub)
+		res, err := sut.UnpublishEntity(ctx, requestStub)
 
 		// asserts
 		assert.Error(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
 	})
 
-	t.Run("fail error in updating entity causes 500 internal server error", func(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
-			HTTPMethod: http.MethodPut,
+			Path:       "/websites/foo/unpublish",
+			HTTPMethod: http.MethodPost,
 			PathParameters: map[string]string{
-				"id": "foo",
+				"website": "foo",
 			},
-			Body: `{"pk":"foo","name":"bar"}`,
 		}
 
 		// expectations
-		expectedStatus := http.StatusInternalServerError
+		expectedStatus := http.StatusNoContent
 
 		// system under test
 		sut, repoMock := createTestHandler()
 
 		// mocks
-		repoMock.On("Update", ctx, mock.AnythingOfType("website")).
+		snapshotModifier := mock.MatchedBy(func(input *snapshot) bool {
+			*input = snapshot{ID: "foo", SK: publishedType, Name: "bar", Version: 3, PublishedAt: time.Now(), PublishedBy: "alice"}
+
+			return true
+		})
+		repoMock.On("Find", ctx, snapshotKey("foo"), snapshotModifier).
 			Once().
-			Return(assert.AnError)
+			Return(true, nil)
+		repoMock.On("DeleteGuarded", ctx, snapshotKey("foo"), []dynamo.Key(nil)).
+			Once().
+			Return(nil)
 
 		// execute
-		res, err := sut.UpdateEntity(ctx, requestStub)
+		res, err := sut.UnpublishEntity(ctx, requestStub)
 
 		// asserts
-		assert.Error(t, err)
+		assert.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+		repoMock.AssertExpectations(t)
 	})
+}
 
+func TestHandler_RetrieveRevisions(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
-			HTTPMethod: http.MethodPut,
+			Path:       "/websites/foo/revisions",
+			HTTPMethod: http.MethodGet,
 			PathParameters: map[string]string{
-				"id": "foo",
+				"website": "foo",
 			},
-			Body: `{"pk":"foo","name":"bar"}`,
 		}
+		revisionsStub := []revision.Revision{{ID: "rev1", Version: 2, Data: json.RawMessage(`{"name":"bar"}`)}}
 
 		// expectations
 		expectedStatus := http.StatusOK
 
 		// system under test
-		sut, repoMock := createTestHandler()
+		sut, _, revisionsMock := createTestRevisionsHandler()
 
 		// mocks
-		repoMock.On("Update", ctx, mock.AnythingOfType("website")).
+		revisionsMock.On("List", ctx, "foo", websiteType, defaultLimit, dynamo.Key(nil)).
 			Once().
-			Return(nil)
+			Return(revisionsStub, dynamo.Page{}, nil)
 
 		// execute
-		res, err := sut.UpdateEntity(ctx, requestStub)
+		res, err := sut.RetrieveRevisions(ctx, requestStub)
 
 		// asserts
-		assert.NoError(t, err)
+		require.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Contains(t, res.Body, `"data":{"name":"bar"}`)
+		revisionsMock.AssertExpectations(t)
 	})
 }
 
-func TestHandler_DeleteEntity(t *testing.T) {
-	t.Run("fail error in deleting item causes 500 internal server error", func(t *testing.T) {
+func TestHandler_RetrieveRevision(t *testing.T) {
+	t.Run("fail missing revision causes 404 not found", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
-			HTTPMethod: http.MethodDelete,
+			Path:       "/websites/foo/revisions/rev1",
+			HTTPMethod: http.MethodGet,
 			PathParameters: map[string]string{
-				"id": "foo",
+				"website": "foo",
+				"rev":     "rev1",
 			},
 		}
-		keyStub := dynamo.K1("foo")
 
 		// expectations
-		expectedStatus := http.StatusInternalServerError
+		expectedStatus := http.StatusNotFound
 
 		// system under test
-		sut, repoMock := createTestHandler()
+		sut, _, revisionsMock := createTestRevisionsHandler()
 
 		// mocks
-		repoMock.On("Delete", ctx, keyStub).
+		revisionsMock.On("Get", ctx, "foo", websiteType, "rev1").
 			Once().
-			Return(assert.AnError)
+			Return(revision.Revision{}, lhttp.NewProblem(http.StatusNotFound, "revision not found in storage: rev1"))
 
 		// execute
-		res, err := sut.DeleteEntity(ctx, requestStub)
+		res, err := sut.RetrieveRevision(ctx, requestStub)
 
 		// asserts
 		assert.Error(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
 	})
+}
 
-	t.Run("success", func(t *testing.T) {
+func TestHandler_RestoreRevision(t *testing.T) {
+	t.Run("fail trashed entity causes 404 not found", func(t *testing.T) {
 		t.Parallel()
 
 		// stubs
 		ctx := context.Background()
 		requestStub := events.APIGatewayProxyRequest{
-			Path:       "/websites/foo",
-			HTTPMethod: http.MethodDelete,
+			Path:       "/websites/foo/revisions/rev1/restore",
+			HTTPMethod: http.MethodPost,
 			PathParameters: map[string]string{
-				"id": "foo",
+				"website": "foo",
+				"rev":     "rev1",
 			},
 		}
-		keyStub := dynamo.K1("foo")
 
 		// expectations
-		expectedStatus := http.StatusNoContent
+		expectedStatus := http.StatusNotFound
 
 		// system under test
-		sut, repoMock := createTestHandler()
+		sut, repoMock, revisionsMock := createTestRevisionsHandler()
+
+		// mocks
+		trashedModifier := mock.MatchedBy(func(input *website) bool {
+			deletedAt := time.Date(2022, 6, 3, 10, 0, 0, 0, time.UTC)
+			*input = website{ID: "foo", DeletedAt: &deletedAt}
+
+			return true
+		})
+		repoMock.On("Find", ctx, websiteKey("foo"), trashedModifier).
+			Once().
+			Return(true, nil)
+
+		// execute
+		res, err := sut.RestoreRevision(ctx, requestStub)
+
+		// asserts
+		assert.Error(t, err)
+		assert.Equal(t, expectedStatus, res.StatusCode)
+		revisionsMock.AssertExpectations(t)
+	})
+
+	t.Run("success /w hostnames of revision", func(t *testing.T) {
+		t.Parallel()
+
+		// stubs
+		ctx := context.Background()
+		requestStub := events.APIGatewayProxyRequest{
+			Path:       "/websites/foo/revisions/rev1/restore",
+			HTTPMethod: http.MethodPost,
+			PathParameters: map[string]string{
+				"website": "foo",
+				"rev":     "rev1",
+			},
+		}
+		revisionStub := revision.Revision{
+			ID:      "rev1",
+			Version: 1,
+			Data:    json.RawMessage(`{"pk":"foo","name":"old","status":"active","hostnames":["bar.com"],"version":1}`),
+		}
+
+		// expectations
+		expectedStatus := http.StatusOK
+		expectedETag := `"4"`
+		expectedGuards := dynamo.Guards{
+			Add:    []interface{}{},
+			Remove: []dynamo.Key{hostKey("www.bar.com")},
+			Keep:   nil,
+		}
+
+		// system under test
+		sut, repoMock, revisionsMock := createTestRevisionsHandler()
 
 		// mocks
-		repoMock.On("Delete", ctx, keyStub).
+		websiteMatcher := mock.MatchedBy(func(input website) bool {
+			return input.ID == "foo" && input.Name == "old" && input.SK == websiteType && input.CreatedBy == "alice"
+		})
+		repoMock.On("Find", ctx, websiteKey("foo"), storedWebsiteModifier("foo")).
+			Once().
+			Return(true, nil)
+		revisionsMock.On("Get", ctx, "foo", websiteType, "rev1").
+			Once().
+			Return(revisionStub, nil)
+		repoMock.On("UpdateGuarded", ctx, websiteMatcher, int64(3), expectedGuards).
+			Once().
+			Return(int64(4), nil)
+		revisionsMock.On("Record", ctx, "foo", websiteType, int64(4), "anonymous", websiteMatcher).
 			Once().
 			Return(nil)
 
 		// execute
-		res, err := sut.DeleteEntity(ctx, requestStub)
+		res, err := sut.RestoreRevision(ctx, requestStub)
 
 		// asserts
-		assert.NoError(t, err)
+		require.NoError(t, err)
 		assert.Equal(t, expectedStatus, res.StatusCode)
+		assert.Equal(t, expectedETag, res.Headers["ETag"])
+		repoMock.AssertExpectations(t)
+		revisionsMock.AssertExpectations(t)
 	})
 }
 
 func createTestHandler() (*Handler, *mocks.Repo) {
 	repoMock := &mocks.Repo{}
+	revisionsMock := &mocks.Revisions{}
+	revisionsMock.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Maybe().
+		Return(nil)
+	revisionsMock.On("Purge", mock.Anything, mock.Anything, mock.Anything).
+		Maybe().
+		Return(nil)
 
-	sut := NewHandler(repoMock)
+	sut := NewHandler(repoMock, createTestCursors(), revisionsMock, 0)
 
 	return sut, repoMock
 }
+
+func createTestRevisionsHandler() (*Handler, *mocks.Repo, *mocks.Revisions) {
+	repoMock := &mocks.Repo{}
+	revisionsMock := &mocks.Revisions{}
+
+	sut := NewHandler(repoMock, createTestCursors(), revisionsMock, 0)
+
+	return sut, repoMock, revisionsMock
+}
+
+// auditJSON is the JSON representation of createTestAudit.
+const auditJSON = `"created_at":"2022-06-01T10:00:00Z","updated_at":"2022-06-02T10:00:00Z","created_by":"alice","updated_by":"bob"`
+
+// createTestAudit returns the audit fields of a website created by alice and changed by bob.
+func createTestAudit() audit.Fields {
+	return audit.Fields{
+		CreatedAt: time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2022, 6, 2, 10, 0, 0, 0, time.UTC),
+		CreatedBy: "alice",
+		UpdatedBy: "bob",
+	}
+}
+
+// storedWebsiteModifier fills the website read from the repository with a stored website created by alice.
+func storedWebsiteModifier(id string) interface{} {
+	return mock.MatchedBy(func(input *website) bool {
+		createdAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+		*input = website{
+			ID:        id,
+			Name:      "bar",
+			Status:    statusActive,
+			Hostnames: []string{"bar.com", "www.bar.com"},
+			Version:   3,
+			Fields:    audit.Fields{CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "alice", UpdatedBy: "alice"},
+		}
+
+		return true
+	})
+}
+
+// trashedWebsiteModifier fills the website read from the repository with a trashed website, which released its hostnames.
+func trashedWebsiteModifier(id string) interface{} {
+	return mock.MatchedBy(func(input *website) bool {
+		deletedAt := time.Date(2022, 6, 2, 10, 0, 0, 0, time.UTC)
+
+		*input = website{
+			ID:        id,
+			Name:      "bar",
+			Status:    statusActive,
+			Hostnames: []string{"bar.com", "www.bar.com"},
+			Version:   4,
+			DeletedAt: &deletedAt,
+		}
+
+		return true
+	})
+}
+
+func createTestCursors() *cursor.Codec {
+	return cursor.NewCodec([]byte("secret"))
+}
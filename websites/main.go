@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/aquasecurity/lmdrouter"
 	"github.com/aws/aws-lambda-go/events"
@@ -12,24 +14,72 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
-	"github.com/abtercms/abtercms2/pkg/dynamo"
+	"github.com/abtercms/abtercms2/pkg/cursor"
 	"github.com/abtercms/abtercms2/pkg/lhttp"
+	"github.com/abtercms/abtercms2/pkg/revision"
+	"github.com/abtercms/abtercms2/pkg/table"
 )
 
 const (
-	limit int32 = 25
+	defaultLimit int32 = 25
+	minLimit     int32 = 1
+	maxLimit     int32 = 100
+	limitParam         = "limit"
+
+	// sortCreatedAt is the only field websites can be sorted by, prefixed with "-" for descending order.
+	sortCreatedAt = "created_at"
+
+	statusActive   = "active"
+	statusInactive = "inactive"
+
+	// websiteType is the sort key of websites and the partition of the index listing them.
+	websiteType = "WEBSITE"
+
+	// trashType is the partition of the index listing trashed websites.
+	trashType = "TRASH#WEBSITE"
+
+	// publishedType is the sort key of the published snapshots of websites, stored in the partition of their website.
+	publishedType = "PUBLISHED#WEBSITE"
+
+	// hostType prefixes the partition of the guards reserving hostnames, which are unique across websites.
+	hostType = "HOST#"
+
+	// hostGuardType is the sort key of the guards reserving hostnames.
+	hostGuardType = "HOST"
+
+	// maxHostnames is the number of hostnames a website can have at most.
+	maxHostnames = 20
 
 	EnvAwsRegion                = "AWS_REGION"
 	EnvTableName                = "TABLE_NAME"
 	EnvAwsSamLocal              = "AWS_SAM_LOCAL"
 	EnvAwsDynamoDBLocalEndpoint = "AWS_DYNAMODB_LOCAL_ENDPOINT"
+	EnvCursorSecret             = "CURSOR_SECRET"
+	EnvTrashRetentionDays       = "TRASH_RETENTION_DAYS"
+	EnvDatabaseURL              = "DATABASE_URL"
+	EnvRepository               = "REPOSITORY"
 
 	trueString = "true"
 
+	hoursPerDay = 24
+
+	headerContentType = "Content-Type"
+
 	errUnmarshallBody             = "failed to unmarshal the request, body: %s"
 	errUnmarshallParams           = "failed to unmarshal the request, query: %v"
 	errInvalidIDDetail            = "value in path: \"%s\", in payload: \"%s\", err: %s"
 	errPrimaryKeyNotAllowedDetail = "primary key: \"%s\", err: %w"
+	errFieldNotPatchable          = "field can not be patched: %s"
+	errInvalidLimit               = "limit %d is out of range, it must be between %d and %d"
+	errUnsupportedParam           = "query parameter is not supported: %s"
+	errUnsupportedSort            = "sorting is not supported: %s"
+	errInvalidCreatedAfter        = "created_after must be an RFC 3339 timestamp: %s"
+	errInvalidStatus              = "status must be either active or inactive: %v"
+	errWebsiteNotFound            = "website not found in storage"
+	errWebsiteNotTrashed          = "website not found in trash"
+	errWebsiteNotPublished        = "website is not published"
+
+	reasonMaxHostnames = "must have at most %d hostnames"
 )
 
 var (
@@ -41,16 +91,40 @@ func main() {
 	var (
 		awsRegion        = os.Getenv(EnvAwsRegion)
 		tableName        = os.Getenv(EnvTableName)
+		cursorSecret     = os.Getenv(EnvCursorSecret)
+		retentionDays    = os.Getenv(EnvTrashRetentionDays)
+		databaseURL      = os.Getenv(EnvDatabaseURL)
+		retention        time.Duration
 		dynamoDBEndpoint = ""
+		repository       = ""
 	)
 
 	if os.Getenv(EnvAwsSamLocal) == trueString {
 		dynamoDBEndpoint = os.Getenv(EnvAwsDynamoDBLocalEndpoint)
+		repository = os.Getenv(EnvRepository)
 	}
 
 	// UNIX Time is faster and smaller than most timestamps
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
+	if cursorSecret == "" {
+		log.Fatal().
+			Str(EnvTableName, tableName).
+			Msg("cursor secret is required to sign pagination cursors")
+	}
+
+	if retentionDays != "" {
+		days, err := strconv.Atoi(retentionDays)
+		if err != nil || days < 0 {
+			log.Fatal().
+				Err(err).
+				Str(EnvTrashRetentionDays, retentionDays).
+				Msg("trash retention must be a non-negative number of days")
+		}
+
+		retention = time.Duration(days) * hoursPerDay * time.Hour
+	}
+
 	sdkConfig, err := config.LoadDefaultConfig(context.TODO(), func(o *config.LoadOptions) error {
 		o.Region = awsRegion
 
@@ -64,8 +138,15 @@ func main() {
 			Msg("cannot establish connection with dynamodb")
 	}
 
-	repo := dynamo.NewRepo(sdkConfig, tableName, dynamoDBEndpoint)
-	lambda.Start(NewRouter(NewHandler(repo)).Handler)
+	repo, err := table.Open(context.TODO(), sdkConfig, tableName, dynamoDBEndpoint, databaseURL, repository)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str(EnvTableName, tableName).
+			Msg("cannot open the table")
+	}
+
+	lambda.Start(NewRouter(NewHandler(repo, cursor.NewCodec([]byte(cursorSecret)), revision.NewStore(repo, revision.DefaultLimit), retention)).Handler)
 }
 
 type handler interface {
@@ -73,16 +154,31 @@ type handler interface {
 	CreateEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 	RetrieveEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 	UpdateEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	PatchEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 	DeleteEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveTrash(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RestoreEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	PublishEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	UnpublishEntity(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveRevisions(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RetrieveRevision(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	RestoreRevision(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 }
 
 func NewRouter(h handler) *lmdrouter.Router {
 	router := lmdrouter.NewRouter("/websites", lhttp.LoggerMiddleware)
 	router.Route(http.MethodGet, "", h.RetrieveCollection)
 	router.Route(http.MethodPost, "", h.CreateEntity)
-	router.Route(http.MethodGet, "/:id", h.RetrieveEntity)
-	router.Route(http.MethodPut, "/:id", h.UpdateEntity)
-	router.Route(http.MethodDelete, "/:id", h.DeleteEntity)
+	router.Route(http.MethodGet, "/:website", lhttp.Fork("website", map[string]lmdrouter.Handler{"trash": h.RetrieveTrash}, h.RetrieveEntity))
+	router.Route(http.MethodPut, "/:website", h.UpdateEntity)
+	router.Route(http.MethodPatch, "/:website", h.PatchEntity)
+	router.Route(http.MethodDelete, "/:website", h.DeleteEntity)
+	router.Route(http.MethodPost, "/:website/restore", h.RestoreEntity)
+	router.Route(http.MethodPost, "/:website/publish", h.PublishEntity)
+	router.Route(http.MethodPost, "/:website/unpublish", h.UnpublishEntity)
+	router.Route(http.MethodGet, "/:website/revisions", h.RetrieveRevisions)
+	router.Route(http.MethodGet, "/:website/revisions/:rev", h.RetrieveRevision)
+	router.Route(http.MethodPost, "/:website/revisions/:rev/restore", h.RestoreRevision)
 
 	return router
 }